@@ -1,12 +1,16 @@
 package cors
 
 import (
+	"cmp"
 	"errors"
 	"maps"
+	"mime"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jub0bs/cors/internal/headers"
 	"github.com/jub0bs/cors/internal/methods"
@@ -121,6 +125,19 @@ import (
 //	https://bar.foo.example.com
 //	https://baz.bar.foo.example.com
 //
+// Note that such a pattern does not encompass the apex domain
+// (https://example.com, in this case).
+// In contrast, a leading double asterisk followed by a period
+// in a host pattern denotes the apex domain as well as its subdomains.
+// For instance, the pattern
+//
+//	https://**.example.com
+//
+// is equivalent to the following pair of patterns:
+//
+//	https://example.com
+//	https://*.example.com
+//
 // An asterisk in place of a port denotes an arbitrary (possibly implicit)
 // port. For instance,
 //
@@ -139,8 +156,13 @@ import (
 //	https://*.example.com:9090 // permitted
 //	https://example.com:*      // permitted
 //	https://*.example.com:*    // prohibited
+//	https://**.example.com:*   // prohibited
 //
 // No other forms of origin patterns are supported.
+// In particular, origin patterns cannot express IP ranges:
+//
+//	http://10.0.0.0/8 // prohibited
+//	http://10.0.0.0   // permitted, but encompasses only one origin
 //
 // Origin patterns whose scheme is http and whose host is neither localhost
 // nor a [loopback IP address] are deemed insecure;
@@ -202,7 +224,9 @@ import (
 // The three so-called "[CORS-safelisted methods]" ([GET], [HEAD], and [POST])
 // are by default allowed by the CORS protocol.
 // As such, allowing them explicitly in your CORS configuration is
-// permitted but never actually necessary.
+// permitted but never actually necessary;
+// because listing only those methods likely betrays a mistake,
+// it elicits a warning (see [*Middleware.Warnings]).
 //
 // Moreover, the CORS protocol forbids the use of some method names.
 // Accordingly, specifying [forbidden method names] is prohibited.
@@ -250,6 +274,15 @@ import (
 //	RequestHeaders: []string{"*", "Foo"},                  // prohibited
 //	RequestHeaders: []string{"*", "Authorization", "Foo"}, // prohibited
 //
+// Some request headers (Accept, Accept-Language, Content-Language,
+// Content-Type, and Range) are "[CORS-safelisted request headers]"
+// for some of their values; browsers list them in the
+// Access-Control-Request-Headers header of preflight requests only
+// when their values are not safelisted.
+// Allowing them is permitted, but, because users who do so may
+// overestimate the effect of such a setting,
+// it elicits a warning (see [*Middleware.Warnings]).
+//
 // The CORS protocol defines a number of so-called
 // "[forbidden request-header names]";
 // browsers prevent clients from including such headers in their requests.
@@ -281,6 +314,9 @@ import (
 // (the highest cap currently is Firefox's: 86,400 seconds),
 // this field is subject to an upper bound:
 // specifying a value larger than 86400 is prohibited.
+// Because the lowest cap currently is Safari's (600 seconds),
+// specifying a value larger than 600 results in a warning
+// (see [*Middleware.Warnings]) that browsers may clamp it.
 //
 // # ResponseHeaders
 //
@@ -298,7 +334,11 @@ import (
 // However, for technical reasons, this is only permitted if the Credentialed
 // field is unset.
 //
-// Specifying response-header names in addition to the asterisk is prohibited:
+// Because, contrary to the asterisk in Access-Control-Allow-Headers
+// (which doesn't cover Authorization), the asterisk in
+// Access-Control-Expose-Headers covers all response-header names,
+// specifying response-header names in addition to the asterisk
+// would be redundant and is therefore prohibited:
 //
 //	ResponseHeaders: []string{"*", "X-Response-Time"}, // prohibited
 //
@@ -309,7 +349,7 @@ import (
 // "[forbidden response-header names]",
 // which cannot be exposed to clients.
 // Accordingly, specifying one or more safelisted or forbidden response-header
-// name(s) is prohibited.
+// name(s) is prohibited (but see ExtraConfig.KeepSafelistedExposedHeaders).
 //
 // Finally, some header names that have no place in a response are prohibited:
 //
@@ -322,6 +362,7 @@ import (
 // [Authorization]: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Authorization
 // [Bearer tokens]: https://developer.mozilla.org/en-US/docs/Web/HTTP/Authentication#bearer
 // [CORS-safelisted methods]: https://fetch.spec.whatwg.org/#cors-safelisted-method
+// [CORS-safelisted request headers]: https://fetch.spec.whatwg.org/#cors-safelisted-request-header
 // [CORS-safelisted response-header names]: https://fetch.spec.whatwg.org/#cors-safelisted-response-header-name
 // [GET]: https://developer.mozilla.org/en-US/docs/Web/HTTP/Methods/GET
 // [HEAD]: https://developer.mozilla.org/en-US/docs/Web/HTTP/Methods/HEAD
@@ -373,6 +414,72 @@ type Config struct {
 // when some of your clients choke on preflight responses that are meant
 // to be successful but have a 2xx status code other than 200.
 //
+// # PreflightFailureStatus
+//
+// PreflightFailureStatus configures a CORS middleware to use the specified
+// status code in failed preflight responses.
+// The default status code, which is used if this field has the zero value,
+// is [403].
+//
+// Specifying a non-zero status code outside the [4xx range] is prohibited.
+//
+// Setting a custom preflight-failure status is useful
+// when some gateway in front of your server routes responses
+// or raises alerts on the basis of their status code.
+// Note that, when debug mode is on, preflight responses that fail
+// because of some step other than the origin check
+// still use the preflight-success status.
+//
+// # PreflightStatusByMethod
+//
+// PreflightStatusByMethod configures a CORS middleware to use,
+// in successful responses to preflight requests for some method
+// (as named by the request's Access-Control-Request-Method header),
+// the status code associated to that method in the map, e.g.
+//
+//	PreflightStatusByMethod: map[string]int{
+//	  http.MethodPut:    http.StatusOK,
+//	  http.MethodDelete: http.StatusOK,
+//	},
+//
+// Successful preflight responses for methods absent from the map use
+// the status configured by PreflightSuccessStatus (or its default).
+// Keys are method names, which are case-sensitive;
+// the same rules apply to them as to the elements of
+// the Config.Methods field, except that the wildcard is prohibited.
+// Specifying a status code outside the [2xx range] is prohibited.
+// Failed preflight responses are unaffected, even in debug mode.
+//
+// # RejectionMarkerHeader
+//
+// RejectionMarkerHeader configures a CORS middleware to include,
+// in its responses to the CORS-preflight requests that it rejects
+// (even those it responds to with an ok status in debug mode),
+// a marker header whose value names the stage of preflight that failed:
+//
+//	X-CORS-Rejected: origin
+//
+// The possible values are fetch-metadata (see ValidateSecFetchMetadata),
+// origin, private-network-access, method, and headers.
+// They reveal nothing about the configuration
+// beyond the outcome of the CORS check,
+// but they let other components (e.g. edge logging) classify
+// rejected preflight requests without inspecting their status.
+// In report-only mode, no preflight requests are rejected and
+// this setting therefore has no effect.
+//
+// # RejectionMarkerHeaderName
+//
+// RejectionMarkerHeaderName specifies the name of the marker header
+// described in the documentation of RejectionMarkerHeader.
+// The default name, which is used if this field has the zero value,
+// is X-CORS-Rejected.
+//
+// Specifying an invalid header name or the name of
+// some Access-Control-* header is prohibited,
+// as is specifying RejectionMarkerHeaderName without also setting
+// RejectionMarkerHeader.
+//
 // # PrivateNetworkAccess
 //
 // PrivateNetworkAccess configures a CORS middleware to enable
@@ -405,6 +512,1069 @@ type Config struct {
 // At most one of PrivateNetworkAccess and PrivateNetworkAccessInNoCORSModeOnly
 // can be set.
 //
+// # RequestMethodHeaderFallback
+//
+// RequestMethodHeaderFallback configures a CORS middleware to read
+// the requested method from the specified request header
+// whenever an OPTIONS request carries an Origin header
+// but no Access-Control-Request-Method header:
+//
+//	RequestMethodHeaderFallback: "X-Requested-Method",
+//
+// Such requests are then treated as [CORS-preflight requests].
+// Header names are case-insensitive.
+// Because whether a response is a preflight response then depends on
+// the specified header, the middleware lists its name in the Vary header
+// alongside the names it normally lists there for OPTIONS requests.
+// Specifying an invalid header name, Origin, or the name of
+// some Access-Control-* header is prohibited.
+//
+// This setting is merely an interoperability shim for some non-compliant
+// clients; it has no bearing on the behavior of browsers,
+// which invariably name the requested method
+// in the Access-Control-Request-Method header.
+// The default value (the empty string) disables this fallback.
+//
+// # LenientACRHTokenWhitespace
+//
+// Browsers list the names of the request headers that they intend to send
+// in the Access-Control-Request-Headers header of CORS-preflight requests
+// without any whitespace (e.g. authorization,x-foo);
+// by default, a CORS middleware deems request-header names that contain
+// whitespace disallowed.
+// LenientACRHTokenWhitespace configures a CORS middleware to strip
+// all spaces and horizontal tabs from the Access-Control-Request-Headers
+// header before checking the requested names against the allowed ones;
+// if allowed, the stripped names are then listed in
+// the Access-Control-Allow-Headers header of the preflight response.
+//
+// This setting is merely a temporary interoperability workaround for some
+// non-compliant intermediaries that insert whitespace within
+// request-header names; it is non-compliant with the Fetch standard.
+// Moreover, it incurs a heap allocation per preflight request
+// whose Access-Control-Request-Headers header contains whitespace.
+// It has no effect when all request headers are allowed.
+//
+// # SortAllowHeaders
+//
+// By default, when a CORS-preflight request succeeds and specific
+// request-header names (rather than the wildcard) are allowed,
+// a CORS middleware lists in the Access-Control-Allow-Headers header
+// of the preflight response the very names that the request's
+// Access-Control-Request-Headers header lists, in the same order;
+// moreover, the middleware deems disallowed any
+// Access-Control-Request-Headers header whose names are unsorted or
+// duplicated, something that Fetch-compliant browsers never send.
+// SortAllowHeaders configures a CORS middleware to instead accept
+// the requested names in any order (and regardless of duplicates),
+// as long as they're all allowed,
+// and to list them in lexicographical order and without duplicates
+// in the Access-Control-Allow-Headers header,
+// so that the latter is stable regardless of the client.
+//
+// Computing that list has a cost: it incurs a few heap allocations
+// per preflight request that lists request-header names, from which
+// the default behavior is free.
+// Therefore, you should only set SortAllowHeaders if some tool
+// (e.g. one that diffs responses) expects a stable
+// Access-Control-Allow-Headers header.
+// SortAllowHeaders has no effect when all request headers are allowed
+// or in debug mode.
+//
+// # LegacyAllowedRequestHeaders
+//
+// LegacyAllowedRequestHeaders configures a CORS middleware to allow
+// the specified request-header names, exactly as if they were listed
+// in the Config.RequestHeaders field.
+// However, those names are tracked separately from the ones listed in
+// the Config.RequestHeaders field (see [*Middleware.Config]),
+// and [*Middleware.Warnings] reports one warning per such name;
+// this setting is meant for migrating away from a permissive configuration,
+// in which case you should strive to eventually drop those names.
+// Names that are also listed in the Config.RequestHeaders field
+// are not deemed legacy.
+//
+// The rules that apply to the elements of the Config.RequestHeaders field
+// also apply to the elements of this field.
+// Moreover, specifying legacy request-header names while
+// allowing all request-header names is prohibited.
+//
+// # OriginMethods
+//
+// OriginMethods configures a CORS middleware to allow,
+// for requests from the specified origins,
+// the associated HTTP methods rather than those specified
+// in the Config.Methods field:
+//
+//	Methods: []string{http.MethodGet},
+//	OriginMethods: map[string][]string{
+//	  "https://app.example.com": {http.MethodPut, http.MethodDelete},
+//	},
+//
+// Requests from origins absent from this map are subject to
+// the Config.Methods field.
+// Each list of methods obeys the same rules as the Config.Methods field.
+// Each key must be a discrete origin (i.e. an origin pattern that contains
+// no asterisk) that the Config.Origins field allows;
+// other keys are prohibited.
+//
+// # CredentialsHeuristic
+//
+// CredentialsHeuristic configures a CORS middleware to include the
+// Access-Control-Allow-Credentials header in responses to actual
+// (i.e. non-preflight) requests only if those requests carry
+// a Cookie header or an Authorization header.
+// Because preflight requests never carry credentials,
+// this setting has no bearing on responses to preflight requests.
+//
+// By default, a CORS middleware that allows credentialed access
+// systematically includes that header in responses to allowed actual
+// requests, simply because a request's [credentials mode] is not observable
+// on the server. In contrast, this setting relies on a mere heuristic,
+// which may be wrong for some requests (e.g. requests that carry
+// TLS client certificates); use it only if you accept that caveat.
+// Because responses to actual requests then depend on the presence of
+// those request headers, the middleware lists Cookie and Authorization
+// (in addition to Origin) in the Vary header of such responses,
+// unless ExtraConfig.VaryStrategy says otherwise.
+//
+// Setting CredentialsHeuristic without also setting
+// the Config.Credentialed field is prohibited.
+//
+// # CredentialedSchemes
+//
+// CredentialedSchemes restricts credentialed access to allowed origins
+// whose scheme is one of the specified schemes (http and/or https);
+// a CORS middleware then includes the Access-Control-Allow-Credentials
+// header in its responses only to requests from such origins,
+// and it grants mere anonymous access to the other allowed origins:
+//
+//	Origins:             []string{"https://example.com", "http://localhost:3000"},
+//	Credentialed:        true,
+//	CredentialedSchemes: []string{"https"}, // http://localhost:3000 gets anonymous access only
+//
+// This field has the zero value by default,
+// which grants credentialed access to all allowed origins
+// (regardless of their scheme) if the Config.Credentialed field is set.
+// Note that CredentialedSchemes does not relax the restrictions
+// that apply to insecure origins when credentialed access is enabled;
+// see the documentation of DangerouslyTolerateInsecureOrigins.
+//
+// Specifying schemes other than http and https is prohibited,
+// as is setting CredentialedSchemes without also setting
+// the Config.Credentialed field.
+//
+// # CredentialedPathPredicate
+//
+// CredentialedPathPredicate, if non-nil, restricts credentialed access
+// to the requests for which it returns true;
+// a CORS middleware then includes the Access-Control-Allow-Credentials
+// header only in its responses to such requests,
+// and it grants mere anonymous access to the other requests
+// from allowed origins.
+// This setting is useful when a single middleware wraps an API
+// of which only some parts require credentials:
+//
+//	CredentialedPathPredicate: func(r *http.Request) bool {
+//	  return strings.HasPrefix(r.URL.Path, "/session/")
+//	},
+//
+// The predicate gets invoked on both CORS-preflight requests and
+// actual (i.e. non-preflight) requests; since CORS-preflight
+// requests never carry credentials, the predicate should base its decision
+// on request attributes that preflight and actual requests share
+// (e.g. the request's path) rather than on the presence of credentials.
+// CredentialedPathPredicate can only restrict credentialed access,
+// never extend it: setting CredentialedPathPredicate without
+// also setting the Config.Credentialed field is prohibited.
+// Moreover, this setting does not relax the restrictions that apply
+// to origin patterns when credentialed access is enabled.
+// The predicate must not modify the request and
+// should be safe for concurrent use by multiple goroutines.
+//
+// # CredentialedWildcardMethods
+//
+// Because the Fetch standard doesn't let the wildcard (*) in
+// the Access-Control-Allow-Methods header cover any methods in responses to
+// credentialed requests, a CORS middleware that allows all methods and
+// credentialed access by default echoes the requested method in that header.
+// CredentialedWildcardMethods configures such a middleware to instead
+// systematically list the specified methods in that header:
+//
+//	Methods:                     []string{"*"},
+//	Credentialed:                true,
+//	CredentialedWildcardMethods: []string{"DELETE", "PATCH", "PUT"},
+//
+// Browsers then reject preflight for methods absent from that list
+// (other than [CORS-safelisted methods]).
+// This setting also applies to the discrete origins for which
+// the OriginMethods field allows all methods.
+// Each method obeys the same rules as elements of the Config.Methods field;
+// the wildcard is prohibited in this list.
+//
+// Setting CredentialedWildcardMethods without also setting
+// the Config.Credentialed field and allowing all methods is prohibited.
+//
+// # AdditionalSafelistedMethods
+//
+// Because browsers do not require the [CORS-safelisted methods]
+// (GET, HEAD, and POST) to be explicitly allowed,
+// a CORS middleware never lists them in the Access-Control-Allow-Methods
+// header of preflight responses.
+// AdditionalSafelistedMethods configures a CORS middleware to grant
+// the same free pass to the specified methods (e.g. QUERY):
+// preflight requests for those methods then succeed
+// (as far as the method is concerned) regardless of
+// the Config.Methods field, but without the middleware listing
+// those methods in the Access-Control-Allow-Methods header.
+//
+// Be aware that this setting only affects the responses of
+// the middleware; it doesn't alter the behavior of browsers,
+// whose CORS-safelisted methods are set in stone by the Fetch standard.
+// Therefore, this setting is only useful for aligning the middleware's
+// responses with the expectations of some custom (non-browser) client.
+// Each method obeys the same rules as elements of the Config.Methods field;
+// the wildcard is prohibited in this list,
+// and the CORS-safelisted methods are silently ignored.
+//
+// # TreatHeadAsNonSafe
+//
+// TreatHeadAsNonSafe configures a CORS middleware to stop granting
+// HEAD the free pass that it grants to the other [CORS-safelisted methods]:
+// preflight requests for HEAD then only succeed if HEAD is allowed
+// by the Config.Methods field (or by the ExtraConfig.OriginMethods field),
+// and the middleware lists HEAD in the Access-Control-Allow-Methods header
+// of the corresponding preflight responses.
+// This setting is useful for backends whose logic is method-specific
+// and that need HEAD to be listed explicitly.
+//
+// Be aware that this setting only affects the responses of
+// the middleware; it doesn't alter the behavior of browsers,
+// which, as far as the method is concerned, allow HEAD requests regardless of
+// the Access-Control-Allow-Methods header.
+//
+// # AlwaysEmitMaxAge
+//
+// AlwaysEmitMaxAge configures a CORS middleware to explicitly include
+// an Access-Control-Max-Age header whose value is the [default max-age value]
+// (five seconds) in successful preflight responses
+// when the Config.MaxAgeInSeconds field has the zero value.
+// Browsers behave no differently, but the value is then visible to
+// intermediaries that interpret it.
+// This setting has no effect on other values of the Config.MaxAgeInSeconds
+// field; in particular, -1 still results in a value of 0.
+//
+// # ResponseHeaderHook
+//
+// ResponseHeaderHook, if non-nil, gets invoked with the response headers
+// once a CORS middleware has set the CORS headers of a response
+// to a CORS request; in the case of preflight requests,
+// the hook gets invoked just before the middleware writes the response's
+// status code; in the case of actual (i.e. non-preflight) requests,
+// it gets invoked just before the middleware delegates to
+// the handler it wraps.
+// The hook enables you to make last-mile adjustments
+// to the headers that the middleware set.
+//
+// Use at your own risk!
+// The hook can overwrite or remove any header set by the middleware,
+// and misuse of it can easily break CORS.
+// Moreover, the hook should be safe for concurrent use by multiple goroutines
+// and should not retain a reference to the headers it's passed.
+//
+// # LowercaseResponseHeaderNames
+//
+// LowercaseResponseHeaderNames configures a CORS middleware to write
+// the names of the CORS response headers it sets
+// (i.e. the Access-Control-* headers and, in debug mode,
+// X-Debug-Rejected-Header, X-Debug-Forbidden-Method, and X-Debug-PNA)
+// in lowercase (e.g. access-control-allow-origin)
+// rather than in the canonical form that [http.Header] otherwise uses.
+// HTTP header names are case-insensitive and HTTP/2 transmits them in
+// lowercase anyway, but some clients and test harnesses
+// compare them case-sensitively and expect lowercase.
+// Accommodating such clients is the only legitimate reason for
+// setting LowercaseResponseHeaderNames, and you should rarely need to.
+//
+// The middleware lowercases those names after invoking
+// the ResponseHeaderHook (if any); in the case of actual requests,
+// it does so before delegating to the handler it wraps,
+// which therefore observes lowercase keys in the response headers.
+// Note that the names of the Vary header, to which other handlers
+// commonly contribute, and of the Deprecation and Sunset headers
+// are left untouched.
+// Because the OverrideHandlerCORSHeaders and ReflectExposedResponseHeaders
+// settings operate on the canonical names of CORS response headers,
+// they are incompatible with LowercaseResponseHeaderNames.
+//
+// # UseCanonicalHeaderWrites
+//
+// For efficiency, a CORS middleware by default populates the response
+// headers it manages by assigning them directly to the [http.Header] map,
+// often with values that it shares across responses
+// (e.g. a precomputed Access-Control-Allow-Methods value) or with
+// the request (e.g. when it echoes the request's Origin header in
+// the Access-Control-Allow-Origin header).
+// Header-rewriting layers (e.g. the ResponseHeaderHook, the handler that
+// the middleware wraps, or some middleware that wraps it) that modify
+// those values in place rather than replace them could therefore
+// inadvertently alter the request's headers or, worse, the values that
+// the middleware emits in subsequent responses.
+// UseCanonicalHeaderWrites configures a CORS middleware to instead
+// give each of those headers (the Access-Control-* headers, as well as
+// Allow, Deprecation, Link, Sunset, Vary, and the debug-only headers)
+// a value of its own, as if the middleware had written them with
+// [http.Header.Set] and [http.Header.Add].
+// The cost is a few additional heap allocations per response that
+// carries such headers; the default remains the fast path.
+//
+// # VaryStrategy
+//
+// VaryStrategy controls which header names a CORS middleware lists in
+// the Vary header of responses to actual (i.e. non-preflight) requests
+// and to non-CORS requests; see the [VaryStrategy] type.
+// The Vary header of responses to preflight requests is unaffected.
+// The zero value, VaryDefault, corresponds to the middleware's usual
+// behavior, which is the only one guaranteed to keep Web caches safe
+// from poisoning.
+// Other strategies are meant for deployments in which caching
+// intermediaries in front of the middleware compute their cache keys
+// by other means; misusing them may cause Web caches to serve
+// a response intended for one origin to another.
+//
+// # OmitVaryForDisallowedOrigins
+//
+// OmitVaryForDisallowedOrigins configures a CORS middleware to refrain from
+// listing Origin in the Vary header of responses to actual
+// (i.e. non-preflight) requests whose origin is not allowed;
+// responses to actual requests whose origin is allowed,
+// as well as responses to preflight requests and to non-CORS requests,
+// are unaffected.
+// This setting is meant for deployments that sit behind a cache keyed
+// on a single allowed origin and that want responses to requests from
+// disallowed origins not to perturb that cache key.
+//
+// Be aware that the resulting responses no longer tell Web caches
+// that they depend on the request's origin.
+// As a result, if you later reconfigure the middleware to allow
+// some origin that it previously disallowed,
+// Web caches may keep serving, to that origin, stale responses
+// that lack the necessary CORS headers.
+// This setting has no effect in report-only mode (see ReportOnly),
+// in which responses carry an Access-Control-Allow-Origin header
+// regardless of the request's origin.
+//
+// # NormalizeIPv4Shorthand
+//
+// NormalizeIPv4Shorthand configures a CORS middleware to normalize
+// origins whose host is an IPv4 address in shorthand form
+// (e.g. http://127.1) to dotted-quad form (e.g. http://127.0.0.1)
+// before matching them against the allowed origins.
+// Browsers never send such origins, but some non-browser clients do.
+// Note that the Access-Control-Allow-Origin header of responses
+// to requests from such origins echoes the origin as sent by the client.
+//
+// # DecodePercentEncodedOrigin
+//
+// DecodePercentEncodedOrigin configures a CORS middleware to percent-decode
+// the host of origins (e.g. https://ex%61mple.com)
+// before matching them against the allowed origins
+// (e.g. https://example.com).
+// Browsers never send such origins, but some non-browser clients do;
+// this setting is a mere interoperability shim for such clients.
+// Origins whose host would, once decoded, contain characters illegal in
+// a domain (e.g. https://example.com%2Fevil.com) are left as is
+// and therefore fail to match.
+// Decoding happens before the normalization that
+// NormalizeIPv4Shorthand calls for, if any.
+// Note that the Access-Control-Allow-Origin header of responses
+// to requests from such origins echoes the origin as sent by the client.
+//
+// # TreatEmptyOriginAsAbsent
+//
+// By default, a CORS middleware treats a request that contains
+// an Origin header whose value is empty as a CORS request
+// from a disallowed origin.
+// TreatEmptyOriginAsAbsent instead configures a CORS middleware
+// to treat such a request as a non-CORS request,
+// i.e. as if it contained no Origin header at all.
+// Some intermediaries are known to strip the value of the Origin header
+// while leaving the (empty) header in place.
+//
+// # OriginHeaderNames
+//
+// OriginHeaderNames configures a CORS middleware to read the request's
+// origin from the first of the specified request headers
+// that the request carries, rather than from the Origin header:
+//
+//	OriginHeaderNames: []string{"X-Forwarded-Origin", "X-Original-Origin"},
+//
+// The middleware ignores the request headers not listed in this field;
+// in particular, it only reads the Origin header if you list it.
+// The default list, which is used if this field is empty,
+// consists of Origin alone.
+// Header names are case-insensitive, and duplicates are ignored.
+// This setting is meant for controlled environments in which some proxies
+// (e.g. in a service mesh) relocate the original Origin header
+// before rewriting the latter.
+//
+// Use with caution!
+// A CORS middleware cannot tell whether a request header was set by
+// a trusted hop; you must therefore guarantee that the proxies in front of
+// your server reliably set (or strip) the specified headers,
+// lest clients be able to claim any origin they like.
+// Because responses then depend on the specified headers,
+// the middleware lists their names, instead of Origin,
+// in the Vary header of its responses.
+//
+// Specifying an invalid header name or the name of
+// some Access-Control-* header is prohibited.
+//
+// # PreserveOriginOrder
+//
+// By default, [*Middleware.Config] reports the allowed origin patterns
+// in lexicographical order, regardless of the order in which they were
+// specified in the Config.Origins field.
+// PreserveOriginOrder configures a CORS middleware to retain, for
+// presentation purposes (e.g. in a configuration portal),
+// the order in which its origin patterns were specified
+// (duplicates excepted); that order is then available via
+// [*Middleware.OriginsInConfigOrder] and in the Origins field of
+// the result of [*Middleware.Config],
+// which therefore round-trips through [*Middleware.Reconfigure].
+// This setting has no bearing on how the middleware matches origins.
+//
+// Setting PreserveOriginOrder in a configuration passed to
+// [NewMiddlewareFromMatcher] is prohibited, since an [OriginMatcher]
+// retains no order.
+//
+// # ValidateSecFetchMetadata
+//
+// ValidateSecFetchMetadata configures a CORS middleware to reject,
+// for defense in depth, CORS requests whose [Fetch-metadata request headers]
+// are inconsistent with them being CORS requests from their origin.
+// The check is deliberately conservative: a CORS middleware rejects
+//
+//   - CORS-preflight requests whose Sec-Fetch-Mode header is not cors,
+//     since browsers send CORS-preflight requests in cors mode only;
+//   - CORS-preflight requests whose Sec-Fetch-Site header is same-origin
+//     or none, since browsers never send CORS-preflight requests
+//     for same-origin requests;
+//   - actual (i.e. non-preflight) requests whose Sec-Fetch-Site header is
+//     same-origin but whose origin's host and port differ from
+//     those of the request's Host header.
+//
+// A rejected CORS-preflight request fails as if its origin were not allowed
+// (see RejectionMarkerHeader for the corresponding stage, fetch-metadata);
+// the response to a rejected actual request lacks CORS headers.
+// In report-only mode (see ReportOnly), such requests are reported
+// with reason [ReasonFetchMetadata] instead.
+//
+// Be aware that Fetch-metadata request headers are set by browsers;
+// non-browser clients and older browsers may omit them.
+// Therefore, the absence of those headers is tolerated,
+// as is an origin of null.
+// Moreover, because the last check relies on the request's Host header,
+// you should not set ValidateSecFetchMetadata if some intermediary
+// (e.g. a reverse proxy) in front of the middleware rewrites that header.
+//
+// # ReportOnly
+//
+// ReportOnly, in the spirit of [CSP's report-only mode], enables you to
+// deploy a CORS policy without enforcing it.
+// In report-only mode, a CORS middleware responds to requests that its
+// configuration allows as usual; however, rather than rejecting requests that
+// its configuration disallows, the middleware reports them via
+// the ReportOnlyHook field (if non-nil) and lets them through
+// by reflecting the request's origin, method, and headers
+// in the relevant CORS response headers.
+// Report-only mode is useful for validating a new (tighter) policy
+// against production traffic before actually enforcing it.
+//
+// Use with caution!
+// While report-only mode is active, a CORS middleware effectively
+// allows all origins, with credentialed access if
+// the Config.Credentialed field is set; therefore,
+// you should only ever activate it temporarily.
+//
+// ReportOnlyHook, if non-nil, gets invoked with the request's origin and
+// the [Reason] why the request would have been rejected
+// if report-only mode had not been active;
+// that reason reflects the very check that the request failed.
+// The hook should be safe for concurrent use by multiple goroutines.
+// Setting ReportOnlyHook without also setting ReportOnly is prohibited.
+//
+// # DeprecatedOrigins
+//
+// DeprecatedOrigins configures a CORS middleware to annotate responses
+// to actual (i.e. non-preflight) requests from the specified origins
+// with a [Deprecation] header (of value "true") and a [Sunset] header
+// whose value is the associated time:
+//
+//	DeprecatedOrigins: map[string]time.Time{
+//	  "https://partner.example.com": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+//	},
+//
+// Those headers merely notify clients that their origin is scheduled for
+// removal from the allowed origins; they don't affect
+// the outcome of the CORS check.
+// Each key must be a discrete origin (i.e. an origin pattern that contains
+// no asterisk) that the Config.Origins field allows;
+// other keys are prohibited, as are zero times.
+//
+// # PolicyLink
+//
+// PolicyLink, if non-empty, configures a CORS middleware to advertise
+// a machine-readable document that describes your CORS policy:
+// responses to allowed actual (i.e. non-preflight) requests get annotated
+// with a [Link] header whose value references that URL
+// with link relation type "cors-policy":
+//
+//	PolicyLink: "https://example.com/.well-known/cors-policy",
+//
+// results in
+//
+//	Link: <https://example.com/.well-known/cors-policy>; rel="cors-policy"
+//
+// The middleware adds that header without overwriting any Link header
+// already present in the response. Like the Deprecation and Sunset headers
+// (see DeprecatedOrigins), the Link header doesn't affect
+// the outcome of the CORS check; it merely aids discoverability.
+// PolicyLink must be an absolute URL; other values are prohibited.
+//
+// # MaxOriginLength
+//
+// MaxOriginLength configures a CORS middleware to deem disallowed,
+// without even attempting to parse them, the values of the Origin header
+// that are longer than the specified number of bytes.
+// The default maximum length, which is used if this field has the zero value,
+// is the length of the longest origin that the middleware could possibly
+// allow (267 bytes).
+// Specifying a tighter maximum length enables a middleware
+// to fail faster on adversarially long Origin headers.
+//
+// Specifying a negative value or a value larger than the default maximum
+// length is prohibited.
+//
+// # MaxDebugACAHBytes
+//
+// In debug mode, the response to a CORS-preflight request may contain data
+// derived from the request's Access-Control-Request-Headers header
+// (e.g. in the non-standard X-Debug-Rejected-Header response header);
+// left unchecked, adversaries could abuse debug mode to amplify
+// preflight responses by sending preflight requests that contain
+// a maliciously long Access-Control-Request-Headers header.
+// MaxDebugACAHBytes configures a CORS middleware to, in debug mode,
+// fail (without any CORS response headers and with the status configured
+// by PreflightFailureStatus) preflight requests whose
+// Access-Control-Request-Headers header is longer than
+// the specified number of bytes.
+// The default maximum length, which is used if this field has
+// the zero value, is generous (8192 bytes).
+// This setting has no effect when debug mode is off.
+//
+// Specifying a negative value is prohibited.
+//
+// # DebugOmitCredentialsHeader
+//
+// In debug mode, the response to a CORS-preflight request that fails
+// after its origin has been allowed contains, for easier troubleshooting
+// on the client side, not only the Access-Control-Allow-Origin header
+// but also, if credentialed access is enabled,
+// the Access-Control-Allow-Credentials header.
+// DebugOmitCredentialsHeader configures a CORS middleware to omit
+// the latter from such responses, so that debug mode reveals whether
+// the request's origin is allowed but not whether credentialed access
+// is enabled.
+// This setting has no effect when debug mode is off;
+// in particular, it has no bearing on responses to successful preflight
+// requests or to actual requests.
+//
+// Setting DebugOmitCredentialsHeader without also setting
+// the Config.Credentialed field is prohibited.
+//
+// # MaxProcessedHeaderBytes
+//
+// MaxProcessedHeaderBytes configures a CORS middleware to reject,
+// with status 431 (Request Header Fields Too Large) and
+// without any CORS response headers, CORS-preflight requests in which
+// the combined size of the values of the Origin,
+// Access-Control-Request-Method, and Access-Control-Request-Headers headers
+// exceeds the specified number of bytes.
+// Such requests are rejected before any of those headers gets examined;
+// in particular, the middleware then spares itself
+// the cost of parsing Access-Control-Request-Headers.
+// This overall budget complements per-header limits
+// (e.g. MaxOriginLength) and, because it guards the middleware's resources
+// rather than expresses the CORS policy, it is enforced even
+// in report-only mode and in debug mode.
+// By default (i.e. if this field has the zero value), no such budget applies.
+//
+// Specifying a negative value is prohibited.
+//
+// # InjectDecision
+//
+// InjectDecision configures a CORS middleware to store, in the context of
+// each actual (i.e. non-preflight) CORS request it lets through,
+// a [Decision] describing the outcome of the middleware's CORS check,
+// including which of the configured origin patterns (if any) matched
+// the request's origin.
+// Wrapped handlers can retrieve that Decision via [DecisionFromContext],
+// e.g. in order to apply per-partner logic:
+//
+//	d, ok := cors.DecisionFromContext(r.Context())
+//	if ok && d.MatchedPattern == "https://*.partner.example.com" {
+//	  // ...
+//	}
+//
+// Because injecting a Decision in the request's context incurs
+// heap allocations, this option is disabled by default.
+//
+// # WildcardCoversAuthorization
+//
+// WildcardCoversAuthorization configures a CORS middleware to interpret
+// a single asterisk in the Config.RequestHeaders field as denoting
+// all request-header names, including Authorization,
+// even when credentialed access is disabled; in other words,
+// it makes the following two configurations equivalent:
+//
+//	RequestHeaders: []string{"*"},
+//	RequestHeaders: []string{"*", "Authorization"},
+//
+// Use with caution!
+// This option diverges from the semantics of the wildcard
+// specified by [the Fetch standard], according to which the wildcard
+// does not cover request-header name Authorization;
+// it exists solely for controlled scenarios involving non-browser clients
+// that (wrongly) rely on the wildcard covering Authorization.
+// You should instead, whenever possible,
+// explicitly allow request-header name Authorization.
+// Setting WildcardCoversAuthorization without also specifying
+// the asterisk in the Config.RequestHeaders field is prohibited.
+//
+// # BoundedAuthorizationScan
+//
+// When all request-header names as well as Authorization are allowed
+// and credentialed access is disabled,
+// a CORS middleware by default systematically lists Authorization
+// alongside the wildcard (i.e. "*,authorization")
+// in the Access-Control-Allow-Headers header of successful preflight
+// responses, because scanning the Access-Control-Request-Headers header
+// in search of "authorization" would be costly if that header were long;
+// the downside is that the middleware then reveals that it allows
+// Authorization even to clients that don't request it.
+// BoundedAuthorizationScan, if positive, configures a CORS middleware
+// to scan Access-Control-Request-Headers headers no longer than
+// BoundedAuthorizationScan bytes and to list Authorization
+// alongside the wildcard only if they contain "authorization";
+// longer Access-Control-Request-Headers headers are not scanned and
+// elicit the default behavior.
+// The cost of the scan is linear in the length of the header and
+// therefore capped by BoundedAuthorizationScan.
+// A negative value is prohibited, as is a positive value
+// in configurations other than the one described above.
+//
+// # SlowPreflightThreshold and OnSlowPreflight
+//
+// SlowPreflightThreshold and OnSlowPreflight enable you to detect
+// preflight requests whose processing takes suspiciously long;
+// such requests may be part of a preflight flood abusing
+// the processing of the Access-Control-Request-Headers header,
+// which is the costliest part of preflight.
+// A CORS middleware times that processing and, if it lasts longer than
+// SlowPreflightThreshold, invokes OnSlowPreflight with the elapsed time
+// and the preflight request:
+//
+//	SlowPreflightThreshold: time.Millisecond,
+//	OnSlowPreflight: func(d time.Duration, r *http.Request) {
+//	  slog.Warn("slow preflight", "duration", d, "remote", r.RemoteAddr)
+//	},
+//
+// The hook is invoked synchronously, before the middleware writes
+// the preflight response; it must neither retain nor modify the request
+// and should be safe for concurrent use by multiple goroutines.
+// Timing only occurs if OnSlowPreflight is non-nil.
+//
+// Specifying a negative threshold is prohibited,
+// as is specifying OnSlowPreflight without also specifying
+// a positive SlowPreflightThreshold.
+//
+// # ObserveLatency
+//
+// ObserveLatency, if non-nil, gets invoked with the time spent
+// inside a CORS middleware for every CORS request, which enables you
+// to record the distribution of that latency (e.g. in a histogram)
+// and to detect regressions:
+//
+//	ObserveLatency: func(kind string, d time.Duration) {
+//	  corsLatency.WithLabelValues(kind).Observe(d.Seconds())
+//	},
+//
+// The kind argument is "preflight" for CORS-preflight requests
+// and "actual" for actual (i.e. non-preflight) CORS requests.
+// In the case of preflight requests, the measured time runs up to
+// the writing of the preflight response's status code;
+// in the case of actual requests, it runs up to the point where the
+// middleware delegates to the handler it wraps, and therefore
+// excludes the time spent in that handler.
+// Responses to requests that aren't CORS requests are not timed.
+// The hook is invoked synchronously and should be safe for concurrent use
+// by multiple goroutines. Timing only occurs if ObserveLatency is non-nil;
+// otherwise, its overhead is negligible.
+//
+// # AllowedContentTypesOnPreflight and OnDisallowedContentType
+//
+// When a client intends to send a request whose Content-Type header
+// isn't [CORS-safelisted][CORS-safelisted request header]
+// (e.g. application/json),
+// the corresponding preflight request lists content-type in its
+// Access-Control-Request-Headers header but, by design,
+// doesn't reveal the intended value of the Content-Type header.
+// Therefore, no CORS middleware can restrict content types at preflight.
+//
+// AllowedContentTypesOnPreflight and OnDisallowedContentType nonetheless
+// enable you to detect, after the fact, actual (i.e. non-preflight)
+// CORS requests whose Content-Type header specifies some media type
+// other than those listed in AllowedContentTypesOnPreflight
+// and the CORS-safelisted ones; a CORS middleware
+// invokes OnDisallowedContentType with the value of such a request's
+// Content-Type header and the request itself:
+//
+//	AllowedContentTypesOnPreflight: []string{"application/json"},
+//	OnDisallowedContentType: func(contentType string, r *http.Request) {
+//	  slog.Warn("unexpected content type", "type", contentType, "path", r.URL.Path)
+//	},
+//
+// Media types are case-insensitive, and parameters (e.g. charset)
+// in the Content-Type header are disregarded.
+// Note that this check is not a gate: it neither fails preflight nor
+// prevents the middleware from delegating to the handler it wraps,
+// which remains free to reject the request.
+// The hook is invoked synchronously, before the middleware delegates to
+// the handler it wraps; it must neither retain nor modify the request
+// and should be safe for concurrent use by multiple goroutines.
+// Checking only occurs if OnDisallowedContentType is non-nil.
+//
+// Specifying an invalid media type, a media type with parameters,
+// or a CORS-safelisted media type (application/x-www-form-urlencoded,
+// multipart/form-data, or text/plain) in AllowedContentTypesOnPreflight
+// is prohibited, as is specifying OnDisallowedContentType without also
+// specifying AllowedContentTypesOnPreflight.
+//
+// # OverrideHandlerCORSHeaders
+//
+// Upon receiving an actual (i.e. non-preflight) CORS request,
+// a CORS middleware sets the relevant CORS response headers
+// before invoking the wrapped handler;
+// therefore, any CORS response headers that the wrapped handler
+// (mistakenly) sets or adds end up in the response,
+// possibly alongside conflicting values set by the middleware.
+// (This concern doesn't apply to preflight requests, which a CORS middleware
+// handles without invoking the wrapped handler.)
+//
+// OverrideHandlerCORSHeaders configures a CORS middleware to ensure
+// that its own CORS response headers win:
+// right before the response headers get written,
+// the middleware discards all Access-Control-* response headers
+// and reinstates those that it set itself (if any).
+// To that end, the middleware passes the wrapped handler
+// a [http.ResponseWriter] that wraps the original one,
+// at the cost of a heap allocation per actual request.
+// That wrapper implements [http.Flusher] and is compatible with
+// [http.ResponseController], but it doesn't implement
+// other optional interfaces, such as [http.Hijacker], directly.
+//
+// # ApplyAfterHandler
+//
+// A CORS middleware normally sets the CORS response headers of responses
+// to actual (i.e. non-preflight) requests, as well as the Vary header,
+// before delegating to the handler it wraps. Consequently, if that handler
+// (or some middleware that sits between the CORS middleware and
+// your final handler) overwrites the Vary header (e.g. via [http.Header.Set])
+// or deletes it, "Origin" goes missing from the Vary header,
+// which can lead Web caches to serve responses to the wrong origins.
+//
+// ApplyAfterHandler configures a CORS middleware to defer the setting
+// of those headers until right before the response headers get written
+// (i.e. when the wrapped handler first calls WriteHeader, Write, or Flush,
+// or, if it does none of those things, once it returns),
+// so that the middleware's values build upon those that the handler set.
+// This is useful if you cannot apply the CORS middleware
+// as the outermost layer of the handlers that contribute to the Vary header.
+// To that end, the middleware passes the wrapped handler
+// a [http.ResponseWriter] that wraps the original one
+// (see OverrideHandlerCORSHeaders for the caveats).
+// Note that, in the wrapped handler, the CORS response headers
+// are not yet present in the response headers.
+// (This setting doesn't affect preflight requests, which a CORS middleware
+// handles without invoking the wrapped handler.)
+//
+// Because OverrideHandlerCORSHeaders and ReflectExposedResponseHeaders
+// rely on a similar mechanism, setting ApplyAfterHandler alongside either
+// of them is prohibited.
+//
+// # SanitizeInboundCORSHeaders
+//
+// Clients have no legitimate reason to send CORS response headers
+// (e.g. Access-Control-Allow-Origin) in requests, yet handlers may
+// (mistakenly) read them as if they had been set by a trusted party.
+// SanitizeInboundCORSHeaders configures a CORS middleware to delete,
+// before delegating to the handler it wraps, every Access-Control-*
+// header from the request, with the exception of the legitimate
+// CORS request headers:
+//   - Access-Control-Request-Method,
+//   - Access-Control-Request-Headers, and
+//   - Access-Control-Request-Private-Network.
+//
+// The Origin header is never deleted.
+// Note that the middleware modifies the request's headers in place.
+//
+// # ReflectExposedResponseHeaders
+//
+// Because the wildcard (*) in the Access-Control-Expose-Headers header
+// has no special meaning in responses to credentialed requests,
+// specifying the wildcard in the Config.ResponseHeaders field is
+// prohibited when credentialed access is enabled.
+// ReflectExposedResponseHeaders provides a safer alternative:
+// it configures a CORS middleware to build the value of
+// the Access-Control-Expose-Headers header of responses to allowed actual
+// (i.e. non-preflight) requests from the names of the response headers
+// that are present when the response headers get written,
+// thereby exposing every response header present,
+// except the [forbidden response-header names] (e.g. Set-Cookie),
+// the [CORS-safelisted response-header names] (which need not be exposed),
+// CORS response headers, and the Vary header.
+//
+// To that end, the middleware passes the wrapped handler
+// a [http.ResponseWriter] that wraps the original one
+// (see OverrideHandlerCORSHeaders for the caveats),
+// and it inspects the response headers right before they get written;
+// this setting therefore incurs a few heap allocations per allowed
+// actual request.
+// Response headers that get added after the response headers are written
+// (e.g. trailers) or by middleware that wrap the CORS middleware
+// are not exposed.
+//
+// Setting ReflectExposedResponseHeaders without also setting
+// the Config.Credentialed field is prohibited,
+// as is setting it in addition to specifying response-header names
+// in the Config.ResponseHeaders field.
+//
+// # KeepSafelistedExposedHeaders
+//
+// By default, specifying [CORS-safelisted response-header names]
+// (e.g. Content-Type) in the Config.ResponseHeaders field is prohibited,
+// since browsers expose the corresponding headers to clients anyway.
+// KeepSafelistedExposedHeaders lifts that prohibition:
+// it configures a CORS middleware to retain such names and list them,
+// alongside the other specified names, in the Access-Control-Expose-Headers
+// header. Doing so is harmless and makes that header (and the result of
+// [*Middleware.Config]) reflect the names you specified,
+// which may be desirable for tools that check for specific names.
+//
+// # WarnOnUnusedExposedHeaders and OnUnusedExposedHeaders
+//
+// WarnOnUnusedExposedHeaders and OnUnusedExposedHeaders enable you to
+// detect configuration drift whereby the Config.ResponseHeaders field
+// lists names of response headers that the wrapped handler no longer sets.
+// After the wrapped handler has handled an actual (i.e. non-preflight)
+// request to whose response the middleware added
+// an Access-Control-Expose-Headers header, the middleware
+// invokes OnUnusedExposedHeaders with the (byte-lowercase) names
+// of the exposed response headers that are absent from the response,
+// if any, and the request:
+//
+//	WarnOnUnusedExposedHeaders: true,
+//	OnUnusedExposedHeaders: func(names []string, r *http.Request) {
+//	  slog.Warn("unused exposed headers", "names", names, "path", r.URL.Path)
+//	},
+//
+// This setting is a development-time aid; because the inspection of
+// the response headers incurs some overhead, you should refrain from
+// setting WarnOnUnusedExposedHeaders in production.
+// Specifying OnUnusedExposedHeaders without also setting
+// WarnOnUnusedExposedHeaders is allowed (the hook then never gets invoked),
+// which lets you keep the hook wired up and toggle the check
+// (e.g. from a development flag).
+// The hook must neither retain nor modify the request and
+// should be safe for concurrent use by multiple goroutines.
+//
+// Setting WarnOnUnusedExposedHeaders without also specifying
+// OnUnusedExposedHeaders is prohibited, as is setting it without also
+// specifying discrete response-header names (i.e. other than the wildcard)
+// in the Config.ResponseHeaders field.
+//
+// # HandleWebSocketUpgrade
+//
+// The CORS protocol does not apply to [WebSocket] connections:
+// browsers do include an Origin header in WebSocket opening handshakes,
+// but they neither send preflight requests for them
+// nor heed CORS response headers in the server's (101) response.
+// Therefore, a CORS middleware can neither allow nor prevent
+// cross-origin WebSocket connections;
+// if you want to restrict the origins from which clients can open
+// WebSocket connections to your server, your WebSocket handler must itself
+// check the Origin header (e.g. via [*OriginMatcher.Contains]).
+//
+// By default, a CORS middleware treats a WebSocket opening handshake
+// like any other actual (i.e. non-preflight) CORS request;
+// the resulting CORS response headers are harmless but useless.
+// HandleWebSocketUpgrade configures a CORS middleware to recognize
+// WebSocket opening handshakes (i.e. requests whose Upgrade header
+// contains "websocket" and whose Connection header contains "upgrade")
+// and to pass them through to the wrapped handler untouched,
+// i.e. without adding any headers (not even Vary) to the response.
+//
+// # OriginResolver
+//
+// OriginResolver, if non-nil, gets invoked whenever a request lacks
+// an Origin header (or, if TreatEmptyOriginAsAbsent is set,
+// whenever that header's value is empty); if it returns true,
+// the CORS middleware then proceeds as if the request's Origin header
+// had the value that OriginResolver returned.
+// This setting enables you to gate the emission of CORS response headers
+// for non-browser clients on some other request attribute
+// (e.g. the tenant associated with some API key):
+//
+//	OriginResolver: func(r *http.Request) (string, bool) {
+//	  return tenantOrigin(r.Header.Get("X-Api-Key")) // defined elsewhere
+//	},
+//
+// Because browsers always include an Origin header in CORS requests,
+// this setting only affects requests from non-browser clients,
+// where the CORS protocol offers no protection anyway;
+// in particular, OriginResolver cannot be used to grant browser-based
+// clients any access.
+// The hook must not modify the request and
+// should be safe for concurrent use by multiple goroutines.
+// Be aware that caching intermediaries must take into account
+// the request attributes on which OriginResolver relies.
+//
+// # TransformAllowedOrigin
+//
+// By default, a CORS middleware echoes the value of the request's Origin
+// header in the Access-Control-Allow-Origin header of responses to
+// requests from allowed origins.
+// TransformAllowedOrigin, if non-nil, gets invoked with the request's
+// origin once the middleware has confirmed that this origin is allowed;
+// the middleware then writes the function's result
+// in the Access-Control-Allow-Origin header instead.
+// This setting is an escape hatch for some reverse-proxy topologies
+// (e.g. ones in which the middleware must map an internal origin to its
+// public counterpart):
+//
+//	TransformAllowedOrigin: func(origin string) string {
+//	  return strings.Replace(origin, ".internal.example.com", ".example.com", 1)
+//	},
+//
+// If the function returns the empty string or,
+// when credentialed access is enabled, a value that is not a single valid
+// origin, the middleware falls back to echoing the request's origin.
+// TransformAllowedOrigin has no effect on responses that allow all origins
+// by means of the wildcard, nor on responses to requests from disallowed
+// origins (even in report-only mode).
+//
+// Be aware that the Fetch standard requires the value of
+// the Access-Control-Allow-Origin header to match the value of
+// the request's Origin header byte for byte;
+// browsers fail the CORS check if the transformed value,
+// as observed by the browser, differs from the origin that it sent.
+// Therefore, this setting only makes sense if some intermediary
+// reverses the transformation before the response reaches the browser.
+// The function should be safe for concurrent use by multiple goroutines.
+//
+// # PublicAnyOriginPredicate
+//
+// PublicAnyOriginPredicate, if non-nil, gets invoked on every actual
+// (i.e. non-preflight) CORS request that carries neither a Cookie header
+// nor an Authorization header; if it returns true,
+// the CORS middleware bypasses its origin patterns and allows
+// the request from any origin by means of the wildcard:
+// it sets the Access-Control-Allow-Origin header to *
+// and, if any response headers are to be exposed,
+// the Access-Control-Expose-Headers header.
+// This setting is useful for exposing some public endpoint
+// (e.g. one that serves a public key set) under an otherwise
+// restricted service without resorting to a second middleware:
+//
+//	PublicAnyOriginPredicate: func(r *http.Request) bool {
+//	  return r.URL.Path == "/.well-known/jwks.json"
+//	},
+//
+// Requests that carry credentials are always subject to
+// the middleware's origin patterns, and responses to which
+// the middleware adds Access-Control-Allow-Origin: * never contain
+// Access-Control-Allow-Credentials, which ensures that this setting
+// cannot be used to grant credentialed access to arbitrary origins.
+// Note that CORS-preflight requests are not subject to this setting;
+// if the public endpoint must be accessible by means of requests that
+// require preflight, the middleware's configuration must allow them.
+// The predicate must not modify the request and
+// should be safe for concurrent use by multiple goroutines.
+//
+// # ConcreteOriginForAnonymousAllowAll
+//
+// By default, a CORS middleware configured to allow all origins
+// (by means of the single-asterisk origin pattern) sets
+// the Access-Control-Allow-Origin header to * in its responses
+// to CORS requests.
+// ConcreteOriginForAnonymousAllowAll, if non-nil, gets invoked on every
+// CORS request to such a middleware; if it returns true,
+// the middleware instead echoes the request's origin in that header.
+// This setting is meant for the few clients (e.g. some link-preview bots)
+// that require a concrete origin in the Access-Control-Allow-Origin header:
+//
+//	ConcreteOriginForAnonymousAllowAll: func(r *http.Request) bool {
+//	  return strings.Contains(r.UserAgent(), "ExampleBot")
+//	},
+//
+// Requests for which the predicate returns false, as well as requests
+// whose origin is not a valid Web origin (e.g. null), still elicit *.
+// Because credentialed access cannot be enabled alongside
+// the single-asterisk origin pattern, this setting never grants
+// credentialed access to arbitrary origins.
+// Because responses then depend on the request's origin, the middleware
+// lists Origin in the Vary header of responses to non-OPTIONS requests;
+// however, it cannot know which request headers (e.g. User-Agent)
+// the predicate inspects. If Web caches sit in front of your server,
+// make sure the wrapped handler lists those in the Vary header too.
+// The predicate must not modify the request and
+// should be safe for concurrent use by multiple goroutines.
+// Specifying ConcreteOriginForAnonymousAllowAll without also specifying
+// the single-asterisk origin pattern in the Config.Origins field
+// is prohibited.
+//
+// # AnswerPlainOptions
+//
+// By default, a CORS middleware delegates OPTIONS requests that are not
+// CORS requests (i.e. that lack an Origin header) to the handler it wraps.
+// AnswerPlainOptions configures a CORS middleware to instead respond to
+// such requests itself, with a 204 status and an [Allow] header that lists
+// the methods that the Config.Methods and ExtraConfig.OriginMethods
+// fields allow, along with the [CORS-safelisted methods] and OPTIONS:
+//
+//	Allow: DELETE, GET, HEAD, OPTIONS, POST, PUT
+//
+// This setting is handy when the wrapped handler doesn't handle
+// OPTIONS requests by itself.
+// Setting AnswerPlainOptions while allowing all methods
+// (whether for all origins or, via ExtraConfig.OriginMethods,
+// for some discrete origin only) is prohibited,
+// since the Allow header cannot then list the allowed methods.
+//
+// # SuppressCORSHeadersOnNonCORSOptions
+//
+// By default, a CORS middleware that allows all origins with
+// credentialed access disabled includes, in responses to
+// all non-preflight requests (including those that are not CORS requests),
+// an Access-Control-Allow-Origin header (and, if response headers are to be
+// exposed, an Access-Control-Expose-Headers header).
+// SuppressCORSHeadersOnNonCORSOptions configures a CORS middleware
+// to omit those headers from responses to OPTIONS requests that are not
+// CORS requests (i.e. that lack an Origin header),
+// which then only get a Vary header.
+// This setting has no effect on other requests.
+//
 // # DangerouslyTolerateInsecureOrigins
 //
 // DangerouslyTolerateInsecureOrigins enables you to allow insecure origins
@@ -415,6 +1585,10 @@ type Config struct {
 // Be aware that allowing insecure origins exposes your clients to
 // some [active network attacks],
 // as described by James Kettle in [the talk he gave at AppSec EU 2017].
+// If you allow an insecure origin alongside its secure counterpart
+// (e.g. http://example.com alongside https://example.com),
+// the resulting middleware reports as much via its
+// [*Middleware.Warnings] method.
 //
 // # DangerouslyTolerateSubdomainsOfPublicSuffixes
 //
@@ -429,88 +1603,676 @@ type Config struct {
 //
 // [204]: https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/204
 // [2xx range]: https://fetch.spec.whatwg.org/#ok-status
+// [403]: https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/403
+// [4xx range]: https://developer.mozilla.org/en-US/docs/Web/HTTP/Status#client_error_responses
+// [CORS-preflight requests]: https://developer.mozilla.org/en-US/docs/Glossary/Preflight_request
 // [Private-Network Access]: https://wicg.github.io/private-network-access/
 // [Same-Origin Policy]: https://developer.mozilla.org/en-US/docs/Web/Security/Same-origin_policy
 // [active network attacks]: https://en.wikipedia.org/wiki/Man-in-the-middle_attack
+// [Deprecation]: https://www.rfc-editor.org/rfc/rfc9745
+// [Link]: https://www.rfc-editor.org/rfc/rfc8288
+// [CSP's report-only mode]: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Security-Policy-Report-Only
+// [Fetch-metadata request headers]: https://developer.mozilla.org/en-US/docs/Glossary/Fetch_metadata_request_header
+// [Sunset]: https://www.rfc-editor.org/rfc/rfc8594
+// [Allow]: https://www.rfc-editor.org/rfc/rfc9110#name-allow
+// [CORS-safelisted response-header names]: https://fetch.spec.whatwg.org/#cors-safelisted-response-header-name
+// [forbidden response-header names]: https://fetch.spec.whatwg.org/#forbidden-response-header-name
+// [CORS-safelisted methods]: https://fetch.spec.whatwg.org/#cors-safelisted-method
+// [CORS-safelisted request header]: https://fetch.spec.whatwg.org/#cors-safelisted-request-header
+// [WebSocket]: https://www.rfc-editor.org/rfc/rfc6455
+// [credentials mode]: https://fetch.spec.whatwg.org/#concept-request-credentials-mode
+// [default max-age value]: https://fetch.spec.whatwg.org/#http-access-control-max-age
 // [link-shortening-service example]: https://wicg.github.io/private-network-access/#shortlinks
 // [no-cors mode]: https://fetch.spec.whatwg.org/#concept-request-mode
 // [public suffix]: https://publicsuffix.org/
 // [security reasons]: https://developer.chrome.com/blog/private-network-access-preflight/#no-cors-mode
+// [the Fetch standard]: https://fetch.spec.whatwg.org/#cors-non-wildcard-request-header-name
 // [the talk he gave at AppSec EU 2017]: https://www.youtube.com/watch?v=wgkj4ZgxI4c&t=1305s
 type ExtraConfig struct {
 	_ [0]func() // precludes comparability and unkeyed struct literals
 
 	PreflightSuccessStatus                        int
+	PreflightFailureStatus                        int
+	PreflightStatusByMethod                       map[string]int
+	RejectionMarkerHeader                         bool
+	RejectionMarkerHeaderName                     string
 	PrivateNetworkAccess                          bool
 	PrivateNetworkAccessInNoCORSModeOnly          bool
+	RequestMethodHeaderFallback                   string
+	LenientACRHTokenWhitespace                    bool
+	SortAllowHeaders                              bool
+	LegacyAllowedRequestHeaders                   []string
+	OriginMethods                                 map[string][]string
+	CredentialsHeuristic                          bool
+	CredentialedSchemes                           []string
+	CredentialedPathPredicate                     func(*http.Request) bool `json:"-"`
+	CredentialedWildcardMethods                   []string
+	AdditionalSafelistedMethods                   []string
+	TreatHeadAsNonSafe                            bool
+	AlwaysEmitMaxAge                              bool
+	ResponseHeaderHook                            func(http.Header) `json:"-"`
+	LowercaseResponseHeaderNames                  bool
+	UseCanonicalHeaderWrites                      bool
+	VaryStrategy                                  VaryStrategy
+	OmitVaryForDisallowedOrigins                  bool
+	NormalizeIPv4Shorthand                        bool
+	DecodePercentEncodedOrigin                    bool
+	TreatEmptyOriginAsAbsent                      bool
+	OriginHeaderNames                             []string
+	PreserveOriginOrder                           bool
+	ValidateSecFetchMetadata                      bool
+	ReportOnly                                    bool
+	ReportOnlyHook                                func(origin string, reason Reason) `json:"-"`
+	DeprecatedOrigins                             map[string]time.Time
+	PolicyLink                                    string
+	MaxOriginLength                               int
+	MaxDebugACAHBytes                             int
+	DebugOmitCredentialsHeader                    bool
+	MaxProcessedHeaderBytes                       int
+	InjectDecision                                bool
+	WildcardCoversAuthorization                   bool
+	BoundedAuthorizationScan                      int
+	SlowPreflightThreshold                        time.Duration
+	OnSlowPreflight                               func(d time.Duration, r *http.Request) `json:"-"`
+	ObserveLatency                                func(kind string, d time.Duration)     `json:"-"`
+	AllowedContentTypesOnPreflight                []string
+	OnDisallowedContentType                       func(contentType string, r *http.Request) `json:"-"`
+	OverrideHandlerCORSHeaders                    bool
+	ApplyAfterHandler                             bool
+	SanitizeInboundCORSHeaders                    bool
+	ReflectExposedResponseHeaders                 bool
+	KeepSafelistedExposedHeaders                  bool
+	WarnOnUnusedExposedHeaders                    bool
+	OnUnusedExposedHeaders                        func(names []string, r *http.Request) `json:"-"`
+	HandleWebSocketUpgrade                        bool
+	OriginResolver                                func(*http.Request) (string, bool) `json:"-"`
+	TransformAllowedOrigin                        func(origin string) string         `json:"-"`
+	PublicAnyOriginPredicate                      func(*http.Request) bool           `json:"-"`
+	ConcreteOriginForAnonymousAllowAll            func(*http.Request) bool           `json:"-"`
+	AnswerPlainOptions                            bool
+	SuppressCORSHeadersOnNonCORSOptions           bool
 	DangerouslyTolerateInsecureOrigins            bool
 	DangerouslyTolerateSubdomainsOfPublicSuffixes bool
 }
 
+// A VaryStrategy specifies which header names a CORS middleware lists
+// in the Vary header of responses to actual (i.e. non-preflight) requests
+// and to non-CORS requests;
+// see the documentation of ExtraConfig.VaryStrategy.
+type VaryStrategy uint8
+
+const (
+	// VaryDefault is equivalent to VaryFull.
+	VaryDefault VaryStrategy = iota
+	// VaryOriginOnly lists only Origin, wherever the middleware would
+	// otherwise list any header name.
+	VaryOriginOnly
+	// VaryNone lists no header name at all.
+	// Unless no Web cache sits between clients and your server,
+	// this strategy is unsafe for middleware that allow more than one origin.
+	VaryNone
+	// VaryFull lists Origin in the Vary header of responses to
+	// non-OPTIONS requests
+	// (unless the middleware allows all origins without credentials)
+	// and all the request-header names involved in CORS-preflight requests
+	// (Access-Control-Request-Headers, Access-Control-Request-Method,
+	// Access-Control-Request-Private-Network, and Origin)
+	// in the Vary header of responses to OPTIONS requests.
+	VaryFull
+)
+
+// String returns the name of the VaryStrategy constant equal to vs
+// (e.g. "VaryNone").
+func (vs VaryStrategy) String() string {
+	switch vs {
+	case VaryDefault:
+		return "VaryDefault"
+	case VaryOriginOnly:
+		return "VaryOriginOnly"
+	case VaryNone:
+		return "VaryNone"
+	case VaryFull:
+		return "VaryFull"
+	default:
+		return "VaryStrategy(" + strconv.Itoa(int(vs)) + ")"
+	}
+}
+
 type internalConfig struct {
 	// origins
 	corpus         origins.Corpus
 	allowAnyOrigin bool
+	originOrder    []string // nil unless ExtraConfig.PreserveOriginOrder is set
+	maxOriginLen   int
+	maxDebugACRH   int
+	debugOmitACAC  bool
+	maxHdrBytes    int // 0 if no budget applies
 
 	// credentialed
-	credentialed bool
+	credentialed         bool
+	credentialsHeuristic bool
+	credentialedSchemes  util.Set[string] // nil means all schemes
+	credPathPredicate    func(*http.Request) bool
 
 	// methods
 	allowedMethods util.Set[string]
 	allowAnyMethod bool
+	originMethods  map[string]methodSet // keyed by discrete origin
+	// credWildcardACAM is the single-element value of the ACAM header emitted
+	// in lieu of an echo of the requested method when all methods are
+	// allowed and access is credentialed; nil means echo.
+	credWildcardACAM []string
+	// extraSafelistedMethods are the methods that get the same free pass as
+	// the CORS-safelisted methods; see ExtraConfig.AdditionalSafelistedMethods.
+	extraSafelistedMethods util.Set[string]
+	headNonSafe            bool // see ExtraConfig.TreatHeadAsNonSafe
 
 	// request headers
 	acah               []string
 	allowedReqHdrs     headers.SortedSet
+	legacyReqHdrs      []string // canonical, sorted, and without duplicates
 	asteriskReqHdrs    bool
 	allowAuthorization bool
 
 	// max age
-	acma []string
+	acma             []string
+	alwaysEmitMaxAge bool
 
 	// response headers
 	aceh             string
 	exposeAllResHdrs bool
 
 	// misc
+	warnings                   []error
 	preflightStatus            int
+	preflightFailureStatus     int
+	preflightStatusByMethod    map[string]int // nil if empty
+	rejectionMarker            string         // empty unless ExtraConfig.RejectionMarkerHeader is set
 	tmp                        *tmpConfig
-	debug                      bool
 	privateNetworkAccess       bool
 	privateNetworkAccessNoCors bool
+	acrmFallback               string
+	varyOriginNames            string   // names in originHdrs, as listed in Vary
+	varyPreflightSgl           []string // Vary value of preflight responses
+	varyActual                 string   // Vary value of responses to actual requests
+	lenientACRHWhitespace      bool
+	sortACAH                   bool
+	resHdrHook                 func(http.Header)
+	lowercaseResHdrNames       bool
+	canonicalWrites            bool
+	varyStrategy               VaryStrategy
+	omitVaryDisallowed         bool
+	normalizeIPv4Shorthand     bool
+	decodePercentEncodedOrigin bool
+	emptyOriginAsAbsent        bool
+	originHdrs                 []string // canonical names of the headers holding the origin
+	secFetchCheck              bool     // see ExtraConfig.ValidateSecFetchMetadata
+	reportOnly                 bool
+	reportOnlyHook             func(origin string, reason Reason)
+	deprecatedOrigins          map[string]deprecation // keyed by discrete origin
+	policyLink                 string
+	policyLinkSgl              []string // value of the Link header
+	injectDecision             bool
+	wildcardCoversAuthz        bool
+	authzScanLimit             int
+	slowPreflightThreshold     time.Duration
+	onSlowPreflight            func(d time.Duration, r *http.Request)
+	observeLatency             func(kind string, d time.Duration)
+	allowedContentTypes        util.Set[string] // nil if empty
+	onDisallowedContentType    func(contentType string, r *http.Request)
+	overrideHandlerCORSHdrs    bool
+	applyAfterHandler          bool
+	sanitizeReqCORSHdrs        bool
+	reflectACEH                bool
+	keepSafelistedACEH         bool
+	warnUnusedACEH             bool
+	onUnusedACEH               func(names []string, r *http.Request)
+	webSocketPassthrough       bool
+	originResolver             func(*http.Request) (string, bool)
+	transformACAO              func(string) string
+	publicAnyOrigin            func(*http.Request) bool
+	concreteAnyACAO            func(*http.Request) bool
+	plainOptionsAllow          []string // nil unless ExtraConfig.AnswerPlainOptions is set
+	suppressNonCORSOptions     bool
 	subsOfPublicSuffixes       bool
 	insecureOrigins            bool
 }
 
+// A methodSet represents the methods allowed for some origin.
+type methodSet struct {
+	allowed  util.Set[string]
+	allowAny bool
+}
+
+// A deprecation represents the scheduled removal of some origin.
+type deprecation struct {
+	sunset    time.Time
+	sunsetSgl []string // the value of the Sunset header
+}
+
 type tmpConfig struct {
+	originPatterns         []origins.Pattern
 	publicSuffixes         []string
 	insecureOriginPatterns []string
+	secureOriginPatterns   util.Set[string]
+	networkAddrPatterns    []string // see origins.Pattern.LooksLikeNetworkAddress
 	exposedResHdrs         []string
+	onlySafelistedMethods  bool // whether Methods lists only safelisted methods
+	maxAge                 int  // as specified by MaxAgeInSeconds
+}
+
+// reset empties tmp while retaining its underlying storage,
+// so that tmp can serve as scratch space for validating another Config.
+func (tmp *tmpConfig) reset() {
+	tmp.originPatterns = tmp.originPatterns[:0]
+	tmp.publicSuffixes = tmp.publicSuffixes[:0]
+	tmp.insecureOriginPatterns = tmp.insecureOriginPatterns[:0]
+	clear(tmp.secureOriginPatterns)
+	tmp.networkAddrPatterns = tmp.networkAddrPatterns[:0]
+	tmp.exposedResHdrs = tmp.exposedResHdrs[:0]
+	tmp.onlySafelistedMethods = false
+	tmp.maxAge = 0
+}
+
+// SimpleConfig returns a minimal Config that is a safe starting point for
+// enabling cross-origin reads of your resources: the resulting Config
+// allows the specified origin patterns (see the documentation of
+// the Config.Origins field), only allows the [CORS-safelisted methods]
+// (GET, HEAD, and POST) and no request headers other than
+// the CORS-safelisted ones, doesn't enable credentialed access,
+// and exposes a few response headers commonly needed by
+// browser-based clients: ETag, Link, Location, and Retry-After.
+// For instance,
+//
+//	cors.SimpleConfig("https://example.com", "https://*.example.com")
+//
+// is equivalent to
+//
+//	cors.Config{
+//	  Origins:         []string{"https://example.com", "https://*.example.com"},
+//	  ResponseHeaders: []string{"ETag", "Link", "Location", "Retry-After"},
+//	}
+//
+// SimpleConfig performs no validation of its own;
+// just like any other Config, the result is validated by [NewMiddleware],
+// which in particular rejects it if no origin patterns are specified.
+// You are free to extend the result (e.g. by allowing more methods)
+// before passing it to NewMiddleware.
+//
+// [CORS-safelisted methods]: https://fetch.spec.whatwg.org/#cors-safelisted-method
+func SimpleConfig(origins ...string) Config {
+	return Config{
+		Origins:         slices.Clone(origins),
+		ResponseHeaders: []string{"ETag", "Link", "Location", "Retry-After"},
+	}
+}
+
+// ValidateConfigs validates each element of cfgs and returns a slice
+// of the same length as cfgs, whose element at index i is either nil
+// (if cfgs[i] is valid) or the error that [NewMiddleware] would return
+// for cfgs[i].
+//
+// ValidateConfigs is meant for control planes that need to validate
+// many configurations at once. It is more economical than calling
+// NewMiddleware for each configuration and discarding the results,
+// because it reuses scratch buffers across configurations
+// and it skips the work required only for building a Middleware.
+func ValidateConfigs(cfgs []Config) []error {
+	errs := make([]error, len(cfgs))
+	tmp := new(tmpConfig)
+	for i := range cfgs {
+		tmp.reset()
+		icfg := internalConfig{
+			tmp: tmp,
+		}
+		errs[i] = icfg.validateConfig(&cfgs[i])
+	}
+	return errs
+}
+
+// RequireSecureOrigins returns a non-nil error that enumerates the insecure
+// origin patterns (e.g. http://example.com, or the single-asterisk pattern)
+// among cfg.Origins, if any; otherwise, it returns nil.
+// An origin pattern is deemed insecure if its scheme is http,
+// unless its host is localhost or a loopback IP address.
+// Invalid origin patterns are ignored, since [NewMiddleware] reports them.
+//
+// RequireSecureOrigins is stricter than NewMiddleware, which tolerates
+// insecure origin patterns in some cases (e.g. in anonymous-only mode or
+// when ExtraConfig.DangerouslyTolerateInsecureOrigins is set);
+// it is meant to serve as a gate (e.g. in CI) that forbids
+// insecure origins altogether in production configurations.
+func RequireSecureOrigins(cfg Config) error {
+	var insecure []string
+	for _, raw := range expandOriginPatterns(cfg.Origins) {
+		if raw == headers.ValueWildcard {
+			insecure = append(insecure, raw)
+			continue
+		}
+		pattern, err := origins.ParsePattern(raw)
+		if err == nil && pattern.IsDeemedInsecure() {
+			insecure = append(insecure, raw)
+		}
+	}
+	if len(insecure) == 0 {
+		return nil
+	}
+	var errorMsg strings.Builder
+	errorMsg.WriteString("the following origin patterns are insecure: ")
+	util.Join(&errorMsg, insecure)
+	return util.NewError(errorMsg.String())
+}
+
+// MergeConfigs returns a new Config that results from layering override
+// on top of base (e.g. a per-service configuration on top of
+// an organization-wide one), in accordance with the following rules:
+//   - The Origins, Methods, RequestHeaders, ResponseHeaders,
+//     LegacyAllowedRequestHeaders, and AllowedContentTypesOnPreflight fields
+//     of the result are the union of those of base and override,
+//     without duplicates and with the elements of base first.
+//   - The PreflightStatusByMethod, OriginMethods, and DeprecatedOrigins
+//     fields of the result
+//     contain the entries of both base and override;
+//     in case of conflict, the entry of override wins.
+//   - Every other field of the result is that of override,
+//     unless the latter has the zero value, in which case
+//     it is that of base. In particular, override cannot unset
+//     a boolean field that is set in base.
+//
+// MergeConfigs performs no validation; call [NewMiddleware]
+// on the result to validate it. Be aware that the union of a list that
+// contains the wildcard (*) and a list that contains other elements is
+// typically prohibited (e.g. "*" alongside "https://example.com" in Origins).
+//
+// Mutating the fields of base or override after MergeConfigs has returned
+// does not alter the result.
+func MergeConfigs(base, override Config) Config {
+	var cfg Config
+	cfg.Origins = union(base.Origins, override.Origins)
+	cfg.Credentialed = base.Credentialed || override.Credentialed
+	cfg.Methods = union(base.Methods, override.Methods)
+	cfg.RequestHeaders = union(base.RequestHeaders, override.RequestHeaders)
+	cfg.MaxAgeInSeconds = cmp.Or(override.MaxAgeInSeconds, base.MaxAgeInSeconds)
+	cfg.ResponseHeaders = union(base.ResponseHeaders, override.ResponseHeaders)
+
+	b, o, x := &base.ExtraConfig, &override.ExtraConfig, &cfg.ExtraConfig
+	x.PreflightSuccessStatus = cmp.Or(o.PreflightSuccessStatus,
+		b.PreflightSuccessStatus)
+	x.PreflightFailureStatus = cmp.Or(o.PreflightFailureStatus,
+		b.PreflightFailureStatus)
+	x.PreflightStatusByMethod = mergeMaps(b.PreflightStatusByMethod,
+		o.PreflightStatusByMethod, identity)
+	x.RejectionMarkerHeader = b.RejectionMarkerHeader || o.RejectionMarkerHeader
+	x.RejectionMarkerHeaderName = cmp.Or(o.RejectionMarkerHeaderName,
+		b.RejectionMarkerHeaderName)
+	x.PrivateNetworkAccess = b.PrivateNetworkAccess || o.PrivateNetworkAccess
+	x.PrivateNetworkAccessInNoCORSModeOnly = b.PrivateNetworkAccessInNoCORSModeOnly ||
+		o.PrivateNetworkAccessInNoCORSModeOnly
+	x.RequestMethodHeaderFallback = cmp.Or(o.RequestMethodHeaderFallback,
+		b.RequestMethodHeaderFallback)
+	x.LenientACRHTokenWhitespace = b.LenientACRHTokenWhitespace ||
+		o.LenientACRHTokenWhitespace
+	x.SortAllowHeaders = b.SortAllowHeaders || o.SortAllowHeaders
+	x.LegacyAllowedRequestHeaders = union(b.LegacyAllowedRequestHeaders,
+		o.LegacyAllowedRequestHeaders)
+	x.OriginMethods = mergeMaps(b.OriginMethods, o.OriginMethods, slices.Clone)
+	x.CredentialsHeuristic = b.CredentialsHeuristic || o.CredentialsHeuristic
+	x.CredentialedSchemes = slices.Clone(orSlice(o.CredentialedSchemes,
+		b.CredentialedSchemes))
+	x.CredentialedPathPredicate = o.CredentialedPathPredicate
+	if x.CredentialedPathPredicate == nil {
+		x.CredentialedPathPredicate = b.CredentialedPathPredicate
+	}
+	x.CredentialedWildcardMethods = slices.Clone(orSlice(o.CredentialedWildcardMethods,
+		b.CredentialedWildcardMethods))
+	x.AdditionalSafelistedMethods = slices.Clone(orSlice(o.AdditionalSafelistedMethods,
+		b.AdditionalSafelistedMethods))
+	x.TreatHeadAsNonSafe = b.TreatHeadAsNonSafe || o.TreatHeadAsNonSafe
+	x.AlwaysEmitMaxAge = b.AlwaysEmitMaxAge || o.AlwaysEmitMaxAge
+	x.ResponseHeaderHook = o.ResponseHeaderHook
+	if x.ResponseHeaderHook == nil {
+		x.ResponseHeaderHook = b.ResponseHeaderHook
+	}
+	x.LowercaseResponseHeaderNames = b.LowercaseResponseHeaderNames ||
+		o.LowercaseResponseHeaderNames
+	x.UseCanonicalHeaderWrites = b.UseCanonicalHeaderWrites ||
+		o.UseCanonicalHeaderWrites
+	x.VaryStrategy = cmp.Or(o.VaryStrategy, b.VaryStrategy)
+	x.OmitVaryForDisallowedOrigins = b.OmitVaryForDisallowedOrigins || o.OmitVaryForDisallowedOrigins
+	x.NormalizeIPv4Shorthand = b.NormalizeIPv4Shorthand || o.NormalizeIPv4Shorthand
+	x.DecodePercentEncodedOrigin = b.DecodePercentEncodedOrigin ||
+		o.DecodePercentEncodedOrigin
+	x.TreatEmptyOriginAsAbsent = b.TreatEmptyOriginAsAbsent || o.TreatEmptyOriginAsAbsent
+	x.OriginHeaderNames = slices.Clone(orSlice(o.OriginHeaderNames,
+		b.OriginHeaderNames))
+	x.PreserveOriginOrder = b.PreserveOriginOrder || o.PreserveOriginOrder
+	x.ValidateSecFetchMetadata = b.ValidateSecFetchMetadata || o.ValidateSecFetchMetadata
+	x.ReportOnly = b.ReportOnly || o.ReportOnly
+	x.ReportOnlyHook = o.ReportOnlyHook
+	if x.ReportOnlyHook == nil {
+		x.ReportOnlyHook = b.ReportOnlyHook
+	}
+	x.DeprecatedOrigins = mergeMaps(b.DeprecatedOrigins, o.DeprecatedOrigins, identity)
+	x.PolicyLink = cmp.Or(o.PolicyLink, b.PolicyLink)
+	x.MaxOriginLength = cmp.Or(o.MaxOriginLength, b.MaxOriginLength)
+	x.MaxDebugACAHBytes = cmp.Or(o.MaxDebugACAHBytes, b.MaxDebugACAHBytes)
+	x.DebugOmitCredentialsHeader = b.DebugOmitCredentialsHeader || o.DebugOmitCredentialsHeader
+	x.MaxProcessedHeaderBytes = cmp.Or(o.MaxProcessedHeaderBytes, b.MaxProcessedHeaderBytes)
+	x.InjectDecision = b.InjectDecision || o.InjectDecision
+	x.WildcardCoversAuthorization = b.WildcardCoversAuthorization ||
+		o.WildcardCoversAuthorization
+	x.BoundedAuthorizationScan = cmp.Or(o.BoundedAuthorizationScan,
+		b.BoundedAuthorizationScan)
+	x.SlowPreflightThreshold = cmp.Or(o.SlowPreflightThreshold,
+		b.SlowPreflightThreshold)
+	x.OnSlowPreflight = o.OnSlowPreflight
+	if x.OnSlowPreflight == nil {
+		x.OnSlowPreflight = b.OnSlowPreflight
+	}
+	x.ObserveLatency = o.ObserveLatency
+	if x.ObserveLatency == nil {
+		x.ObserveLatency = b.ObserveLatency
+	}
+	x.AllowedContentTypesOnPreflight = union(b.AllowedContentTypesOnPreflight,
+		o.AllowedContentTypesOnPreflight)
+	x.OnDisallowedContentType = o.OnDisallowedContentType
+	if x.OnDisallowedContentType == nil {
+		x.OnDisallowedContentType = b.OnDisallowedContentType
+	}
+	x.OverrideHandlerCORSHeaders = b.OverrideHandlerCORSHeaders ||
+		o.OverrideHandlerCORSHeaders
+	x.ApplyAfterHandler = b.ApplyAfterHandler || o.ApplyAfterHandler
+	x.SanitizeInboundCORSHeaders = b.SanitizeInboundCORSHeaders ||
+		o.SanitizeInboundCORSHeaders
+	x.ReflectExposedResponseHeaders = b.ReflectExposedResponseHeaders ||
+		o.ReflectExposedResponseHeaders
+	x.KeepSafelistedExposedHeaders = b.KeepSafelistedExposedHeaders ||
+		o.KeepSafelistedExposedHeaders
+	x.WarnOnUnusedExposedHeaders = b.WarnOnUnusedExposedHeaders ||
+		o.WarnOnUnusedExposedHeaders
+	x.OnUnusedExposedHeaders = o.OnUnusedExposedHeaders
+	if x.OnUnusedExposedHeaders == nil {
+		x.OnUnusedExposedHeaders = b.OnUnusedExposedHeaders
+	}
+	x.HandleWebSocketUpgrade = b.HandleWebSocketUpgrade || o.HandleWebSocketUpgrade
+	x.OriginResolver = o.OriginResolver
+	if x.OriginResolver == nil {
+		x.OriginResolver = b.OriginResolver
+	}
+	x.TransformAllowedOrigin = o.TransformAllowedOrigin
+	if x.TransformAllowedOrigin == nil {
+		x.TransformAllowedOrigin = b.TransformAllowedOrigin
+	}
+	x.PublicAnyOriginPredicate = o.PublicAnyOriginPredicate
+	if x.PublicAnyOriginPredicate == nil {
+		x.PublicAnyOriginPredicate = b.PublicAnyOriginPredicate
+	}
+	x.ConcreteOriginForAnonymousAllowAll = o.ConcreteOriginForAnonymousAllowAll
+	if x.ConcreteOriginForAnonymousAllowAll == nil {
+		x.ConcreteOriginForAnonymousAllowAll = b.ConcreteOriginForAnonymousAllowAll
+	}
+	x.AnswerPlainOptions = b.AnswerPlainOptions || o.AnswerPlainOptions
+	x.SuppressCORSHeadersOnNonCORSOptions = b.SuppressCORSHeadersOnNonCORSOptions ||
+		o.SuppressCORSHeadersOnNonCORSOptions
+	x.DangerouslyTolerateInsecureOrigins = b.DangerouslyTolerateInsecureOrigins ||
+		o.DangerouslyTolerateInsecureOrigins
+	x.DangerouslyTolerateSubdomainsOfPublicSuffixes =
+		b.DangerouslyTolerateSubdomainsOfPublicSuffixes ||
+			o.DangerouslyTolerateSubdomainsOfPublicSuffixes
+	return cfg
+}
+
+// union returns a new slice that contains the elements of a followed by
+// the elements of b that are not in a, without duplicates;
+// it returns nil if both a and b are empty.
+func union(a, b []string) []string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(a)+len(b))
+	seen := make(util.Set[string], len(a)+len(b))
+	for _, s := range slices.Concat(a, b) {
+		if seen.Contains(s) {
+			continue
+		}
+		seen.Add(s)
+		res = append(res, s)
+	}
+	return res
+}
+
+// mergeMaps returns a new map that contains the entries of both a and b,
+// with those of b taking precedence, and whose values are copied via clone;
+// it returns nil if both a and b are empty.
+func mergeMaps[V any](a, b map[string]V, clone func(V) V) map[string]V {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	res := make(map[string]V, len(a)+len(b))
+	for _, m := range []map[string]V{a, b} {
+		for k, v := range m {
+			res[k] = clone(v)
+		}
+	}
+	return res
+}
+
+func identity[T any](t T) T { return t }
+
+// orSlice returns a if a is non-empty, and b otherwise.
+func orSlice[S ~[]E, E any](a, b S) S {
+	if len(a) != 0 {
+		return a
+	}
+	return b
 }
 
 func newInternalConfig(cfg *Config) (*internalConfig, error) {
+	return newInternalConfigWithCorpus(cfg, nil)
+}
+
+// newInternalConfigWithCorpus is like newInternalConfig, except that,
+// if corpus is non-nil, the resulting internalConfig uses corpus
+// (which is assumed to be valid and is never mutated)
+// as its set of allowed origins, in which case cfg.Origins must be empty.
+func newInternalConfigWithCorpus(cfg *Config, corpus origins.Corpus) (*internalConfig, error) {
 	if cfg == nil {
 		return nil, nil
 	}
 	icfg := internalConfig{
-		tmp: new(tmpConfig),
+		corpus: corpus,
+		tmp:    new(tmpConfig),
+	}
+	if err := icfg.validateConfig(cfg); err != nil {
+		return nil, err
 	}
+
+	// Identify (potentially dangerous) settings that are not worth
+	// a failure but that users may still want to know about.
+	icfg.warnings = icfg.warn()
+
+	// precompute ACAH if discrete request headers are allowed (without *)
+	if icfg.allowedReqHdrs.Size() != 0 {
+		// The elements of a header-field value may be separated simply by commas;
+		// since whitespace is optional, let's not use any.
+		// See https://httpwg.org/http-core/draft-ietf-httpbis-semantics-latest.html#abnf.extension.recipient
+		icfg.acah = []string{icfg.allowedReqHdrs.String()}
+	}
+
+	// precompute ACEH
+	switch {
+	case icfg.exposeAllResHdrs:
+		icfg.aceh = headers.ValueWildcard
+	case len(icfg.tmp.exposedResHdrs) != 0:
+		icfg.aceh = strings.Join(icfg.tmp.exposedResHdrs, headers.ValueSep)
+	}
+
+	// precompute the Vary value of responses to preflight requests;
+	// see the documentation of ExtraConfig.OriginHeaderNames and
+	// ExtraConfig.RequestMethodHeaderFallback
+	icfg.varyOriginNames = strings.Join(icfg.originHdrs, ", ")
+	icfg.varyPreflightSgl = headers.PreflightVarySgl
+	if icfg.varyOriginNames != headers.Origin || icfg.acrmFallback != "" {
+		vary := headers.ACRH + ", " + headers.ACRM + ", " + headers.ACRPN +
+			", " + icfg.varyOriginNames
+		if icfg.acrmFallback != "" {
+			vary += ", " + icfg.acrmFallback
+		}
+		icfg.varyPreflightSgl = []string{vary}
+	}
+
+	// precompute the Vary value of responses to actual requests
+	icfg.varyActual = icfg.varyOriginNames
+	if icfg.credentialsHeuristic {
+		// See the documentation of ExtraConfig.CredentialsHeuristic.
+		icfg.varyActual += ", " + headers.Cookie + ", " + headers.Authz
+	}
+
+	// tmp is no longer needed; let's make it eligible to GC
+	icfg.tmp = nil
+
+	return &icfg, nil
+}
+
+// validateConfig validates cfg and populates icfg accordingly.
+// Precondition: icfg.tmp is non-nil.
+func (icfg *internalConfig) validateConfig(cfg *Config) error {
 	var errs []error
 
 	// base config
-	if err := icfg.validateOrigins(cfg.Origins); err != nil {
-		errs = append(errs, err)
+	switch {
+	case icfg.corpus == nil:
+		if err := icfg.validateOrigins(cfg.Origins); err != nil {
+			errs = append(errs, err)
+		}
+		if cfg.PreserveOriginOrder {
+			// See the documentation of ExtraConfig.PreserveOriginOrder.
+			icfg.originOrder = union(cfg.Origins, nil)
+		}
+	case len(cfg.Origins) != 0: // see NewMiddlewareFromMatcher
+		const msg = "you cannot specify origin patterns in addition to an origin matcher"
+		errs = append(errs, util.NewError(msg))
+	case cfg.PreserveOriginOrder: // see NewMiddlewareFromMatcher
+		const msg = "you cannot preserve the order of origin patterns " +
+			"when using an origin matcher"
+		errs = append(errs, util.NewError(msg))
 	}
 	icfg.credentialed = cfg.Credentialed
+	// Note: validateMethods and validateOriginMethods depend on headNonSafe.
+	icfg.headNonSafe = cfg.TreatHeadAsNonSafe
 	if err := icfg.validateMethods(cfg.Methods); err != nil {
 		errs = append(errs, err)
 	}
 	if err := icfg.validateRequestHeaders(cfg.RequestHeaders); err != nil {
 		errs = append(errs, err)
+	} else if err := icfg.validateLegacyRequestHeaders(cfg.LegacyAllowedRequestHeaders); err != nil {
+		errs = append(errs, err)
 	}
 	if err := icfg.validateMaxAge(cfg.MaxAgeInSeconds); err != nil {
 		errs = append(errs, err)
 	}
+	// Note: validateResponseHeaders depends on keepSafelistedACEH.
+	icfg.keepSafelistedACEH = cfg.KeepSafelistedExposedHeaders
 	if err := icfg.validateResponseHeaders(cfg.ResponseHeaders); err != nil {
 		errs = append(errs, err)
 	}
@@ -519,8 +2281,101 @@ func newInternalConfig(cfg *Config) (*internalConfig, error) {
 	if err := icfg.validatePreflightStatus(cfg.PreflightSuccessStatus); err != nil {
 		errs = append(errs, err)
 	}
+	if err := icfg.validatePreflightFailureStatus(cfg.PreflightFailureStatus); err != nil {
+		errs = append(errs, err)
+	}
+	if err := icfg.validatePreflightStatusByMethod(cfg.PreflightStatusByMethod); err != nil {
+		errs = append(errs, err)
+	}
+	if err := icfg.validateRejectionMarker(cfg.RejectionMarkerHeader, cfg.RejectionMarkerHeaderName); err != nil {
+		errs = append(errs, err)
+	}
 	icfg.privateNetworkAccess = cfg.PrivateNetworkAccess
 	icfg.privateNetworkAccessNoCors = cfg.PrivateNetworkAccessInNoCORSModeOnly
+	if err := icfg.validateRequestMethodHeaderFallback(cfg.RequestMethodHeaderFallback); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.lenientACRHWhitespace = cfg.LenientACRHTokenWhitespace
+	icfg.sortACAH = cfg.SortAllowHeaders
+	if err := icfg.validateOriginMethods(cfg.OriginMethods); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.credentialsHeuristic = cfg.CredentialsHeuristic
+	if err := icfg.validateCredentialedSchemes(cfg.CredentialedSchemes); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.credPathPredicate = cfg.CredentialedPathPredicate
+	if err := icfg.validateCredentialedWildcardMethods(cfg.CredentialedWildcardMethods); err != nil {
+		errs = append(errs, err)
+	}
+	if err := icfg.validateAdditionalSafelistedMethods(cfg.AdditionalSafelistedMethods); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.alwaysEmitMaxAge = cfg.AlwaysEmitMaxAge
+	icfg.resHdrHook = cfg.ResponseHeaderHook
+	icfg.lowercaseResHdrNames = cfg.LowercaseResponseHeaderNames
+	icfg.canonicalWrites = cfg.UseCanonicalHeaderWrites
+	if err := icfg.validateVaryStrategy(cfg.VaryStrategy); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.omitVaryDisallowed = cfg.OmitVaryForDisallowedOrigins
+	icfg.normalizeIPv4Shorthand = cfg.NormalizeIPv4Shorthand
+	icfg.decodePercentEncodedOrigin = cfg.DecodePercentEncodedOrigin
+	icfg.emptyOriginAsAbsent = cfg.TreatEmptyOriginAsAbsent
+	if err := icfg.validateOriginHeaderNames(cfg.OriginHeaderNames); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.secFetchCheck = cfg.ValidateSecFetchMetadata
+	icfg.reportOnly = cfg.ReportOnly
+	icfg.reportOnlyHook = cfg.ReportOnlyHook
+	if err := icfg.validateDeprecatedOrigins(cfg.DeprecatedOrigins); err != nil {
+		errs = append(errs, err)
+	}
+	if err := icfg.validatePolicyLink(cfg.PolicyLink); err != nil {
+		errs = append(errs, err)
+	}
+	if err := icfg.validateMaxOriginLength(cfg.MaxOriginLength); err != nil {
+		errs = append(errs, err)
+	}
+	if err := icfg.validateMaxDebugACAHBytes(cfg.MaxDebugACAHBytes); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.debugOmitACAC = cfg.DebugOmitCredentialsHeader
+	if err := icfg.validateMaxProcessedHeaderBytes(cfg.MaxProcessedHeaderBytes); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.injectDecision = cfg.InjectDecision
+	icfg.wildcardCoversAuthz = cfg.WildcardCoversAuthorization
+	if icfg.wildcardCoversAuthz && icfg.asteriskReqHdrs {
+		icfg.allowAuthorization = true
+	}
+	if err := icfg.validateBoundedAuthorizationScan(cfg.BoundedAuthorizationScan); err != nil {
+		errs = append(errs, err)
+	}
+	if err := icfg.validateSlowPreflightThreshold(cfg.SlowPreflightThreshold); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.onSlowPreflight = cfg.OnSlowPreflight
+	icfg.observeLatency = cfg.ObserveLatency
+	if err := icfg.validateAllowedContentTypes(cfg.AllowedContentTypesOnPreflight); err != nil {
+		errs = append(errs, err)
+	}
+	icfg.onDisallowedContentType = cfg.OnDisallowedContentType
+	icfg.overrideHandlerCORSHdrs = cfg.OverrideHandlerCORSHeaders
+	icfg.applyAfterHandler = cfg.ApplyAfterHandler
+	icfg.sanitizeReqCORSHdrs = cfg.SanitizeInboundCORSHeaders
+	icfg.reflectACEH = cfg.ReflectExposedResponseHeaders
+	icfg.warnUnusedACEH = cfg.WarnOnUnusedExposedHeaders
+	icfg.onUnusedACEH = cfg.OnUnusedExposedHeaders
+	icfg.webSocketPassthrough = cfg.HandleWebSocketUpgrade
+	icfg.originResolver = cfg.OriginResolver
+	icfg.transformACAO = cfg.TransformAllowedOrigin
+	icfg.publicAnyOrigin = cfg.PublicAnyOriginPredicate
+	icfg.concreteAnyACAO = cfg.ConcreteOriginForAnonymousAllowAll
+	if cfg.AnswerPlainOptions {
+		icfg.plainOptionsAllow = icfg.allowValue()
+	}
+	icfg.suppressNonCORSOptions = cfg.SuppressCORSHeadersOnNonCORSOptions
 	icfg.insecureOrigins = cfg.DangerouslyTolerateInsecureOrigins
 	icfg.subsOfPublicSuffixes = cfg.DangerouslyTolerateSubdomainsOfPublicSuffixes
 
@@ -528,30 +2383,13 @@ func newInternalConfig(cfg *Config) (*internalConfig, error) {
 	if err := icfg.validate(); err != nil {
 		errs = append(errs, err)
 	}
+	// enforce the policy in effect, if any; see SetConfigPolicy
+	// and ForbidDangerousFlags
+	errs = append(errs, icfg.checkPolicy()...)
 	if len(errs) != 0 {
-		return nil, errors.Join(errs...)
-	}
-
-	// precompute ACAH if discrete request headers are allowed (without *)
-	if icfg.allowedReqHdrs.Size() != 0 {
-		// The elements of a header-field value may be separated simply by commas;
-		// since whitespace is optional, let's not use any.
-		// See https://httpwg.org/http-core/draft-ietf-httpbis-semantics-latest.html#abnf.extension.recipient
-		icfg.acah = []string{icfg.allowedReqHdrs.String()}
-	}
-
-	// precompute ACEH
-	switch {
-	case icfg.exposeAllResHdrs:
-		icfg.aceh = headers.ValueWildcard
-	case len(icfg.tmp.exposedResHdrs) != 0:
-		icfg.aceh = strings.Join(icfg.tmp.exposedResHdrs, headers.ValueSep)
+		return errors.Join(errs...)
 	}
-
-	// tmp is no longer needed; let's make it eligible to GC
-	icfg.tmp = nil
-
-	return &icfg, nil
+	return nil
 }
 
 func (icfg *internalConfig) validateOrigins(patterns []string) error {
@@ -559,14 +2397,20 @@ func (icfg *internalConfig) validateOrigins(patterns []string) error {
 		const msg = "at least one origin pattern must be specified"
 		return util.NewError(msg)
 	}
+	// Note: reuse tmp's storage, if any; see ValidateConfigs.
 	var (
-		originPatterns         = make([]origins.Pattern, 0, len(patterns))
-		publicSuffixes         []string
-		insecureOriginPatterns []string
+		originPatterns         = slices.Grow(icfg.tmp.originPatterns, len(patterns))
+		publicSuffixes         = icfg.tmp.publicSuffixes
+		insecureOriginPatterns = icfg.tmp.insecureOriginPatterns
+		secureOriginPatterns   = icfg.tmp.secureOriginPatterns
+		networkAddrPatterns    = icfg.tmp.networkAddrPatterns
 		discreteOrigin         string
 	)
+	if secureOriginPatterns == nil {
+		secureOriginPatterns = make(util.Set[string])
+	}
 	var errs []error
-	for _, raw := range patterns {
+	for _, raw := range expandOriginPatterns(patterns) {
 		if raw == headers.ValueWildcard {
 			icfg.allowAnyOrigin = true
 			continue
@@ -578,6 +2422,11 @@ func (icfg *internalConfig) validateOrigins(patterns []string) error {
 		}
 		if pattern.IsDeemedInsecure() {
 			insecureOriginPatterns = append(insecureOriginPatterns, raw)
+		} else {
+			secureOriginPatterns.Add(raw)
+		}
+		if pattern.LooksLikeNetworkAddress() {
+			networkAddrPatterns = append(networkAddrPatterns, raw)
 		}
 		if pattern.Kind != origins.PatternKindSubdomains && discreteOrigin == "" {
 			discreteOrigin = raw
@@ -594,8 +2443,11 @@ func (icfg *internalConfig) validateOrigins(patterns []string) error {
 		const msg = "specifying origin patterns in addition to * is prohibited"
 		return util.NewError(msg)
 	}
+	icfg.tmp.originPatterns = originPatterns
 	icfg.tmp.insecureOriginPatterns = insecureOriginPatterns
+	icfg.tmp.secureOriginPatterns = secureOriginPatterns
 	icfg.tmp.publicSuffixes = publicSuffixes
+	icfg.tmp.networkAddrPatterns = networkAddrPatterns
 	if len(errs) != 0 {
 		return errors.Join(errs...)
 	}
@@ -610,16 +2462,61 @@ func (icfg *internalConfig) validateOrigins(patterns []string) error {
 	return nil
 }
 
+// expandOriginPatterns replaces, in patterns, each pattern that
+// encompasses both some apex domain and its subdomains
+// (e.g. "https://**.example.com") by two patterns:
+// one that encompasses the subdomains (e.g. "https://*.example.com")
+// and one that encompasses the apex (e.g. "https://example.com").
+func expandOriginPatterns(patterns []string) []string {
+	var res []string // lazily allocated
+	for i, raw := range patterns {
+		subdomains, apex, ok := origins.SplitApexAndSubdomains(raw)
+		if !ok {
+			if res != nil {
+				res = append(res, raw)
+			}
+			continue
+		}
+		if res == nil {
+			res = make([]string, i, len(patterns)+1)
+			copy(res, patterns[:i])
+		}
+		res = append(res, subdomains, apex)
+	}
+	if res == nil {
+		return patterns
+	}
+	return res
+}
+
 func (icfg *internalConfig) validateMethods(names []string) error {
+	allowedMethods, allowAnyMethod, err := newMethodSet(names, icfg.headNonSafe)
+	if err != nil {
+		return err
+	}
+	icfg.allowedMethods = allowedMethods
+	icfg.allowAnyMethod = allowAnyMethod
+	icfg.tmp.onlySafelistedMethods = len(names) > 0 && !allowAnyMethod &&
+		len(allowedMethods) == 0
+	return nil
+}
+
+// newMethodSet validates names and returns the corresponding set of allowed
+// methods (safelisted methods excluded, except HEAD if keepHead is true)
+// and whether the wildcard was specified.
+func newMethodSet(names []string, keepHead bool) (util.Set[string], bool, error) {
 	if len(names) == 0 {
-		return nil
+		return nil, false, nil
 	}
 	sizeHint := len(names) // optimizing for no dupes
 	allowedMethods := make(util.Set[string], sizeHint)
-	var errs []error
+	var (
+		allowAnyMethod bool
+		errs           []error
+	)
 	for _, name := range names {
 		if name == headers.ValueWildcard {
-			icfg.allowAnyMethod = true
+			allowAnyMethod = true
 			continue
 		}
 		if !methods.IsValid(name) {
@@ -634,28 +2531,87 @@ func (icfg *internalConfig) validateMethods(names []string) error {
 		}
 		allowedMethods.Add(name)
 	}
-	if icfg.allowAnyMethod && len(allowedMethods) > 0 {
+	if allowAnyMethod && len(allowedMethods) > 0 {
 		// discard the errors accumulated in errs and return a single error
 		const msg = "specifying methods in addition to * is prohibited"
-		return util.NewError(msg)
+		return nil, false, util.NewError(msg)
 	}
 	// Because safelisted methods need not be explicitly allowed
 	// (see https://stackoverflow.com/a/71429784/2541573),
 	// let's remove them silently.
-	maps.DeleteFunc(allowedMethods, methods.IsSafelisted)
+	maps.DeleteFunc(allowedMethods, func(name string, _ struct{}) bool {
+		// See the documentation of ExtraConfig.TreatHeadAsNonSafe.
+		return methods.IsSafelisted(name, struct{}{}) &&
+			!(keepHead && name == http.MethodHead)
+	})
 	if len(errs) != 0 {
-		return errors.Join(errs...)
+		return nil, false, errors.Join(errs...)
 	}
-	if icfg.allowAnyMethod {
-		return nil
+	if allowAnyMethod {
+		return nil, true, nil
 	}
-	icfg.allowedMethods = allowedMethods
-	return nil
+	return allowedMethods, false, nil
 }
 
-func (icfg *internalConfig) validateRequestHeaders(names []string) error {
-	if len(names) == 0 {
-		return nil
+// allowsAnyMethod reports whether icfg allows all methods,
+// whether for all origins or for some discrete origin only.
+func (icfg *internalConfig) allowsAnyMethod() bool {
+	if icfg.allowAnyMethod {
+		return true
+	}
+	for _, ms := range icfg.originMethods {
+		if ms.allowAny {
+			return true
+		}
+	}
+	return false
+}
+
+// allowValue returns the single-element value of the Allow header
+// with which a CORS middleware configured by icfg answers plain OPTIONS
+// requests; see the documentation of ExtraConfig.AnswerPlainOptions.
+// allowValue disregards the wildcard in method lists, since validate
+// rejects configurations that answer plain OPTIONS requests while allowing
+// all methods (whether for all origins or for some discrete origin only).
+func (icfg *internalConfig) allowValue() []string {
+	names := []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodOptions,
+		http.MethodPost,
+	}
+	sets := []util.Set[string]{icfg.allowedMethods, icfg.extraSafelistedMethods}
+	for _, ms := range icfg.originMethods {
+		sets = append(sets, ms.allowed)
+	}
+	for _, set := range sets {
+		for name := range set {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return []string{strings.Join(names, ", ")}
+}
+
+// methodNames is the inverse of newMethodSet: it returns the sorted names
+// of the methods in allowed, or a single asterisk if allowAny is true;
+// if no methods are allowed, it returns nil.
+func methodNames(allowed util.Set[string], allowAny bool) []string {
+	switch {
+	case allowAny:
+		return []string{headers.ValueWildcard}
+	case len(allowed) > 0:
+		return allowed.ToSortedSlice()
+	default:
+		return nil
+	}
+}
+
+func (icfg *internalConfig) validateRequestHeaders(names []string) error {
+	if len(names) == 0 {
+		return nil
 	}
 	allowedHeaders := make([]string, 0, len(names))
 	var maxLength int
@@ -710,6 +2666,67 @@ func (icfg *internalConfig) validateRequestHeaders(names []string) error {
 	return nil
 }
 
+// Precondition: validateRequestHeaders succeeded.
+func (icfg *internalConfig) validateLegacyRequestHeaders(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if icfg.asteriskReqHdrs {
+		const msg = "specifying legacy request-header names " +
+			"while allowing all request-header names is prohibited"
+		return util.NewError(msg)
+	}
+	legacy := make([]string, 0, len(names))
+	var errs []error
+	for _, name := range names {
+		if !headers.IsValid(name) {
+			err := util.Errorf("invalid legacy request-header name %q", name)
+			errs = append(errs, err)
+			continue
+		}
+		// see the implementation comment in validateRequestHeaders
+		normalized := util.ByteLowercase(name)
+		if headers.IsForbiddenRequestHeaderName(normalized) {
+			err := util.Errorf("forbidden legacy request-header name %q", name)
+			errs = append(errs, err)
+			continue
+		}
+		if headers.IsProhibitedRequestHeaderName(normalized) {
+			err := util.Errorf("prohibited legacy request-header name %q", name)
+			errs = append(errs, err)
+			continue
+		}
+		legacy = append(legacy, normalized)
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	// Names that are also listed in RequestHeaders are not deemed legacy.
+	explicit := icfg.allowedReqHdrs
+	legacy = slices.DeleteFunc(legacy, explicit.Subsumes)
+	if len(legacy) == 0 {
+		return nil
+	}
+	// Note: SortedSet's String method lists explicit's elements in
+	// lowercase, whereas its ToSortedSlice method canonicalizes them.
+	var allowed []string
+	if explicit.Size() != 0 {
+		allowed = strings.Split(explicit.String(), ",")
+	}
+	icfg.allowedReqHdrs = headers.NewSortedSet(slices.Concat(allowed, legacy)...)
+	if slices.Contains(legacy, headers.Authorization) {
+		icfg.allowAuthorization = true
+	}
+	// Store the legacy names in the canonical format in which
+	// *Middleware.Config reports request-header names.
+	for i, name := range legacy {
+		legacy[i] = http.CanonicalHeaderKey(name)
+	}
+	slices.Sort(legacy)
+	icfg.legacyReqHdrs = slices.Compact(legacy)
+	return nil
+}
+
 func (icfg *internalConfig) validateMaxAge(delta int) error {
 	const noPreflightCaching = -1 // sentinel value
 	if delta < noPreflightCaching {
@@ -723,25 +2740,30 @@ func (icfg *internalConfig) validateMaxAge(delta int) error {
 	if delta == 0 { // leave cfg.ACMA at nil
 		return nil
 	}
-	// Current upper bounds:
-	//  - Firefox: 86400 (24h)
-	//  - Chromium: 7200 (2h)
-	//  - WebKit/Safari: 600 (10m)
-	// see https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Access-Control-Max-Age#delta-seconds
-	const upperBound = 86400
+	const upperBound = maxAgeCapFirefox
 	if delta > upperBound {
 		const tmpl = "specified max-age value %d exceeds upper bound %d"
 		return util.Errorf(tmpl, delta, upperBound)
 	}
 	icfg.acma = []string{strconv.Itoa(delta)}
+	icfg.tmp.maxAge = delta
 	return nil
 }
 
+// current caps (in seconds) of browsers on the max-age value;
+// see https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Access-Control-Max-Age#delta-seconds
+const (
+	maxAgeCapFirefox  = 86400 // 24h
+	maxAgeCapChromium = 7200  // 2h
+	maxAgeCapWebKit   = 600   // 10m
+)
+
 func (icfg *internalConfig) validateResponseHeaders(names []string) error {
 	if len(names) == 0 {
 		return nil
 	}
-	exposedHeaders := make([]string, 0, len(names))
+	// Note: reuse tmp's storage, if any; see ValidateConfigs.
+	exposedHeaders := slices.Grow(icfg.tmp.exposedResHdrs, len(names))
 	var errs []error
 	for _, name := range names {
 		if name == headers.ValueWildcard {
@@ -764,7 +2786,7 @@ func (icfg *internalConfig) validateResponseHeaders(names []string) error {
 			errs = append(errs, err)
 			continue
 		}
-		if headers.IsSafelistedResponseHeaderName(normalized) {
+		if headers.IsSafelistedResponseHeaderName(normalized) && !icfg.keepSafelistedACEH {
 			const tmpl = "response-header name %q needs not be explicitly exposed"
 			err := util.Errorf(tmpl, name)
 			errs = append(errs, err)
@@ -802,6 +2824,417 @@ func (icfg *internalConfig) validatePreflightStatus(status int) error {
 
 const defaultPreflightStatus = http.StatusNoContent
 
+func (icfg *internalConfig) validatePreflightFailureStatus(status int) error {
+	if status == 0 {
+		icfg.preflightFailureStatus = defaultPreflightFailureStatus
+		return nil
+	}
+	if !(400 <= status && status < 500) {
+		const tmpl = "specified status %d lies outside the 4xx range"
+		return util.Errorf(tmpl, status)
+	}
+	icfg.preflightFailureStatus = status
+	return nil
+}
+
+const defaultPreflightFailureStatus = http.StatusForbidden
+
+func (icfg *internalConfig) validatePreflightStatusByMethod(m map[string]int) error {
+	if len(m) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, name := range sortedKeys(m) { // for deterministic error messages
+		switch {
+		case name == headers.ValueWildcard:
+			const msg = "the wildcard is prohibited in PreflightStatusByMethod"
+			errs = append(errs, util.NewError(msg))
+			continue
+		case !methods.IsValid(name):
+			const tmpl = "invalid method name %q in PreflightStatusByMethod"
+			errs = append(errs, util.Errorf(tmpl, name))
+			continue
+		case methods.IsForbidden(name):
+			const tmpl = "forbidden method name %q in PreflightStatusByMethod"
+			errs = append(errs, util.Errorf(tmpl, name))
+			continue
+		}
+		// see https://fetch.spec.whatwg.org/#ok-status
+		if status := m[name]; !(200 <= status && status < 300) {
+			const tmpl = "specified status %d for method %q lies outside the 2xx range"
+			errs = append(errs, util.Errorf(tmpl, status, name))
+		}
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	icfg.preflightStatusByMethod = maps.Clone(m)
+	return nil
+}
+
+// defaultRejectionMarker is the default name of the marker header;
+// see the documentation of ExtraConfig.RejectionMarkerHeaderName.
+const defaultRejectionMarker = "X-Cors-Rejected"
+
+func (icfg *internalConfig) validateRejectionMarker(enabled bool, name string) error {
+	if !enabled {
+		if name != "" {
+			const msg = "you cannot specify a rejection-marker header name " +
+				"without also enabling the rejection-marker header"
+			return util.NewError(msg)
+		}
+		return nil
+	}
+	if name == "" {
+		icfg.rejectionMarker = defaultRejectionMarker
+		return nil
+	}
+	if !headers.IsValid(name) {
+		const tmpl = "invalid rejection-marker header name %q"
+		return util.Errorf(tmpl, name)
+	}
+	// Because we write this header to http.Header values,
+	// we need its name in canonical format.
+	name = http.CanonicalHeaderKey(name)
+	if strings.HasPrefix(name, headers.PrefixAccessControl) {
+		const tmpl = "prohibited rejection-marker header name %q"
+		return util.Errorf(tmpl, name)
+	}
+	icfg.rejectionMarker = name
+	return nil
+}
+
+func (icfg *internalConfig) validateMaxOriginLength(n int) error {
+	if n == 0 {
+		icfg.maxOriginLen = origins.MaxLen
+		return nil
+	}
+	if n < 0 || origins.MaxLen < n {
+		const tmpl = "specified max origin length %d lies outside the [1, %d] range"
+		return util.Errorf(tmpl, n, origins.MaxLen)
+	}
+	icfg.maxOriginLen = n
+	return nil
+}
+
+const defaultMaxDebugACAHBytes = 8192
+
+func (icfg *internalConfig) validateMaxDebugACAHBytes(n int) error {
+	if n == 0 {
+		icfg.maxDebugACRH = defaultMaxDebugACAHBytes
+		return nil
+	}
+	if n < 0 {
+		const tmpl = "specified max debug ACAH bytes %d is negative"
+		return util.Errorf(tmpl, n)
+	}
+	icfg.maxDebugACRH = n
+	return nil
+}
+
+func (icfg *internalConfig) validateMaxProcessedHeaderBytes(n int) error {
+	if n < 0 {
+		const tmpl = "specified max processed header bytes %d is negative"
+		return util.Errorf(tmpl, n)
+	}
+	icfg.maxHdrBytes = n
+	return nil
+}
+
+func (icfg *internalConfig) validateRequestMethodHeaderFallback(name string) error {
+	if name == "" {
+		return nil
+	}
+	if !headers.IsValid(name) {
+		const tmpl = "invalid request-method fallback header name %q"
+		return util.Errorf(tmpl, name)
+	}
+	// Because we look this header up in http.Header values,
+	// we need its name in canonical format.
+	name = http.CanonicalHeaderKey(name)
+	if name == headers.Origin || strings.HasPrefix(name, headers.PrefixAccessControl) {
+		const tmpl = "prohibited request-method fallback header name %q"
+		return util.Errorf(tmpl, name)
+	}
+	icfg.acrmFallback = name
+	return nil
+}
+
+func (icfg *internalConfig) validateOriginHeaderNames(names []string) error {
+	if len(names) == 0 {
+		icfg.originHdrs = headers.OriginSgl
+		return nil
+	}
+	originHdrs := make([]string, 0, len(names))
+	var errs []error
+	for _, name := range names {
+		if !headers.IsValid(name) {
+			const tmpl = "invalid origin header name %q"
+			errs = append(errs, util.Errorf(tmpl, name))
+			continue
+		}
+		// Because we look those headers up in http.Header values,
+		// we need their names in canonical format.
+		name = http.CanonicalHeaderKey(name)
+		if strings.HasPrefix(name, headers.PrefixAccessControl) {
+			const tmpl = "prohibited origin header name %q"
+			errs = append(errs, util.Errorf(tmpl, name))
+			continue
+		}
+		if !slices.Contains(originHdrs, name) {
+			originHdrs = append(originHdrs, name)
+		}
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	icfg.originHdrs = originHdrs
+	return nil
+}
+
+func (icfg *internalConfig) validateOriginMethods(m map[string][]string) error {
+	if len(m) == 0 {
+		return nil
+	}
+	originMethods := make(map[string]methodSet, len(m))
+	var errs []error
+	for _, origin := range sortedKeys(m) { // for deterministic error messages
+		if err := icfg.validateOriginKey(origin, "OriginMethods"); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		allowed, allowAny, err := newMethodSet(m[origin], icfg.headNonSafe)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		originMethods[origin] = methodSet{
+			allowed:  allowed,
+			allowAny: allowAny,
+		}
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	icfg.originMethods = originMethods
+	return nil
+}
+
+func (icfg *internalConfig) validateCredentialedSchemes(schemes []string) error {
+	if len(schemes) == 0 {
+		return nil
+	}
+	set := make(util.Set[string], len(schemes))
+	var errs []error
+	for _, scheme := range schemes {
+		if !origins.IsSupportedScheme(scheme) {
+			const tmpl = "invalid scheme %q in CredentialedSchemes"
+			errs = append(errs, util.Errorf(tmpl, scheme))
+			continue
+		}
+		set.Add(scheme)
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	icfg.credentialedSchemes = set
+	return nil
+}
+
+func (icfg *internalConfig) validateCredentialedWildcardMethods(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(util.Set[string], len(names))
+	var errs []error
+	for _, name := range names {
+		switch {
+		case name == headers.ValueWildcard:
+			const msg = "* is prohibited in CredentialedWildcardMethods"
+			errs = append(errs, util.NewError(msg))
+		case !methods.IsValid(name):
+			const tmpl = "invalid method name %q in CredentialedWildcardMethods"
+			errs = append(errs, util.Errorf(tmpl, name))
+		case methods.IsForbidden(name):
+			const tmpl = "forbidden method name %q in CredentialedWildcardMethods"
+			errs = append(errs, util.Errorf(tmpl, name))
+		default:
+			set.Add(name)
+		}
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	// Note that, unlike in validateMethods, safelisted methods are retained:
+	// they're harmless in the ACAM header, and retaining them guarantees
+	// that the resulting header value is never empty.
+	icfg.credWildcardACAM = []string{strings.Join(set.ToSortedSlice(), ",")}
+	return nil
+}
+
+func (icfg *internalConfig) validateAdditionalSafelistedMethods(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(util.Set[string], len(names))
+	var errs []error
+	for _, name := range names {
+		switch {
+		case name == headers.ValueWildcard:
+			const msg = "* is prohibited in AdditionalSafelistedMethods"
+			errs = append(errs, util.NewError(msg))
+		case !methods.IsValid(name):
+			const tmpl = "invalid method name %q in AdditionalSafelistedMethods"
+			errs = append(errs, util.Errorf(tmpl, name))
+		case methods.IsForbidden(name):
+			const tmpl = "forbidden method name %q in AdditionalSafelistedMethods"
+			errs = append(errs, util.Errorf(tmpl, name))
+		case methods.IsSafelisted(name, struct{}{}):
+			// already safelisted; ignore silently
+		default:
+			set.Add(name)
+		}
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	if len(set) > 0 {
+		icfg.extraSafelistedMethods = set
+	}
+	return nil
+}
+
+func (icfg *internalConfig) validateSlowPreflightThreshold(d time.Duration) error {
+	if d < 0 {
+		const tmpl = "specified slow-preflight threshold %v is negative"
+		return util.Errorf(tmpl, d)
+	}
+	icfg.slowPreflightThreshold = d
+	return nil
+}
+
+func (icfg *internalConfig) validateAllowedContentTypes(mediaTypes []string) error {
+	if len(mediaTypes) == 0 {
+		return nil
+	}
+	allowed := make(util.Set[string], len(mediaTypes))
+	var errs []error
+	for _, raw := range mediaTypes {
+		mediaType, params, err := mime.ParseMediaType(raw)
+		if err != nil || len(params) != 0 {
+			err := util.Errorf("invalid content type %q", raw)
+			errs = append(errs, err)
+			continue
+		}
+		if headers.IsSafelistedContentType(mediaType) {
+			const tmpl = "content type %q needs not be explicitly allowed"
+			err := util.Errorf(tmpl, raw)
+			errs = append(errs, err)
+			continue
+		}
+		allowed.Add(mediaType)
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	icfg.allowedContentTypes = allowed
+	return nil
+}
+
+func (icfg *internalConfig) validateBoundedAuthorizationScan(n int) error {
+	if n < 0 {
+		const tmpl = "specified bounded Authorization scan %d is negative"
+		return util.Errorf(tmpl, n)
+	}
+	icfg.authzScanLimit = n
+	return nil
+}
+
+func (icfg *internalConfig) validateVaryStrategy(vs VaryStrategy) error {
+	if vs > VaryFull {
+		const tmpl = "unknown Vary strategy %v"
+		return util.Errorf(tmpl, vs)
+	}
+	icfg.varyStrategy = vs
+	return nil
+}
+
+func (icfg *internalConfig) validateDeprecatedOrigins(m map[string]time.Time) error {
+	if len(m) == 0 {
+		return nil
+	}
+	deprecatedOrigins := make(map[string]deprecation, len(m))
+	var errs []error
+	for _, origin := range sortedKeys(m) { // for deterministic error messages
+		if err := icfg.validateOriginKey(origin, "DeprecatedOrigins"); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sunset := m[origin]
+		if sunset.IsZero() {
+			const tmpl = "zero sunset time for origin %q in DeprecatedOrigins"
+			errs = append(errs, util.Errorf(tmpl, origin))
+			continue
+		}
+		deprecatedOrigins[origin] = deprecation{
+			sunset:    sunset,
+			sunsetSgl: []string{sunset.UTC().Format(http.TimeFormat)},
+		}
+	}
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+	icfg.deprecatedOrigins = deprecatedOrigins
+	return nil
+}
+
+func (icfg *internalConfig) validatePolicyLink(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	// Because the URL gets enclosed in angle brackets in the Link header,
+	// we reject any that contains characters that would break out of them.
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || u.Host == "" ||
+		strings.ContainsAny(rawURL, "<> \t\r\n") {
+		return util.Errorf("invalid policy link %q: not an absolute URL", rawURL)
+	}
+	icfg.policyLink = rawURL
+	icfg.policyLinkSgl = []string{"<" + rawURL + `>; rel="cors-policy"`}
+	return nil
+}
+
+// validateOriginKey checks that origin, a key of the map-typed ExtraConfig
+// field named field, is a discrete origin allowed by Config.Origins.
+func (icfg *internalConfig) validateOriginKey(origin, field string) error {
+	pattern, err := origins.ParsePattern(origin)
+	if err != nil {
+		return err
+	}
+	if !pattern.IsDiscrete() {
+		const tmpl = "origin pattern %q in %s is not a discrete origin"
+		return util.Errorf(tmpl, origin, field)
+	}
+	// Note: icfg.corpus is nil if Config.Origins is invalid;
+	// in that case, we cannot check whether origin is allowed.
+	if o, _ := origins.Parse(origin); icfg.corpus != nil &&
+		!icfg.corpus.Contains(&o) {
+		const tmpl = "origin %q in %s is not allowed by Origins"
+		return util.Errorf(tmpl, origin, field)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in increasing order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
 func (icfg *internalConfig) validate() error {
 	var errs []error
 	pna := icfg.privateNetworkAccess || icfg.privateNetworkAccessNoCors
@@ -819,6 +3252,11 @@ func (icfg *internalConfig) validate() error {
 			errs = append(errs, util.NewError(msg))
 		}
 	}
+	if icfg.concreteAnyACAO != nil && !icfg.allowAnyOrigin {
+		const msg = "you cannot emit a concrete origin for anonymous allow-all " +
+			"without also allowing all origins"
+		errs = append(errs, util.NewError(msg))
+	}
 	if len(icfg.tmp.insecureOriginPatterns) > 0 &&
 		!icfg.insecureOrigins &&
 		(icfg.credentialed || pna) {
@@ -859,6 +3297,95 @@ func (icfg *internalConfig) validate() error {
 		const msg = "at most one form of Private-Network Access can be enabled"
 		errs = append(errs, util.NewError(msg))
 	}
+	if icfg.credentialsHeuristic && !icfg.credentialed {
+		const msg = "you cannot enable the credentials heuristic without " +
+			"also enabling credentialed access"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.credentialedSchemes != nil && !icfg.credentialed {
+		const msg = "you cannot specify credentialed schemes without " +
+			"also enabling credentialed access"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.credPathPredicate != nil && !icfg.credentialed {
+		const msg = "you cannot specify a credentialed-path predicate without " +
+			"also enabling credentialed access"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.debugOmitACAC && !icfg.credentialed {
+		const msg = "you cannot omit the credentials header in debug mode " +
+			"without also enabling credentialed access"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.credWildcardACAM != nil && (!icfg.credentialed || !icfg.allowsAnyMethod()) {
+		const msg = "you cannot specify credentialed wildcard methods without " +
+			"also enabling credentialed access and allowing all methods"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.plainOptionsAllow != nil && icfg.allowsAnyMethod() {
+		const msg = "you cannot answer plain OPTIONS requests " +
+			"while allowing all methods"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.lowercaseResHdrNames && (icfg.overrideHandlerCORSHdrs || icfg.reflectACEH) {
+		const msg = "you cannot lowercase the names of CORS response headers " +
+			"while overriding the handler's CORS headers or " +
+			"reflecting exposed response headers"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.applyAfterHandler && (icfg.overrideHandlerCORSHdrs || icfg.reflectACEH) {
+		const msg = "you cannot apply CORS headers after the handler " +
+			"while overriding the handler's CORS headers or " +
+			"reflecting exposed response headers"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.reflectACEH && !icfg.credentialed {
+		const msg = "you cannot reflect exposed response headers without " +
+			"also enabling credentialed access"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.reflectACEH && (icfg.exposeAllResHdrs || len(icfg.tmp.exposedResHdrs) != 0) {
+		const msg = "you cannot specify response-header names to expose " +
+			"in addition to reflecting exposed response headers"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.warnUnusedACEH && icfg.onUnusedACEH == nil {
+		const msg = "you cannot warn about unused exposed response headers " +
+			"without also specifying OnUnusedExposedHeaders"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.warnUnusedACEH && (icfg.exposeAllResHdrs || len(icfg.tmp.exposedResHdrs) == 0) {
+		const msg = "you cannot warn about unused exposed response headers " +
+			"without also specifying discrete response-header names to expose"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.reportOnlyHook != nil && !icfg.reportOnly {
+		const msg = "you cannot specify a report-only hook without " +
+			"also enabling report-only mode"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.onSlowPreflight != nil && icfg.slowPreflightThreshold <= 0 {
+		const msg = "you cannot specify a slow-preflight hook without " +
+			"also specifying a positive slow-preflight threshold"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.onDisallowedContentType != nil && len(icfg.allowedContentTypes) == 0 {
+		const msg = "you cannot specify a disallowed-content-type hook without " +
+			"also specifying allowed content types"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.wildcardCoversAuthz && !icfg.asteriskReqHdrs {
+		const msg = "you cannot make the wildcard cover Authorization without " +
+			"also allowing all request-header names"
+		errs = append(errs, util.NewError(msg))
+	}
+	if icfg.authzScanLimit > 0 &&
+		(!icfg.asteriskReqHdrs || !icfg.allowAuthorization || icfg.credentialed) {
+		const msg = "you cannot specify a bounded Authorization scan without " +
+			"also allowing all request-header names and Authorization " +
+			"with credentialed access disabled"
+		errs = append(errs, util.NewError(msg))
+	}
 	if icfg.exposeAllResHdrs && icfg.credentialed {
 		const msg = "you cannot both expose all response headers and enable " +
 			"credentialed access"
@@ -870,6 +3397,125 @@ func (icfg *internalConfig) validate() error {
 	return nil
 }
 
+// warn returns warnings about the (valid) configuration represented by icfg.
+// Precondition: icfg.validate returned a nil error.
+func (icfg *internalConfig) warn() []error {
+	var warnings []error
+	pna := icfg.privateNetworkAccess || icfg.privateNetworkAccessNoCors
+	if icfg.reportOnly {
+		const msg = "report-only mode is active: the CORS policy is not enforced"
+		warnings = append(warnings, util.NewError(msg))
+	}
+	if icfg.insecureOrigins && (icfg.credentialed || pna) {
+		const schemeHTTP = "http"
+		for _, raw := range icfg.tmp.insecureOriginPatterns {
+			rest, ok := strings.CutPrefix(raw, schemeHTTP)
+			if !ok {
+				continue
+			}
+			counterpart := schemeHTTP + "s" + rest
+			if !icfg.tmp.secureOriginPatterns.Contains(counterpart) {
+				continue
+			}
+			const tmpl = "insecure origin pattern %q is allowed alongside " +
+				"its secure counterpart %q"
+			warnings = append(warnings, util.Errorf(tmpl, raw, counterpart))
+		}
+	}
+	if icfg.varyStrategy == VaryNone && icfg.mayAllowMultipleOrigins() {
+		const msg = "Vary strategy VaryNone is unsafe for Web caches " +
+			"because more than one origin may be allowed"
+		warnings = append(warnings, util.NewError(msg))
+	}
+	if icfg.tmp.onlySafelistedMethods && len(icfg.originMethods) == 0 {
+		// Users who list only safelisted methods may believe that
+		// they need to, or may have intended to allow other methods.
+		const msg = "Methods lists only CORS-safelisted methods " +
+			"(GET, HEAD, and POST), which are allowed anyway; " +
+			"no other method is allowed"
+		warnings = append(warnings, util.NewError(msg))
+	}
+	if icfg.emitsWildcardACAO() && icfg.exposesNonSafelistedResHdrs() {
+		// Intermediaries may then cache and serve to any origin
+		// responses that expose headers (e.g. session-specific ones)
+		// that the user may have meant for a few origins only.
+		const msg = "exposing response headers other than the CORS-safelisted " +
+			"ones to all origins may have caching implications, because " +
+			"responses to actual requests then don't list Origin in their Vary header"
+		warnings = append(warnings, util.NewError(msg))
+	}
+	if icfg.allowedReqHdrs.Size() != 0 {
+		for _, name := range strings.Split(icfg.allowedReqHdrs.String(), ",") {
+			if !headers.IsSafelistedRequestHeaderName(name) {
+				continue
+			}
+			// Allowing such a request-header name is not a no-op
+			// (some of its values aren't safelisted), but users may not
+			// realize that browsers often omit it from ACRH.
+			const tmpl = "request-header name %q is CORS-safelisted for some values: " +
+				"browsers only list it in Access-Control-Request-Headers " +
+				"when its value is not safelisted"
+			warnings = append(warnings, util.Errorf(tmpl, http.CanonicalHeaderKey(name)))
+		}
+	}
+	for _, name := range icfg.legacyReqHdrs {
+		const tmpl = "legacy request-header name %q is allowed; consider removing it"
+		warnings = append(warnings, util.Errorf(tmpl, name))
+	}
+	for _, raw := range icfg.tmp.networkAddrPatterns {
+		// Origin patterns cannot express IP ranges;
+		// the author of raw may have intended otherwise.
+		const tmpl = "origin pattern %q looks like a network address, " +
+			"but it encompasses only the origin whose host is that very IP address"
+		warnings = append(warnings, util.Errorf(tmpl, raw))
+	}
+	if icfg.tmp.maxAge > maxAgeCapWebKit {
+		const tmpl = "max-age value %d exceeds the cap of some browsers, " +
+			"which silently clamp it (known caps: Firefox %d, Chromium %d, Safari %d)"
+		err := util.Errorf(tmpl, icfg.tmp.maxAge,
+			maxAgeCapFirefox, maxAgeCapChromium, maxAgeCapWebKit)
+		warnings = append(warnings, err)
+	}
+	if len(icfg.allowedContentTypes) > 0 && !icfg.asteriskReqHdrs &&
+		!icfg.allowedReqHdrs.Subsumes("content-type") {
+		// Preflight then fails for all requests whose content type
+		// is not safelisted; the allowed content types are moot.
+		const msg = "allowed content types are specified, " +
+			"but request-header name Content-Type is not allowed"
+		warnings = append(warnings, util.NewError(msg))
+	}
+	return warnings
+}
+
+// exposesNonSafelistedResHdrs reports whether icfg exposes some
+// response headers other than the CORS-safelisted ones.
+// Precondition: icfg.tmp is non-nil.
+func (icfg *internalConfig) exposesNonSafelistedResHdrs() bool {
+	if icfg.exposeAllResHdrs {
+		return true
+	}
+	for _, name := range icfg.tmp.exposedResHdrs {
+		if !headers.IsSafelistedResponseHeaderName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayAllowMultipleOrigins reports whether icfg may allow more than one
+// origin, in which case responses to actual requests depend
+// on the request's origin.
+// Precondition: icfg.tmp is non-nil.
+func (icfg *internalConfig) mayAllowMultipleOrigins() bool {
+	patterns := icfg.tmp.originPatterns
+	if icfg.allowAnyOrigin || len(patterns) != 1 {
+		// Note: an empty list of patterns means that icfg
+		// relies on an origin matcher, whose contents are opaque.
+		return true
+	}
+	return !patterns[0].IsDiscrete()
+}
+
 // newConfig returns a Config on the basis of icfg.
 // The soundness of the result is guaranteed only if icfg is the result of a
 // previous call to newInternalConfig.
@@ -882,9 +3528,12 @@ func newConfig(icfg *internalConfig) *Config {
 	var cfg Config
 
 	// origins
-	if icfg.allowAnyOrigin {
+	switch {
+	case icfg.originOrder != nil:
+		cfg.Origins = slices.Clone(icfg.originOrder)
+	case icfg.allowAnyOrigin:
 		cfg.Origins = []string{"*"}
-	} else {
+	default:
 		cfg.Origins = icfg.corpus.Elems()
 	}
 
@@ -892,21 +3541,34 @@ func newConfig(icfg *internalConfig) *Config {
 	cfg.Credentialed = icfg.credentialed
 
 	// methods
-	switch {
-	case icfg.allowAnyMethod:
-		cfg.Methods = []string{"*"}
-	case len(icfg.allowedMethods) > 0:
-		cfg.Methods = icfg.allowedMethods.ToSortedSlice()
+	cfg.Methods = methodNames(icfg.allowedMethods, icfg.allowAnyMethod)
+
+	if len(icfg.originMethods) > 0 {
+		cfg.OriginMethods = make(map[string][]string, len(icfg.originMethods))
+		for origin, ms := range icfg.originMethods {
+			cfg.OriginMethods[origin] = methodNames(ms.allowed, ms.allowAny)
+		}
 	}
 
 	// request headers
 	switch {
-	case !icfg.credentialed && icfg.asteriskReqHdrs && icfg.allowAuthorization:
+	case !icfg.credentialed && icfg.asteriskReqHdrs && icfg.allowAuthorization &&
+		!icfg.wildcardCoversAuthz:
 		cfg.RequestHeaders = []string{"*", "Authorization"}
 	case icfg.asteriskReqHdrs:
 		cfg.RequestHeaders = []string{"*"}
 	case icfg.allowedReqHdrs.Size() > 0:
 		cfg.RequestHeaders = icfg.allowedReqHdrs.ToSortedSlice()
+		if len(icfg.legacyReqHdrs) > 0 {
+			cfg.RequestHeaders = slices.DeleteFunc(cfg.RequestHeaders, func(name string) bool {
+				_, found := slices.BinarySearch(icfg.legacyReqHdrs, name)
+				return found
+			})
+			if len(cfg.RequestHeaders) == 0 {
+				cfg.RequestHeaders = nil
+			}
+			cfg.ExtraConfig.LegacyAllowedRequestHeaders = slices.Clone(icfg.legacyReqHdrs)
+		}
 	}
 
 	// max age
@@ -932,8 +3594,86 @@ func newConfig(icfg *internalConfig) *Config {
 	if icfg.preflightStatus != defaultPreflightStatus {
 		cfg.ExtraConfig.PreflightSuccessStatus = icfg.preflightStatus
 	}
+	if icfg.preflightFailureStatus != defaultPreflightFailureStatus {
+		cfg.ExtraConfig.PreflightFailureStatus = icfg.preflightFailureStatus
+	}
+	cfg.ExtraConfig.PreflightStatusByMethod = maps.Clone(icfg.preflightStatusByMethod)
+	cfg.ExtraConfig.RejectionMarkerHeader = icfg.rejectionMarker != ""
+	if icfg.rejectionMarker != defaultRejectionMarker {
+		cfg.ExtraConfig.RejectionMarkerHeaderName = icfg.rejectionMarker
+	}
 	cfg.ExtraConfig.PrivateNetworkAccess = icfg.privateNetworkAccess
 	cfg.ExtraConfig.PrivateNetworkAccessInNoCORSModeOnly = icfg.privateNetworkAccessNoCors
+	cfg.ExtraConfig.RequestMethodHeaderFallback = icfg.acrmFallback
+	cfg.ExtraConfig.LenientACRHTokenWhitespace = icfg.lenientACRHWhitespace
+	cfg.ExtraConfig.SortAllowHeaders = icfg.sortACAH
+	cfg.ExtraConfig.CredentialsHeuristic = icfg.credentialsHeuristic
+	if icfg.credentialedSchemes != nil {
+		cfg.ExtraConfig.CredentialedSchemes = icfg.credentialedSchemes.ToSortedSlice()
+	}
+	cfg.ExtraConfig.CredentialedPathPredicate = icfg.credPathPredicate
+	if icfg.credWildcardACAM != nil {
+		cfg.ExtraConfig.CredentialedWildcardMethods = strings.Split(icfg.credWildcardACAM[0], ",")
+	}
+	if icfg.extraSafelistedMethods != nil {
+		cfg.ExtraConfig.AdditionalSafelistedMethods = icfg.extraSafelistedMethods.ToSortedSlice()
+	}
+	cfg.ExtraConfig.TreatHeadAsNonSafe = icfg.headNonSafe
+	cfg.ExtraConfig.AlwaysEmitMaxAge = icfg.alwaysEmitMaxAge
+	cfg.ExtraConfig.ResponseHeaderHook = icfg.resHdrHook
+	cfg.ExtraConfig.LowercaseResponseHeaderNames = icfg.lowercaseResHdrNames
+	cfg.ExtraConfig.UseCanonicalHeaderWrites = icfg.canonicalWrites
+	cfg.ExtraConfig.VaryStrategy = icfg.varyStrategy
+	cfg.ExtraConfig.OmitVaryForDisallowedOrigins = icfg.omitVaryDisallowed
+	cfg.ExtraConfig.NormalizeIPv4Shorthand = icfg.normalizeIPv4Shorthand
+	cfg.ExtraConfig.DecodePercentEncodedOrigin = icfg.decodePercentEncodedOrigin
+	cfg.ExtraConfig.TreatEmptyOriginAsAbsent = icfg.emptyOriginAsAbsent
+	if !slices.Equal(icfg.originHdrs, headers.OriginSgl) {
+		cfg.ExtraConfig.OriginHeaderNames = slices.Clone(icfg.originHdrs)
+	}
+	cfg.ExtraConfig.PreserveOriginOrder = icfg.originOrder != nil
+	cfg.ExtraConfig.ValidateSecFetchMetadata = icfg.secFetchCheck
+	cfg.ExtraConfig.ReportOnly = icfg.reportOnly
+	cfg.ExtraConfig.ReportOnlyHook = icfg.reportOnlyHook
+	if icfg.maxOriginLen != origins.MaxLen {
+		cfg.ExtraConfig.MaxOriginLength = icfg.maxOriginLen
+	}
+	if icfg.maxDebugACRH != defaultMaxDebugACAHBytes {
+		cfg.ExtraConfig.MaxDebugACAHBytes = icfg.maxDebugACRH
+	}
+	cfg.ExtraConfig.DebugOmitCredentialsHeader = icfg.debugOmitACAC
+	cfg.ExtraConfig.MaxProcessedHeaderBytes = icfg.maxHdrBytes
+	if len(icfg.deprecatedOrigins) > 0 {
+		cfg.ExtraConfig.DeprecatedOrigins = make(map[string]time.Time, len(icfg.deprecatedOrigins))
+		for origin, d := range icfg.deprecatedOrigins {
+			cfg.ExtraConfig.DeprecatedOrigins[origin] = d.sunset
+		}
+	}
+	cfg.ExtraConfig.PolicyLink = icfg.policyLink
+	cfg.ExtraConfig.InjectDecision = icfg.injectDecision
+	cfg.ExtraConfig.WildcardCoversAuthorization = icfg.wildcardCoversAuthz
+	cfg.ExtraConfig.BoundedAuthorizationScan = icfg.authzScanLimit
+	cfg.ExtraConfig.SlowPreflightThreshold = icfg.slowPreflightThreshold
+	cfg.ExtraConfig.OnSlowPreflight = icfg.onSlowPreflight
+	cfg.ExtraConfig.ObserveLatency = icfg.observeLatency
+	if len(icfg.allowedContentTypes) > 0 {
+		cfg.ExtraConfig.AllowedContentTypesOnPreflight = icfg.allowedContentTypes.ToSortedSlice()
+	}
+	cfg.ExtraConfig.OnDisallowedContentType = icfg.onDisallowedContentType
+	cfg.ExtraConfig.OverrideHandlerCORSHeaders = icfg.overrideHandlerCORSHdrs
+	cfg.ExtraConfig.ApplyAfterHandler = icfg.applyAfterHandler
+	cfg.ExtraConfig.SanitizeInboundCORSHeaders = icfg.sanitizeReqCORSHdrs
+	cfg.ExtraConfig.ReflectExposedResponseHeaders = icfg.reflectACEH
+	cfg.ExtraConfig.KeepSafelistedExposedHeaders = icfg.keepSafelistedACEH
+	cfg.ExtraConfig.WarnOnUnusedExposedHeaders = icfg.warnUnusedACEH
+	cfg.ExtraConfig.OnUnusedExposedHeaders = icfg.onUnusedACEH
+	cfg.ExtraConfig.HandleWebSocketUpgrade = icfg.webSocketPassthrough
+	cfg.ExtraConfig.OriginResolver = icfg.originResolver
+	cfg.ExtraConfig.TransformAllowedOrigin = icfg.transformACAO
+	cfg.ExtraConfig.PublicAnyOriginPredicate = icfg.publicAnyOrigin
+	cfg.ExtraConfig.ConcreteOriginForAnonymousAllowAll = icfg.concreteAnyACAO
+	cfg.ExtraConfig.AnswerPlainOptions = icfg.plainOptionsAllow != nil
+	cfg.ExtraConfig.SuppressCORSHeadersOnNonCORSOptions = icfg.suppressNonCORSOptions
 	cfg.ExtraConfig.DangerouslyTolerateInsecureOrigins = icfg.insecureOrigins
 	cfg.ExtraConfig.DangerouslyTolerateSubdomainsOfPublicSuffixes = icfg.subsOfPublicSuffixes
 	return &cfg
@@ -0,0 +1,24 @@
+package cors_test
+
+import (
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func BenchmarkValidateConfigs(b *testing.B) {
+	b.Run("NewMiddleware loop", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			for _, cfg := range testConfigs {
+				_, _ = cors.NewMiddleware(cfg)
+			}
+		}
+	})
+	b.Run("ValidateConfigs", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			_ = cors.ValidateConfigs(testConfigs)
+		}
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/jub0bs/cors"
 )
@@ -543,6 +544,659 @@ func TestIncorrectConfig(t *testing.T) {
 			msgs: []string{
 				`cors: you cannot both expose all response headers and enable credentialed access`,
 			},
+		}, {
+			desc: "invalid request-method fallback header name",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RequestMethodHeaderFallback: "résumé",
+				},
+			},
+			msgs: []string{
+				`cors: invalid request-method fallback header name "résumé"`,
+			},
+		}, {
+			desc: "Origin as request-method fallback header",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RequestMethodHeaderFallback: "origin",
+				},
+			},
+			msgs: []string{
+				`cors: prohibited request-method fallback header name "Origin"`,
+			},
+		}, {
+			desc: "ACRM as request-method fallback header",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RequestMethodHeaderFallback: "access-control-request-method",
+				},
+			},
+			msgs: []string{
+				`cors: prohibited request-method fallback header name "Access-Control-Request-Method"`,
+			},
+		}, {
+			desc: "Access-Control-* header as request-method fallback header",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RequestMethodHeaderFallback: "Access-Control-Request-Method-Override",
+				},
+			},
+			msgs: []string{
+				`cors: prohibited request-method fallback header name "Access-Control-Request-Method-Override"`,
+			},
+		}, {
+			desc: "invalid origin methods",
+			cfg: &cors.Config{
+				Origins: []string{
+					"https://example.com",
+					"https://*.example.com",
+				},
+				ExtraConfig: cors.ExtraConfig{
+					OriginMethods: map[string][]string{
+						"https://example.com/":    {http.MethodPut},
+						"https://*.example.com":   {http.MethodPut},
+						"https://example.org":     {http.MethodPut},
+						"https://app.example.com": {http.MethodConnect},
+						"https://api.example.com": {"*", http.MethodPut},
+					},
+				},
+			},
+			msgs: []string{
+				`cors: invalid origin pattern "https://example.com/"`,
+				`cors: origin pattern "https://*.example.com" in OriginMethods is not a discrete origin`,
+				`cors: origin "https://example.org" in OriginMethods is not allowed by Origins`,
+				`cors: forbidden method name "CONNECT"`,
+				`cors: specifying methods in addition to * is prohibited`,
+			},
+		}, {
+			desc: "credentials heuristic without Credentialed",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialsHeuristic: true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot enable the credentials heuristic without also enabling credentialed access`,
+			},
+		}, {
+			desc: "report-only hook without ReportOnly",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ReportOnlyHook: func(string, cors.Reason) {},
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify a report-only hook without also enabling report-only mode`,
+			},
+		}, {
+			desc: "preflight-failure status below the 4xx range",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightFailureStatus: 399,
+				},
+			},
+			msgs: []string{
+				`cors: specified status 399 lies outside the 4xx range`,
+			},
+		}, {
+			desc: "preflight-failure status above the 4xx range",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightFailureStatus: 500,
+				},
+			},
+			msgs: []string{
+				`cors: specified status 500 lies outside the 4xx range`,
+			},
+		}, {
+			desc: "invalid deprecated origins",
+			cfg: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					DeprecatedOrigins: map[string]time.Time{
+						"https://*.example.com":     time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+						"https://example.org":       time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+						"https://app.example.com":   {},
+						"https://example.com/":      time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+						"https://valid.example.com": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			msgs: []string{
+				`cors: origin pattern "https://*.example.com" in DeprecatedOrigins is not a discrete origin`,
+				`cors: origin "https://example.org" in DeprecatedOrigins is not allowed by Origins`,
+				`cors: zero sunset time for origin "https://app.example.com" in DeprecatedOrigins`,
+				`cors: invalid origin pattern "https://example.com/"`,
+			},
+		}, {
+			desc: "apex and subdomains with arbitrary ports",
+			cfg: &cors.Config{
+				Origins: []string{"https://**.example.com:*"},
+			},
+			msgs: []string{
+				`cors: specifying both arbitrary subdomains and arbitrary ports is prohibited: "https://*.example.com:*"`,
+			},
+		}, {
+			desc: "negative max origin length",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxOriginLength: -1,
+				},
+			},
+			msgs: []string{
+				`cors: specified max origin length -1 lies outside the [1, 267] range`,
+			},
+		}, {
+			desc: "max origin length beyond the default",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxOriginLength: 268,
+				},
+			},
+			msgs: []string{
+				`cors: specified max origin length 268 lies outside the [1, 267] range`,
+			},
+		}, {
+			desc: "wildcard covers authorization without wildcard",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					WildcardCoversAuthorization: true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot make the wildcard cover Authorization without also allowing all request-header names`,
+			},
+		}, {
+			desc: "invalid credentialed schemes",
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedSchemes: []string{"https", "ftp", "HTTP"},
+				},
+			},
+			msgs: []string{
+				`cors: invalid scheme "ftp" in CredentialedSchemes`,
+				`cors: invalid scheme "HTTP" in CredentialedSchemes`,
+			},
+		}, {
+			desc: "credentialed schemes without credentialed access",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedSchemes: []string{"https"},
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify credentialed schemes without also enabling credentialed access`,
+			},
+		}, {
+			desc: "negative slow-preflight threshold",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					SlowPreflightThreshold: -time.Second,
+				},
+			},
+			msgs: []string{
+				`cors: specified slow-preflight threshold -1s is negative`,
+			},
+		}, {
+			desc: "slow-preflight hook without threshold",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OnSlowPreflight: func(time.Duration, *http.Request) {},
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify a slow-preflight hook without also specifying a positive slow-preflight threshold`,
+			},
+		}, {
+			desc: "invalid credentialed wildcard methods",
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				Methods:      []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedWildcardMethods: []string{"PUT", "*", "bad method", "CONNECT"},
+				},
+			},
+			msgs: []string{
+				`cors: * is prohibited in CredentialedWildcardMethods`,
+				`cors: invalid method name "bad method" in CredentialedWildcardMethods`,
+				`cors: forbidden method name "CONNECT" in CredentialedWildcardMethods`,
+			},
+		}, {
+			desc: "credentialed wildcard methods without credentialed access",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedWildcardMethods: []string{"PUT"},
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify credentialed wildcard methods without also enabling credentialed access and allowing all methods`,
+			},
+		}, {
+			desc: "credentialed wildcard methods without allowing all methods",
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				Methods:      []string{"PUT"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedWildcardMethods: []string{"PUT"},
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify credentialed wildcard methods without also enabling credentialed access and allowing all methods`,
+			},
+		}, {
+			desc: "CIDR notation in origin patterns",
+			cfg: &cors.Config{
+				Origins: []string{"http://10.0.0.0/8", "http://[2001:db8::]/32"},
+			},
+			msgs: []string{
+				`cors: CIDR notation is unsupported in origin patterns: "http://10.0.0.0/8"`,
+				`cors: CIDR notation is unsupported in origin patterns: "http://[2001:db8::]/32"`,
+			},
+		}, {
+			desc: "answer plain OPTIONS while allowing all methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					AnswerPlainOptions: true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot answer plain OPTIONS requests while allowing all methods`,
+			},
+		}, {
+			desc: "answer plain OPTIONS while allowing all methods for some origin",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginMethods: map[string][]string{
+						"https://example.com": {"*"},
+					},
+					AnswerPlainOptions: true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot answer plain OPTIONS requests while allowing all methods`,
+			},
+		}, {
+			desc: "invalid additional safelisted methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					AdditionalSafelistedMethods: []string{"QUERY", "*", "bad method", "TRACE"},
+				},
+			},
+			msgs: []string{
+				`cors: * is prohibited in AdditionalSafelistedMethods`,
+				`cors: invalid method name "bad method" in AdditionalSafelistedMethods`,
+				`cors: forbidden method name "TRACE" in AdditionalSafelistedMethods`,
+			},
+		}, {
+			desc: "reflect exposed response headers without credentialed access",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ReflectExposedResponseHeaders: true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot reflect exposed response headers without also enabling credentialed access`,
+			},
+		}, {
+			desc: "reflect exposed response headers alongside response headers",
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				Credentialed:    true,
+				ResponseHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					ReflectExposedResponseHeaders: true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify response-header names to expose in addition to reflecting exposed response headers`,
+			},
+		}, {
+			desc: "lowercase response-header names alongside override of handler's CORS headers",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					LowercaseResponseHeaderNames: true,
+					OverrideHandlerCORSHeaders:   true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot lowercase the names of CORS response headers while overriding the handler's CORS headers or reflecting exposed response headers`,
+			},
+		}, {
+			desc: "credentialed-path predicate without credentialed access",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedPathPredicate: func(*http.Request) bool { return true },
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify a credentialed-path predicate without ` +
+					`also enabling credentialed access`,
+			},
+		}, {
+			desc: "warn on unused exposed headers without hook",
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				ResponseHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					WarnOnUnusedExposedHeaders: true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot warn about unused exposed response headers ` +
+					`without also specifying OnUnusedExposedHeaders`,
+			},
+		}, {
+			desc: "warn on unused exposed headers without discrete exposed headers",
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				ResponseHeaders: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					WarnOnUnusedExposedHeaders: true,
+					OnUnusedExposedHeaders:     func([]string, *http.Request) {},
+				},
+			},
+			msgs: []string{
+				`cors: you cannot warn about unused exposed response headers ` +
+					`without also specifying discrete response-header names to expose`,
+			},
+		}, {
+			desc: "negative max debug ACAH bytes",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxDebugACAHBytes: -1,
+				},
+			},
+			msgs: []string{
+				`cors: specified max debug ACAH bytes -1 is negative`,
+			},
+		}, {
+			desc: "invalid policy link",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PolicyLink: "/cors-policy",
+				},
+			},
+			msgs: []string{
+				`cors: invalid policy link "/cors-policy": not an absolute URL`,
+			},
+		}, {
+			desc: "apply after handler alongside override of handler's CORS headers",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OverrideHandlerCORSHeaders: true,
+					ApplyAfterHandler:          true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot apply CORS headers after the handler while overriding the handler's CORS headers or reflecting exposed response headers`,
+			},
+		}, {
+			desc: "unknown Vary strategy",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					VaryStrategy: cors.VaryStrategy(42),
+				},
+			},
+			msgs: []string{
+				`cors: unknown Vary strategy VaryStrategy(42)`,
+			},
+		}, {
+			desc: "negative bounded Authorization scan",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"*", "Authorization"},
+				ExtraConfig: cors.ExtraConfig{
+					BoundedAuthorizationScan: -1,
+				},
+			},
+			msgs: []string{
+				`cors: specified bounded Authorization scan -1 is negative`,
+			},
+		}, {
+			desc: "bounded Authorization scan without Authorization",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					BoundedAuthorizationScan: 256,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify a bounded Authorization scan without ` +
+					`also allowing all request-header names and Authorization ` +
+					`with credentialed access disabled`,
+			},
+		}, {
+			desc: "bounded Authorization scan with credentialed access",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				Credentialed:   true,
+				RequestHeaders: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					BoundedAuthorizationScan: 256,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify a bounded Authorization scan without ` +
+					`also allowing all request-header names and Authorization ` +
+					`with credentialed access disabled`,
+			},
+		}, {
+			desc: "invalid preflight status by method",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightStatusByMethod: map[string]int{
+						"*":              200,
+						"CONNECT":        200,
+						"not a method":   200,
+						http.MethodPut:   301,
+						http.MethodPatch: 200,
+					},
+				},
+			},
+			msgs: []string{
+				`cors: the wildcard is prohibited in PreflightStatusByMethod`,
+				`cors: forbidden method name "CONNECT" in PreflightStatusByMethod`,
+				`cors: invalid method name "not a method" in PreflightStatusByMethod`,
+				`cors: specified status 301 for method "PUT" lies outside the 2xx range`,
+			},
+		}, {
+			desc: "invalid origin header name",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginHeaderNames: []string{"x forwarded origin"},
+				},
+			},
+			msgs: []string{
+				`cors: invalid origin header name "x forwarded origin"`,
+			},
+		}, {
+			desc: "prohibited origin header name",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginHeaderNames: []string{"access-control-request-method"},
+				},
+			},
+			msgs: []string{
+				`cors: prohibited origin header name "Access-Control-Request-Method"`,
+			},
+		}, {
+			desc: "invalid and prohibited origin header names",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginHeaderNames: []string{
+						"X-Forwarded-Origin",
+						"x original origin",
+						"Access-Control-Allow-Origin",
+					},
+				},
+			},
+			msgs: []string{
+				`cors: invalid origin header name "x original origin"`,
+				`cors: prohibited origin header name "Access-Control-Allow-Origin"`,
+			},
+		}, {
+			desc: "debug omit credentials header without Credentialed",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					DebugOmitCredentialsHeader: true,
+				},
+			},
+			msgs: []string{
+				`cors: you cannot omit the credentials header in debug mode without also enabling credentialed access`,
+			},
+		}, {
+			desc: "invalid legacy request headers",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{
+						"x legacy",
+						"Connection",
+						"Access-Control-Allow-Origin",
+					},
+				},
+			},
+			msgs: []string{
+				`cors: invalid legacy request-header name "x legacy"`,
+				`cors: forbidden legacy request-header name "Connection"`,
+				`cors: prohibited legacy request-header name "Access-Control-Allow-Origin"`,
+			},
+		}, {
+			desc: "legacy request headers alongside all request headers",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{"X-Legacy"},
+				},
+			},
+			msgs: []string{
+				`cors: specifying legacy request-header names while allowing all request-header names is prohibited`,
+			},
+		}, {
+			desc: "rejection marker header name without rejection marker header",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RejectionMarkerHeaderName: "X-Rejected",
+				},
+			},
+			msgs: []string{
+				"cors: you cannot specify a rejection-marker header name " +
+					"without also enabling the rejection-marker header",
+			},
+		}, {
+			desc: "invalid rejection marker header name",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RejectionMarkerHeader:     true,
+					RejectionMarkerHeaderName: "x rejected",
+				},
+			},
+			msgs: []string{
+				`cors: invalid rejection-marker header name "x rejected"`,
+			},
+		}, {
+			desc: "prohibited rejection marker header name",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RejectionMarkerHeader:     true,
+					RejectionMarkerHeaderName: "access-control-rejected",
+				},
+			},
+			msgs: []string{
+				`cors: prohibited rejection-marker header name "Access-Control-Rejected"`,
+			},
+		}, {
+			desc: "negative max processed header bytes",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxProcessedHeaderBytes: -1,
+				},
+			},
+			msgs: []string{
+				"cors: specified max processed header bytes -1 is negative",
+			},
+		}, {
+			desc: "invalid allowed content types",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					AllowedContentTypesOnPreflight: []string{
+						"application/",
+						"application/json; charset=utf-8",
+						"Text/Plain",
+					},
+				},
+			},
+			msgs: []string{
+				`cors: invalid content type "application/"`,
+				`cors: invalid content type "application/json; charset=utf-8"`,
+				`cors: content type "Text/Plain" needs not be explicitly allowed`,
+			},
+		}, {
+			desc: "disallowed-content-type hook without allowed content types",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OnDisallowedContentType: func(string, *http.Request) {},
+				},
+			},
+			msgs: []string{
+				`cors: you cannot specify a disallowed-content-type hook without also specifying allowed content types`,
+			},
+		}, {
+			desc: "concrete origin for anonymous allow all without allowing all origins",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ConcreteOriginForAnonymousAllowAll: func(*http.Request) bool { return true },
+				},
+			},
+			msgs: []string{
+				`cors: you cannot emit a concrete origin for anonymous allow-all without also allowing all origins`,
+			},
 		}, {
 			desc: "multiple configuration issues",
 			cfg: &cors.Config{
@@ -595,6 +1249,262 @@ func TestIncorrectConfig(t *testing.T) {
 	}
 }
 
+// testConfigs is a mix of valid and invalid configurations,
+// in an order apt to reveal state leaking from one validation to the next.
+var testConfigs = []cors.Config{
+	{
+		Origins:         []string{"http://example.com", "https://*.example.com"},
+		Credentialed:    true,
+		ResponseHeaders: []string{"X-Foo", "X-Bar"},
+	}, {
+		Origins:      []string{"https://example.com"},
+		Credentialed: true,
+	}, {
+		Origins:         []string{"*"},
+		ResponseHeaders: []string{"*"},
+	}, {
+		Origins: []string{"https://*.com"},
+	}, {
+		Origins:        []string{"https://example.com", "http://example.com"},
+		RequestHeaders: []string{"Content-Type"},
+	}, {
+		Origins:         []string{"https://example.com/", "*"},
+		ResponseHeaders: []string{"X-Foo", "*"},
+	}, {}, {
+		Origins:         []string{"https://example.com"},
+		ResponseHeaders: []string{"X-Foo"},
+	},
+}
+
+func TestSimpleConfig(t *testing.T) {
+	origins := []string{"https://example.com", "https://*.example.com"}
+	cfg := cors.SimpleConfig(origins...)
+	origins[0] = "https://example.org" // must not affect cfg
+	want := cors.Config{
+		Origins:         []string{"https://example.com", "https://*.example.com"},
+		ResponseHeaders: []string{"ETag", "Link", "Location", "Retry-After"},
+	}
+	assertConfigEqual(t, &cfg, &want)
+	if _, err := cors.NewMiddleware(cfg); err != nil {
+		t.Errorf("got error %v; want nil error", err)
+	}
+	if _, err := cors.NewMiddleware(cors.SimpleConfig()); err == nil {
+		t.Error("got nil error; want some non-nil error")
+	}
+}
+
+func TestValidateConfigs(t *testing.T) {
+	errs := cors.ValidateConfigs(testConfigs)
+	if len(errs) != len(testConfigs) {
+		t.Fatalf("got %d error(s); want %d", len(errs), len(testConfigs))
+	}
+	for i, cfg := range testConfigs {
+		_, want := cors.NewMiddleware(cfg)
+		switch got := errs[i]; {
+		case got == nil && want == nil:
+		case got == nil || want == nil || got.Error() != want.Error():
+			t.Errorf("config #%d: got error %v; want %v", i, got, want)
+		}
+	}
+}
+
+func TestRequireSecureOrigins(t *testing.T) {
+	cases := []struct {
+		desc    string
+		origins []string
+		errMsg  string
+	}{
+		{
+			desc:    "secure origins",
+			origins: []string{"https://example.com", "https://**.example.org"},
+		}, {
+			desc: "localhost and loopback IP addresses",
+			origins: []string{
+				"http://localhost:3000",
+				"http://127.0.0.1:*",
+				"http://[::1]:9090",
+			},
+		}, {
+			desc:    "invalid origin patterns are ignored",
+			origins: []string{"http://example.com/"},
+		}, {
+			desc: "insecure origins",
+			origins: []string{
+				"https://example.com",
+				"http://example.com",
+				"http://**.example.org:8080",
+			},
+			errMsg: `cors: the following origin patterns are insecure: ` +
+				`"http://example.com", "http://*.example.org:8080", ` +
+				`and "http://example.org:8080"`,
+		}, {
+			desc:    "all origins",
+			origins: []string{"*"},
+			errMsg:  `cors: the following origin patterns are insecure: "*"`,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			err := cors.RequireSecureOrigins(cors.Config{Origins: tc.origins})
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Errorf("got error %v; want nil error", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("got error %v; want %q", err, tc.errMsg)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestMergeConfigs(t *testing.T) {
+	sunset := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	later := sunset.AddDate(0, 1, 0)
+	base := cors.Config{
+		Origins:         []string{"https://example.com", "https://*.example.com"},
+		Methods:         []string{http.MethodPut},
+		RequestHeaders:  []string{"X-Foo"},
+		MaxAgeInSeconds: 30,
+		ExtraConfig: cors.ExtraConfig{
+			PreflightSuccessStatus: 200,
+			OriginMethods: map[string][]string{
+				"https://example.com": {http.MethodDelete},
+			},
+			DeprecatedOrigins: map[string]time.Time{
+				"https://example.com": sunset,
+			},
+			ResponseHeaderHook:             func(http.Header) {},
+			ReportOnly:                     true,
+			AllowedContentTypesOnPreflight: []string{"application/json"},
+		},
+	}
+	override := cors.Config{
+		Origins:         []string{"https://example.org", "https://example.com"},
+		Credentialed:    true,
+		Methods:         []string{http.MethodPatch},
+		MaxAgeInSeconds: 60,
+		ResponseHeaders: []string{"X-Bar"},
+		ExtraConfig: cors.ExtraConfig{
+			OriginMethods: map[string][]string{
+				"https://example.com": {http.MethodPost},
+				"https://example.org": {http.MethodPut},
+			},
+			DeprecatedOrigins: map[string]time.Time{
+				"https://example.com": later,
+			},
+			CredentialedSchemes:            []string{"https"},
+			AllowedContentTypesOnPreflight: []string{"application/cbor", "application/json"},
+		},
+	}
+	want := &cors.Config{
+		Origins: []string{
+			"https://example.com",
+			"https://*.example.com",
+			"https://example.org",
+		},
+		Credentialed:    true,
+		Methods:         []string{http.MethodPut, http.MethodPatch},
+		RequestHeaders:  []string{"X-Foo"},
+		MaxAgeInSeconds: 60,
+		ResponseHeaders: []string{"X-Bar"},
+		ExtraConfig: cors.ExtraConfig{
+			PreflightSuccessStatus: 200,
+			OriginMethods: map[string][]string{
+				"https://example.com": {http.MethodPost},
+				"https://example.org": {http.MethodPut},
+			},
+			DeprecatedOrigins: map[string]time.Time{
+				"https://example.com": later,
+			},
+			CredentialedSchemes: []string{"https"},
+			ResponseHeaderHook:  func(http.Header) {},
+			ReportOnly:          true,
+			AllowedContentTypesOnPreflight: []string{
+				"application/json",
+				"application/cbor",
+			},
+		},
+	}
+	got := cors.MergeConfigs(base, override)
+	assertConfigEqual(t, &got, want)
+
+	// The result must not share mutable state with the inputs.
+	override.Origins[0] = "https://example.net"
+	override.OriginMethods["https://example.org"][0] = http.MethodGet
+	override.CredentialedSchemes[0] = "http"
+	override.AllowedContentTypesOnPreflight[0] = "text/csv"
+	assertConfigEqual(t, &got, want)
+}
+
+// TestMergeConfigsCoversAllFields guards against the addition of
+// configuration fields that MergeConfigs would silently ignore.
+func TestMergeConfigsCoversAllFields(t *testing.T) {
+	var override cors.Config
+	fill(t, reflect.ValueOf(&override).Elem())
+	cfgs := map[string]cors.Config{
+		"override": cors.MergeConfigs(cors.Config{}, override),
+		"base":     cors.MergeConfigs(override, cors.Config{}),
+	}
+	for desc, cfg := range cfgs {
+		assertNoZeroFields(t, desc, reflect.ValueOf(cfg))
+	}
+}
+
+// fill sets each exported field of struct v to some non-zero value.
+func fill(t *testing.T, v reflect.Value) {
+	t.Helper()
+	for i := range v.NumField() {
+		field, sf := v.Field(i), v.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Struct:
+			fill(t, field)
+		case reflect.Bool:
+			field.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			field.SetInt(1)
+		case reflect.Uint8:
+			field.SetUint(1)
+		case reflect.String:
+			field.SetString("x")
+		case reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 1, 1))
+		case reflect.Map:
+			m := reflect.MakeMap(field.Type())
+			m.SetMapIndex(reflect.ValueOf("x"), reflect.Zero(field.Type().Elem()))
+			field.Set(m)
+		case reflect.Func:
+			field.Set(reflect.MakeFunc(field.Type(), func([]reflect.Value) []reflect.Value {
+				return nil
+			}))
+		default:
+			t.Fatalf("field %s: unsupported kind %v", sf.Name, field.Kind())
+		}
+	}
+}
+
+func assertNoZeroFields(t *testing.T, desc string, v reflect.Value) {
+	t.Helper()
+	for i := range v.NumField() {
+		field, sf := v.Field(i), v.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if field.Kind() == reflect.Struct {
+			assertNoZeroFields(t, desc, field)
+			continue
+		}
+		if field.IsZero() {
+			t.Errorf("%s: field %s was not merged", desc, sf.Name)
+		}
+	}
+}
+
 func flatten(err error) []string {
 	return flattenRec(err, nil)
 }
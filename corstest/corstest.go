@@ -0,0 +1,99 @@
+// Package corstest provides utilities for testing code that relies on
+// the CORS middleware provided by package [github.com/jub0bs/cors].
+package corstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"slices"
+	"strings"
+
+	"github.com/jub0bs/cors/internal/headers"
+)
+
+// CountManagedHeaders tallies the occurrences, in resp,
+// of the response headers that CORS middleware manage,
+// so that you can assert that the response that your fully composed stack
+// (reverse proxies, routers, middleware, handlers) produces
+// contains no accidental duplicates.
+//
+// The keys of the result are the following:
+//   - the (canonical) name of each CORS response header
+//     (e.g. Access-Control-Allow-Origin),
+//     whose associated value is the number of field lines by that name;
+//   - "Vary: " followed by the (canonical) name of each element of
+//     the Vary header's value (e.g. "Vary: Origin"),
+//     whose associated value is the number of occurrences of that element
+//     across all Vary field lines.
+//
+// Because a CORS middleware never emits those response headers
+// (or Vary elements) more than once, a value greater than 1 in the result
+// indicates interference from some other component in your stack.
+// CountManagedHeaders never returns a nil map.
+func CountManagedHeaders(resp http.Header) map[string]int {
+	counts := make(map[string]int)
+	for name, values := range resp {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		if strings.HasPrefix(name, headers.PrefixAccessControl) {
+			counts[name] += len(values)
+			continue
+		}
+		if name != headers.Vary {
+			continue
+		}
+		for _, value := range values {
+			for _, elem := range strings.Split(value, ",") {
+				elem = strings.TrimSpace(elem)
+				if elem == "" {
+					continue
+				}
+				if elem != headers.ValueWildcard {
+					elem = textproto.CanonicalMIMEHeaderKey(elem)
+				}
+				counts[headers.Vary+": "+elem]++
+			}
+		}
+	}
+	return counts
+}
+
+// target is the URL of the requests that NewPreflightRequest and
+// NewActualRequest return; CORS middleware are oblivious to it.
+const target = "https://example.com/"
+
+// NewPreflightRequest returns a [CORS-preflight request], suitable for
+// passing to a [http.Handler] (e.g. in benchmarks or profiling sessions),
+// from origin for method and, if acrh is non-empty, for request headers acrh.
+// Each element of acrh results in a separate
+// Access-Control-Request-Headers field line; therefore, you can exercise
+// your stack with adversarial inputs (e.g. many field lines),
+// and, to obtain a single field line that lists multiple header names
+// (as browsers send), you can specify a single comma-separated element:
+//
+//	corstest.NewPreflightRequest("https://example.com", "PUT", []string{"authorization,x-foo"})
+//
+// Note that browsers list header names in lowercase, in lexicographical
+// order, and without whitespace; the result uses acrh's elements verbatim.
+//
+// [CORS-preflight request]: https://fetch.spec.whatwg.org/#cors-preflight-request
+func NewPreflightRequest(origin, method string, acrh []string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, target, nil)
+	req.Header[headers.Origin] = []string{origin}
+	req.Header[headers.ACRM] = []string{method}
+	if len(acrh) != 0 {
+		req.Header[headers.ACRH] = slices.Clone(acrh)
+	}
+	return req
+}
+
+// NewActualRequest returns an actual (i.e. non-preflight) [CORS request],
+// suitable for passing to a [http.Handler] (e.g. in benchmarks or
+// profiling sessions), from origin and with method method.
+//
+// [CORS request]: https://fetch.spec.whatwg.org/#cors-request
+func NewActualRequest(origin, method string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header[headers.Origin] = []string{origin}
+	return req
+}
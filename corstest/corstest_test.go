@@ -0,0 +1,143 @@
+package corstest_test
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/jub0bs/cors"
+	"github.com/jub0bs/cors/corstest"
+)
+
+func TestCountManagedHeaders(t *testing.T) {
+	cases := []struct {
+		desc string
+		resp http.Header
+		want map[string]int
+	}{
+		{
+			desc: "nil",
+			want: map[string]int{},
+		}, {
+			desc: "no managed headers",
+			resp: http.Header{
+				"Content-Type": {"text/plain"},
+			},
+			want: map[string]int{},
+		}, {
+			desc: "duplicates",
+			resp: http.Header{
+				"Access-Control-Allow-Origin":  {"https://example.com", "*"},
+				"access-control-allow-methods": {"PUT"},
+				"Vary": {
+					"origin, Access-Control-Request-Method",
+					"Origin,,accept-encoding",
+					"*",
+				},
+			},
+			want: map[string]int{
+				"Access-Control-Allow-Origin":         2,
+				"Access-Control-Allow-Methods":        1,
+				"Vary: Origin":                        2,
+				"Vary: Access-Control-Request-Method": 1,
+				"Vary: Accept-Encoding":               1,
+				"Vary: *":                             1,
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			got := corstest.CountManagedHeaders(tc.resp)
+			if !maps.Equal(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestCountManagedHeadersOfMiddlewareOutput(t *testing.T) {
+	cfg := cors.Config{
+		Origins:         []string{"https://example.com"},
+		Credentialed:    true,
+		Methods:         []string{http.MethodPut},
+		RequestHeaders:  []string{"X-Foo"},
+		ResponseHeaders: []string{"X-Bar"},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	handler := mw.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}))
+	reqs := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "https://example.org", nil),
+		httptest.NewRequest(http.MethodOptions, "https://example.org", nil),
+	}
+	for _, req := range reqs {
+		req.Header.Set("Origin", "https://example.com")
+	}
+	reqs[1].Header.Set("Access-Control-Request-Method", http.MethodPut)
+	reqs[1].Header.Set("Access-Control-Request-Headers", "x-foo")
+	for _, req := range reqs {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		counts := corstest.CountManagedHeaders(rec.Header())
+		if len(counts) == 0 {
+			t.Errorf("%s: no managed headers", req.Method)
+		}
+		for name, n := range counts {
+			if n != 1 {
+				t.Errorf("%s: got %d occurrences of %q; want 1", req.Method, n, name)
+			}
+		}
+	}
+}
+
+func TestNewRequests(t *testing.T) {
+	cfg := cors.Config{
+		Origins:        []string{"https://example.com"},
+		Methods:        []string{http.MethodPut},
+		RequestHeaders: []string{"X-Foo", "X-Bar"},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	handler := mw.Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	acrh := []string{"x-bar", "x-foo"} // two field lines
+	req := corstest.NewPreflightRequest("https://example.com", http.MethodPut, acrh)
+	if req.Method != http.MethodOptions {
+		t.Errorf("preflight: got method %q; want %q", req.Method, http.MethodOptions)
+	}
+	if got := req.Header["Access-Control-Request-Headers"]; !slices.Equal(got, acrh) {
+		t.Errorf("preflight: got ACRH %q; want %q", got, acrh)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusNoContent; got != want {
+		t.Errorf("preflight: got status %d; want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Access-Control-Allow-Methods"), http.MethodPut; got != want {
+		t.Errorf("preflight: got ACAM %q; want %q", got, want)
+	}
+
+	req = corstest.NewPreflightRequest("https://example.com", http.MethodPut, nil)
+	if _, found := req.Header["Access-Control-Request-Headers"]; found {
+		t.Error("preflight without ACRH: got ACRH; want none")
+	}
+
+	req = corstest.NewActualRequest("https://example.com", http.MethodPut)
+	if req.Method != http.MethodPut {
+		t.Errorf("actual: got method %q; want %q", req.Method, http.MethodPut)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got, want := rec.Header().Get("Access-Control-Allow-Origin"), "https://example.com"; got != want {
+		t.Errorf("actual: got ACAO %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package cors
+
+import (
+	"context"
+
+	"github.com/jub0bs/cors/internal/headers"
+	"github.com/jub0bs/cors/internal/origins"
+)
+
+// A Decision describes the outcome of the CORS check that a middleware
+// performed on some actual (i.e. non-preflight) CORS request.
+// See the documentation of the ExtraConfig.InjectDecision field.
+type Decision struct {
+	// Origin is the request's origin, as evaluated by the middleware.
+	Origin string
+	// Allowed indicates whether the middleware's configuration
+	// allows Origin. Note that, in report-only mode, a middleware may let
+	// a request through even though its origin is not allowed.
+	Allowed bool
+	// MatchedPattern is the textual form of the origin pattern that
+	// matched Origin, in the normalized form in which
+	// [*Middleware.Config] reports allowed origins
+	// (e.g. https://*.example.com), or "*" if all origins are allowed.
+	// MatchedPattern is empty if Allowed is false.
+	MatchedPattern string
+}
+
+type decisionKey struct{}
+
+// DecisionFromContext returns the Decision that a CORS middleware
+// stored in ctx (if any) and reports whether such a Decision was found.
+// A CORS middleware only stores a Decision in the context of the requests
+// it lets through if its ExtraConfig.InjectDecision field is set.
+func DecisionFromContext(ctx context.Context) (Decision, bool) {
+	d, ok := ctx.Value(decisionKey{}).(Decision)
+	return d, ok
+}
+
+func withDecision(ctx context.Context, d Decision) context.Context {
+	return context.WithValue(ctx, decisionKey{}, d)
+}
+
+// decide re-evaluates origin against icfg and describes the outcome.
+// Unlike icfg.originIsAllowed, it also determines the pattern that matched,
+// which is costlier; therefore, it should only be used if
+// icfg.injectDecision is set.
+func (icfg *internalConfig) decide(origin string) Decision {
+	d := Decision{Origin: origin}
+	if icfg.allowAnyOrigin {
+		d.Allowed = true
+		d.MatchedPattern = headers.ValueWildcard
+		return d
+	}
+	if len(origin) > icfg.maxOriginLen { // fail fast
+		return d
+	}
+	o, ok := origins.Parse(origin)
+	if !ok {
+		return d
+	}
+	d.MatchedPattern, d.Allowed = icfg.corpus.ContainsWhich(&o)
+	return d
+}
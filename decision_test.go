@@ -0,0 +1,161 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestDecisionFromContext(t *testing.T) {
+	cases := []struct {
+		desc    string
+		cfg     cors.Config
+		origin  string
+		want    cors.Decision
+		wantOK  bool
+		noCORS  bool
+		options bool
+	}{
+		{
+			desc: "injection disabled",
+			cfg: cors.Config{
+				Origins: []string{"https://*.example.com"},
+			},
+			origin: "https://a.example.com",
+		}, {
+			desc: "subdomain pattern matched",
+			cfg: cors.Config{
+				Origins: []string{"https://example.com", "https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					InjectDecision: true,
+				},
+			},
+			origin: "https://a.example.com",
+			want: cors.Decision{
+				Origin:         "https://a.example.com",
+				Allowed:        true,
+				MatchedPattern: "https://*.example.com",
+			},
+			wantOK: true,
+		}, {
+			desc: "discrete origin matched",
+			cfg: cors.Config{
+				Origins: []string{"https://example.com", "https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					InjectDecision: true,
+				},
+			},
+			origin: "https://example.com",
+			want: cors.Decision{
+				Origin:         "https://example.com",
+				Allowed:        true,
+				MatchedPattern: "https://example.com",
+			},
+			wantOK: true,
+		}, {
+			desc: "arbitrary port matched",
+			cfg: cors.Config{
+				Origins: []string{"http://localhost:*"},
+				ExtraConfig: cors.ExtraConfig{
+					InjectDecision: true,
+				},
+			},
+			origin: "http://localhost:9090",
+			want: cors.Decision{
+				Origin:         "http://localhost:9090",
+				Allowed:        true,
+				MatchedPattern: "http://localhost:*",
+			},
+			wantOK: true,
+		}, {
+			desc: "all origins allowed",
+			cfg: cors.Config{
+				Origins: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					InjectDecision: true,
+				},
+			},
+			origin: "https://example.org",
+			want: cors.Decision{
+				Origin:         "https://example.org",
+				Allowed:        true,
+				MatchedPattern: "*",
+			},
+			wantOK: true,
+		}, {
+			desc: "disallowed origin",
+			cfg: cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					InjectDecision: true,
+				},
+			},
+			origin: "https://example.org",
+			want: cors.Decision{
+				Origin: "https://example.org",
+			},
+			wantOK: true,
+		}, {
+			desc: "non-CORS request",
+			cfg: cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					InjectDecision: true,
+				},
+			},
+			noCORS: true,
+		}, {
+			desc: "preflight request",
+			cfg: cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					InjectDecision: true,
+				},
+			},
+			origin:  "https://a.example.com",
+			options: true,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			mw, err := cors.NewMiddleware(tc.cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			var (
+				got    cors.Decision
+				gotOK  bool
+				called bool
+			)
+			h := func(_ http.ResponseWriter, r *http.Request) {
+				called = true
+				got, gotOK = cors.DecisionFromContext(r.Context())
+			}
+			hdrs := Headers{}
+			if !tc.noCORS {
+				hdrs[headerOrigin] = tc.origin
+			}
+			method := http.MethodGet
+			if tc.options {
+				method = http.MethodOptions
+				hdrs[headerACRM] = http.MethodGet
+			}
+			req := newRequest(method, hdrs)
+			mw.Wrap(http.HandlerFunc(h)).ServeHTTP(httptest.NewRecorder(), req)
+			if tc.options {
+				if called {
+					t.Error("wrapped handler unexpectedly called")
+				}
+				return
+			}
+			if gotOK != tc.wantOK || got != tc.want {
+				const tmpl = "DecisionFromContext: got %+v, %t; want %+v, %t"
+				t.Errorf(tmpl, got, gotOK, tc.want, tc.wantOK)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
@@ -24,6 +24,8 @@ and the ultimate handler. Follow the rules listed below:
     [SHOULD NOT] take place "ahead of" a CORS middleware
     (e.g. in a reverse proxy or in some middleware further up the chain).
     However, a CORS middleware [MAY] wrap an authentication middleware.
+    If you cannot reorder your middleware chain accordingly,
+    see [PreflightBypass].
   - The [CORS response headers] that are set by this library's middleware
     [MUST NOT] be altered; moreover, additional CORS response headers
     [MUST NOT] be included in responses.
@@ -0,0 +1,149 @@
+package cors
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jub0bs/cors/internal/util"
+)
+
+const (
+	dsnEntrySep    = ';'
+	dsnEscape      = '\\'
+	dsnKeyValueSep = "="
+	dsnEnvPrefix   = "CORS_"
+	dsnMaxAgeAlias = "max_age" // shorthand for max_age_in_seconds
+)
+
+// ParseConfigDSN builds a Config from s, a compact, DSN-like string
+// that is convenient for command-line tools and simple deployments:
+//
+//	origins=https://example.com,https://*.example.org;credentialed=true;methods=PUT;max_age=30
+//
+// The grammar of s is as follows:
+//
+//	dsn   = [ entry *( ";" entry ) ]
+//	entry = key "=" value
+//
+// Each key is the name of one of the environment variables listed in
+// the documentation of [*Middleware.ConfigAsEnv], stripped of
+// its CORS_ prefix (e.g. max_age_in_seconds for CORS_MAX_AGE_IN_SECONDS);
+// max_age is accepted as a shorthand for max_age_in_seconds.
+// Keys are case-insensitive, and whitespace around them is ignored,
+// as are empty entries.
+// Each value obeys the same syntax as the corresponding environment variable;
+// in particular, list-valued settings are comma-separated.
+//
+// Within a value, a semicolon must be escaped by a backslash (\;),
+// and so must a backslash (\\); no other escape sequences are valid.
+// For instance:
+//
+//	origins=https://example.com;preflight_status_by_method=PUT=201\;DELETE=200
+//
+// Commas cannot be escaped and invariably separate list elements;
+// however, no element (e.g. origin pattern, method, or header name)
+// of a valid list-valued setting contains a comma anyway.
+//
+// ParseConfigDSN returns some non-nil error if s is malformed
+// (e.g. if some entry lacks an equal sign or contains an invalid escape
+// sequence), if s specifies some unknown key or the same setting twice,
+// or if some value is syntactically invalid (e.g. a non-numeric max-age value).
+// Like [ConfigFromEnv], ParseConfigDSN only reports syntactic errors;
+// the validity of the resulting Config is only checked
+// when you pass it to [NewMiddleware] or [*Middleware.Reconfigure].
+func ParseConfigDSN(s string) (Config, error) {
+	entries, err := parseDSN(s)
+	if err != nil {
+		return Config{}, err
+	}
+	looked := make(util.Set[string], len(entries))
+	getenv := func(name string) string {
+		looked.Add(name)
+		return entries[name].value
+	}
+	cfg, err := configFromEnv(getenv, invalidDSNErr)
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, name := range sortedKeys(entries) { // for deterministic error messages
+		if !looked.Contains(name) {
+			const tmpl = "unknown DSN key %q"
+			errs = append(errs, util.Errorf(tmpl, entries[name].key))
+		}
+	}
+	if len(errs) != 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// A dsnEntry is an entry of a DSN; see ParseConfigDSN.
+type dsnEntry struct {
+	key   string // as specified
+	value string // unescaped
+}
+
+// parseDSN splits s into entries, which it returns keyed by
+// the names of the corresponding environment variables.
+func parseDSN(s string) (map[string]dsnEntry, error) {
+	var (
+		parts []string
+		sb    strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case dsnEscape:
+			if i+1 == len(s) || (s[i+1] != dsnEntrySep && s[i+1] != dsnEscape) {
+				const tmpl = "invalid escape sequence at offset %d in DSN"
+				return nil, util.Errorf(tmpl, i)
+			}
+			i++
+			sb.WriteByte(s[i])
+		case dsnEntrySep:
+			parts = append(parts, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	parts = append(parts, sb.String())
+	entries := make(map[string]dsnEntry, len(parts))
+	var errs []error
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, found := strings.Cut(part, dsnKeyValueSep)
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			errs = append(errs, util.Errorf("malformed DSN entry %q", part))
+			continue
+		}
+		name := dsnEnvName(key)
+		if _, found := entries[name]; found {
+			errs = append(errs, util.Errorf("duplicate DSN key %q", key))
+			continue
+		}
+		entries[name] = dsnEntry{key: key, value: value}
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return entries, nil
+}
+
+// dsnEnvName returns the name of the environment variable
+// that corresponds to DSN key key.
+func dsnEnvName(key string) string {
+	key = strings.ToLower(key)
+	if key == dsnMaxAgeAlias {
+		return envMaxAgeInSeconds
+	}
+	return dsnEnvPrefix + strings.ToUpper(key)
+}
+
+func invalidDSNErr(name, value string) error {
+	key := strings.ToLower(strings.TrimPrefix(name, dsnEnvPrefix))
+	return util.Errorf("invalid value %q for DSN key %s", value, key)
+}
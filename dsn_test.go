@@ -0,0 +1,170 @@
+package cors_test
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestParseConfigDSN(t *testing.T) {
+	cases := []struct {
+		desc string
+		dsn  string
+		want cors.Config
+	}{
+		{
+			desc: "empty",
+		}, {
+			desc: "base config",
+			dsn:  "origins=https://a.com,https://*.b.com;credentialed=true;methods=GET,POST;max_age=30",
+			want: cors.Config{
+				Origins:         []string{"https://a.com", "https://*.b.com"},
+				Credentialed:    true,
+				Methods:         []string{http.MethodGet, http.MethodPost},
+				MaxAgeInSeconds: 30,
+			},
+		}, {
+			desc: "whitespace, case, and empty entries",
+			dsn:  " Origins = https://example.com ;;MAX_AGE_IN_SECONDS=-1;",
+			want: cors.Config{
+				Origins:         []string{"https://example.com"},
+				MaxAgeInSeconds: -1,
+			},
+		}, {
+			desc: "escaped semicolons",
+			dsn:  `origins=https://example.com;preflight_status_by_method=PUT=201\;DELETE=200`,
+			want: cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightStatusByMethod: map[string]int{
+						http.MethodPut:    201,
+						http.MethodDelete: 200,
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			got, err := cors.ParseConfigDSN(tc.dsn)
+			if err != nil {
+				t.Fatalf("got error %v; want nil error", err)
+			}
+			assertConfigEqual(t, &got, &tc.want)
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestParseConfigDSNRoundTrip(t *testing.T) {
+	cfg := cors.Config{
+		Origins:         []string{"https://example.com", "https://*.example.org"},
+		Credentialed:    true,
+		Methods:         []string{http.MethodPut, http.MethodDelete},
+		RequestHeaders:  []string{"X-Foo"},
+		MaxAgeInSeconds: 30,
+		ExtraConfig: cors.ExtraConfig{
+			PreflightStatusByMethod: map[string]int{
+				http.MethodPut:    201,
+				http.MethodDelete: 200,
+			},
+			OriginMethods: map[string][]string{
+				"https://example.com":     {http.MethodPatch, http.MethodPut},
+				"https://foo.example.org": {http.MethodDelete},
+			},
+			DeprecatedOrigins: map[string]time.Time{
+				"https://example.com": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			},
+			VaryStrategy: cors.VaryOriginOnly,
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	env := mw.ConfigAsEnv()
+	escaper := strings.NewReplacer(`\`, `\\`, `;`, `\;`)
+	var entries []string
+	for k, v := range env {
+		key := strings.ToLower(strings.TrimPrefix(k, "CORS_"))
+		entries = append(entries, key+"="+escaper.Replace(v))
+	}
+	slices.Sort(entries)
+	dsn := strings.Join(entries, ";")
+	if !strings.Contains(dsn, `\;`) {
+		t.Fatalf("%q: want some escaped semicolon", dsn)
+	}
+	got, err := cors.ParseConfigDSN(dsn)
+	if err != nil {
+		t.Fatalf("%q: got error %v; want nil error", dsn, err)
+	}
+	mw2, err := cors.NewMiddleware(got)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	assertConfigEqual(t, mw2.Config(), mw.Config())
+}
+
+func TestParseConfigDSNWithInvalidInput(t *testing.T) {
+	cases := []struct {
+		desc string
+		dsn  string
+		want []string
+	}{
+		{
+			desc: "dangling backslash",
+			dsn:  `origins=https://example.com\`,
+			want: []string{
+				`cors: invalid escape sequence at offset 27 in DSN`,
+			},
+		}, {
+			desc: "invalid escape sequence",
+			dsn:  `origins=https://example.com\,https://example.org`,
+			want: []string{
+				`cors: invalid escape sequence at offset 27 in DSN`,
+			},
+		}, {
+			desc: "malformed entries",
+			dsn:  "origins;=true;credentialed=true;credentialed=false;max_age_in_seconds=1;max_age=2",
+			want: []string{
+				`cors: malformed DSN entry "origins"`,
+				`cors: malformed DSN entry "=true"`,
+				`cors: duplicate DSN key "credentialed"`,
+				`cors: duplicate DSN key "max_age"`,
+			},
+		}, {
+			desc: "unknown keys and invalid values",
+			dsn:  "origins=https://example.com;credentialed=yes;max_age=thirty;origin=https://example.org;foo=bar",
+			want: []string{
+				`cors: invalid value "yes" for DSN key credentialed`,
+				`cors: invalid value "thirty" for DSN key max_age_in_seconds`,
+				`cors: unknown DSN key "foo"`,
+				`cors: unknown DSN key "origin"`,
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			_, err := cors.ParseConfigDSN(tc.dsn)
+			if err == nil {
+				t.Fatal("got nil error; want non-nil error")
+			}
+			msgs := flatten(err)
+			sort.Strings(msgs)
+			want := slices.Clone(tc.want)
+			sort.Strings(want)
+			if res, same := diff(msgs, want); !same {
+				t.Error("unexpected error message(s):")
+				for _, s := range res {
+					t.Logf("\t%s", s)
+				}
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
@@ -0,0 +1,420 @@
+package cors
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jub0bs/cors/internal/util"
+)
+
+// names of the environment variables used by ConfigAsEnv and ConfigFromEnv
+const (
+	envOrigins              = "CORS_ORIGINS"
+	envCredentialed         = "CORS_CREDENTIALED"
+	envMethods              = "CORS_METHODS"
+	envRequestHeaders       = "CORS_REQUEST_HEADERS"
+	envMaxAgeInSeconds      = "CORS_MAX_AGE_IN_SECONDS"
+	envResponseHeaders      = "CORS_RESPONSE_HEADERS"
+	envPreflightStatus      = "CORS_PREFLIGHT_SUCCESS_STATUS"
+	envPreflightFailure     = "CORS_PREFLIGHT_FAILURE_STATUS"
+	envStatusByMethod       = "CORS_PREFLIGHT_STATUS_BY_METHOD"
+	envRejectionMarker      = "CORS_REJECTION_MARKER_HEADER"
+	envRejectionMarkerName  = "CORS_REJECTION_MARKER_HEADER_NAME"
+	envPNA                  = "CORS_PRIVATE_NETWORK_ACCESS"
+	envPNANoCORS            = "CORS_PRIVATE_NETWORK_ACCESS_IN_NO_CORS_MODE_ONLY"
+	envACRMFallback         = "CORS_REQUEST_METHOD_HEADER_FALLBACK"
+	envLenientACRH          = "CORS_LENIENT_ACRH_TOKEN_WHITESPACE"
+	envSortACAH             = "CORS_SORT_ALLOW_HEADERS"
+	envLegacyReqHeaders     = "CORS_LEGACY_ALLOWED_REQUEST_HEADERS"
+	envOriginMethods        = "CORS_ORIGIN_METHODS"
+	envCredentialsHeuristic = "CORS_CREDENTIALS_HEURISTIC"
+	envCredentialedSchemes  = "CORS_CREDENTIALED_SCHEMES"
+	envCredWildcardMethods  = "CORS_CREDENTIALED_WILDCARD_METHODS"
+	envExtraSafelisted      = "CORS_ADDITIONAL_SAFELISTED_METHODS"
+	envHeadNonSafe          = "CORS_TREAT_HEAD_AS_NON_SAFE"
+	envAlwaysEmitMaxAge     = "CORS_ALWAYS_EMIT_MAX_AGE"
+	envLowercaseResHdrNames = "CORS_LOWERCASE_RESPONSE_HEADER_NAMES"
+	envCanonicalWrites      = "CORS_USE_CANONICAL_HEADER_WRITES"
+	envVaryStrategy         = "CORS_VARY_STRATEGY"
+	envOmitVaryDisallowed   = "CORS_OMIT_VARY_FOR_DISALLOWED_ORIGINS"
+	envNormalizeIPv4        = "CORS_NORMALIZE_IPV4_SHORTHAND"
+	envDecodePercentOrigin  = "CORS_DECODE_PERCENT_ENCODED_ORIGIN"
+	envEmptyOriginAsAbsent  = "CORS_TREAT_EMPTY_ORIGIN_AS_ABSENT"
+	envOriginHeaderNames    = "CORS_ORIGIN_HEADER_NAMES"
+	envPreserveOriginOrder  = "CORS_PRESERVE_ORIGIN_ORDER"
+	envSecFetchMetadata     = "CORS_VALIDATE_SEC_FETCH_METADATA"
+	envReportOnly           = "CORS_REPORT_ONLY"
+	envDeprecatedOrigins    = "CORS_DEPRECATED_ORIGINS"
+	envPolicyLink           = "CORS_POLICY_LINK"
+	envMaxOriginLength      = "CORS_MAX_ORIGIN_LENGTH"
+	envMaxDebugACAHBytes    = "CORS_MAX_DEBUG_ACAH_BYTES"
+	envDebugOmitACAC        = "CORS_DEBUG_OMIT_CREDENTIALS_HEADER"
+	envMaxProcessedHdrBytes = "CORS_MAX_PROCESSED_HEADER_BYTES"
+	envInjectDecision       = "CORS_INJECT_DECISION"
+	envWildcardCoversAuthz  = "CORS_WILDCARD_COVERS_AUTHORIZATION"
+	envBoundedAuthzScan     = "CORS_BOUNDED_AUTHORIZATION_SCAN"
+	envSlowPreflight        = "CORS_SLOW_PREFLIGHT_THRESHOLD"
+	envAllowedContentTypes  = "CORS_ALLOWED_CONTENT_TYPES_ON_PREFLIGHT"
+	envOverrideHandlerCORS  = "CORS_OVERRIDE_HANDLER_CORS_HEADERS"
+	envApplyAfterHandler    = "CORS_APPLY_AFTER_HANDLER"
+	envSanitizeInboundCORS  = "CORS_SANITIZE_INBOUND_CORS_HEADERS"
+	envReflectExposed       = "CORS_REFLECT_EXPOSED_RESPONSE_HEADERS"
+	envKeepSafelisted       = "CORS_KEEP_SAFELISTED_EXPOSED_HEADERS"
+	envWebSocketUpgrade     = "CORS_HANDLE_WEBSOCKET_UPGRADE"
+	envAnswerPlainOptions   = "CORS_ANSWER_PLAIN_OPTIONS"
+	envSuppressNonCORSOpts  = "CORS_SUPPRESS_CORS_HEADERS_ON_NON_CORS_OPTIONS"
+	envInsecureOrigins      = "CORS_DANGEROUSLY_TOLERATE_INSECURE_ORIGINS"
+	envSubsOfPublicSuffixes = "CORS_DANGEROUSLY_TOLERATE_SUBDOMAINS_OF_PUBLIC_SUFFIXES"
+)
+
+const (
+	envListSep     = ","
+	envEntrySep    = ";"
+	envKeyValueSep = "="
+	envValueTrue   = "true"
+)
+
+// ConfigAsEnv returns m's current configuration as a set of
+// environment-variable assignments (e.g. CORS_ORIGINS=https://example.com),
+// which [ConfigFromEnv] can parse back;
+// if m is a passthrough middleware, it simply returns nil.
+//
+// List-valued settings are represented as comma-separated values.
+// The value of CORS_PREFLIGHT_STATUS_BY_METHOD is a semicolon-separated
+// list of entries of the form method=status.
+// The value of CORS_ORIGIN_METHODS is a semicolon-separated list
+// of entries of the form origin=method1,method2.
+// The value of CORS_DEPRECATED_ORIGINS is a semicolon-separated list
+// of entries of the form origin=sunset, where sunset is
+// in [time.RFC3339] format.
+// The value of CORS_VARY_STRATEGY is the name of a VaryStrategy constant
+// (e.g. VaryOriginOnly).
+// The value of CORS_SLOW_PREFLIGHT_THRESHOLD is in the format
+// accepted by [time.ParseDuration].
+// Settings that have their zero value are omitted from the result.
+// Func-valued settings (e.g. ResponseHeaderHook) cannot be represented
+// as environment variables and are therefore omitted from the result,
+// as are the settings that are useless without them
+// (i.e. WarnOnUnusedExposedHeaders).
+//
+// Mutating the result does not alter m's behavior.
+func (m *Middleware) ConfigAsEnv() map[string]string {
+	cfg := m.Config()
+	if cfg == nil {
+		return nil
+	}
+	return configToEnv(cfg)
+}
+
+// configToEnv returns the representation of cfg as environment variables
+// described in the documentation of [*Middleware.ConfigAsEnv].
+// For the result to be canonical, cfg should be the result of newConfig.
+func configToEnv(cfg *Config) map[string]string {
+	env := make(map[string]string)
+	setEnvList(env, envOrigins, cfg.Origins)
+	setEnvBool(env, envCredentialed, cfg.Credentialed)
+	setEnvList(env, envMethods, cfg.Methods)
+	setEnvList(env, envRequestHeaders, cfg.RequestHeaders)
+	setEnvInt(env, envMaxAgeInSeconds, cfg.MaxAgeInSeconds)
+	setEnvList(env, envResponseHeaders, cfg.ResponseHeaders)
+	setEnvInt(env, envPreflightStatus, cfg.PreflightSuccessStatus)
+	setEnvInt(env, envPreflightFailure, cfg.PreflightFailureStatus)
+	if len(cfg.PreflightStatusByMethod) > 0 {
+		var sb strings.Builder
+		for i, method := range sortedKeys(cfg.PreflightStatusByMethod) {
+			if i > 0 {
+				sb.WriteString(envEntrySep)
+			}
+			sb.WriteString(method)
+			sb.WriteString(envKeyValueSep)
+			sb.WriteString(strconv.Itoa(cfg.PreflightStatusByMethod[method]))
+		}
+		env[envStatusByMethod] = sb.String()
+	}
+	setEnvBool(env, envRejectionMarker, cfg.RejectionMarkerHeader)
+	if cfg.RejectionMarkerHeaderName != "" {
+		env[envRejectionMarkerName] = cfg.RejectionMarkerHeaderName
+	}
+	setEnvBool(env, envPNA, cfg.PrivateNetworkAccess)
+	setEnvBool(env, envPNANoCORS, cfg.PrivateNetworkAccessInNoCORSModeOnly)
+	if cfg.RequestMethodHeaderFallback != "" {
+		env[envACRMFallback] = cfg.RequestMethodHeaderFallback
+	}
+	setEnvBool(env, envLenientACRH, cfg.LenientACRHTokenWhitespace)
+	setEnvBool(env, envSortACAH, cfg.SortAllowHeaders)
+	setEnvList(env, envLegacyReqHeaders, cfg.LegacyAllowedRequestHeaders)
+	if len(cfg.OriginMethods) > 0 {
+		var sb strings.Builder
+		for i, origin := range sortedKeys(cfg.OriginMethods) {
+			if i > 0 {
+				sb.WriteString(envEntrySep)
+			}
+			sb.WriteString(origin)
+			sb.WriteString(envKeyValueSep)
+			sb.WriteString(strings.Join(cfg.OriginMethods[origin], envListSep))
+		}
+		env[envOriginMethods] = sb.String()
+	}
+	setEnvBool(env, envCredentialsHeuristic, cfg.CredentialsHeuristic)
+	setEnvList(env, envCredentialedSchemes, cfg.CredentialedSchemes)
+	setEnvList(env, envCredWildcardMethods, cfg.CredentialedWildcardMethods)
+	setEnvList(env, envExtraSafelisted, cfg.AdditionalSafelistedMethods)
+	setEnvBool(env, envHeadNonSafe, cfg.TreatHeadAsNonSafe)
+	setEnvBool(env, envAlwaysEmitMaxAge, cfg.AlwaysEmitMaxAge)
+	setEnvBool(env, envLowercaseResHdrNames, cfg.LowercaseResponseHeaderNames)
+	setEnvBool(env, envCanonicalWrites, cfg.UseCanonicalHeaderWrites)
+	if cfg.VaryStrategy != VaryDefault {
+		env[envVaryStrategy] = cfg.VaryStrategy.String()
+	}
+	setEnvBool(env, envOmitVaryDisallowed, cfg.OmitVaryForDisallowedOrigins)
+	setEnvBool(env, envNormalizeIPv4, cfg.NormalizeIPv4Shorthand)
+	setEnvBool(env, envDecodePercentOrigin, cfg.DecodePercentEncodedOrigin)
+	setEnvBool(env, envEmptyOriginAsAbsent, cfg.TreatEmptyOriginAsAbsent)
+	setEnvList(env, envOriginHeaderNames, cfg.OriginHeaderNames)
+	setEnvBool(env, envPreserveOriginOrder, cfg.PreserveOriginOrder)
+	setEnvBool(env, envSecFetchMetadata, cfg.ValidateSecFetchMetadata)
+	setEnvBool(env, envReportOnly, cfg.ReportOnly)
+	if len(cfg.DeprecatedOrigins) > 0 {
+		var sb strings.Builder
+		for i, origin := range sortedKeys(cfg.DeprecatedOrigins) {
+			if i > 0 {
+				sb.WriteString(envEntrySep)
+			}
+			sb.WriteString(origin)
+			sb.WriteString(envKeyValueSep)
+			sb.WriteString(cfg.DeprecatedOrigins[origin].Format(time.RFC3339))
+		}
+		env[envDeprecatedOrigins] = sb.String()
+	}
+	if cfg.PolicyLink != "" {
+		env[envPolicyLink] = cfg.PolicyLink
+	}
+	setEnvInt(env, envMaxOriginLength, cfg.MaxOriginLength)
+	setEnvInt(env, envMaxDebugACAHBytes, cfg.MaxDebugACAHBytes)
+	setEnvBool(env, envDebugOmitACAC, cfg.DebugOmitCredentialsHeader)
+	setEnvInt(env, envMaxProcessedHdrBytes, cfg.MaxProcessedHeaderBytes)
+	setEnvBool(env, envInjectDecision, cfg.InjectDecision)
+	setEnvBool(env, envWildcardCoversAuthz, cfg.WildcardCoversAuthorization)
+	setEnvInt(env, envBoundedAuthzScan, cfg.BoundedAuthorizationScan)
+	if cfg.SlowPreflightThreshold != 0 {
+		env[envSlowPreflight] = cfg.SlowPreflightThreshold.String()
+	}
+	setEnvList(env, envAllowedContentTypes, cfg.AllowedContentTypesOnPreflight)
+	setEnvBool(env, envOverrideHandlerCORS, cfg.OverrideHandlerCORSHeaders)
+	setEnvBool(env, envApplyAfterHandler, cfg.ApplyAfterHandler)
+	setEnvBool(env, envSanitizeInboundCORS, cfg.SanitizeInboundCORSHeaders)
+	setEnvBool(env, envReflectExposed, cfg.ReflectExposedResponseHeaders)
+	setEnvBool(env, envKeepSafelisted, cfg.KeepSafelistedExposedHeaders)
+	setEnvBool(env, envWebSocketUpgrade, cfg.HandleWebSocketUpgrade)
+	setEnvBool(env, envAnswerPlainOptions, cfg.AnswerPlainOptions)
+	setEnvBool(env, envSuppressNonCORSOpts, cfg.SuppressCORSHeadersOnNonCORSOptions)
+	setEnvBool(env, envInsecureOrigins, cfg.DangerouslyTolerateInsecureOrigins)
+	setEnvBool(env, envSubsOfPublicSuffixes, cfg.DangerouslyTolerateSubdomainsOfPublicSuffixes)
+	return env
+}
+
+func setEnvList(env map[string]string, key string, values []string) {
+	if len(values) > 0 {
+		env[key] = strings.Join(values, envListSep)
+	}
+}
+
+func setEnvBool(env map[string]string, key string, b bool) {
+	if b {
+		env[key] = envValueTrue
+	}
+}
+
+func setEnvInt(env map[string]string, key string, i int) {
+	if i != 0 {
+		env[key] = strconv.Itoa(i)
+	}
+}
+
+// ConfigFromEnv builds a Config from the environment variables
+// listed in the documentation of [*Middleware.ConfigAsEnv],
+// which it looks up via getenv (typically [os.Getenv]).
+// Unset or empty variables leave the corresponding settings at their
+// zero value.
+//
+// ConfigFromEnv only reports syntactic errors (e.g. a non-numeric max-age
+// value); the validity of the resulting Config is only checked
+// when you pass it to [NewMiddleware] or [*Middleware.Reconfigure].
+//
+// For any valid Config, say cfg,
+// the following round trip yields a Config equivalent to cfg
+// (func-valued settings and the settings that depend on them
+// notwithstanding):
+//
+//	env := mw.ConfigAsEnv()
+//	cfg, err := cors.ConfigFromEnv(func(k string) string { return env[k] })
+func ConfigFromEnv(getenv func(string) string) (Config, error) {
+	return configFromEnv(getenv, invalidEnvErr)
+}
+
+// configFromEnv implements ConfigFromEnv; it reports each invalid value
+// of a variable named key via invalid(key, value).
+func configFromEnv(
+	getenv func(string) string,
+	invalid func(key, value string) error,
+) (Config, error) {
+	var (
+		cfg  Config
+		errs []error
+	)
+	boolVar := func(dst *bool, key string) {
+		v := getenv(key)
+		if v == "" {
+			return
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, invalid(key, v))
+			return
+		}
+		*dst = b
+	}
+	intVar := func(dst *int, key string) {
+		v := getenv(key)
+		if v == "" {
+			return
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, invalid(key, v))
+			return
+		}
+		*dst = i
+	}
+	cfg.Origins = splitEnvList(getenv(envOrigins))
+	boolVar(&cfg.Credentialed, envCredentialed)
+	cfg.Methods = splitEnvList(getenv(envMethods))
+	cfg.RequestHeaders = splitEnvList(getenv(envRequestHeaders))
+	intVar(&cfg.MaxAgeInSeconds, envMaxAgeInSeconds)
+	cfg.ResponseHeaders = splitEnvList(getenv(envResponseHeaders))
+	intVar(&cfg.PreflightSuccessStatus, envPreflightStatus)
+	intVar(&cfg.PreflightFailureStatus, envPreflightFailure)
+	if v := getenv(envStatusByMethod); v != "" {
+		cfg.PreflightStatusByMethod = make(map[string]int)
+		for _, entry := range strings.Split(v, envEntrySep) {
+			method, status, found := strings.Cut(entry, envKeyValueSep)
+			method = strings.TrimSpace(method)
+			i, err := strconv.Atoi(strings.TrimSpace(status))
+			if !found || method == "" || err != nil {
+				errs = append(errs, invalid(envStatusByMethod, v))
+				break
+			}
+			cfg.PreflightStatusByMethod[method] = i
+		}
+	}
+	boolVar(&cfg.RejectionMarkerHeader, envRejectionMarker)
+	cfg.RejectionMarkerHeaderName = strings.TrimSpace(getenv(envRejectionMarkerName))
+	boolVar(&cfg.PrivateNetworkAccess, envPNA)
+	boolVar(&cfg.PrivateNetworkAccessInNoCORSModeOnly, envPNANoCORS)
+	cfg.RequestMethodHeaderFallback = strings.TrimSpace(getenv(envACRMFallback))
+	boolVar(&cfg.LenientACRHTokenWhitespace, envLenientACRH)
+	boolVar(&cfg.SortAllowHeaders, envSortACAH)
+	cfg.LegacyAllowedRequestHeaders = splitEnvList(getenv(envLegacyReqHeaders))
+	if v := getenv(envOriginMethods); v != "" {
+		cfg.OriginMethods = make(map[string][]string)
+		for _, entry := range strings.Split(v, envEntrySep) {
+			origin, methods, found := strings.Cut(entry, envKeyValueSep)
+			origin = strings.TrimSpace(origin)
+			if !found || origin == "" {
+				errs = append(errs, invalid(envOriginMethods, v))
+				break
+			}
+			cfg.OriginMethods[origin] = splitEnvList(methods)
+		}
+	}
+	boolVar(&cfg.CredentialsHeuristic, envCredentialsHeuristic)
+	cfg.CredentialedSchemes = splitEnvList(getenv(envCredentialedSchemes))
+	cfg.CredentialedWildcardMethods = splitEnvList(getenv(envCredWildcardMethods))
+	cfg.AdditionalSafelistedMethods = splitEnvList(getenv(envExtraSafelisted))
+	boolVar(&cfg.TreatHeadAsNonSafe, envHeadNonSafe)
+	boolVar(&cfg.AlwaysEmitMaxAge, envAlwaysEmitMaxAge)
+	boolVar(&cfg.LowercaseResponseHeaderNames, envLowercaseResHdrNames)
+	boolVar(&cfg.UseCanonicalHeaderWrites, envCanonicalWrites)
+	if v := strings.TrimSpace(getenv(envVaryStrategy)); v != "" {
+		vs := VaryDefault
+		for vs <= VaryFull && vs.String() != v {
+			vs++
+		}
+		if vs > VaryFull {
+			errs = append(errs, invalid(envVaryStrategy, v))
+		} else {
+			cfg.VaryStrategy = vs
+		}
+	}
+	boolVar(&cfg.OmitVaryForDisallowedOrigins, envOmitVaryDisallowed)
+	boolVar(&cfg.NormalizeIPv4Shorthand, envNormalizeIPv4)
+	boolVar(&cfg.DecodePercentEncodedOrigin, envDecodePercentOrigin)
+	boolVar(&cfg.TreatEmptyOriginAsAbsent, envEmptyOriginAsAbsent)
+	cfg.OriginHeaderNames = splitEnvList(getenv(envOriginHeaderNames))
+	boolVar(&cfg.PreserveOriginOrder, envPreserveOriginOrder)
+	boolVar(&cfg.ValidateSecFetchMetadata, envSecFetchMetadata)
+	boolVar(&cfg.ReportOnly, envReportOnly)
+	if v := getenv(envDeprecatedOrigins); v != "" {
+		cfg.DeprecatedOrigins = make(map[string]time.Time)
+		for _, entry := range strings.Split(v, envEntrySep) {
+			origin, sunset, found := strings.Cut(entry, envKeyValueSep)
+			origin = strings.TrimSpace(origin)
+			t, err := time.Parse(time.RFC3339, strings.TrimSpace(sunset))
+			if !found || origin == "" || err != nil {
+				errs = append(errs, invalid(envDeprecatedOrigins, v))
+				break
+			}
+			cfg.DeprecatedOrigins[origin] = t
+		}
+	}
+	cfg.PolicyLink = strings.TrimSpace(getenv(envPolicyLink))
+	intVar(&cfg.MaxOriginLength, envMaxOriginLength)
+	intVar(&cfg.MaxDebugACAHBytes, envMaxDebugACAHBytes)
+	boolVar(&cfg.DebugOmitCredentialsHeader, envDebugOmitACAC)
+	intVar(&cfg.MaxProcessedHeaderBytes, envMaxProcessedHdrBytes)
+	boolVar(&cfg.InjectDecision, envInjectDecision)
+	boolVar(&cfg.WildcardCoversAuthorization, envWildcardCoversAuthz)
+	intVar(&cfg.BoundedAuthorizationScan, envBoundedAuthzScan)
+	if v := getenv(envSlowPreflight); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, invalid(envSlowPreflight, v))
+		} else {
+			cfg.SlowPreflightThreshold = d
+		}
+	}
+	cfg.AllowedContentTypesOnPreflight = splitEnvList(getenv(envAllowedContentTypes))
+	boolVar(&cfg.OverrideHandlerCORSHeaders, envOverrideHandlerCORS)
+	boolVar(&cfg.ApplyAfterHandler, envApplyAfterHandler)
+	boolVar(&cfg.SanitizeInboundCORSHeaders, envSanitizeInboundCORS)
+	boolVar(&cfg.ReflectExposedResponseHeaders, envReflectExposed)
+	boolVar(&cfg.KeepSafelistedExposedHeaders, envKeepSafelisted)
+	boolVar(&cfg.HandleWebSocketUpgrade, envWebSocketUpgrade)
+	boolVar(&cfg.AnswerPlainOptions, envAnswerPlainOptions)
+	boolVar(&cfg.SuppressCORSHeadersOnNonCORSOptions, envSuppressNonCORSOpts)
+	boolVar(&cfg.DangerouslyTolerateInsecureOrigins, envInsecureOrigins)
+	boolVar(&cfg.DangerouslyTolerateSubdomainsOfPublicSuffixes, envSubsOfPublicSuffixes)
+	if len(errs) != 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// splitEnvList splits v around commas and trims the resulting elements
+// of leading and trailing whitespace. If v is empty, splitEnvList returns nil.
+func splitEnvList(v string) []string {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	res := strings.Split(v, envListSep)
+	for i := range res {
+		res[i] = strings.TrimSpace(res[i])
+	}
+	return res
+}
+
+func invalidEnvErr(key, value string) error {
+	return util.Errorf("invalid value %q for environment variable %s", value, key)
+}
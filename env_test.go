@@ -0,0 +1,258 @@
+package cors_test
+
+import (
+	"maps"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestConfigAsEnv(t *testing.T) {
+	cases := []struct {
+		desc string
+		cfg  *cors.Config
+		want map[string]string
+	}{
+		{
+			desc: "passthrough",
+			cfg:  nil,
+		}, {
+			desc: "anonymous allow all",
+			cfg: &cors.Config{
+				Origins:         []string{"*"},
+				Methods:         []string{"*"},
+				RequestHeaders:  []string{"authorization", "*"},
+				ResponseHeaders: []string{"*"},
+			},
+			want: map[string]string{
+				"CORS_ORIGINS":          "*",
+				"CORS_METHODS":          "*",
+				"CORS_REQUEST_HEADERS":  "*,Authorization",
+				"CORS_RESPONSE_HEADERS": "*",
+			},
+		}, {
+			desc: "wildcard covers authorization",
+			cfg: &cors.Config{
+				Origins:        []string{"*"},
+				RequestHeaders: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					LowercaseResponseHeaderNames:        true,
+					WildcardCoversAuthorization:         true,
+					BoundedAuthorizationScan:            256,
+					ApplyAfterHandler:                   true,
+					SuppressCORSHeadersOnNonCORSOptions: true,
+				},
+			},
+			want: map[string]string{
+				"CORS_ORIGINS":                                   "*",
+				"CORS_REQUEST_HEADERS":                           "*",
+				"CORS_LOWERCASE_RESPONSE_HEADER_NAMES":           "true",
+				"CORS_WILDCARD_COVERS_AUTHORIZATION":             "true",
+				"CORS_BOUNDED_AUTHORIZATION_SCAN":                "256",
+				"CORS_APPLY_AFTER_HANDLER":                       "true",
+				"CORS_SUPPRESS_CORS_HEADERS_ON_NON_CORS_OPTIONS": "true",
+			},
+		}, {
+			desc: "credentialed with all methods",
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				Methods:      []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedWildcardMethods:   []string{"PUT", "DELETE"},
+					ReflectExposedResponseHeaders: true,
+					VaryStrategy:                  cors.VaryOriginOnly,
+				},
+			},
+			want: map[string]string{
+				"CORS_ORIGINS":                          "https://example.com",
+				"CORS_CREDENTIALED":                     "true",
+				"CORS_METHODS":                          "*",
+				"CORS_CREDENTIALED_WILDCARD_METHODS":    "DELETE,PUT",
+				"CORS_REFLECT_EXPOSED_RESPONSE_HEADERS": "true",
+				"CORS_VARY_STRATEGY":                    "VaryOriginOnly",
+			},
+		}, {
+			desc: "credentialed",
+			cfg: &cors.Config{
+				Origins: []string{
+					"http://example.com",
+					"https://*.example.com",
+				},
+				Credentialed:    true,
+				Methods:         []string{http.MethodPut, http.MethodDelete},
+				RequestHeaders:  []string{"X-Foo", "X-Bar"},
+				MaxAgeInSeconds: -1,
+				ResponseHeaders: []string{"X-Baz", "Content-Type"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightSuccessStatus: 200,
+					PreflightFailureStatus: 400,
+					PreflightStatusByMethod: map[string]int{
+						http.MethodPut:    201,
+						http.MethodDelete: 200,
+					},
+					RejectionMarkerHeader:       true,
+					RejectionMarkerHeaderName:   "x-rejected",
+					PrivateNetworkAccess:        true,
+					RequestMethodHeaderFallback: "X-Requested-Method",
+					LenientACRHTokenWhitespace:  true,
+					SortAllowHeaders:            true,
+					LegacyAllowedRequestHeaders: []string{"x-legacy", "X-Foo"},
+					OriginMethods: map[string][]string{
+						"https://b.example.com": {http.MethodPatch},
+						"https://a.example.com": {http.MethodGet},
+					},
+					CredentialsHeuristic:        true,
+					CredentialedSchemes:         []string{"https", "http"},
+					AdditionalSafelistedMethods: []string{"QUERY", "GET"},
+					TreatHeadAsNonSafe:          true,
+					AlwaysEmitMaxAge:            true,
+					UseCanonicalHeaderWrites:    true,
+					NormalizeIPv4Shorthand:      true,
+					DecodePercentEncodedOrigin:  true,
+					TreatEmptyOriginAsAbsent:    true,
+					OriginHeaderNames:           []string{"X-Forwarded-Origin", "Origin"},
+					PreserveOriginOrder:         true,
+					ValidateSecFetchMetadata:    true,
+					ReportOnly:                  true,
+					DeprecatedOrigins: map[string]time.Time{
+						"https://a.example.com": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+					},
+					OmitVaryForDisallowedOrigins:       true,
+					PolicyLink:                         "https://example.com/cors-policy",
+					MaxOriginLength:                    64,
+					MaxDebugACAHBytes:                  1024,
+					DebugOmitCredentialsHeader:         true,
+					MaxProcessedHeaderBytes:            4096,
+					InjectDecision:                     true,
+					SlowPreflightThreshold:             250 * time.Millisecond,
+					AllowedContentTypesOnPreflight:     []string{"Application/JSON"},
+					OverrideHandlerCORSHeaders:         true,
+					SanitizeInboundCORSHeaders:         true,
+					KeepSafelistedExposedHeaders:       true,
+					HandleWebSocketUpgrade:             true,
+					AnswerPlainOptions:                 true,
+					DangerouslyTolerateInsecureOrigins: true,
+				},
+			},
+			want: map[string]string{
+				"CORS_ORIGINS":                               "http://example.com,https://*.example.com",
+				"CORS_CREDENTIALED":                          "true",
+				"CORS_METHODS":                               "DELETE,PUT",
+				"CORS_REQUEST_HEADERS":                       "X-Bar,X-Foo",
+				"CORS_MAX_AGE_IN_SECONDS":                    "-1",
+				"CORS_RESPONSE_HEADERS":                      "Content-Type,X-Baz",
+				"CORS_PREFLIGHT_SUCCESS_STATUS":              "200",
+				"CORS_PREFLIGHT_FAILURE_STATUS":              "400",
+				"CORS_PREFLIGHT_STATUS_BY_METHOD":            "DELETE=200;PUT=201",
+				"CORS_REJECTION_MARKER_HEADER":               "true",
+				"CORS_REJECTION_MARKER_HEADER_NAME":          "X-Rejected",
+				"CORS_PRIVATE_NETWORK_ACCESS":                "true",
+				"CORS_REQUEST_METHOD_HEADER_FALLBACK":        "X-Requested-Method",
+				"CORS_LENIENT_ACRH_TOKEN_WHITESPACE":         "true",
+				"CORS_SORT_ALLOW_HEADERS":                    "true",
+				"CORS_LEGACY_ALLOWED_REQUEST_HEADERS":        "X-Legacy",
+				"CORS_ORIGIN_METHODS":                        "https://a.example.com=;https://b.example.com=PATCH",
+				"CORS_CREDENTIALS_HEURISTIC":                 "true",
+				"CORS_CREDENTIALED_SCHEMES":                  "http,https",
+				"CORS_ADDITIONAL_SAFELISTED_METHODS":         "QUERY",
+				"CORS_TREAT_HEAD_AS_NON_SAFE":                "true",
+				"CORS_ALWAYS_EMIT_MAX_AGE":                   "true",
+				"CORS_USE_CANONICAL_HEADER_WRITES":           "true",
+				"CORS_OMIT_VARY_FOR_DISALLOWED_ORIGINS":      "true",
+				"CORS_NORMALIZE_IPV4_SHORTHAND":              "true",
+				"CORS_DECODE_PERCENT_ENCODED_ORIGIN":         "true",
+				"CORS_TREAT_EMPTY_ORIGIN_AS_ABSENT":          "true",
+				"CORS_ORIGIN_HEADER_NAMES":                   "X-Forwarded-Origin,Origin",
+				"CORS_PRESERVE_ORIGIN_ORDER":                 "true",
+				"CORS_VALIDATE_SEC_FETCH_METADATA":           "true",
+				"CORS_REPORT_ONLY":                           "true",
+				"CORS_POLICY_LINK":                           "https://example.com/cors-policy",
+				"CORS_MAX_ORIGIN_LENGTH":                     "64",
+				"CORS_MAX_DEBUG_ACAH_BYTES":                  "1024",
+				"CORS_DEBUG_OMIT_CREDENTIALS_HEADER":         "true",
+				"CORS_MAX_PROCESSED_HEADER_BYTES":            "4096",
+				"CORS_INJECT_DECISION":                       "true",
+				"CORS_SLOW_PREFLIGHT_THRESHOLD":              "250ms",
+				"CORS_ALLOWED_CONTENT_TYPES_ON_PREFLIGHT":    "application/json",
+				"CORS_OVERRIDE_HANDLER_CORS_HEADERS":         "true",
+				"CORS_SANITIZE_INBOUND_CORS_HEADERS":         "true",
+				"CORS_KEEP_SAFELISTED_EXPOSED_HEADERS":       "true",
+				"CORS_HANDLE_WEBSOCKET_UPGRADE":              "true",
+				"CORS_ANSWER_PLAIN_OPTIONS":                  "true",
+				"CORS_DEPRECATED_ORIGINS":                    "https://a.example.com=2025-03-01T00:00:00Z",
+				"CORS_DANGEROUSLY_TOLERATE_INSECURE_ORIGINS": "true",
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			env := mw.ConfigAsEnv()
+			if !maps.Equal(env, tc.want) {
+				t.Errorf("got %q; want %q", env, tc.want)
+			}
+			if tc.cfg == nil {
+				return
+			}
+			cfg, err := cors.ConfigFromEnv(func(k string) string { return env[k] })
+			if err != nil {
+				t.Fatalf("failure to parse config from env: %v", err)
+			}
+			mw2, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			assertConfigEqual(t, mw2.Config(), mw.Config())
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestConfigFromEnvWithInvalidValues(t *testing.T) {
+	env := map[string]string{
+		"CORS_ORIGINS":                    "https://example.com",
+		"CORS_CREDENTIALED":               "yes",
+		"CORS_MAX_AGE_IN_SECONDS":         "thirty",
+		"CORS_ORIGIN_METHODS":             "https://example.com",
+		"CORS_DEPRECATED_ORIGINS":         "https://example.com=tomorrow",
+		"CORS_VARY_STRATEGY":              "none",
+		"CORS_PREFLIGHT_STATUS_BY_METHOD": "PUT=created",
+	}
+	_, err := cors.ConfigFromEnv(func(k string) string { return env[k] })
+	if err == nil {
+		t.Fatal("got nil error; want non-nil error")
+	}
+	msgs := flatten(err)
+	sort.Strings(msgs)
+	want := []string{
+		`cors: invalid value "https://example.com" for environment variable CORS_ORIGIN_METHODS`,
+		`cors: invalid value "https://example.com=tomorrow" for environment variable CORS_DEPRECATED_ORIGINS`,
+		`cors: invalid value "thirty" for environment variable CORS_MAX_AGE_IN_SECONDS`,
+		`cors: invalid value "yes" for environment variable CORS_CREDENTIALED`,
+		`cors: invalid value "none" for environment variable CORS_VARY_STRATEGY`,
+		`cors: invalid value "PUT=created" for environment variable CORS_PREFLIGHT_STATUS_BY_METHOD`,
+	}
+	sort.Strings(want)
+	if res, same := diff(msgs, want); !same {
+		t.Error("unexpected error message(s):")
+		for _, s := range res {
+			t.Logf("\t%s", s)
+		}
+	}
+}
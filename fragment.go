@@ -0,0 +1,72 @@
+package cors
+
+// A DocFragment summarizes a middleware's CORS policy in a shape suited to
+// embedding in API reference documentation (e.g. as an OpenAPI extension);
+// see [*Middleware.ToDocFragment].
+// It can be marshaled to JSON (and to YAML, by libraries that honor
+// yaml struct tags); its field names are stable.
+type DocFragment struct {
+	// Origins lists the allowed origin patterns (see the documentation of
+	// the Config.Origins field), or a single asterisk if all origins
+	// are allowed.
+	Origins []string `json:"origins,omitempty" yaml:"origins,omitempty"`
+	// Credentialed indicates whether credentialed access is enabled.
+	Credentialed bool `json:"credentialed" yaml:"credentialed"`
+	// Methods lists the allowed methods other than the
+	// CORS-safelisted ones (GET, HEAD, and POST),
+	// or a single asterisk if all methods are allowed.
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+	// RequestHeaders lists the allowed request-header names,
+	// or a single asterisk (possibly followed by Authorization)
+	// if all request-header names are allowed.
+	RequestHeaders []string `json:"requestHeaders,omitempty" yaml:"requestHeaders,omitempty"`
+	// ExposedHeaders lists the response-header names exposed to clients,
+	// or a single asterisk if all response-header names are exposed.
+	ExposedHeaders []string `json:"exposedHeaders,omitempty" yaml:"exposedHeaders,omitempty"`
+	// MaxAgeInSeconds is the number of seconds during which browsers
+	// may cache the results of successful CORS-preflight requests.
+	// Contrary to the Config.MaxAgeInSeconds field, it reflects the value
+	// in effect: 5 (the default value) if no max age was specified,
+	// and 0 if caching of preflight responses is disabled.
+	MaxAgeInSeconds int `json:"maxAgeInSeconds" yaml:"maxAgeInSeconds"`
+}
+
+// ToDocFragment returns a summary of m's current CORS policy
+// for documentation pipelines, which can then rely on it
+// rather than on scraping live responses.
+// The summary is derived from the normalized form of m's configuration
+// (as reported by [*Middleware.Config]) and is therefore deterministic;
+// contrary to the latter, it omits settings that only matter to
+// the middleware's operators (e.g. ExtraConfig.ReportOnly).
+// If m is a passthrough middleware, ToDocFragment returns
+// the zero DocFragment.
+//
+// Mutating the fields of the result does not alter m's behavior.
+func (m *Middleware) ToDocFragment() DocFragment {
+	cfg := m.Config()
+	if cfg == nil {
+		return DocFragment{}
+	}
+	frag := DocFragment{
+		Origins:        cfg.Origins,
+		Credentialed:   cfg.Credentialed,
+		Methods:        cfg.Methods,
+		RequestHeaders: cfg.RequestHeaders,
+		ExposedHeaders: cfg.ResponseHeaders,
+	}
+	switch cfg.MaxAgeInSeconds {
+	case 0:
+		frag.MaxAgeInSeconds = defaultMaxAge
+	case -1:
+		frag.MaxAgeInSeconds = 0
+	default:
+		frag.MaxAgeInSeconds = cfg.MaxAgeInSeconds
+	}
+	return frag
+}
+
+// defaultMaxAge is the number of seconds during which browsers cache
+// the results of successful CORS-preflight requests in the absence of
+// an Access-Control-Max-Age header;
+// see https://fetch.spec.whatwg.org/#http-access-control-max-age.
+const defaultMaxAge = 5
@@ -0,0 +1,82 @@
+package cors_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestToDocFragment(t *testing.T) {
+	cases := []struct {
+		desc string
+		cfg  *cors.Config
+		want string // JSON
+	}{
+		{
+			desc: "passthrough",
+			cfg:  nil,
+			want: `{"credentialed":false,"maxAgeInSeconds":0}`,
+		}, {
+			desc: "anonymous allow all",
+			cfg: &cors.Config{
+				Origins:         []string{"*"},
+				Methods:         []string{"*"},
+				RequestHeaders:  []string{"authorization", "*"},
+				ResponseHeaders: []string{"*"},
+			},
+			want: `{"origins":["*"],"credentialed":false,"methods":["*"],` +
+				`"requestHeaders":["*","Authorization"],"exposedHeaders":["*"],` +
+				`"maxAgeInSeconds":5}`,
+		}, {
+			desc: "credentialed",
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				Credentialed:    true,
+				Methods:         []string{http.MethodPut, http.MethodGet},
+				RequestHeaders:  []string{"x-foo", "Content-Type"},
+				MaxAgeInSeconds: 30,
+				ResponseHeaders: []string{"x-bar"},
+				ExtraConfig: cors.ExtraConfig{
+					ReportOnly: true,
+				},
+			},
+			want: `{"origins":["https://example.com"],"credentialed":true,` +
+				`"methods":["PUT"],"requestHeaders":["Content-Type","X-Foo"],` +
+				`"exposedHeaders":["X-Bar"],"maxAgeInSeconds":30}`,
+		}, {
+			desc: "preflight caching disabled",
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				MaxAgeInSeconds: -1,
+			},
+			want: `{"origins":["https://example.com"],"credentialed":false,` +
+				`"maxAgeInSeconds":0}`,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			b, err := json.Marshal(mw.ToDocFragment())
+			if err != nil {
+				t.Fatalf("failure to marshal doc fragment: %v", err)
+			}
+			if got := string(b); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
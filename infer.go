@@ -0,0 +1,93 @@
+package cors
+
+import (
+	"github.com/jub0bs/cors/internal/headers"
+	"github.com/jub0bs/cors/internal/methods"
+	"github.com/jub0bs/cors/internal/origins"
+	"github.com/jub0bs/cors/internal/util"
+)
+
+// A RequestSample describes a CORS request observed in production;
+// see [InferConfig].
+type RequestSample struct {
+	// Origin is the value of the request's Origin header
+	// (e.g. https://example.com).
+	Origin string
+	// Method is the request's method or, in the case of a CORS-preflight
+	// request, the value of its Access-Control-Request-Method header.
+	Method string
+	// Headers lists the names of the request headers that
+	// the corresponding CORS-preflight request listed in its
+	// Access-Control-Request-Headers header.
+	// Header names are case-insensitive.
+	Headers []string
+}
+
+// InferConfig returns the minimal Config that allows all of samples,
+// i.e. one that allows their distinct origins, the union of their methods,
+// and the union of their request-header names.
+// InferConfig enables you to turn traffic captured from a legacy service
+// into a concrete configuration for it.
+//
+// The result is deterministic and normalized: the elements of
+// its Origins, Methods, and RequestHeaders fields are deduplicated and sorted,
+// request-header names are byte-lowercased,
+// and CORS-safelisted methods (GET, HEAD, and POST), which are allowed anyway,
+// are omitted.
+// Sample values that no valid Config could allow (e.g. the null origin
+// or forbidden method names such as CONNECT) are ignored.
+// InferConfig doesn't coalesce origins into patterns
+// (e.g. https://*.example.com), since doing so would allow unobserved origins.
+// The result enables neither credentialed access nor any ExtraConfig setting;
+// you should review it before use.
+// In particular, if samples contains no valid origin,
+// [NewMiddleware] rejects the result.
+func InferConfig(samples []RequestSample) Config {
+	var (
+		originSet = make(util.Set[string])
+		methodSet = make(util.Set[string])
+		headerSet = make(util.Set[string])
+	)
+	for _, s := range samples {
+		if inferableOrigin(s.Origin) {
+			originSet.Add(s.Origin)
+		}
+		if inferableMethod(s.Method) {
+			methodSet.Add(s.Method)
+		}
+		for _, name := range s.Headers {
+			if !headers.IsValid(name) {
+				continue
+			}
+			normalized := util.ByteLowercase(name)
+			if headers.IsForbiddenRequestHeaderName(normalized) ||
+				headers.IsProhibitedRequestHeaderName(normalized) {
+				continue
+			}
+			headerSet.Add(normalized)
+		}
+	}
+	return Config{
+		Origins:        originSet.ToSortedSlice(),
+		Methods:        methodSet.ToSortedSlice(),
+		RequestHeaders: headerSet.ToSortedSlice(),
+	}
+}
+
+// inferableOrigin reports whether origin is a valid Web origin that
+// some Config could allow by listing it in its Origins field.
+func inferableOrigin(origin string) bool {
+	if _, ok := origins.Parse(origin); !ok {
+		return false
+	}
+	_, err := origins.ParsePattern(origin)
+	return err == nil
+}
+
+// inferableMethod reports whether name is a method name that
+// needs to be listed in the Methods field of a Config for it to be allowed.
+func inferableMethod(name string) bool {
+	return methods.IsValid(name) &&
+		!methods.IsForbidden(name) &&
+		!methods.IsSafelisted(name, struct{}{})
+}
@@ -0,0 +1,81 @@
+package cors_test
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestInferConfig(t *testing.T) {
+	samples := []cors.RequestSample{
+		{
+			Origin:  "https://foo.example.com",
+			Method:  http.MethodPut,
+			Headers: []string{"X-Foo", "content-type"},
+		}, {
+			Origin:  "https://bar.example.com",
+			Method:  http.MethodGet,
+			Headers: []string{"x-foo", "Authorization"},
+		}, {
+			Origin: "https://foo.example.com", // duplicate
+			Method: http.MethodDelete,
+		}, {
+			Origin:  "null",                      // cannot be allowed
+			Method:  http.MethodConnect,          // forbidden
+			Headers: []string{"Cookie", "x bar"}, // forbidden and invalid
+		}, {
+			Origin: "http://localhost:8080",
+			Method: http.MethodPost,
+		},
+	}
+	cfg := cors.InferConfig(samples)
+	wantOrigins := []string{
+		"http://localhost:8080",
+		"https://bar.example.com",
+		"https://foo.example.com",
+	}
+	if !slices.Equal(cfg.Origins, wantOrigins) {
+		t.Errorf("Origins: got %q; want %q", cfg.Origins, wantOrigins)
+	}
+	wantMethods := []string{http.MethodDelete, http.MethodPut}
+	if !slices.Equal(cfg.Methods, wantMethods) {
+		t.Errorf("Methods: got %q; want %q", cfg.Methods, wantMethods)
+	}
+	wantHeaders := []string{"authorization", "content-type", "x-foo"}
+	if !slices.Equal(cfg.RequestHeaders, wantHeaders) {
+		t.Errorf("RequestHeaders: got %q; want %q", cfg.RequestHeaders, wantHeaders)
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	for _, s := range samples[:3] {
+		// Browsers byte-lowercase and sort the names they list in ACRH.
+		acrh := make([]string, len(s.Headers))
+		for i, name := range s.Headers {
+			acrh[i] = strings.ToLower(name)
+		}
+		slices.Sort(acrh)
+		status, hdrs := mw.DumpPreflightResponse(s.Origin, s.Method, acrh)
+		if status != http.StatusNoContent || hdrs.Get(headerACAO) != s.Origin {
+			const tmpl = "sample %+v: got status %d and ACAO %q; want allowed"
+			t.Errorf(tmpl, s, status, hdrs.Get(headerACAO))
+		}
+	}
+}
+
+func TestInferConfigWithoutValidOrigins(t *testing.T) {
+	samples := []cors.RequestSample{
+		{Origin: "null", Method: http.MethodPut},
+	}
+	cfg := cors.InferConfig(samples)
+	if len(cfg.Origins) != 0 {
+		t.Errorf("Origins: got %q; want none", cfg.Origins)
+	}
+	if _, err := cors.NewMiddleware(cfg); err == nil {
+		t.Error("got nil error; want non-nil error")
+	}
+}
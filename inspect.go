@@ -0,0 +1,239 @@
+package cors
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jub0bs/cors/internal/headers"
+	"github.com/jub0bs/cors/internal/origins"
+	"github.com/jub0bs/cors/internal/util"
+)
+
+// A PatternInfo describes a valid origin pattern.
+// See the documentation of the Config.Origins field for details
+// about the supported forms of origin patterns.
+type PatternInfo struct {
+	// Scheme is the pattern's scheme (http or https).
+	Scheme string
+	// Host is the pattern's host, without any leading wildcard character
+	// sequence (e.g. example.com for https://*.example.com).
+	// IPv6 addresses are not enclosed in brackets.
+	Host string
+	// HostIsIP indicates whether the pattern's host is an IP address
+	// (as opposed to a domain).
+	HostIsIP bool
+	// HostIsLoopbackIP indicates whether the pattern's host is
+	// a loopback IP address.
+	HostIsLoopbackIP bool
+	// ArbitrarySubdomains indicates whether the pattern encompasses
+	// arbitrary subdomains of its host (e.g. https://*.example.com).
+	ArbitrarySubdomains bool
+	// IncludesApex indicates whether the pattern also encompasses
+	// the apex domain (e.g. https://**.example.com).
+	// It is only ever true if ArbitrarySubdomains is true.
+	IncludesApex bool
+	// Port is the pattern's port (if any).
+	// The zero value marks the absence of an explicit port.
+	Port int
+	// ArbitraryPort indicates whether the pattern encompasses
+	// arbitrary ports (e.g. http://localhost:*).
+	ArbitraryPort bool
+	// DeemedInsecure indicates whether the pattern is deemed insecure
+	// and is therefore by default prohibited when credentialed access
+	// and/or some form of Private-Network Access is enabled; see the
+	// documentation of ExtraConfig.DangerouslyTolerateInsecureOrigins.
+	DeemedInsecure bool
+	// PublicSuffix, if non-empty, is the public suffix
+	// of which the pattern encompasses arbitrary subdomains;
+	// such a pattern is by default prohibited; see the documentation of
+	// ExtraConfig.DangerouslyTolerateSubdomainsOfPublicSuffixes.
+	PublicSuffix string
+}
+
+// InspectOriginPattern checks whether str is a valid origin pattern.
+// If so, it returns a description of that pattern and a nil error.
+// Otherwise, it returns the zero PatternInfo and some non-nil error.
+// Because the single-asterisk pattern is not an origin pattern per se
+// but a special value that denotes all origins, InspectOriginPattern
+// reports it as invalid.
+//
+// InspectOriginPattern follows the very rules that [NewMiddleware] enforces
+// for the elements of the Config.Origins field;
+// it is therefore well suited for validating user input
+// (e.g. in a configuration UI) before submission.
+// Note, however, that some restrictions only apply to combinations
+// of settings (e.g. insecure origins with credentialed access);
+// the DeemedInsecure and PublicSuffix fields of the result
+// flag the patterns to which those restrictions apply.
+func InspectOriginPattern(str string) (PatternInfo, error) {
+	raw, includesApex := str, false
+	if subdomains, _, ok := origins.SplitApexAndSubdomains(str); ok {
+		raw, includesApex = subdomains, true
+	}
+	pattern, err := origins.ParsePattern(raw)
+	if err != nil {
+		return PatternInfo{}, err
+	}
+	info := PatternInfo{
+		Scheme:              pattern.Scheme,
+		Host:                pattern.Value,
+		HostIsIP:            pattern.IsIP(),
+		HostIsLoopbackIP:    pattern.Kind == origins.PatternKindLoopbackIP,
+		ArbitrarySubdomains: pattern.Kind == origins.PatternKindSubdomains,
+		IncludesApex:        includesApex,
+		DeemedInsecure:      pattern.IsDeemedInsecure(),
+	}
+	if info.ArbitrarySubdomains {
+		info.Host = strings.TrimPrefix(info.Host, "*.")
+		if etld, isEffectiveTLD := pattern.HostIsEffectiveTLD(); isEffectiveTLD {
+			info.PublicSuffix = etld
+		}
+	}
+	if pattern.Port < 0 { // sentinel value for arbitrary ports
+		info.ArbitraryPort = true
+	} else {
+		info.Port = pattern.Port
+	}
+	return info, nil
+}
+
+// representative values used by SampleOrigins
+var (
+	sampleSubdomains = []string{"foo", "bar", "foo.bar"}
+	samplePorts      = []int{8080, 3000, 9090}
+)
+
+// SampleOrigins returns up to n representative Web origins that
+// origin pattern str encompasses, or a non-nil error if str is not
+// a valid origin pattern (in the sense of [InspectOriginPattern]).
+// For instance, SampleOrigins("https://*.example.com", 2) returns
+//
+//	[]string{"https://foo.example.com", "https://bar.example.com"}
+//
+// SampleOrigins is merely a helper for documentation and test generation;
+// the set of results is deterministic but otherwise unspecified,
+// and it may change in future versions of this package.
+// If n is not positive, SampleOrigins returns a nil slice.
+func SampleOrigins(str string, n int) ([]string, error) {
+	info, err := InspectOriginPattern(str)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	host := info.Host
+	if info.HostIsIP && strings.Contains(host, ":") { // IPv6
+		host = "[" + host + "]"
+	}
+	var hosts []string
+	if info.ArbitrarySubdomains {
+		if info.IncludesApex {
+			hosts = append(hosts, host)
+		}
+		for _, sub := range sampleSubdomains {
+			hosts = append(hosts, sub+"."+host)
+		}
+	} else {
+		hosts = []string{host}
+	}
+	ports := []int{info.Port}
+	if info.ArbitraryPort {
+		ports = append(ports, samplePorts...) // info.Port is 0 here
+	}
+	res := make([]string, 0, min(n, len(hosts)*len(ports)))
+	for _, h := range hosts {
+		for _, port := range ports {
+			if len(res) == n {
+				return res, nil
+			}
+			origin := info.Scheme + "://" + h
+			if port != 0 {
+				origin += ":" + strconv.Itoa(port)
+			}
+			res = append(res, origin)
+		}
+	}
+	return res, nil
+}
+
+// OriginsDiff compares the Origins fields of old and new and returns
+// the origin patterns that new allows but old doesn't (added)
+// and those that old allows but new doesn't (removed),
+// both in lexicographical order.
+// OriginsDiff is useful for notifying the owners of Web origins
+// whose access a reconfiguration grants or revokes.
+//
+// The comparison is performed on the normalized forms of both sets of
+// origin patterns (as reported by [*Middleware.Config]);
+// therefore, reordering or duplicating origin patterns goes unreported.
+// Moreover, a discrete origin (e.g. https://foo.example.com) is not reported
+// as long as the other set encompasses it (e.g. via https://*.example.com
+// or via the single-asterisk pattern).
+// Other than that, OriginsDiff compares origin patterns, not the sets of
+// Web origins they encompass: for instance, replacing https://*.example.com
+// by https://*.foo.example.com results in the former being reported
+// as removed and the latter as added.
+// Invalid origin patterns are ignored; the other fields of old and new
+// have no bearing on the result.
+func OriginsDiff(old, new Config) (added, removed []string) {
+	oldSet := newOriginSet(old.Origins)
+	newSet := newOriginSet(new.Origins)
+	for pattern := range newSet.patterns {
+		if !oldSet.encompasses(pattern) {
+			added = append(added, pattern)
+		}
+	}
+	for pattern := range oldSet.patterns {
+		if !newSet.encompasses(pattern) {
+			removed = append(removed, pattern)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
+}
+
+// An originSet is the normalized form of a list of origin patterns;
+// see OriginsDiff.
+type originSet struct {
+	patterns util.Set[string] // normalized
+	corpus   origins.Corpus
+	allowAny bool
+}
+
+// newOriginSet returns the originSet corresponding to the valid elements
+// of patterns.
+func newOriginSet(patterns []string) originSet {
+	s := originSet{
+		patterns: make(util.Set[string]),
+		corpus:   make(origins.Corpus),
+	}
+	for _, raw := range expandOriginPatterns(patterns) {
+		if raw == headers.ValueWildcard {
+			s.patterns.Add(raw)
+			s.allowAny = true
+			continue
+		}
+		pattern, err := origins.ParsePattern(raw)
+		if err != nil {
+			continue
+		}
+		s.corpus.Add(&pattern)
+	}
+	for _, pattern := range s.corpus.Elems() {
+		s.patterns.Add(pattern)
+	}
+	return s
+}
+
+// encompasses reports whether s contains normalized pattern
+// or, if pattern is a discrete origin, whether s allows it.
+func (s *originSet) encompasses(pattern string) bool {
+	if s.allowAny || s.patterns.Contains(pattern) {
+		return true
+	}
+	o, ok := origins.Parse(pattern)
+	return ok && s.corpus.Contains(&o)
+}
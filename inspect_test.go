@@ -0,0 +1,270 @@
+package cors_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestInspectOriginPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    cors.PatternInfo
+		errMsg  string
+	}{
+		{
+			pattern: "https://example.com",
+			want: cors.PatternInfo{
+				Scheme: "https",
+				Host:   "example.com",
+			},
+		}, {
+			pattern: "http://example.com:8080",
+			want: cors.PatternInfo{
+				Scheme:         "http",
+				Host:           "example.com",
+				Port:           8080,
+				DeemedInsecure: true,
+			},
+		}, {
+			pattern: "https://*.example.com",
+			want: cors.PatternInfo{
+				Scheme:              "https",
+				Host:                "example.com",
+				ArbitrarySubdomains: true,
+			},
+		}, {
+			pattern: "https://**.example.com",
+			want: cors.PatternInfo{
+				Scheme:              "https",
+				Host:                "example.com",
+				ArbitrarySubdomains: true,
+				IncludesApex:        true,
+			},
+		}, {
+			pattern: "https://*.github.io",
+			want: cors.PatternInfo{
+				Scheme:              "https",
+				Host:                "github.io",
+				ArbitrarySubdomains: true,
+				PublicSuffix:        "github.io",
+			},
+		}, {
+			pattern: "http://localhost:*",
+			want: cors.PatternInfo{
+				Scheme:        "http",
+				Host:          "localhost",
+				ArbitraryPort: true,
+			},
+		}, {
+			pattern: "http://127.0.0.1:9090",
+			want: cors.PatternInfo{
+				Scheme:           "http",
+				Host:             "127.0.0.1",
+				HostIsIP:         true,
+				HostIsLoopbackIP: true,
+				Port:             9090,
+			},
+		}, {
+			pattern: "http://[2001:db8::1]",
+			want: cors.PatternInfo{
+				Scheme:         "http",
+				Host:           "2001:db8::1",
+				HostIsIP:       true,
+				DeemedInsecure: true,
+			},
+		}, {
+			pattern: "*",
+			errMsg:  `cors: prohibited origin pattern "*"`,
+		}, {
+			pattern: "https://example.com/",
+			errMsg:  `cors: invalid origin pattern "https://example.com/"`,
+		}, {
+			pattern: "https://*.example.com:*",
+			errMsg: `cors: specifying both arbitrary subdomains ` +
+				`and arbitrary ports is prohibited: "https://*.example.com:*"`,
+		},
+	}
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			got, err := cors.InspectOriginPattern(c.pattern)
+			if c.errMsg != "" {
+				if err == nil || err.Error() != c.errMsg {
+					t.Errorf("got error %v; want %q", err, c.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v; want nil error", err)
+			}
+			if got != c.want {
+				t.Errorf("got %+v; want %+v", got, c.want)
+			}
+		}
+		t.Run(c.pattern, f)
+	}
+}
+
+func TestSampleOrigins(t *testing.T) {
+	cases := []struct {
+		pattern string
+		n       int
+		want    []string
+		errMsg  string
+	}{
+		{
+			pattern: "https://example.com",
+			n:       3,
+			want:    []string{"https://example.com"},
+		}, {
+			pattern: "https://example.com:8443",
+			n:       1,
+			want:    []string{"https://example.com:8443"},
+		}, {
+			pattern: "https://*.example.com",
+			n:       2,
+			want: []string{
+				"https://foo.example.com",
+				"https://bar.example.com",
+			},
+		}, {
+			pattern: "https://**.example.com",
+			n:       10,
+			want: []string{
+				"https://example.com",
+				"https://foo.example.com",
+				"https://bar.example.com",
+				"https://foo.bar.example.com",
+			},
+		}, {
+			pattern: "http://localhost:*",
+			n:       3,
+			want: []string{
+				"http://localhost",
+				"http://localhost:8080",
+				"http://localhost:3000",
+			},
+		}, {
+			pattern: "http://[::1]:9090",
+			n:       1,
+			want:    []string{"http://[::1]:9090"},
+		}, {
+			pattern: "https://example.com",
+			n:       0,
+		}, {
+			pattern: "https://*.example.com:*",
+			n:       1,
+			errMsg: `cors: specifying both arbitrary subdomains ` +
+				`and arbitrary ports is prohibited: "https://*.example.com:*"`,
+		},
+	}
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			got, err := cors.SampleOrigins(c.pattern, c.n)
+			if c.errMsg != "" {
+				if err == nil || err.Error() != c.errMsg {
+					t.Errorf("got error %v; want %q", err, c.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v; want nil error", err)
+			}
+			if !slices.Equal(got, c.want) {
+				t.Errorf("got %q; want %q", got, c.want)
+			}
+			// Check that the pattern actually encompasses every sample.
+			mw, err := cors.NewMiddleware(cors.Config{Origins: []string{c.pattern}})
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			for _, origin := range got {
+				rec := httptest.NewRecorder()
+				req := newRequest(http.MethodGet, Headers{headerOrigin: origin})
+				mw.Wrap(http.NotFoundHandler()).ServeHTTP(rec, req)
+				if acao := rec.Header().Get(headerACAO); acao != origin {
+					t.Errorf("sample %q: got ACAO %q; want %q", origin, acao, origin)
+				}
+			}
+		}
+		t.Run(fmt.Sprintf("%s/%d", c.pattern, c.n), f)
+	}
+}
+
+func TestOriginsDiff(t *testing.T) {
+	cases := []struct {
+		desc    string
+		old     []string
+		new     []string
+		added   []string
+		removed []string
+	}{
+		{
+			desc: "identical",
+			old:  []string{"https://example.com"},
+			new:  []string{"https://example.com"},
+		}, {
+			desc: "reordered and duplicated",
+			old:  []string{"https://example.com", "https://*.example.org"},
+			new:  []string{"https://*.example.org", "https://example.com", "https://example.com"},
+		}, {
+			desc: "redundant discrete origin",
+			old:  []string{"https://*.example.com"},
+			new:  []string{"https://foo.example.com", "https://*.example.com"},
+		}, {
+			desc:  "discrete origin subsumed by new pattern",
+			old:   []string{"https://foo.example.com"},
+			new:   []string{"https://*.example.com"},
+			added: []string{"https://*.example.com"},
+		}, {
+			desc:    "narrower pattern",
+			old:     []string{"https://*.example.com"},
+			new:     []string{"https://*.foo.example.com"},
+			added:   []string{"https://*.foo.example.com"},
+			removed: []string{"https://*.example.com"},
+		}, {
+			desc: "apex and subdomains",
+			old:  []string{"https://**.example.com"},
+			new:  []string{"https://example.com", "https://*.example.com"},
+		}, {
+			desc:    "added and removed",
+			old:     []string{"https://a.example.com", "https://b.example.com"},
+			new:     []string{"https://c.example.com", "https://a.example.com", "http://localhost:*"},
+			added:   []string{"http://localhost:*", "https://c.example.com"},
+			removed: []string{"https://b.example.com"},
+		}, {
+			desc:  "to any",
+			old:   []string{"https://example.com"},
+			new:   []string{"*"},
+			added: []string{"*"},
+		}, {
+			desc:    "from any",
+			old:     []string{"*"},
+			new:     []string{"https://example.com"},
+			removed: []string{"*"},
+		}, {
+			desc:    "invalid patterns ignored",
+			old:     []string{"https://example.com/", "https://example.com"},
+			new:     nil,
+			removed: []string{"https://example.com"},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			old := cors.Config{Origins: tc.old}
+			new := cors.Config{Origins: tc.new}
+			added, removed := cors.OriginsDiff(old, new)
+			if !slices.Equal(added, tc.added) {
+				t.Errorf("added: got %q; want %q", added, tc.added)
+			}
+			if !slices.Equal(removed, tc.removed) {
+				t.Errorf("removed: got %q; want %q", removed, tc.removed)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
@@ -16,6 +16,17 @@ const (
 	ACRM  = "Access-Control-Request-Method"
 	ACRH  = "Access-Control-Request-Headers"
 
+	// Fetch-metadata request headers
+	SecFetchSite = "Sec-Fetch-Site"
+	SecFetchMode = "Sec-Fetch-Mode"
+
+	// request headers that may carry credentials
+	Cookie = "Cookie"
+	Authz  = "Authorization"
+
+	// request header subject to post-hoc checks
+	ContentType = "Content-Type"
+
 	// common response headers
 	ACAO = "Access-Control-Allow-Origin"
 	ACAC = "Access-Control-Allow-Credentials"
@@ -29,15 +40,46 @@ const (
 	// actual-only response headers
 	ACEH = "Access-Control-Expose-Headers"
 
-	Vary = "Vary"
+	// deprecation-related response headers
+	Deprecation = "Deprecation"
+	Sunset      = "Sunset"
+
+	// policy-advertisement response header
+	Link = "Link"
+
+	Vary  = "Vary"
+	Allow = "Allow"
+
+	// diagnostic-handler response header
+	CacheControl = "Cache-Control"
+
+	// upgrade-related request headers
+	Connection = "Connection"
+	Upgrade    = "Upgrade"
+
+	// debug-only response headers
+	XDebugRejectedHeader  = "X-Debug-Rejected-Header"
+	XDebugForbiddenMethod = "X-Debug-Forbidden-Method"
+	XDebugPNA             = "X-Debug-Pna"
 )
 
 const Authorization = "authorization" // note: byte-lowercase
 
+// PrefixAccessControl is the prefix shared by the names
+// (in canonical format) of all CORS response headers.
+const PrefixAccessControl = "Access-Control-"
+
 const (
-	ValueTrue        = "true"
-	ValueWildcard    = "*"
-	ValueVaryOptions = ACRH + ", " + ACRM + ", " + ACRPN + ", " + Origin
+	ValueTrue          = "true"
+	ValueWildcard      = "*"
+	ValueDefaultMaxAge = "5" // see https://fetch.spec.whatwg.org/#http-access-control-max-age
+	ValueVaryOptions   = ACRH + ", " + ACRM + ", " + ACRPN + ", " + Origin
+	ValuePNADisabled   = "requested-but-disabled"
+
+	// values of Fetch-metadata request headers
+	ValueModeCORS   = "cors"
+	ValueSameOrigin = "same-origin"
+	ValueNone       = "none"
 )
 
 const ValueSep = ","
@@ -48,6 +90,8 @@ var ( // each of them an effective constant wrapped in a (singleton) slice
 	OriginSgl        = []string{Origin}
 	WildcardSgl      = []string{ValueWildcard}
 	WildcardAuthSgl  = []string{ValueWildcard + ValueSep + Authorization}
+	DefaultMaxAgeSgl = []string{ValueDefaultMaxAge}
+	PNADisabledSgl   = []string{ValuePNADisabled}
 )
 
 // IsValid reports whether name is a valid header name,
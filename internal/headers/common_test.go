@@ -16,6 +16,9 @@ func TestThatAllRelevantHeaderNamesAreInCanonicalFormat(t *testing.T) {
 		ACRPN,
 		ACRM,
 		ACRH,
+		Cookie,
+		Authz,
+		ContentType,
 		ACAO,
 		ACAC,
 		ACAPN,
@@ -23,7 +26,15 @@ func TestThatAllRelevantHeaderNamesAreInCanonicalFormat(t *testing.T) {
 		ACAH,
 		ACMA,
 		ACEH,
+		Deprecation,
+		Sunset,
 		Vary,
+		Allow,
+		Connection,
+		Upgrade,
+		XDebugRejectedHeader,
+		XDebugForbiddenMethod,
+		XDebugPNA,
 	}
 	for _, name := range headerNames {
 		if http.CanonicalHeaderKey(name) != name {
@@ -1,9 +1,11 @@
 package headers
 
 import (
+	"net/http"
 	"strings"
 
 	"github.com/jub0bs/cors/internal/util"
+	"golang.org/x/net/http/httpguts"
 )
 
 // IsForbiddenRequestHeaderName reports whether name is a
@@ -67,3 +69,74 @@ var prohibitedRequestHeaderNames = util.NewSet(
 	util.ByteLowercase(ACMA),
 	util.ByteLowercase(ACEH),
 )
+
+// IsSafelistedRequestHeaderName reports whether name is the name of
+// a request header that is [CORS-safelisted] for some of its values;
+// browsers omit such request headers from
+// the Access-Control-Request-Headers header of preflight requests
+// whenever their values are safelisted.
+//
+// Precondition: name is a valid and [byte-lowercase] header name.
+//
+// [byte-lowercase]: https://infra.spec.whatwg.org/#byte-lowercase
+// [CORS-safelisted]: https://fetch.spec.whatwg.org/#cors-safelisted-request-header
+func IsSafelistedRequestHeaderName(name string) bool {
+	return safelistedRequestHeaderNames.Contains(name)
+}
+
+var safelistedRequestHeaderNames = util.NewSet(
+	"accept",
+	"accept-language",
+	"content-language",
+	"content-type",
+	"range",
+)
+
+// IsSafelistedContentType reports whether mediaType,
+// the essence of some MIME type, is one of those that make
+// a Content-Type request header [CORS-safelisted].
+//
+// Precondition: mediaType is byte-lowercase.
+//
+// [CORS-safelisted]: https://fetch.spec.whatwg.org/#cors-safelisted-request-header
+func IsSafelistedContentType(mediaType string) bool {
+	return safelistedContentTypes.Contains(mediaType)
+}
+
+var safelistedContentTypes = util.NewSet(
+	"application/x-www-form-urlencoded",
+	"multipart/form-data",
+	"text/plain",
+)
+
+// ListsAuthorization reports whether any of the values in acrhSgl
+// (that of an Access-Control-Request-Headers header) lists Authorization,
+// case-insensitively and regardless of optional whitespace.
+// Its cost is linear in the total length of those values.
+func ListsAuthorization(acrhSgl []string) bool {
+	return httpguts.HeaderValuesContainsToken(acrhSgl, Authorization)
+}
+
+// IsWebSocketUpgrade reports whether hdrs are the headers of
+// a request for an upgrade to the WebSocket protocol;
+// see https://www.rfc-editor.org/rfc/rfc6455#section-4.1.
+func IsWebSocketUpgrade(hdrs http.Header) bool {
+	return httpguts.HeaderValuesContainsToken(hdrs[Upgrade], "websocket") &&
+		httpguts.HeaderValuesContainsToken(hdrs[Connection], "upgrade")
+}
+
+// StripWhitespace returns s without any of its space and horizontal-tab
+// bytes, and reports whether s contained any such byte.
+func StripWhitespace(s string) (string, bool) {
+	if strings.IndexAny(s, " \t") < 0 { // fast path
+		return s, false
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := range len(s) {
+		if c := s[i]; c != ' ' && c != '\t' {
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), true
+}
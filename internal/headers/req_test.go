@@ -1,6 +1,7 @@
 package headers
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/jub0bs/cors/internal/util"
@@ -80,3 +81,153 @@ func TestThatAllProhibitedRequestHeaderNamesAreByteLowercase(t *testing.T) {
 		}
 	}
 }
+
+func TestIsSafelistedRequestHeaderName(t *testing.T) {
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{name: "authorization", want: false},
+		{name: "x-foo", want: false},
+		{name: "accept", want: true},
+		{name: "accept-language", want: true},
+		{name: "content-language", want: true},
+		{name: "content-type", want: true},
+		{name: "range", want: true},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			got := IsSafelistedRequestHeaderName(tc.name)
+			if got != tc.want {
+				const tmpl = "%q: got %t; want %t"
+				t.Errorf(tmpl, tc.name, got, tc.want)
+			}
+		}
+		t.Run(tc.name, f)
+	}
+}
+
+func TestIsSafelistedContentType(t *testing.T) {
+	cases := []struct {
+		mediaType string
+		want      bool
+	}{
+		{mediaType: "application/json", want: false},
+		{mediaType: "application/x-www-form-urlencoded", want: true},
+		{mediaType: "multipart/form-data", want: true},
+		{mediaType: "text/plain", want: true},
+		{mediaType: "text/html", want: false},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			got := IsSafelistedContentType(tc.mediaType)
+			if got != tc.want {
+				const tmpl = "%q: got %t; want %t"
+				t.Errorf(tmpl, tc.mediaType, got, tc.want)
+			}
+		}
+		t.Run(tc.mediaType, f)
+	}
+}
+
+// This check is important because, otherwise, index expressions
+// involving a http.Header and one of those names would yield
+// unexpected results.
+func TestThatAllSafelistedRequestHeaderNamesAreByteLowercase(t *testing.T) {
+	for name := range safelistedRequestHeaderNames {
+		if util.ByteLowercase(name) != name {
+			t.Errorf("safelisted request-header name %q is not byte-lowercase", name)
+		}
+	}
+}
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	cases := []struct {
+		desc string
+		hdrs http.Header
+		want bool
+	}{
+		{
+			desc: "no upgrade",
+			hdrs: http.Header{},
+		}, {
+			desc: "websocket upgrade",
+			hdrs: http.Header{
+				Connection: {"Upgrade"},
+				Upgrade:    {"websocket"},
+			},
+			want: true,
+		}, {
+			desc: "websocket upgrade among other tokens",
+			hdrs: http.Header{
+				Connection: {"keep-alive, Upgrade"},
+				Upgrade:    {"WebSocket"},
+			},
+			want: true,
+		}, {
+			desc: "upgrade to another protocol",
+			hdrs: http.Header{
+				Connection: {"Upgrade"},
+				Upgrade:    {"h2c"},
+			},
+		}, {
+			desc: "Upgrade without Connection",
+			hdrs: http.Header{
+				Upgrade: {"websocket"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			if got := IsWebSocketUpgrade(tc.hdrs); got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestStripWhitespace(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    string
+		changed bool
+	}{
+		{"", "", false},
+		{"x-foo,x-bar", "x-foo,x-bar", false},
+		{"x-f\too,x-bar", "x-foo,x-bar", true},
+		{" x-foo , x-b ar\t", "x-foo,x-bar", true},
+	}
+	for _, tc := range cases {
+		got, changed := StripWhitespace(tc.s)
+		if got != tc.want || changed != tc.changed {
+			const tmpl = "%q: got %q, %t; want %q, %t"
+			t.Errorf(tmpl, tc.s, got, changed, tc.want, tc.changed)
+		}
+	}
+}
+
+func TestListsAuthorization(t *testing.T) {
+	cases := []struct {
+		acrh string
+		want bool
+	}{
+		{acrh: "", want: false},
+		{acrh: "x-foo", want: false},
+		{acrh: "x-authorization", want: false},
+		{acrh: "authorization", want: true},
+		{acrh: "Authorization", want: true},
+		{acrh: "content-type,authorization,x-foo", want: true},
+		{acrh: "content-type, authorization", want: true},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			got := ListsAuthorization([]string{tc.acrh})
+			if got != tc.want {
+				const tmpl = "%q: got %t; want %t"
+				t.Errorf(tmpl, tc.acrh, got, tc.want)
+			}
+		}
+		t.Run(tc.acrh, f)
+	}
+}
@@ -1,6 +1,12 @@
 package headers
 
-import "github.com/jub0bs/cors/internal/util"
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jub0bs/cors/internal/util"
+)
 
 // IsForbiddenResponseHeaderName reports whether name is a
 // forbidden response-header name [per the Fetch standard].
@@ -60,3 +66,36 @@ var safelistedResponseHeaderNames = util.NewSet(
 	"last-modified",
 	"pragma",
 )
+
+// ExposableNames returns the comma-separated, sorted, and byte-lowercase
+// names of the headers in hdrs that can meaningfully be listed
+// in the Access-Control-Expose-Headers header, i.e. the valid names
+// that are neither forbidden, nor prohibited, nor safelisted response-header
+// names, nor names of CORS response headers, nor Vary (which CORS
+// middleware itself sets).
+// ExposableNames returns the empty string if there are no such names.
+func ExposableNames(hdrs http.Header) string {
+	var names []string
+	for name := range hdrs {
+		if !IsValid(name) {
+			continue
+		}
+		name = util.ByteLowercase(name)
+		if IsForbiddenResponseHeaderName(name) ||
+			IsProhibitedResponseHeaderName(name) ||
+			IsSafelistedResponseHeaderName(name) ||
+			strings.HasPrefix(name, accessControlPrefixLower) ||
+			name == varyLower {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	names = slices.Compact(names) // hdrs may contain non-canonical keys
+	return strings.Join(names, ValueSep)
+}
+
+var (
+	accessControlPrefixLower = util.ByteLowercase(PrefixAccessControl)
+	varyLower                = util.ByteLowercase(Vary)
+)
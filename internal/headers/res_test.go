@@ -1,6 +1,7 @@
 package headers
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/jub0bs/cors/internal/util"
@@ -117,3 +118,45 @@ func TestThatAllSafelistedResponseHeaderNamesAreByteLowercase(t *testing.T) {
 		}
 	}
 }
+
+func TestExposableNames(t *testing.T) {
+	cases := []struct {
+		desc string
+		hdrs http.Header
+		want string
+	}{
+		{
+			desc: "nil",
+		}, {
+			desc: "only unexposable names",
+			hdrs: http.Header{
+				"Set-Cookie":                  {"id=123"},
+				"Content-Type":                {"text/plain"},
+				"Access-Control-Allow-Origin": {"https://example.com"},
+				"Access-Control-Max-Age":      {"10"},
+				"Vary":                        {"Origin"},
+				"Bad Name":                    {"foo"},
+			},
+		}, {
+			desc: "mix",
+			hdrs: http.Header{
+				"X-Foo":        {"foo"},
+				"x-foo":        {"foo"},
+				"Etag":         {`"123"`},
+				"Set-Cookie2":  {"id=123"},
+				"X-Request-Id": {"42"},
+				"Pragma":       {"no-cache"},
+			},
+			want: "etag,x-foo,x-request-id",
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			got := ExposableNames(tc.hdrs)
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
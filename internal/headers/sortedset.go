@@ -85,6 +85,73 @@ func (set SortedSet) Subsumes(csv string) bool {
 	}
 }
 
+// FirstNonElem returns the first name, in csv (a sequence of comma-separated
+// names), that is not an element of set, and true;
+// if all the names in csv are elements of set, FirstNonElem returns "", false.
+// FirstNonElem stops scanning csv at the first such name.
+func (set SortedSet) FirstNonElem(csv string) (string, bool) {
+	if csv == "" {
+		return "", false
+	}
+	var (
+		name       string
+		commaFound bool
+	)
+	for {
+		// Contrary to Subsumes, FirstNonElem must isolate the offending name
+		// in full; its cost is nevertheless linear in the length of csv.
+		name, csv, commaFound = strings.Cut(csv, ",")
+		if _, ok := set.m[name]; !ok {
+			return name, true
+		}
+		if !commaFound { // We have now exhausted the names in csv.
+			return "", false
+		}
+	}
+}
+
+// Sorted reports whether csv is a sequence of comma-separated names
+// that are all elements of set, regardless of their order and of
+// any duplicates; if so, Sorted also returns those names,
+// without duplicates, sorted in lexicographical order and
+// joined with a comma.
+// Contrary to Subsumes, Sorted allocates.
+func (set SortedSet) Sorted(csv string) (string, bool) {
+	if csv == "" {
+		return "", true
+	}
+	seen := make([]bool, len(set.m))
+	var (
+		name       string
+		commaFound bool
+	)
+	for {
+		// As a defense against maliciously long names in csv,
+		// we process only a small number of csv's leading bytes per iteration.
+		name, csv, commaFound = cutAtComma(csv, set.maxLen+1) // +1 for comma
+		pos, ok := set.m[name]
+		if !ok {
+			return "", false
+		}
+		seen[pos] = true
+		if !commaFound { // We have now exhausted the names in csv.
+			break
+		}
+	}
+	elems := make([]string, len(set.m))
+	for elem, i := range set.m {
+		elems[i] = elem // safe indexing, by construction of SortedSet
+	}
+	var n int
+	for i, ok := range seen {
+		if ok {
+			elems[n] = elems[i]
+			n++
+		}
+	}
+	return strings.Join(elems[:n], ","), true
+}
+
 // cutAtComma slices s around the first comma that appears among (up to) the
 // first n bytes of s, returning the parts of s before and after the comma.
 // The found result reports whether a comma appears in that portion of s.
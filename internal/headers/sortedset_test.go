@@ -133,3 +133,56 @@ func TestSortedSet(t *testing.T) {
 		t.Run(tc.desc, f)
 	}
 }
+
+func TestSortedSetFirstNonElem(t *testing.T) {
+	set := headers.NewSortedSet("x-bar", "x-baz", "x-foo")
+	cases := []struct {
+		csv   string
+		name  string
+		found bool
+	}{
+		{"", "", false},
+		{"x-bar", "", false},
+		{"x-bar,x-baz,x-foo", "", false},
+		{"x-foo,x-bar", "", false}, // ordering is irrelevant here
+		{"x-qux", "x-qux", true},
+		{"x-bar,x-qux,x-quux", "x-qux", true},
+		{"x-bar,,x-foo", "", true},
+		{"x-bar,", "", true},
+		{"x-quxbaz,x-foo", "x-quxbaz", true},
+	}
+	for _, c := range cases {
+		name, found := set.FirstNonElem(c.csv)
+		if name != c.name || found != c.found {
+			const tmpl = "%q.FirstNonElem(%q): got %q, %t; want %q, %t"
+			t.Errorf(tmpl, set, c.csv, name, found, c.name, c.found)
+		}
+	}
+}
+
+func TestSortedSetSorted(t *testing.T) {
+	set := headers.NewSortedSet("x-bar", "x-baz", "x-foo")
+	cases := []struct {
+		csv    string
+		sorted string
+		ok     bool
+	}{
+		{"", "", true},
+		{"x-bar", "x-bar", true},
+		{"x-bar,x-baz,x-foo", "x-bar,x-baz,x-foo", true},
+		{"x-foo,x-bar", "x-bar,x-foo", true},
+		{"x-foo,x-bar,x-foo", "x-bar,x-foo", true},
+		{"x-qux", "", false},
+		{"x-foo,x-qux", "", false},
+		{"x-bar,,x-foo", "", false},
+		{"x-bar,", "", false},
+		{"x-quxbaz,x-foo", "", false},
+	}
+	for _, c := range cases {
+		sorted, ok := set.Sorted(c.csv)
+		if sorted != c.sorted || ok != c.ok {
+			const tmpl = "%q.Sorted(%q): got %q, %t; want %q, %t"
+			t.Errorf(tmpl, set, c.csv, sorted, ok, c.sorted, c.ok)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package origins
 
 import (
+	"encoding/binary"
 	"slices"
 
 	"github.com/jub0bs/cors/internal/origins/radix"
+	"github.com/jub0bs/cors/internal/util"
 )
 
 // A Corpus represents a set of allowed (tuple) [Web origins].
@@ -25,6 +27,20 @@ func (c Corpus) Contains(o *Origin) bool {
 	return found && tree.Contains(o.Value, o.Port)
 }
 
+// ContainsWhich reports whether c contains origin o and, if so,
+// also returns the textual representation of the element of c that matched.
+func (c Corpus) ContainsWhich(o *Origin) (string, bool) {
+	tree, found := c[o.Scheme]
+	if !found {
+		return "", false
+	}
+	elem, ok := tree.ContainsWhich(o.Value, o.Port)
+	if !ok {
+		return "", false
+	}
+	return o.Scheme + "://" + elem, true
+}
+
 // Elems returns a slice containing textual representations of c's elements.
 func (c Corpus) Elems() []string {
 	var res []string
@@ -43,3 +59,90 @@ func (c Corpus) Elems() []string {
 	}
 	return res
 }
+
+// binaryFormatVersion identifies the binary form of a Corpus;
+// it should be incremented whenever that form changes.
+const binaryFormatVersion = 1
+
+var errMalformedCorpus = util.NewError("malformed binary form of origin corpus")
+
+// AppendBinary appends the binary form of c to b and returns the result.
+// The binary form of a Corpus consists in a version byte,
+// followed by the number of schemes (as a uvarint), followed,
+// for each scheme (in lexicographical order), by the length of the scheme,
+// the scheme, the length of the binary form of the associated tree,
+// and the binary form of that tree (see [radix.Tree.AppendBinary]).
+// All lengths are represented as uvarints.
+func (c Corpus) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, binaryFormatVersion)
+	b = binary.AppendUvarint(b, uint64(len(c)))
+	schemes := make([]string, 0, len(c))
+	for scheme := range c {
+		schemes = append(schemes, scheme)
+	}
+	slices.Sort(schemes)
+	for _, scheme := range schemes {
+		b = binary.AppendUvarint(b, uint64(len(scheme)))
+		b = append(b, scheme...)
+		tree := c[scheme]
+		treeBytes, err := tree.AppendBinary(nil)
+		if err != nil {
+			return nil, err
+		}
+		b = binary.AppendUvarint(b, uint64(len(treeBytes)))
+		b = append(b, treeBytes...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces the contents of *c by the contents of the corpus
+// whose binary form (as produced by [Corpus.AppendBinary]) is data.
+func (c *Corpus) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != binaryFormatVersion {
+		return errMalformedCorpus
+	}
+	data = data[1:]
+	numSchemes, data, ok := cutUvarint(data)
+	if !ok {
+		return errMalformedCorpus
+	}
+	res := make(Corpus)
+	for range numSchemes {
+		var scheme, treeBytes []byte
+		if scheme, data, ok = cutLengthPrefixed(data); !ok {
+			return errMalformedCorpus
+		}
+		if !IsSupportedScheme(string(scheme)) {
+			return errMalformedCorpus
+		}
+		if treeBytes, data, ok = cutLengthPrefixed(data); !ok {
+			return errMalformedCorpus
+		}
+		var tree radix.Tree
+		if err := tree.UnmarshalBinary(treeBytes); err != nil {
+			return errMalformedCorpus
+		}
+		res[string(scheme)] = tree
+	}
+	if len(data) != 0 {
+		return errMalformedCorpus
+	}
+	*c = res
+	return nil
+}
+
+func cutUvarint(b []byte) (uint64, []byte, bool) {
+	x, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, false
+	}
+	return x, b[n:], true
+}
+
+func cutLengthPrefixed(b []byte) (before, after []byte, ok bool) {
+	l, b, ok := cutUvarint(b)
+	if !ok || uint64(len(b)) < l {
+		return nil, nil, false
+	}
+	return b[:l], b[l:], true
+}
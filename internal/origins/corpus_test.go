@@ -97,6 +97,10 @@ func TestCorpus(t *testing.T) {
 				if !corpus.Contains(&origin) {
 					t.Errorf("corpus.Contains(%q): got false; want true", raw)
 				}
+				if elem, ok := corpus.ContainsWhich(&origin); !ok || !slices.Contains(tc.elems, elem) {
+					const tmpl = "corpus.ContainsWhich(%q): got %q, %t; want one of %q, true"
+					t.Errorf(tmpl, raw, elem, ok, tc.elems)
+				}
 			}
 			for _, raw := range tc.rejects {
 				origin, ok := origins.Parse(raw)
@@ -106,11 +110,25 @@ func TestCorpus(t *testing.T) {
 				if corpus.Contains(&origin) {
 					t.Errorf("corpus.Contains(%q): got true; want false", raw)
 				}
+				if elem, ok := corpus.ContainsWhich(&origin); ok {
+					t.Errorf("corpus.ContainsWhich(%q): got %q, true; want false", raw, elem)
+				}
 			}
 			elems := corpus.Elems()
 			if !slices.Equal(elems, tc.elems) {
 				t.Errorf("corpus.Elems(): got %q; want %q", elems, tc.elems)
 			}
+			data, err := corpus.AppendBinary(nil)
+			if err != nil {
+				t.Fatalf("corpus.AppendBinary: got error %v; want nil", err)
+			}
+			var copied origins.Corpus
+			if err := copied.UnmarshalBinary(data); err != nil {
+				t.Fatalf("corpus.UnmarshalBinary: got error %v; want nil", err)
+			}
+			if elems := copied.Elems(); !slices.Equal(elems, tc.elems) {
+				t.Errorf("copied.Elems(): got %q; want %q", elems, tc.elems)
+			}
 		}
 		t.Run(tc.desc, f)
 	}
@@ -1,6 +1,8 @@
 package origins
 
 import (
+	"net/netip"
+	"strconv"
 	"strings"
 )
 
@@ -22,6 +24,8 @@ const (
 	maxPortLen = len("65535")
 	// maxHostPortLen is the maximum length of an origin's host-port part.
 	maxHostPortLen = maxHostLen + 1 + maxPortLen // 1 for colon character
+	// MaxLen is the maximum length of an origin that Parse accepts.
+	MaxLen = maxSchemeLen + len(schemeHostSep) + maxHostPortLen
 )
 
 // Origin represents a (tuple) [Web origin].
@@ -45,8 +49,7 @@ var zeroOrigin Origin
 // In particular, the scheme and port of the resulting origin are guaranteed
 // to be valid, but its host isn't.
 func Parse(str string) (Origin, bool) {
-	const maxOriginLen = maxSchemeLen + len(schemeHostSep) + maxHostPortLen
-	if len(str) > maxOriginLen {
+	if len(str) > MaxLen {
 		return zeroOrigin, false
 	}
 	scheme, str, ok := scanHttpScheme(str)
@@ -80,6 +83,71 @@ func Parse(str string) (Origin, bool) {
 	return o, true
 }
 
+// NormalizeIPv4Shorthand checks whether str is an origin whose host is
+// an IPv4 address in shorthand form (e.g. "127.1"), i.e. in a form that
+// consists in fewer than four decimal parts, the last of which
+// covers the remaining bytes of the address.
+// If so, it returns str with that host in dotted-quad form
+// (e.g. "127.0.0.1") and true.
+// Otherwise, it returns str and false.
+// Parts that have leading zeros are rejected,
+// because some clients interpret them as octal.
+func NormalizeIPv4Shorthand(str string) (string, bool) {
+	o, ok := Parse(str)
+	if !ok || !o.AssumeIP {
+		return str, false
+	}
+	addr, ok := parseIPv4Shorthand(o.Host.Value)
+	if !ok {
+		return str, false
+	}
+	var sb strings.Builder
+	sb.WriteString(o.Scheme)
+	sb.WriteString(schemeHostSep)
+	sb.WriteString(addr.String())
+	if o.Port != 0 {
+		sb.WriteByte(hostPortSep)
+		sb.WriteString(strconv.Itoa(o.Port))
+	}
+	return sb.String(), true
+}
+
+// parseIPv4Shorthand parses an IPv4 address in shorthand form
+// (one to three decimal parts). It returns the parsed address
+// and a bool that indicates success or failure.
+func parseIPv4Shorthand(str string) (netip.Addr, bool) {
+	const maxParts = 3
+	parts := strings.SplitN(str, string(labelSep), maxParts+1)
+	if len(parts) > maxParts {
+		return netip.Addr{}, false
+	}
+	var ip uint32
+	for i, part := range parts {
+		if part == "" || len(part) > 1 && part[0] == '0' {
+			return netip.Addr{}, false
+		}
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		if i < len(parts)-1 { // not the last part
+			if n > 255 {
+				return netip.Addr{}, false
+			}
+			ip |= uint32(n) << (8 * (3 - i))
+			continue
+		}
+		// The last part covers all the remaining bytes.
+		remainingBits := 8 * (4 - i)
+		if remainingBits < 32 && n >= 1<<remainingBits {
+			return netip.Addr{}, false
+		}
+		ip |= uint32(n)
+	}
+	b := [4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}
+	return netip.AddrFrom4(b), true
+}
+
 // Host represents a host, whether it be an IP address or a domain.
 type Host struct {
 	// Value is the origin's raw host.
@@ -234,3 +302,79 @@ func consume(target, str string) (rest string, ok bool) {
 	}
 	return str[len(target):], true
 }
+
+// DecodePercentEncodedHost checks whether str is an origin-like string
+// whose host contains percent-encoded bytes (e.g. "https://ex%61mple.com").
+// If so, and if every percent-encoded byte decodes to a byte that may
+// legitimately appear in a domain (i.e. a lowercase ASCII letter,
+// an ASCII digit, a hyphen, or a period), it returns str with its host
+// percent-decoded (e.g. "https://example.com") and true.
+// Otherwise, it returns str and false.
+// DecodePercentEncodedHost only decodes once; in particular,
+// a host in which decoding would reveal another percent sign
+// (e.g. "%2561") is rejected.
+// The result is not guaranteed to be a valid origin; use [Parse] on it.
+func DecodePercentEncodedHost(str string) (string, bool) {
+	const maxEncodedLen = 3 * MaxLen // every byte of the host may be encoded
+	if len(str) > maxEncodedLen {
+		return str, false
+	}
+	scheme, hostPort, found := strings.Cut(str, schemeHostSep)
+	if !found || strings.HasPrefix(hostPort, "[") { // no decoding for IPv6
+		return str, false
+	}
+	host, port := hostPort, ""
+	if i := strings.LastIndexByte(hostPort, hostPortSep); i >= 0 {
+		host, port = hostPort[:i], hostPort[i:]
+	}
+	if !strings.Contains(host, "%") {
+		return str, false
+	}
+	var sb strings.Builder
+	sb.Grow(len(str))
+	sb.WriteString(scheme)
+	sb.WriteString(schemeHostSep)
+	for i := 0; i < len(host); i++ {
+		b := host[i]
+		if b != '%' {
+			sb.WriteByte(b)
+			continue
+		}
+		if i+2 >= len(host) {
+			return str, false
+		}
+		hi, ok1 := unhex(host[i+1])
+		lo, ok2 := unhex(host[i+2])
+		if !ok1 || !ok2 {
+			return str, false
+		}
+		b = hi<<4 | lo
+		if !isLegalDecodedHostByte(b) {
+			return str, false
+		}
+		sb.WriteByte(b)
+		i += 2
+	}
+	sb.WriteString(port)
+	return sb.String(), true
+}
+
+func unhex(b byte) (byte, bool) {
+	switch {
+	case '0' <= b && b <= '9':
+		return b - '0', true
+	case 'a' <= b && b <= 'f':
+		return b - 'a' + 10, true
+	case 'A' <= b && b <= 'F':
+		return b - 'A' + 10, true
+	default:
+		return 0, false
+	}
+}
+
+func isLegalDecodedHostByte(b byte) bool {
+	return 'a' <= b && b <= 'z' ||
+		'0' <= b && b <= '9' ||
+		b == '-' ||
+		b == labelSep
+}
@@ -230,6 +230,71 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestNormalizeIPv4Shorthand(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{input: "http://127.1", want: "http://127.0.0.1", ok: true},
+		{input: "http://127.1:8080", want: "http://127.0.0.1:8080", ok: true},
+		{input: "https://10.1.2", want: "https://10.1.0.2", ok: true},
+		{input: "http://2130706433", want: "http://127.0.0.1", ok: true},
+		{input: "http://192.168.65535", want: "http://192.168.255.255", ok: true},
+		{input: "http://127.0.0.1", want: "http://127.0.0.1"},
+		{input: "http://127.01", want: "http://127.01"},
+		{input: "http://256.1", want: "http://256.1"},
+		{input: "http://192.168.65536", want: "http://192.168.65536"},
+		{input: "http://4294967296", want: "http://4294967296"},
+		{input: "http://[::1]", want: "http://[::1]"},
+		{input: "https://example.com", want: "https://example.com"},
+		{input: "null", want: "null"},
+	}
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			got, ok := NormalizeIPv4Shorthand(c.input)
+			if got != c.want || ok != c.ok {
+				t.Errorf("got %q, %t; want %q, %t", got, ok, c.want, c.ok)
+			}
+		}
+		t.Run(c.input, f)
+	}
+}
+
+func TestDecodePercentEncodedHost(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{input: "https://ex%61mple.com", want: "https://example.com", ok: true},
+		{input: "https://%65%78%61%6D%70%6C%65.com:8080", want: "https://example.com:8080", ok: true},
+		{input: "http://%31%32%37.1", want: "http://127.1", ok: true},
+		{input: "https://example%2Ecom", want: "https://example.com", ok: true},
+		{input: "https://example.com", want: "https://example.com"},
+		{input: "https://example.com:80%38", want: "https://example.com:80%38"},
+		{input: "https://example.com%2Fevil.com", want: "https://example.com%2Fevil.com"},
+		{input: "https://example.com%40evil.com", want: "https://example.com%40evil.com"},
+		{input: "https://example.com%3A443", want: "https://example.com%3A443"},
+		{input: "https://ex%41mple.com", want: "https://ex%41mple.com"},
+		{input: "https://ex%00mple.com", want: "https://ex%00mple.com"},
+		{input: "https://ex%2561mple.com", want: "https://ex%2561mple.com"},
+		{input: "https://ex%6", want: "https://ex%6"},
+		{input: "https://ex%zzmple.com", want: "https://ex%zzmple.com"},
+		{input: "http://[::1%25eth0]", want: "http://[::1%25eth0]"},
+		{input: "null", want: "null"},
+	}
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			got, ok := DecodePercentEncodedHost(c.input)
+			if got != c.want || ok != c.ok {
+				t.Errorf("got %q, %t; want %q, %t", got, ok, c.want, c.ok)
+			}
+		}
+		t.Run(c.input, f)
+	}
+}
+
 func BenchmarkParse(b *testing.B) {
 	for _, c := range parseCases {
 		f := func(b *testing.B) {
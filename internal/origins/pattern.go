@@ -3,6 +3,7 @@ package origins
 import (
 	"net/netip"
 	"strings"
+	"sync/atomic"
 
 	"github.com/jub0bs/cors/internal/origins/radix"
 	"github.com/jub0bs/cors/internal/util"
@@ -18,6 +19,8 @@ const (
 )
 
 const (
+	// marks zero, one, or more period-separated arbitrary DNS labels
+	apexAndSubdomainsWildcard = "**"
 	// marks one or more period-separated arbitrary DNS labels
 	subdomainWildcard = "*"
 	// marks an arbitrary (possibly implicit) port number
@@ -26,6 +29,12 @@ const (
 	anyPort int = radix.WildcardElem
 )
 
+// IsSupportedScheme reports whether scheme is one of the schemes
+// (http and https) that origin patterns support.
+func IsSupportedScheme(scheme string) bool {
+	return scheme == schemeHTTP || scheme == schemeHTTPS
+}
+
 // PatternKind represents the kind of a host pattern.
 type PatternKind uint8
 
@@ -61,6 +70,35 @@ func (p *Pattern) IsDeemedInsecure() bool {
 		p.hostOnly() != "localhost"
 }
 
+// IsDiscrete reports whether p encompasses exactly one origin,
+// i.e. whether p contains neither a subdomain wildcard nor a port wildcard.
+func (p *Pattern) IsDiscrete() bool {
+	return p.Kind != PatternKindSubdomains && p.Port != anyPort
+}
+
+// LooksLikeNetworkAddress reports whether the host of p is a non-loopback
+// IP address whose host bits are likely all zero,
+// i.e. an IPv4 address whose last octet is zero
+// or an IPv6 address whose last 64 bits are zero
+// (e.g. 10.0.0.0 or 2001:db8::).
+// Such a pattern encompasses only the origin whose host is that very
+// IP address, but its author may have intended it to denote
+// a whole IP range.
+func (p *Pattern) LooksLikeNetworkAddress() bool {
+	if p.Kind != PatternKindNonLoopbackIP {
+		return false
+	}
+	ip, err := netip.ParseAddr(p.Value)
+	if err != nil {
+		return false
+	}
+	if ip.Is4() {
+		return ip.As4()[3] == 0
+	}
+	b := ip.As16()
+	return [8]byte(b[8:]) == [8]byte{}
+}
+
 // HostIsEffectiveTLD, if the host of p is an effective top-level domain
 // (eTLD), also known as [public suffix],
 // returns the eTLD in question and true.
@@ -74,13 +112,33 @@ func (p *Pattern) HostIsEffectiveTLD() (string, bool) {
 	host = strings.TrimSuffix(host, string(labelSep))
 	// We ignore the second (boolean) result because
 	// it's false for some listed eTLDs (e.g. github.io)
-	etld, _ := publicsuffix.PublicSuffix(host)
+	etld, _ := (*publicSuffixFunc.Load())(host)
 	if etld == host {
 		return host, true
 	}
 	return "", false
 }
 
+// publicSuffixFunc holds the function that HostIsEffectiveTLD
+// relies on; see SetPublicSuffixFunc.
+var publicSuffixFunc atomic.Pointer[func(string) (string, bool)]
+
+func init() {
+	SetPublicSuffixFunc(nil)
+}
+
+// SetPublicSuffixFunc substitutes f for the function, by default
+// [publicsuffix.PublicSuffix], that HostIsEffectiveTLD relies on
+// to determine the public suffix of a domain.
+// If f is nil, SetPublicSuffixFunc restores the default.
+// SetPublicSuffixFunc is safe for concurrent use by multiple goroutines.
+func SetPublicSuffixFunc(f func(string) (string, bool)) {
+	if f == nil {
+		f = publicsuffix.PublicSuffix
+	}
+	publicSuffixFunc.Store(&f)
+}
+
 // ParsePattern parses str into a [Pattern] structure.
 func ParsePattern(str string) (Pattern, error) {
 	if str == "*" || str == "null" {
@@ -103,6 +161,10 @@ func ParsePattern(str string) (Pattern, error) {
 		const tmpl = `scheme "https" is incompatible with an IP address: %q`
 		return zeroPattern, util.Errorf(tmpl, full)
 	}
+	if hp.IsIP() && strings.HasPrefix(str, "/") {
+		const tmpl = "CIDR notation is unsupported in origin patterns: %q"
+		return zeroPattern, util.Errorf(tmpl, full)
+	}
 	var port int // assume no port
 	if len(str) > 0 {
 		str, ok = consume(string(hostPortSep), str)
@@ -134,6 +196,32 @@ func ParsePattern(str string) (Pattern, error) {
 
 var zeroPattern Pattern
 
+// SplitApexAndSubdomains checks whether the host pattern of str starts with
+// a double asterisk followed by a period (e.g. "https://**.example.com").
+// If so, it returns the pattern that encompasses only the subdomains
+// (e.g. "https://*.example.com"), the pattern that encompasses only
+// the apex (e.g. "https://example.com"), and true.
+// Otherwise, it returns two empty strings and false.
+// SplitApexAndSubdomains does not validate the resulting patterns.
+func SplitApexAndSubdomains(str string) (subdomains, apex string, ok bool) {
+	scheme, rest, ok := scanHttpScheme(str)
+	if !ok {
+		return "", "", false
+	}
+	rest, ok = consume(schemeHostSep, rest)
+	if !ok {
+		return "", "", false
+	}
+	rest, ok = consume(apexAndSubdomainsWildcard+string(labelSep), rest)
+	if !ok {
+		return "", "", false
+	}
+	prefix := scheme + schemeHostSep
+	subdomains = prefix + subdomainWildcard + string(labelSep) + rest
+	apex = prefix + rest
+	return subdomains, apex, true
+}
+
 // A HostPattern represents a host pattern.
 type HostPattern struct {
 	Value string      // Value is the host pattern's raw value.
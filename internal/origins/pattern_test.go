@@ -334,6 +334,14 @@ var parsePatternCases = []TestCase{
 		name:    "wildcard character sequence with IPv4",
 		input:   "http://*.127.0.0.1:3999",
 		failure: true,
+	}, {
+		name:    "CIDR notation with IPv4",
+		input:   "http://10.0.0.0/8",
+		failure: true,
+	}, {
+		name:    "CIDR notation with IPv6",
+		input:   "http://[2001:db8::]/32",
+		failure: true,
 	},
 }
 
@@ -408,6 +416,127 @@ func TestIsDeemedInsecure(t *testing.T) {
 	}
 }
 
+func TestLooksLikeNetworkAddress(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    bool
+	}{
+		{
+			pattern: "http://example.com",
+			want:    false,
+		}, {
+			pattern: "http://10.0.0.0",
+			want:    true,
+		}, {
+			pattern: "http://192.168.1.0:8080",
+			want:    true,
+		}, {
+			pattern: "http://192.168.1.1",
+			want:    false,
+		}, {
+			pattern: "http://127.0.0.0",
+			want:    false,
+		}, {
+			pattern: "http://[2001:db8::]",
+			want:    true,
+		}, {
+			pattern: "http://[2001:db8::1]",
+			want:    false,
+		},
+	}
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			spec, err := ParsePattern(c.pattern)
+			if err != nil {
+				t.Errorf("got %v; want non-nil error", err)
+				return
+			}
+			got := spec.LooksLikeNetworkAddress()
+			if got != c.want {
+				t.Errorf("got %t; want %t", got, c.want)
+			}
+		}
+		t.Run(c.pattern, f)
+	}
+}
+
+func TestIsDiscrete(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    bool
+	}{
+		{
+			pattern: "https://example.com",
+			want:    true,
+		}, {
+			pattern: "http://localhost:9090",
+			want:    true,
+		}, {
+			pattern: "http://[::1]:90",
+			want:    true,
+		}, {
+			pattern: "https://*.example.com",
+			want:    false,
+		}, {
+			pattern: "http://localhost:*",
+			want:    false,
+		},
+	}
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			spec, err := ParsePattern(c.pattern)
+			if err != nil {
+				t.Errorf("got %v; want non-nil error", err)
+				return
+			}
+			got := spec.IsDiscrete()
+			if got != c.want {
+				t.Errorf("got %t; want %t", got, c.want)
+			}
+		}
+		t.Run(c.pattern, f)
+	}
+}
+
+func TestSplitApexAndSubdomains(t *testing.T) {
+	cases := []struct {
+		pattern    string
+		subdomains string
+		apex       string
+		ok         bool
+	}{
+		{
+			pattern:    "https://**.example.com",
+			subdomains: "https://*.example.com",
+			apex:       "https://example.com",
+			ok:         true,
+		}, {
+			pattern:    "http://**.example.com:8080",
+			subdomains: "http://*.example.com:8080",
+			apex:       "http://example.com:8080",
+			ok:         true,
+		}, {
+			pattern: "https://*.example.com",
+		}, {
+			pattern: "https://example.com",
+		}, {
+			pattern: "https://**example.com",
+		}, {
+			pattern: "ftp://**.example.com",
+		},
+	}
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			subdomains, apex, ok := SplitApexAndSubdomains(c.pattern)
+			if subdomains != c.subdomains || apex != c.apex || ok != c.ok {
+				const tmpl = "got %q, %q, %t; want %q, %q, %t"
+				t.Errorf(tmpl, subdomains, apex, ok, c.subdomains, c.apex, c.ok)
+			}
+		}
+		t.Run(c.pattern, f)
+	}
+}
+
 func TestHostIsEffectiveTLD(t *testing.T) {
 	cases := []struct {
 		pattern string
@@ -0,0 +1,146 @@
+package radix
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+
+	"github.com/jub0bs/cors/internal/util"
+)
+
+// The binary form of a Tree consists in the binary form of its root node.
+// The binary form of a node consists in
+//   - the length of its suffix (as a uvarint), followed by the suffix itself;
+//   - the binary form of its set, then that of its wildcard set;
+//   - its number of edges (as a uvarint), followed by,
+//     for each edge (in increasing order of label),
+//     the edge's label (a single byte) and the binary form of the child node.
+//
+// The binary form of a set consists in its cardinality (as a uvarint),
+// followed by its elements (each as a varint) in increasing order.
+
+var errMalformed = errors.New("malformed binary tree")
+
+// AppendBinary appends the binary form of t to b and returns the result.
+func (t *Tree) AppendBinary(b []byte) ([]byte, error) {
+	return t.root.appendBinary(b), nil
+}
+
+// UnmarshalBinary replaces the contents of t by the contents of the tree
+// whose binary form (as produced by [Tree.AppendBinary]) is data.
+// It only performs sanity checks on data;
+// in particular, it does not check that data was produced by AppendBinary.
+func (t *Tree) UnmarshalBinary(data []byte) error {
+	var root node
+	rest, err := root.decode(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errMalformed
+	}
+	t.root = root
+	return nil
+}
+
+func (n *node) appendBinary(b []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(n.suf)))
+	b = append(b, n.suf...)
+	b = appendSet(b, n.set)
+	b = appendSet(b, n.wSet)
+	b = binary.AppendUvarint(b, uint64(len(n.edges)))
+	labels := make([]byte, 0, len(n.edges))
+	for label := range n.edges {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	for _, label := range labels {
+		b = append(b, label)
+		b = n.edges[label].appendBinary(b)
+	}
+	return b
+}
+
+func appendSet(b []byte, set util.Set[int]) []byte {
+	b = binary.AppendUvarint(b, uint64(len(set)))
+	for _, elem := range set.ToSortedSlice() {
+		b = binary.AppendVarint(b, int64(elem))
+	}
+	return b
+}
+
+func (n *node) decode(b []byte) ([]byte, error) {
+	l, b, err := decodeUvarint(b)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) < l {
+		return nil, errMalformed
+	}
+	n.suf, b = string(b[:l]), b[l:]
+	if n.set, b, err = decodeSet(b); err != nil {
+		return nil, err
+	}
+	if n.wSet, b, err = decodeSet(b); err != nil {
+		return nil, err
+	}
+	numEdges, b, err := decodeUvarint(b)
+	if err != nil {
+		return nil, err
+	}
+	for range numEdges {
+		if len(b) == 0 {
+			return nil, errMalformed
+		}
+		var label byte
+		label, b = b[0], b[1:]
+		child := new(node)
+		if b, err = child.decode(b); err != nil {
+			return nil, err
+		}
+		// By construction of a Tree, the label of an edge is
+		// the last byte of the suffix of the child node.
+		if last, ok := lastByte(child.suf); !ok || last != label {
+			return nil, errMalformed
+		}
+		n.insertEdge(label, child)
+	}
+	return b, nil
+}
+
+func decodeSet(b []byte) (util.Set[int], []byte, error) {
+	size, b, err := decodeUvarint(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if size == 0 {
+		return nil, b, nil
+	}
+	if uint64(len(b)) < size { // each element takes at least one byte
+		return nil, nil, errMalformed
+	}
+	set := make(util.Set[int], size)
+	for range size {
+		elem, n := binary.Varint(b)
+		if n <= 0 || elem < WildcardElem {
+			return nil, nil, errMalformed
+		}
+		set.Add(int(elem))
+		b = b[n:]
+	}
+	if set.Contains(WildcardElem) {
+		if len(set) != 1 {
+			return nil, nil, errMalformed
+		}
+		set = wildcardSingleton
+	}
+	return set, b, nil
+}
+
+func decodeUvarint(b []byte) (uint64, []byte, error) {
+	x, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, errMalformed
+	}
+	return x, b[n:], nil
+}
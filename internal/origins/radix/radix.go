@@ -88,6 +88,48 @@ func (t *Tree) Insert(keyPattern string, v int) {
 	}
 }
 
+// ContainsWhich reports whether t contains key-value pair (k,v) and,
+// if so, also returns the textual representation (in the format used by
+// [Tree.Elems]) of the element of t that matched.
+// ContainsWhich is costlier than [Tree.Contains] and should therefore
+// only be used when the matched element is of interest.
+func (t *Tree) ContainsWhich(k string, v int) (string, bool) {
+	full := k
+	n := &t.root
+	for {
+		label, ok := lastByte(k)
+		if !ok {
+			switch {
+			case n.set.Contains(v):
+				return format(full, v, false), true
+			case n.set.Contains(WildcardElem):
+				return format(full, WildcardElem, false), true
+			default:
+				return "", false
+			}
+		}
+
+		// k is not empty
+		switch suf := full[len(k):]; {
+		case n.wSet.Contains(v):
+			return format(suf, v, true), true
+		case n.wSet.Contains(WildcardElem):
+			return format(suf, WildcardElem, true), true
+		}
+
+		n = n.edges[label]
+		if n == nil {
+			return "", false
+		}
+
+		prefixOfK, _, suf := splitAtCommonSuffix(k, n.suf)
+		if len(suf) != len(n.suf) { // n.suf is NOT a suffix of k
+			return "", false
+		}
+		k = prefixOfK
+	}
+}
+
 // Contains reports whether t contains key-value pair (k,v).
 func (t *Tree) Contains(k string, v int) bool {
 	n := &t.root
@@ -212,30 +254,28 @@ type edges = map[byte]*node
 func (n *node) Elems(dst *[]string, suf string) {
 	suf = n.suf + suf
 	for port := range n.set {
-		var s string
-		switch port {
-		case WildcardElem:
-			s = suf + ":*"
-		case 0:
-			s = suf
-		default:
-			s = suf + ":" + strconv.Itoa(port)
-		}
-		*dst = append(*dst, s)
+		*dst = append(*dst, format(suf, port, false))
 	}
 	for port := range n.wSet {
-		var s string
-		switch port {
-		case WildcardElem:
-			s = "*" + suf + ":*"
-		case 0:
-			s = "*" + suf
-		default:
-			s = "*" + suf + ":" + strconv.Itoa(port)
-		}
-		*dst = append(*dst, s)
+		*dst = append(*dst, format(suf, port, true))
 	}
 	for _, child := range n.edges {
 		child.Elems(dst, suf)
 	}
 }
+
+// format returns the textual representation of the element of key suf
+// and value port, whose key has a leading asterisk if wildcard is true.
+func format(suf string, port int, wildcard bool) string {
+	if wildcard {
+		suf = "*" + suf
+	}
+	switch port {
+	case WildcardElem:
+		return suf + ":*"
+	case 0:
+		return suf
+	default:
+		return suf + ":" + strconv.Itoa(port)
+	}
+}
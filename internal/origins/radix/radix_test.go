@@ -1,6 +1,7 @@
 package radix_test
 
 import (
+	"reflect"
 	"slices"
 	"testing"
 
@@ -436,11 +437,25 @@ func TestRadix(t *testing.T) {
 			if !slices.Equal(elems, tc.elems) {
 				t.Errorf("got %q; want %q", elems, tc.elems)
 			}
+			data, err := tree.AppendBinary(nil)
+			if err != nil {
+				t.Fatalf("AppendBinary: got error %v; want nil", err)
+			}
+			var copied radix.Tree
+			if err := copied.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary: got error %v; want nil", err)
+			}
+			if !reflect.DeepEqual(copied, tree) {
+				t.Error("tree does not survive a binary round trip")
+			}
 			var (
 				topHeader    bool
 				acceptHeader bool
 			)
 			for _, pair := range tc.accept {
+				if elem, ok := tree.ContainsWhich(pair.key, pair.value); !ok || !slices.Contains(elems, elem) {
+					t.Errorf("ContainsWhich(%v): got %q, %t; want one of %q, true", pair, elem, ok, elems)
+				}
 				if !tree.Contains(pair.key, pair.value) {
 					if !topHeader {
 						logMsgHeader(t, tc.patterns)
@@ -455,6 +470,9 @@ func TestRadix(t *testing.T) {
 			}
 			var rejectHeader bool
 			for _, pair := range tc.reject {
+				if elem, ok := tree.ContainsWhich(pair.key, pair.value); ok {
+					t.Errorf("ContainsWhich(%v): got %q, true; want false", pair, elem)
+				}
 				if tree.Contains(pair.key, pair.value) {
 					if !topHeader {
 						logMsgHeader(t, tc.patterns)
@@ -479,3 +497,53 @@ func logMsgHeader(t *testing.T, pairs []Pair) {
 		t.Logf("\t- %v\n", pair)
 	}
 }
+
+func TestContainsWhich(t *testing.T) {
+	var tree radix.Tree
+	tree.Insert("example.com", 0)
+	tree.Insert("*.example.com", 0)
+	tree.Insert("*.example.com", 8080)
+	tree.Insert("localhost", radix.WildcardElem)
+	cases := []struct {
+		key   string
+		value int
+		want  string
+		ok    bool
+	}{
+		{"example.com", 0, "example.com", true},
+		{"a.example.com", 0, "*.example.com", true},
+		{"b.a.example.com", 8080, "*.example.com:8080", true},
+		{"localhost", 9090, "localhost:*", true},
+		{"localhost", 0, "localhost:*", true},
+		{"example.com", 8080, "", false},
+		{"example.org", 0, "", false},
+	}
+	for _, c := range cases {
+		got, ok := tree.ContainsWhich(c.key, c.value)
+		if got != c.want || ok != c.ok {
+			const tmpl = "ContainsWhich(%q, %d): got %q, %t; want %q, %t"
+			t.Errorf(tmpl, c.key, c.value, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestUnmarshalBinaryOfMalformedData(t *testing.T) {
+	var tree radix.Tree
+	tree.Insert("example.com", 0)
+	tree.Insert("*.example.com", 8080)
+	tree.Insert("localhost", radix.WildcardElem)
+	data, err := tree.AppendBinary(nil)
+	if err != nil {
+		t.Fatalf("AppendBinary: got error %v; want nil", err)
+	}
+	for i := range len(data) {
+		var dst radix.Tree
+		if err := dst.UnmarshalBinary(data[:i]); err == nil {
+			t.Errorf("UnmarshalBinary(data[:%d]): got nil error; want non-nil error", i)
+		}
+	}
+	var dst radix.Tree
+	if err := dst.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("UnmarshalBinary with trailing data: got nil error; want non-nil error")
+	}
+}
@@ -0,0 +1,116 @@
+package cors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/jub0bs/cors/internal/headers"
+	"github.com/jub0bs/cors/internal/util"
+)
+
+// maxConfigJSONBytes is the maximum size of the JSON documents that
+// ConfigFromJSON accepts.
+const maxConfigJSONBytes = 1 << 20 // 1 MiB
+
+// ConfigFromJSON decodes a Config from the JSON document read from r,
+// whose schema is the one that [encoding/json] derives from Config:
+// JSON object keys are the names of Config's fields and, because
+// ExtraConfig is embedded in Config, of ExtraConfig's fields;
+// func-valued settings (e.g. ExtraConfig.ResponseHeaderHook)
+// cannot be specified.
+// For example:
+//
+//	{
+//	  "Origins": ["https://example.com"],
+//	  "Credentialed": true,
+//	  "PreflightSuccessStatus": 200
+//	}
+//
+// Because it is meant for parsing untrusted input (e.g. the body of
+// requests sent to some configuration portal), ConfigFromJSON is strict:
+// it reads at most 1 MiB from r and returns some non-nil error
+// if the document is larger,
+// if it contains unknown fields, or if it is followed by other data.
+//
+// Like [ConfigFromEnv], ConfigFromJSON only reports syntactic errors;
+// the validity of the resulting Config is only checked
+// when you pass it to [NewMiddleware] or [*Middleware.Reconfigure].
+//
+// For any valid Config, json.Marshal(cfg) produces a document that
+// ConfigFromJSON decodes to a Config equivalent to cfg
+// (func-valued settings notwithstanding).
+func ConfigFromJSON(r io.Reader) (Config, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxConfigJSONBytes+1))
+	if err != nil {
+		return Config{}, err
+	}
+	if len(data) > maxConfigJSONBytes {
+		const tmpl = "JSON configuration exceeds %d bytes"
+		return Config{}, util.Errorf(tmpl, maxConfigJSONBytes)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, util.Errorf("invalid JSON configuration: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		const msg = "invalid JSON configuration: unexpected data after top-level value"
+		return Config{}, util.NewError(msg)
+	}
+	return cfg, nil
+}
+
+// A diagnostic is the JSON document that the handler returned by
+// [*Middleware.DiagnosticHandler] serves.
+type diagnostic struct {
+	Version uint64
+	Debug   bool
+	Config  *Config // nil for a passthrough middleware
+}
+
+// DiagnosticHandler returns a handler that reports m's live state
+// as a JSON document (with a Content-Type of application/json)
+// whose Config member describes m's current configuration
+// (as reported by [*Middleware.Config]) according to the schema
+// documented in [ConfigFromJSON], and whose Version and Debug members
+// respectively report m's version (see [*Middleware.Version])
+// and whether its debug mode is on.
+// All three members are read atomically.
+// The Config member is null if m is a passthrough middleware;
+// func-valued settings (e.g. ExtraConfig.ResponseHeaderHook) are omitted.
+// The handler only serves GET and HEAD requests;
+// it responds to other requests with a 405 (Method Not Allowed) status.
+//
+// DiagnosticHandler is meant to be mounted on some internal route
+// of your server:
+//
+//	mux.Handle("GET /debug/cors", corsMw.DiagnosticHandler())
+//
+// Because the document it serves discloses m's entire configuration,
+// you should only mount that handler on some internal or authorized endpoint,
+// for security reasons.
+func (m *Middleware) DiagnosticHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set(headers.Allow, "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		m.mu.RLock()
+		icfg, d := m.icfg, diagnostic{Version: m.version, Debug: m.debug}
+		m.mu.RUnlock()
+		d.Config = newConfig(icfg)
+		data, err := json.Marshal(d)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resHdrs := w.Header()
+		resHdrs.Set(headers.ContentType, "application/json")
+		resHdrs.Set(headers.CacheControl, "no-store")
+		w.Write(data)
+	})
+}
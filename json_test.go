@@ -0,0 +1,219 @@
+package cors_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestConfigFromJSON(t *testing.T) {
+	cases := []struct {
+		desc string
+		json string
+		want cors.Config
+	}{
+		{
+			desc: "empty object",
+			json: `{}`,
+		}, {
+			desc: "trailing whitespace",
+			json: "{\"Origins\":[\"https://example.com\"]}\n",
+			want: cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+		}, {
+			desc: "extra config",
+			json: `{
+				"Origins": ["https://example.com"],
+				"Credentialed": true,
+				"Methods": ["PUT"],
+				"PreflightSuccessStatus": 200,
+				"VaryStrategy": 1,
+				"SlowPreflightThreshold": 250000000,
+				"DeprecatedOrigins": {
+					"https://example.com": "2025-03-01T00:00:00Z"
+				}
+			}`,
+			want: cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				Methods:      []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightSuccessStatus: 200,
+					VaryStrategy:           cors.VaryOriginOnly,
+					SlowPreflightThreshold: 250 * time.Millisecond,
+					DeprecatedOrigins: map[string]time.Time{
+						"https://example.com": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			got, err := cors.ConfigFromJSON(strings.NewReader(tc.json))
+			if err != nil {
+				t.Fatalf("got error %v; want nil error", err)
+			}
+			assertConfigEqual(t, &got, &tc.want)
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestConfigFromJSONRoundTrip(t *testing.T) {
+	cfg := cors.Config{
+		Origins:         []string{"https://example.com", "https://*.example.com"},
+		Credentialed:    true,
+		Methods:         []string{http.MethodPut, http.MethodDelete},
+		RequestHeaders:  []string{"X-Foo"},
+		MaxAgeInSeconds: 30,
+		ResponseHeaders: []string{"X-Bar"},
+		ExtraConfig: cors.ExtraConfig{
+			PreflightStatusByMethod: map[string]int{http.MethodPut: 201},
+			ResponseHeaderHook:      func(http.Header) {},
+			InjectDecision:          true,
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	want := mw.Config()
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("failure to marshal config: %v", err)
+	}
+	got, err := cors.ConfigFromJSON(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("got error %v; want nil error", err)
+	}
+	// func-valued settings cannot survive the round trip
+	want.ResponseHeaderHook = nil
+	assertConfigEqual(t, &got, want)
+}
+
+func TestConfigFromJSONWithInvalidInput(t *testing.T) {
+	cases := []struct {
+		desc string
+		json string
+		msg  string
+	}{
+		{
+			desc: "empty",
+			json: "",
+			msg:  "cors: invalid JSON configuration: EOF",
+		}, {
+			desc: "unknown field",
+			json: `{"Origins":["https://example.com"],"AllowCredentials":true}`,
+			msg:  `cors: invalid JSON configuration: json: unknown field "AllowCredentials"`,
+		}, {
+			desc: "nested extra config",
+			json: `{"ExtraConfig":{"ReportOnly":true}}`,
+			msg:  `cors: invalid JSON configuration: json: unknown field "ExtraConfig"`,
+		}, {
+			desc: "func-valued field",
+			json: `{"ResponseHeaderHook":null}`,
+			msg:  `cors: invalid JSON configuration: json: unknown field "ResponseHeaderHook"`,
+		}, {
+			desc: "type mismatch",
+			json: `{"Credentialed":"yes"}`,
+			msg: "cors: invalid JSON configuration: json: cannot unmarshal " +
+				"string into Go struct field Config.Credentialed of type bool",
+		}, {
+			desc: "trailing data",
+			json: `{"Origins":["https://example.com"]}{}`,
+			msg:  "cors: invalid JSON configuration: unexpected data after top-level value",
+		}, {
+			desc: "trailing closing brace",
+			json: `{"Origins":["https://example.com"]}}`,
+			msg:  "cors: invalid JSON configuration: unexpected data after top-level value",
+		}, {
+			desc: "oversized",
+			json: `{"Origins":["https://example.com"],"ResponseHeaders":[` +
+				strings.Repeat(`"X-Foo",`, 1<<17) + `"X-Bar"]}`,
+			msg: "cors: JSON configuration exceeds 1048576 bytes",
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			_, err := cors.ConfigFromJSON(strings.NewReader(tc.json))
+			if err == nil {
+				t.Fatal("got nil error; want non-nil error")
+			}
+			if got := err.Error(); got != tc.msg {
+				t.Errorf("got error\n%s\nwant\n%s", got, tc.msg)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestDiagnosticHandler(t *testing.T) {
+	cfg := cors.Config{
+		Origins: []string{"https://example.com"},
+		Methods: []string{http.MethodPut},
+		ExtraConfig: cors.ExtraConfig{
+			ResponseHeaderHook: func(http.Header) {},
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	mw.SetDebug(true)
+	handler := mw.DiagnosticHandler()
+	type diagnostic struct {
+		Version uint64
+		Debug   bool
+		Config  json.RawMessage
+	}
+	serve := func() (*httptest.ResponseRecorder, diagnostic) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest(http.MethodGet, nil))
+		var d diagnostic
+		if err := json.Unmarshal(rec.Body.Bytes(), &d); err != nil {
+			t.Fatalf("invalid JSON document %q: %v", rec.Body, err)
+		}
+		return rec, d
+	}
+
+	rec, d := serve()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d; want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q; want application/json", got)
+	}
+	if d.Version != 0 || !d.Debug {
+		t.Errorf("got version %d and debug %t; want 0 and true", d.Version, d.Debug)
+	}
+	got, err := cors.ConfigFromJSON(strings.NewReader(string(d.Config)))
+	if err != nil {
+		t.Fatalf("got error %v; want nil error", err)
+	}
+	if !slices.Equal(got.Origins, cfg.Origins) || !slices.Equal(got.Methods, cfg.Methods) {
+		t.Errorf("got config %+v; want %+v", got, cfg)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest(http.MethodPost, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got status %d; want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+
+	if err := mw.Reconfigure(nil); err != nil {
+		t.Fatalf("got error %v; want nil error", err)
+	}
+	_, d = serve()
+	if d.Version != 1 || d.Debug || string(d.Config) != "null" {
+		const tmpl = "passthrough: got version %d, debug %t, and config %s; want 1, false, and null"
+		t.Errorf(tmpl, d.Version, d.Debug, d.Config)
+	}
+}
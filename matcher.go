@@ -0,0 +1,143 @@
+package cors
+
+import (
+	"github.com/jub0bs/cors/internal/origins"
+	"github.com/jub0bs/cors/internal/util"
+)
+
+// An OriginMatcher is a precompiled set of allowed origins.
+// Building a large OriginMatcher (e.g. one that contains 100k origin
+// patterns) once, serializing it via its [*OriginMatcher.MarshalBinary]
+// method, and loading the result via [*OriginMatcher.UnmarshalBinary]
+// is cheaper than parsing all of those origin patterns anew every time;
+// see [NewMiddlewareFromMatcher].
+//
+// The zero value is an empty matcher, which matches no origins.
+type OriginMatcher struct {
+	corpus origins.Corpus
+}
+
+// NewOriginMatcher returns an OriginMatcher that matches all the origins
+// encompassed by patterns, or some non-nil error if patterns is empty
+// or contains some invalid or prohibited origin pattern.
+// The rules that NewOriginMatcher enforces are those that [NewMiddleware]
+// enforces for the elements of the Config.Origins field, with the following
+// exceptions: the single-asterisk pattern is prohibited,
+// and patterns that encompass all subdomains of a public suffix
+// are invariably prohibited.
+func NewOriginMatcher(patterns ...string) (*OriginMatcher, error) {
+	icfg := internalConfig{
+		tmp: new(tmpConfig),
+	}
+	if err := icfg.validateOrigins(patterns); err != nil {
+		return nil, err
+	}
+	if icfg.allowAnyOrigin {
+		const msg = "an origin matcher cannot contain the single-asterisk pattern"
+		return nil, util.NewError(msg)
+	}
+	// As all other settings have their zero value in icfg,
+	// the only checks that validate performs pertain to origin patterns.
+	if err := icfg.validate(); err != nil {
+		return nil, err
+	}
+	return &OriginMatcher{corpus: icfg.corpus}, nil
+}
+
+// Contains reports whether m matches origin.
+func (m *OriginMatcher) Contains(origin string) bool {
+	if len(origin) > origins.MaxLen { // fail fast
+		return false
+	}
+	o, ok := origins.Parse(origin)
+	return ok && m.corpus.Contains(&o)
+}
+
+// A ParsedOrigin is a Web origin that has been parsed once and for all;
+// see [ParseOrigin] and [*OriginMatcher.MatchesParsed].
+// The zero value is matched by no OriginMatcher.
+type ParsedOrigin struct {
+	o origins.Origin
+}
+
+// ParseOrigin parses origin, which is typically the value of a request's
+// Origin header, and reports whether it is a valid Web origin.
+// Origins that no OriginMatcher could possibly match (e.g. null)
+// are reported as invalid.
+func ParseOrigin(origin string) (ParsedOrigin, bool) {
+	o, ok := origins.Parse(origin)
+	if !ok {
+		return ParsedOrigin{}, false
+	}
+	return ParsedOrigin{o: o}, true
+}
+
+// MatchesParsed reports whether m matches o.
+// For a given origin, MatchesParsed is equivalent to
+// [*OriginMatcher.Contains] but skips the cost of parsing the origin.
+// It is meant for bulk evaluation (e.g. re-checking a cached set of
+// origins against a newly loaded matcher), in which pre-parsing the origins
+// once via [ParseOrigin] pays off.
+func (m *OriginMatcher) MatchesParsed(o ParsedOrigin) bool {
+	return m.corpus.Contains(&o.o)
+}
+
+// MarshalBinary implements [encoding.BinaryMarshaler].
+// The binary form of an OriginMatcher is unspecified and may change
+// across versions of this package; however,
+// [*OriginMatcher.UnmarshalBinary] rejects binary forms produced by
+// incompatible versions.
+func (m *OriginMatcher) MarshalBinary() ([]byte, error) {
+	return m.corpus.AppendBinary(nil)
+}
+
+// UnmarshalBinary implements [encoding.BinaryUnmarshaler].
+// If data is malformed, UnmarshalBinary leaves m unchanged and returns
+// some non-nil error.
+//
+// Beware: UnmarshalBinary only performs sanity checks on data;
+// in particular, it performs none of the validation
+// that [NewOriginMatcher] performs.
+// Therefore, you should only ever load binary forms from trusted sources
+// (e.g. binary forms produced by MarshalBinary in your own control plane
+// and stored securely).
+func (m *OriginMatcher) UnmarshalBinary(data []byte) error {
+	var corpus origins.Corpus
+	if err := corpus.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	m.corpus = corpus
+	return nil
+}
+
+// NewMiddlewareFromMatcher is like [NewMiddleware], except that
+// the resulting middleware allows the origins matched by matcher;
+// cfg.Origins must therefore be empty.
+// NewMiddlewareFromMatcher skips the cost of parsing origin patterns,
+// which makes it well suited for very large allowlists.
+//
+// Note that, contrary to NewMiddleware, NewMiddlewareFromMatcher performs
+// none of the checks that pertain to the combination of origin patterns
+// with other settings (e.g. insecure origins with credentialed access);
+// the same goes for the warnings that [*Middleware.Warnings] would report.
+// For this reason, you should only use NewMiddlewareFromMatcher with
+// trusted, pre-validated allowlists.
+// The policy set by [SetConfigPolicy], however, applies in full
+// to the origins that matcher matches.
+//
+// The resulting middleware doesn't retain any reference to matcher;
+// subsequent calls to matcher's UnmarshalBinary method
+// don't affect the middleware.
+func NewMiddlewareFromMatcher(matcher *OriginMatcher, cfg Config) (*Middleware, error) {
+	if len(matcher.corpus) == 0 {
+		const msg = "the origin matcher must match at least one origin"
+		return nil, util.NewError(msg)
+	}
+	var m Middleware
+	icfg, err := newInternalConfigWithCorpus(&cfg, matcher.corpus)
+	if err != nil {
+		return nil, err
+	}
+	m.icfg = icfg
+	return &m, nil
+}
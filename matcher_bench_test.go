@@ -0,0 +1,44 @@
+package cors_test
+
+import (
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func BenchmarkOriginMatcher(b *testing.B) {
+	matcher, err := cors.NewOriginMatcher(
+		"https://example.com",
+		"https://*.example.org",
+		"http://localhost:*",
+	)
+	if err != nil {
+		b.Fatalf("got error %v; want nil error", err)
+	}
+	origins := []string{
+		"https://example.com",
+		"https://foo.bar.example.org",
+		"http://localhost:9090",
+		"https://attacker.example.net",
+	}
+	parsed := make([]cors.ParsedOrigin, len(origins))
+	for i, origin := range origins {
+		parsed[i], _ = cors.ParseOrigin(origin)
+	}
+	b.Run("parse then match", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			for _, origin := range origins {
+				_ = matcher.Contains(origin)
+			}
+		}
+	})
+	b.Run("match pre-parsed", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			for _, o := range parsed {
+				_ = matcher.MatchesParsed(o)
+			}
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestOriginMatcher(t *testing.T) {
+	patterns := []string{
+		"https://example.com",
+		"https://*.example.org",
+		"http://localhost:*",
+	}
+	orig, err := cors.NewOriginMatcher(patterns...)
+	if err != nil {
+		t.Fatalf("got error %v; want nil error", err)
+	}
+	data, err := orig.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: got error %v; want nil error", err)
+	}
+	var matcher cors.OriginMatcher
+	if err := matcher.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: got error %v; want nil error", err)
+	}
+	accepts := []string{
+		"https://example.com",
+		"https://foo.example.org",
+		"http://localhost",
+		"http://localhost:9090",
+	}
+	rejects := []string{
+		"http://example.com",
+		"https://example.org",
+		"https://localhost:9090",
+		"null",
+	}
+	for _, m := range []*cors.OriginMatcher{orig, &matcher} {
+		for _, origin := range accepts {
+			if !m.Contains(origin) {
+				t.Errorf("Contains(%q): got false; want true", origin)
+			}
+		}
+		for _, origin := range rejects {
+			if m.Contains(origin) {
+				t.Errorf("Contains(%q): got true; want false", origin)
+			}
+		}
+		for _, origin := range accepts {
+			o, ok := cors.ParseOrigin(origin)
+			if !ok {
+				t.Errorf("ParseOrigin(%q): got false; want true", origin)
+			}
+			if !m.MatchesParsed(o) {
+				t.Errorf("MatchesParsed(%q): got false; want true", origin)
+			}
+		}
+		for _, origin := range rejects {
+			o, _ := cors.ParseOrigin(origin)
+			if m.MatchesParsed(o) {
+				t.Errorf("MatchesParsed(%q): got true; want false", origin)
+			}
+		}
+	}
+
+	mw, err := cors.NewMiddlewareFromMatcher(&matcher, cors.Config{
+		Credentialed: true,
+	})
+	if err != nil {
+		t.Fatalf("NewMiddlewareFromMatcher: got error %v; want nil error", err)
+	}
+	for _, origin := range accepts {
+		rec := httptest.NewRecorder()
+		req := newRequest(http.MethodGet, Headers{headerOrigin: origin})
+		mw.Wrap(http.NotFoundHandler()).ServeHTTP(rec, req)
+		if got := rec.Header().Get(headerACAO); got != origin {
+			t.Errorf("%s: got %q; want %q", headerACAO, got, origin)
+		}
+	}
+	wantOrigins := []string{
+		"http://localhost:*",
+		"https://*.example.org",
+		"https://example.com",
+	}
+	if got := mw.Config().Origins; !slices.Equal(got, wantOrigins) {
+		t.Errorf("Config().Origins: got %q; want %q", got, wantOrigins)
+	}
+
+	// Unmarshaling some other matcher doesn't affect the middleware.
+	other, err := cors.NewOriginMatcher("https://example.net")
+	if err != nil {
+		t.Fatalf("got error %v; want nil error", err)
+	}
+	data, _ = other.MarshalBinary()
+	if err := matcher.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: got error %v; want nil error", err)
+	}
+	if got := mw.Config().Origins; !slices.Equal(got, wantOrigins) {
+		t.Errorf("Config().Origins: got %q; want %q", got, wantOrigins)
+	}
+}
+
+func TestIncorrectOriginMatcher(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		errMsg   string
+	}{
+		{
+			errMsg: "cors: at least one origin pattern must be specified",
+		}, {
+			patterns: []string{"*"},
+			errMsg:   "cors: an origin matcher cannot contain the single-asterisk pattern",
+		}, {
+			patterns: []string{"https://example.com/"},
+			errMsg:   `cors: invalid origin pattern "https://example.com/"`,
+		}, {
+			patterns: []string{"https://*.com"},
+			errMsg: `cors: for security reasons, origin patterns like ` +
+				`"https://*.com" that encompass subdomains of a public suffix ` +
+				`are by default prohibited`,
+		},
+	}
+	for _, c := range cases {
+		_, err := cors.NewOriginMatcher(c.patterns...)
+		if err == nil || err.Error() != c.errMsg {
+			t.Errorf("NewOriginMatcher(%q...): got error %v; want %q", c.patterns, err, c.errMsg)
+		}
+	}
+
+	var matcher cors.OriginMatcher
+	if err := matcher.UnmarshalBinary([]byte("garbage")); err == nil {
+		t.Error("UnmarshalBinary: got nil error; want non-nil error")
+	}
+	_, err := cors.NewMiddlewareFromMatcher(&matcher, cors.Config{})
+	const emptyMsg = "cors: the origin matcher must match at least one origin"
+	if err == nil || err.Error() != emptyMsg {
+		t.Errorf("NewMiddlewareFromMatcher: got error %v; want %q", err, emptyMsg)
+	}
+
+	m, err := cors.NewOriginMatcher("https://example.com")
+	if err != nil {
+		t.Fatalf("got error %v; want nil error", err)
+	}
+	_, err = cors.NewMiddlewareFromMatcher(m, cors.Config{
+		Origins: []string{"https://example.org"},
+	})
+	const bothMsg = "cors: you cannot specify origin patterns in addition to an origin matcher"
+	if err == nil || err.Error() != bothMsg {
+		t.Errorf("NewMiddlewareFromMatcher: got error %v; want %q", err, bothMsg)
+	}
+	_, err = cors.NewMiddlewareFromMatcher(m, cors.Config{
+		ExtraConfig: cors.ExtraConfig{
+			PreserveOriginOrder: true,
+		},
+	})
+	const orderMsg = "cors: you cannot preserve the order of origin patterns " +
+		"when using an origin matcher"
+	if err == nil || err.Error() != orderMsg {
+		t.Errorf("NewMiddlewareFromMatcher: got error %v; want %q", err, orderMsg)
+	}
+}
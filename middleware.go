@@ -1,13 +1,23 @@
 package cors
 
 import (
+	"cmp"
+	"hash/fnv"
+	"io"
 	"maps"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jub0bs/cors/internal/headers"
 	"github.com/jub0bs/cors/internal/methods"
 	"github.com/jub0bs/cors/internal/origins"
+	"github.com/jub0bs/cors/internal/util"
 )
 
 // A Middleware is a CORS middleware.
@@ -19,7 +29,8 @@ import (
 // and pass it a valid [Config].
 //
 // Middleware have a debug mode,
-// which can be toggled by calling their [*Middleware.SetDebug] method.
+// which can be toggled by calling their [*Middleware.SetDebug] method
+// or turned on temporarily by calling their [*Middleware.SetDebugFor] method.
 // You should turn debug mode on whenever you're struggling to troubleshoot
 // some [CORS-preflight] issue.
 // When debug mode is off, the information that the middleware includes in
@@ -30,6 +41,16 @@ import (
 // the middleware includes just enough contextual information about the
 // preflight failure in the response for browsers to produce
 // a helpful CORS error message.
+// Moreover, when debug mode is on, the middleware includes non-standard
+// headers in some failed preflight responses.
+// X-Debug-Rejected-Header names the first disallowed request-header name.
+// X-Debug-Forbidden-Method names the requested method
+// if it is a [forbidden method] (e.g. CONNECT),
+// which no browser would ever request.
+// X-Debug-PNA, whose value is requested-but-disabled, signals that
+// the client requested [Private-Network Access] but that
+// the middleware doesn't enable it.
+// Browsers ignore those headers, but developers can inspect them.
 // The debug mode of a passthrough middleware is invariably off.
 //
 // Middleware are safe for concurrent use by multiple goroutines.
@@ -39,9 +60,18 @@ import (
 // internal or authorized endpoints, for security reasons.
 //
 // [CORS-preflight]: https://developer.mozilla.org/en-US/docs/Glossary/Preflight_request
+// [forbidden method]: https://fetch.spec.whatwg.org/#forbidden-method
+// [Private-Network Access]: https://wicg.github.io/private-network-access/
 type Middleware struct {
-	icfg *internalConfig
-	mu   sync.RWMutex
+	icfg  *internalConfig
+	debug bool
+	// debugTimer, if non-nil, is the timer that will turn debug mode off;
+	// see SetDebugFor.
+	debugTimer *time.Timer
+	// version is incremented on each successful reconfiguration;
+	// see ReconfigureWithVersion.
+	version uint64
+	mu      sync.RWMutex
 }
 
 // NewMiddleware creates a CORS middleware that behaves in accordance with cfg.
@@ -82,83 +112,575 @@ func NewMiddleware(cfg Config) (*Middleware, error) {
 //
 // Mutating the fields of cfg after Reconfigure has returned does not alter
 // m's behavior.
+//
+// Each successful call to Reconfigure increments m's version;
+// if you need to guard against concurrent reconfigurations,
+// use [*Middleware.ReconfigureWithVersion] instead.
 func (m *Middleware) Reconfigure(cfg *Config) error {
 	icfg, err := newInternalConfig(cfg)
 	if err != nil {
 		return err
 	}
 	m.mu.Lock()
-	if icfg != nil && m.icfg != nil {
-		// Retain the current debug mode;
+	m.swap(icfg)
+	m.mu.Unlock()
+	return nil
+}
+
+// ErrVersionConflict is the error that [*Middleware.ReconfigureWithVersion]
+// returns when the middleware's current version differs from
+// the expected one.
+var ErrVersionConflict = util.NewError("version conflict")
+
+// ReconfigureWithVersion is like [*Middleware.Reconfigure],
+// but it implements optimistic concurrency control:
+// it only reconfigures m if m's current version
+// (as reported by [*Middleware.Version]) is expectedVersion.
+// If so, it returns m's new version and a nil error.
+// Otherwise, it leaves m unchanged and returns m's current version
+// along with [ErrVersionConflict].
+// If *cfg is invalid, it leaves m unchanged and returns m's current version
+// along with some other non-nil error.
+//
+// ReconfigureWithVersion is useful for control planes that must guarantee
+// that concurrent reconfigurations of m are not silently lost:
+//
+//	for {
+//	  version := mw.Version()
+//	  cfg := update(mw.Config()) // defined elsewhere
+//	  _, err := mw.ReconfigureWithVersion(cfg, version)
+//	  if !errors.Is(err, cors.ErrVersionConflict) {
+//	    return err
+//	  }
+//	}
+func (m *Middleware) ReconfigureWithVersion(cfg *Config, expectedVersion uint64) (newVersion uint64, err error) {
+	icfg, err := newInternalConfig(cfg)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		return m.version, err
+	}
+	if m.version != expectedVersion {
+		return m.version, ErrVersionConflict
+	}
+	m.swap(icfg)
+	return m.version, nil
+}
+
+// ReconfigureIf is like [*Middleware.Reconfigure],
+// but it only reconfigures m if cfg satisfies predicate.
+// If *cfg is invalid, it leaves m unchanged and returns false
+// along with some non-nil error.
+// Otherwise, it calls predicate with the normalized form of cfg
+// (as [*Middleware.Config] would report it after reconfiguration)
+// or with nil if cfg is nil.
+// If predicate returns true, ReconfigureIf reconfigures m
+// and returns true and a nil error;
+// otherwise, it leaves m unchanged and returns false and a nil error.
+//
+// ReconfigureIf is useful for control planes that must enforce
+// some invariant on every configuration they apply:
+//
+//	ok, err := mw.ReconfigureIf(cfg, func(cfg *cors.Config) bool {
+//	  return cfg != nil && slices.Contains(cfg.Origins, "https://monitoring.example.com")
+//	})
+//
+// Mutating the Config passed to predicate does not alter m's behavior.
+// Because predicate is called before m's lock is acquired,
+// it may safely call m's methods.
+func (m *Middleware) ReconfigureIf(cfg *Config, predicate func(*Config) bool) (applied bool, err error) {
+	icfg, err := newInternalConfig(cfg)
+	if err != nil {
+		return false, err
+	}
+	if !predicate(newConfig(icfg)) {
+		return false, nil
+	}
+	m.mu.Lock()
+	m.swap(icfg)
+	m.mu.Unlock()
+	return true, nil
+}
+
+// Version returns m's current version, which starts at 0 and gets
+// incremented each time m gets successfully reconfigured
+// (via [*Middleware.Reconfigure], [*Middleware.ReconfigureWithVersion],
+// or [*Middleware.ReconfigureIf]).
+func (m *Middleware) Version() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.version
+}
+
+// swap substitutes icfg for m's internal config and increments m's version.
+// The caller must hold m.mu in write mode.
+func (m *Middleware) swap(icfg *internalConfig) {
+	if icfg == nil || m.icfg == nil {
+		// The debug mode of a passthrough middleware is invariably off.
+		// Otherwise, retain the current debug mode;
 		// as a result, m.Reconfigure(m.Config()) is a no-op
 		// (albeit an expensive one), which is a nice property.
-		icfg.debug = m.icfg.debug
+		m.debug = false
+		m.stopDebugTimer()
 	}
 	m.icfg = icfg
-	m.mu.Unlock()
-	return nil
+	m.version++
 }
 
 // Wrap applies the CORS middleware to the specified handler.
 func (m *Middleware) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.serve(w, r, h)
+	})
+}
+
+// serve processes r with m before passing it on to h, if need be.
+func (m *Middleware) serve(w http.ResponseWriter, r *http.Request, h http.Handler) {
+	m.mu.RLock()
+	icfg, debug := m.icfg, m.debug
+	m.mu.RUnlock()
+	if icfg == nil { // passthrough middleware
+		h.ServeHTTP(w, r)
+		return
+	}
+	if icfg.webSocketPassthrough && headers.IsWebSocketUpgrade(r.Header) {
+		// CORS doesn't apply to WebSocket;
+		// see the documentation of ExtraConfig.HandleWebSocketUpgrade.
+		icfg.sanitizeRequest(r.Header)
+		h.ServeHTTP(w, r)
+		return
+	}
+	var start time.Time
+	if icfg.observeLatency != nil {
+		start = time.Now()
+	}
+	isOPTIONS := r.Method == http.MethodOptions
+	origin, originSgl, found := icfg.requestOrigin(r)
+	if !found {
+		// r is NOT a CORS request;
+		// see https://fetch.spec.whatwg.org/#cors-request.
+		if isOPTIONS && icfg.plainOptionsAllow != nil {
+			// See the documentation of ExtraConfig.AnswerPlainOptions.
+			icfg.handleNonCORS(w.Header(), isOPTIONS)
+			w.Header()[headers.Allow] = icfg.plainOptionsAllow
+			icfg.detachValues(w.Header())
+			icfg.lowercaseNames(w.Header())
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		icfg.sanitizeRequest(r.Header)
+		if icfg.applyAfterHandler {
+			// See the documentation of ExtraConfig.ApplyAfterHandler.
+			dw := deferredCORSWriter{ResponseWriter: w, icfg: icfg, isOPTIONS: isOPTIONS}
+			h.ServeHTTP(&dw, r)
+			dw.apply()
+			return
+		}
+		icfg.handleNonCORS(w.Header(), isOPTIONS)
+		icfg.lowercaseNames(w.Header())
+		h.ServeHTTP(w, r)
+		return
+	}
+	// r is a CORS request (and possibly a CORS-preflight request);
+	// see https://fetch.spec.whatwg.org/#cors-request.
+	// Note that originSgl, which we may echo in ACAO,
+	// remains as sent by the client.
+	if len(origin) <= icfg.maxOriginLen {
+		// Over-long origins get rejected as is, without being normalized;
+		// see the documentation of ExtraConfig.MaxOriginLength.
+		origin = icfg.normalizeOrigin(origin)
+	}
+
+	var acrm string
+	var acrmSgl []string
+	if isOPTIONS {
+		acrm, acrmSgl, found = icfg.requestedMethod(r.Header)
+	}
+	if isOPTIONS && found {
+		// r is a CORS-preflight request;
+		// see https://fetch.spec.whatwg.org/#cors-preflight-request.
+		icfg.handleCORSPreflight(w, r, origin, originSgl, acrm, acrmSgl, debug)
+		icfg.observe(latencyPreflight, start)
+		return
+	}
+	// r is an "actual" (i.e. non-preflight) CORS request.
+	if icfg.injectDecision {
+		r = r.WithContext(withDecision(r.Context(), icfg.decide(origin)))
+	}
+	if icfg.applyAfterHandler {
+		// See the documentation of ExtraConfig.ApplyAfterHandler.
+		icfg.observe(latencyActual, start)
+		icfg.sanitizeRequest(r.Header)
+		dw := deferredCORSWriter{
+			ResponseWriter: w,
+			icfg:           icfg,
+			r:              r,
+			cors:           true,
+			origin:         origin,
+			originSgl:      originSgl,
+			isOPTIONS:      isOPTIONS,
+		}
+		h.ServeHTTP(&dw, r)
+		dw.apply()
+		if icfg.warnUnusedACEH && w.Header()[headers.ACEH] != nil {
+			// See the documentation of ExtraConfig.WarnOnUnusedExposedHeaders.
+			icfg.checkExposedHeaders(w.Header(), r)
+		}
+		return
+	}
+	icfg.handleActual(w, r, origin, originSgl, isOPTIONS)
+	checkACEH := icfg.warnUnusedACEH && w.Header()[headers.ACEH] != nil
+	reflectACEH := icfg.reflectACEH && w.Header()[headers.ACAO] != nil
+	icfg.lowercaseNames(w.Header())
+	icfg.observe(latencyActual, start)
+	icfg.sanitizeRequest(r.Header)
+	if icfg.overrideHandlerCORSHdrs || reflectACEH {
+		g := newCORSHeaderGuard(w, icfg.overrideHandlerCORSHdrs, reflectACEH)
+		h.ServeHTTP(g, r)
+		// If h didn't write anything, net/http only writes the
+		// response headers once h returns; they're still mutable here.
+		g.sanitize()
+	} else {
+		h.ServeHTTP(w, r)
+	}
+	if checkACEH {
+		// See the documentation of ExtraConfig.WarnOnUnusedExposedHeaders.
+		icfg.checkExposedHeaders(w.Header(), r)
+	}
+}
+
+// handleActual sets the CORS response headers of the response to
+// an actual (i.e. non-preflight) CORS request and invokes
+// the response-header hook, if any.
+func (icfg *internalConfig) handleActual(
+	w http.ResponseWriter,
+	r *http.Request,
+	origin string,
+	originSgl []string,
+	isOPTIONS bool,
+) {
+	if icfg.onDisallowedContentType != nil {
+		// See the documentation of ExtraConfig.OnDisallowedContentType.
+		icfg.checkContentType(r)
+	}
+	if icfg.publicAnyOrigin != nil && isAnonymous(r.Header) && icfg.publicAnyOrigin(r) {
+		// See the documentation of ExtraConfig.PublicAnyOriginPredicate.
+		icfg.handlePublicActual(w.Header(), isOPTIONS)
+	} else {
+		icfg.handleCORSActual(w, r, origin, originSgl, isOPTIONS)
+	}
+	icfg.detachValues(w.Header())
+	if icfg.resHdrHook != nil {
+		icfg.resHdrHook(w.Header())
+	}
+}
+
+// sanitizeRequest, if icfg calls for it, deletes from reqHdrs
+// the Access-Control-* headers that clients have no legitimate reason
+// to send; see the documentation of ExtraConfig.SanitizeInboundCORSHeaders.
+func (icfg *internalConfig) sanitizeRequest(reqHdrs http.Header) {
+	if !icfg.sanitizeReqCORSHdrs {
+		return
+	}
+	for name := range reqHdrs {
+		if !strings.HasPrefix(name, headers.PrefixAccessControl) {
+			continue
+		}
+		switch name {
+		case headers.ACRM, headers.ACRH, headers.ACRPN:
+			continue
+		}
+		delete(reqHdrs, name)
+	}
+}
+
+// checkExposedHeaders invokes icfg.onUnusedACEH with the names listed in
+// icfg.aceh that are absent from resHdrs, if any.
+func (icfg *internalConfig) checkExposedHeaders(resHdrs http.Header, r *http.Request) {
+	var unused []string
+	for _, name := range strings.Split(icfg.aceh, headers.ValueSep) {
+		if len(resHdrs.Values(name)) == 0 {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) != 0 {
+		icfg.onUnusedACEH(unused, r)
+	}
+}
+
+// PreflightHandler returns a handler that only handles
+// [CORS-preflight requests] in accordance with m's configuration;
+// it responds to any other request with a 405 (Method Not Allowed) status.
+// If m is a passthrough middleware, the resulting handler responds to
+// all requests with a 405 status.
+//
+// PreflightHandler is useful for registering m's preflight responder
+// on an explicit OPTIONS route of a method-aware [http.ServeMux],
+// while handling actual (i.e. non-preflight) requests elsewhere:
+//
+//	mux.Handle("OPTIONS /api/users", corsMw.PreflightHandler())
+//	mux.Handle("GET /api/users", corsMw.Wrap(http.HandlerFunc(handleUsersGet)))
+//
+// Note that you still need to apply m (via its [*Middleware.Wrap] method)
+// to the handlers of actual requests, without which responses to
+// such requests would lack the necessary CORS headers.
+//
+// [CORS-preflight requests]: https://developer.mozilla.org/en-US/docs/Glossary/Preflight_request
+func (m *Middleware) PreflightHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		m.mu.RLock()
-		icfg := m.icfg
+		icfg, debug := m.icfg, m.debug
 		m.mu.RUnlock()
-		if icfg == nil { // passthrough middleware
-			h.ServeHTTP(w, r)
+		if icfg == nil || r.Method != http.MethodOptions {
+			respondMethodNotAllowed(w, r.Method == http.MethodOptions)
 			return
 		}
-		isOPTIONS := r.Method == http.MethodOptions
-		// Fetch-compliant browsers send at most one Origin header;
-		// see https://fetch.spec.whatwg.org/#http-network-or-cache-fetch
-		// (step 12).
-		origin, originSgl, found := headers.First(r.Header, headers.Origin)
+		var start time.Time
+		if icfg.observeLatency != nil {
+			start = time.Now()
+		}
+		origin, originSgl, found := icfg.requestOrigin(r)
 		if !found {
-			// r is NOT a CORS request;
-			// see https://fetch.spec.whatwg.org/#cors-request.
-			icfg.handleNonCORS(w.Header(), isOPTIONS)
-			h.ServeHTTP(w, r)
+			respondMethodNotAllowed(w, true)
 			return
 		}
-		// r is a CORS request (and possibly a CORS-preflight request);
-		// see https://fetch.spec.whatwg.org/#cors-request.
+		acrm, acrmSgl, found := icfg.requestedMethod(r.Header)
+		if !found {
+			respondMethodNotAllowed(w, true)
+			return
+		}
+		if len(origin) <= icfg.maxOriginLen {
+			// see the implementation comment in serve
+			origin = icfg.normalizeOrigin(origin)
+		}
+		icfg.handleCORSPreflight(w, r, origin, originSgl, acrm, acrmSgl, debug)
+		icfg.observe(latencyPreflight, start)
+	})
+}
+
+// DumpPreflightResponse simulates the processing of a CORS-preflight request
+// by the handler that [*Middleware.PreflightHandler] returns,
+// and returns the status and headers of the resulting response.
+// The simulated request carries origin in its Origin header
+// (or in the first header specified by ExtraConfig.OriginHeaderNames, if any),
+// method in its Access-Control-Request-Method header and,
+// unless acrh is empty, the elements of acrh (joined, as is, by commas)
+// in its Access-Control-Request-Headers header.
+//
+// DumpPreflightResponse exercises the code path that serves actual
+// preflight requests; in particular, it respects m's current debug mode,
+// and the hooks that m's configuration specifies
+// (e.g. ExtraConfig.ObserveLatency) run as they would for actual requests.
+// It is meant for snapshot testing of CORS policies;
+// see also [github.com/jub0bs/cors/corstest].
+//
+// Mutating the result does not alter m's behavior.
+func (m *Middleware) DumpPreflightResponse(origin, method string, acrh []string) (status int, header http.Header) {
+	m.mu.RLock()
+	icfg := m.icfg
+	m.mu.RUnlock()
+	originHdr := headers.Origin
+	if icfg != nil {
+		originHdr = icfg.originHdrs[0]
+	}
+	r := &http.Request{
+		Method: http.MethodOptions,
+		URL:    &url.URL{Path: "/"},
+		Header: make(http.Header, 3),
+	}
+	r.Header[originHdr] = []string{origin}
+	r.Header[headers.ACRM] = []string{method}
+	if len(acrh) > 0 {
+		r.Header[headers.ACRH] = []string{strings.Join(acrh, ",")}
+	}
+	rec := headerRecorder{header: make(http.Header)}
+	m.PreflightHandler().ServeHTTP(&rec, r)
+	// Some of the header values may be shared with m's configuration;
+	// see the documentation of ExtraConfig.UseCanonicalHeaderWrites.
+	return cmp.Or(rec.status, http.StatusOK), rec.header.Clone()
+}
+
+// A headerRecorder is a [http.ResponseWriter] that records
+// the status and headers of the response and discards its body.
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (rec *headerRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *headerRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return len(b), nil
+}
 
-		// Fetch-compliant browsers send at most one ACRM header;
-		// see https://fetch.spec.whatwg.org/#cors-preflight-fetch (step 3).
-		acrm, acrmSgl, found := headers.First(r.Header, headers.ACRM)
-		if isOPTIONS && found {
-			// r is a CORS-preflight request;
-			// see https://fetch.spec.whatwg.org/#cors-preflight-request.
-			icfg.handleCORSPreflight(w, r.Header, origin, originSgl, acrm, acrmSgl)
+func (rec *headerRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+// kinds of latency reported to ExtraConfig.ObserveLatency
+const (
+	latencyPreflight = "preflight"
+	latencyActual    = "actual"
+)
+
+// observe reports the time elapsed since start, if icfg calls for it;
+// see the documentation of ExtraConfig.ObserveLatency.
+func (icfg *internalConfig) observe(kind string, start time.Time) {
+	if icfg.observeLatency != nil {
+		icfg.observeLatency(kind, time.Since(start))
+	}
+}
+
+// PreflightBypass returns a handler that lets m respond to
+// [CORS-preflight requests] directly (as [*Middleware.PreflightHandler]
+// would) and delegates all other requests to next.
+// If m is a passthrough middleware, the resulting handler delegates
+// all requests to next.
+//
+// PreflightBypass is meant to rescue middleware chains in which
+// authentication (or some other layer that rejects unauthenticated
+// requests) takes place ahead of m, and that you cannot restructure:
+// because browsers never authenticate preflight requests, such chains
+// cause preflight to fail. Place the resulting handler at the very top
+// of the chain:
+//
+//	handler := cors.PreflightBypass(authMw(corsMw.Wrap(api)), corsMw)
+//
+// Actual (i.e. non-preflight) requests still go through the whole chain,
+// including next's authentication layer;
+// therefore, you still need to apply m (via its [*Middleware.Wrap] method)
+// somewhere in next.
+//
+// [CORS-preflight requests]: https://developer.mozilla.org/en-US/docs/Glossary/Preflight_request
+func PreflightBypass(next http.Handler, m *Middleware) http.Handler {
+	preflight := m.PreflightHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.isPreflight(r) {
+			preflight.ServeHTTP(w, r)
 			return
 		}
-		// r is an "actual" (i.e. non-preflight) CORS request.
-		icfg.handleCORSActual(w, origin, originSgl, isOPTIONS)
-		h.ServeHTTP(w, r)
+		next.ServeHTTP(w, r)
 	})
 }
 
-func (icfg *internalConfig) handleNonCORS(resHdrs http.Header, isOPTIONS bool) {
+// isPreflight reports whether r is a CORS-preflight request
+// in the eyes of m's current configuration.
+func (m *Middleware) isPreflight(r *http.Request) bool {
+	if r.Method != http.MethodOptions {
+		return false
+	}
+	m.mu.RLock()
+	icfg := m.icfg
+	m.mu.RUnlock()
+	if icfg == nil {
+		return false
+	}
+	if _, _, found := icfg.requestOrigin(r); !found {
+		return false
+	}
+	_, _, found := icfg.requestedMethod(r.Header)
+	return found
+}
+
+func respondMethodNotAllowed(w http.ResponseWriter, isOPTIONS bool) {
+	resHdrs := w.Header()
 	if isOPTIONS {
 		// see the implementation comment in handleCORSPreflight
 		resHdrs.Add(headers.Vary, headers.ValueVaryOptions)
 	}
+	resHdrs.Set(headers.Allow, http.MethodOptions)
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// requestOrigin returns the origin of r (if any) and reports whether
+// any was found. See the documentation of ExtraConfig.OriginHeaderNames,
+// ExtraConfig.TreatEmptyOriginAsAbsent, and ExtraConfig.OriginResolver.
+func (icfg *internalConfig) requestOrigin(r *http.Request) (string, []string, bool) {
+	for _, name := range icfg.originHdrs {
+		// Fetch-compliant browsers send at most one Origin header;
+		// see https://fetch.spec.whatwg.org/#http-network-or-cache-fetch
+		// (step 12).
+		origin, originSgl, found := headers.First(r.Header, name)
+		if found && (origin != "" || !icfg.emptyOriginAsAbsent) {
+			return origin, originSgl, true
+		}
+	}
+	if icfg.originResolver == nil {
+		return "", nil, false
+	}
+	origin, found := icfg.originResolver(r)
+	if !found {
+		return "", nil, false
+	}
+	return origin, []string{origin}, true
+}
+
+// normalizeOrigin applies to origin the normalizations (if any)
+// that icfg calls for; see the documentation of
+// ExtraConfig.DecodePercentEncodedOrigin and
+// ExtraConfig.NormalizeIPv4Shorthand.
+func (icfg *internalConfig) normalizeOrigin(origin string) string {
+	if icfg.decodePercentEncodedOrigin {
+		origin, _ = origins.DecodePercentEncodedHost(origin)
+	}
+	if icfg.normalizeIPv4Shorthand {
+		origin, _ = origins.NormalizeIPv4Shorthand(origin)
+	}
+	return origin
+}
+
+// requestedMethod returns the method requested by a CORS-preflight request
+// whose headers are reqHdrs, and reports whether any was found.
+func (icfg *internalConfig) requestedMethod(reqHdrs http.Header) (string, []string, bool) {
+	// Fetch-compliant browsers send at most one ACRM header;
+	// see https://fetch.spec.whatwg.org/#cors-preflight-fetch (step 3).
+	acrm, acrmSgl, found := headers.First(reqHdrs, headers.ACRM)
+	if !found && icfg.acrmFallback != "" {
+		// Some non-compliant clients name the requested method
+		// in a non-standard header.
+		acrm, acrmSgl, found = headers.First(reqHdrs, icfg.acrmFallback)
+	}
+	return acrm, acrmSgl, found
+}
+
+func (icfg *internalConfig) handleNonCORS(resHdrs http.Header, isOPTIONS bool) {
+	if isOPTIONS {
+		// see the implementation comment in handleCORSPreflight
+		icfg.varyOptions(resHdrs)
+		if icfg.suppressNonCORSOptions {
+			// See the documentation of
+			// ExtraConfig.SuppressCORSHeadersOnNonCORSOptions.
+			return
+		}
+	}
 	if icfg.privateNetworkAccessNoCors {
 		return
 	}
-	if !icfg.allowAnyOrigin {
+	if !icfg.emitsWildcardACAO() {
 		// See https://fetch.spec.whatwg.org/#cors-protocol-and-http-caches.
 		// Note that we deliberately list "Origin" in the Vary header of responses
 		// to actual requests even in cases where a single origin is allowed,
 		// because doing so is simpler to implement and unlikely to be
 		// detrimental to Web caches.
 		if !isOPTIONS {
-			resHdrs.Add(headers.Vary, headers.Origin)
+			icfg.varyOrigin(resHdrs)
 		}
 		// nothing to do: at this stage, we've already added a Vary header
 		return
 	}
+	if icfg.concreteAnyACAO != nil && !isOPTIONS {
+		// Responses to CORS requests depend on their origin;
+		// see the documentation of
+		// ExtraConfig.ConcreteOriginForAnonymousAllowAll.
+		icfg.varyOrigin(resHdrs)
+	}
 	resHdrs.Set(headers.ACAO, headers.ValueWildcard)
 	if icfg.aceh != "" {
 		// see https://github.com/whatwg/fetch/issues/1601
@@ -168,13 +690,14 @@ func (icfg *internalConfig) handleNonCORS(resHdrs http.Header, isOPTIONS bool) {
 
 func (icfg *internalConfig) handleCORSPreflight(
 	w http.ResponseWriter,
-	reqHdrs http.Header,
+	r *http.Request,
 	origin string,
 	originSgl []string,
 	acrm string,
 	acrmSgl []string,
+	debug bool,
 ) {
-	resHdrs := w.Header()
+	reqHdrs, resHdrs := r.Header, w.Header()
 	// Responses to OPTIONS requests are not meant to be cached but,
 	// for better or worse, some caching intermediaries can nevertheless be
 	// configured to cache such responses.
@@ -186,11 +709,13 @@ func (icfg *internalConfig) handleCORSPreflight(
 	//   - Access-Control-Request-Methods
 	//   - Access-Control-Request-Private-Network
 	//   - Origin
-	vary, found := resHdrs[headers.Vary]
-	if !found { // fast path
-		resHdrs[headers.Vary] = headers.PreflightVarySgl
-	} else { // slow path
-		resHdrs[headers.Vary] = append(vary, headers.ValueVaryOptions)
+	icfg.varyPreflight(resHdrs)
+
+	// See the documentation of ExtraConfig.MaxProcessedHeaderBytes.
+	if icfg.maxHdrBytes > 0 &&
+		icfg.preflightHeadersTooLarge(origin, acrm, reqHdrs[headers.ACRH]) {
+		icfg.writeHeader(w, http.StatusRequestHeaderFieldsTooLarge)
+		return
 	}
 
 	// Populating a small (8 keys or fewer) local map incurs 0 heap
@@ -208,15 +733,30 @@ func (icfg *internalConfig) handleCORSPreflight(
 	//
 	// When debug is off and preflight fails,
 	// we omit all CORS headers from the preflight response.
-	debug := icfg.debug
+
+	// See the documentation of ExtraConfig.ValidateSecFetchMetadata.
+	if icfg.secFetchCheck && !fetchMetadataIsConsistent(r, origin, true) {
+		if icfg.reportOnly {
+			icfg.reportPreflight(w, r, origin, originSgl, acrmSgl, ReasonFetchMetadata)
+			return
+		}
+		icfg.markRejection(resHdrs, stageFetchMetadata)
+		icfg.writeHeader(w, icfg.preflightFailureStatus)
+		return
+	}
 
 	// For details about the order in which we perform the following checks,
 	// see https://fetch.spec.whatwg.org/#cors-preflight-fetch, item 7.
-	if !icfg.processOriginForPreflight(buf, origin, originSgl) {
+	if !icfg.processOriginForPreflight(buf, r, origin, originSgl) {
+		if icfg.reportOnly {
+			icfg.reportPreflight(w, r, origin, originSgl, acrmSgl, ReasonOrigin)
+			return
+		}
+		icfg.markRejection(resHdrs, stageOrigin)
 		if debug {
-			maps.Copy(resHdrs, buf)
+			icfg.copyDebugHeaders(resHdrs, buf)
 		}
-		w.WriteHeader(http.StatusForbidden)
+		icfg.writeHeader(w, icfg.preflightFailureStatus)
 		return
 	}
 
@@ -224,62 +764,305 @@ func (icfg *internalConfig) handleCORSPreflight(
 	// (see https://fetch.spec.whatwg.org/#cors-preflight-fetch-0, step 7)
 	// if the response status is not an ok status
 	// (see https://fetch.spec.whatwg.org/#ok-status).
-	if !icfg.processACRPN(buf, reqHdrs) {
+	if !icfg.processACRPN(buf, reqHdrs, debug) {
+		if icfg.reportOnly {
+			icfg.reportPreflight(w, r, origin, originSgl, acrmSgl, ReasonPNA)
+			return
+		}
+		icfg.markRejection(resHdrs, stagePNA)
 		if debug {
-			maps.Copy(resHdrs, buf)
-			w.WriteHeader(icfg.preflightStatus)
+			icfg.copyDebugHeaders(resHdrs, buf)
+			icfg.writeHeader(w, icfg.preflightStatus)
 			return
 		}
-		w.WriteHeader(http.StatusForbidden)
+		icfg.writeHeader(w, icfg.preflightFailureStatus)
 		return
 	}
 
-	if !icfg.processACRM(buf, acrm, acrmSgl) {
+	if !icfg.processACRM(buf, origin, acrm, acrmSgl, debug) {
+		if icfg.reportOnly {
+			icfg.reportPreflight(w, r, origin, originSgl, acrmSgl, ReasonMethod)
+			return
+		}
+		icfg.markRejection(resHdrs, stageMethod)
 		if debug {
-			maps.Copy(resHdrs, buf)
-			w.WriteHeader(icfg.preflightStatus)
+			icfg.copyDebugHeaders(resHdrs, buf)
+			icfg.writeHeader(w, icfg.preflightStatus)
 			return
 		}
-		w.WriteHeader(http.StatusForbidden)
+		icfg.writeHeader(w, icfg.preflightFailureStatus)
+		return
+	}
+
+	// In debug mode, the response may contain data derived from ACRH;
+	// see the documentation of ExtraConfig.MaxDebugACAHBytes.
+	if debug && icfg.acrhTooLongForDebug(reqHdrs) {
+		icfg.markRejection(resHdrs, stageHeaders)
+		icfg.writeHeader(w, icfg.preflightFailureStatus)
 		return
 	}
 
-	if !icfg.processACRH(buf, reqHdrs, debug) {
+	// Because the processing of ACRH is the costliest part of preflight,
+	// it's the part that we time if a slow-preflight hook was specified.
+	var start time.Time
+	if icfg.onSlowPreflight != nil {
+		start = time.Now()
+	}
+	acrhOK := icfg.processACRH(buf, reqHdrs, debug)
+	if icfg.onSlowPreflight != nil {
+		if d := time.Since(start); d > icfg.slowPreflightThreshold {
+			icfg.onSlowPreflight(d, r)
+		}
+	}
+	if !acrhOK {
+		if icfg.reportOnly {
+			icfg.reportPreflight(w, r, origin, originSgl, acrmSgl, ReasonHeaders)
+			return
+		}
+		icfg.markRejection(resHdrs, stageHeaders)
 		if debug {
-			maps.Copy(resHdrs, buf)
-			w.WriteHeader(icfg.preflightStatus)
+			icfg.copyDebugHeaders(resHdrs, buf)
+			icfg.writeHeader(w, icfg.preflightStatus)
 			return
 		}
-		w.WriteHeader(http.StatusForbidden)
+		icfg.writeHeader(w, icfg.preflightFailureStatus)
 		return
 	}
 	// Preflight was successful.
+	// Note that, in debug mode, processACRH may have let through
+	// a preflight request that browsers will nonetheless fail
+	// because of some disallowed request-header name.
+
+	if debug && buf[headers.XDebugRejectedHeader] != nil {
+		icfg.markRejection(resHdrs, stageHeaders)
+		icfg.copyDebugHeaders(resHdrs, buf)
+	} else {
+		maps.Copy(resHdrs, buf)
+	}
+	if icfg.acma != nil {
+		resHdrs[headers.ACMA] = icfg.acma
+	} else if icfg.alwaysEmitMaxAge {
+		resHdrs[headers.ACMA] = headers.DefaultMaxAgeSgl
+	}
+	icfg.writeHeader(w, icfg.successStatus(acrm))
+}
+
+// preflightHeadersTooLarge reports whether the combined size of
+// the values of the Origin, ACRM, and ACRH headers
+// exceeds icfg's budget; see the documentation of
+// ExtraConfig.MaxProcessedHeaderBytes.
+func (icfg *internalConfig) preflightHeadersTooLarge(
+	origin string,
+	acrm string,
+	acrh []string,
+) bool {
+	n := len(origin) + len(acrm)
+	for _, line := range acrh {
+		n += len(line)
+		if n > icfg.maxHdrBytes { // fail fast
+			return true
+		}
+	}
+	return n > icfg.maxHdrBytes
+}
 
+// copyDebugHeaders copies into resHdrs the CORS response headers
+// accumulated in buf during the processing of a preflight request
+// that fails in debug mode; see the documentation of
+// ExtraConfig.DebugOmitCredentialsHeader.
+func (icfg *internalConfig) copyDebugHeaders(resHdrs, buf http.Header) {
+	if icfg.debugOmitACAC {
+		delete(buf, headers.ACAC)
+	}
 	maps.Copy(resHdrs, buf)
+}
+
+// stages of preflight reported in the marker header;
+// see the documentation of ExtraConfig.RejectionMarkerHeader
+const (
+	stageFetchMetadata = "fetch-metadata"
+	stageOrigin        = "origin"
+	stagePNA           = "private-network-access"
+	stageMethod        = "method"
+	stageHeaders       = "headers"
+)
+
+// markRejection, if icfg calls for it, adds to resHdrs the marker header
+// that names the failed stage of preflight;
+// see the documentation of ExtraConfig.RejectionMarkerHeader.
+func (icfg *internalConfig) markRejection(resHdrs http.Header, stage string) {
+	if icfg.rejectionMarker != "" {
+		resHdrs[icfg.rejectionMarker] = []string{stage}
+	}
+}
+
+// successStatus returns the status of successful responses to
+// preflight requests for method acrm;
+// see the documentation of ExtraConfig.PreflightStatusByMethod.
+func (icfg *internalConfig) successStatus(acrm string) int {
+	if status, found := icfg.preflightStatusByMethod[acrm]; found {
+		return status
+	}
+	return icfg.preflightStatus
+}
+
+// A Reason describes why a CORS middleware would have rejected a request
+// if report-only mode had not been active;
+// see the documentation of ExtraConfig.ReportOnlyHook.
+// The values of the Reason constants defined by this package are stable
+// and can therefore safely serve as keys (e.g. in dashboards).
+type Reason string
+
+// reasons reported in report-only mode
+const (
+	// ReasonOrigin indicates that the request's origin is not allowed.
+	ReasonOrigin Reason = "origin not allowed"
+	// ReasonPNA indicates that the CORS-preflight request asks for
+	// Private-Network Access, which is not allowed.
+	ReasonPNA Reason = "Private-Network Access not allowed"
+	// ReasonMethod indicates that the method requested by
+	// the CORS-preflight request is not allowed.
+	ReasonMethod Reason = "method not allowed"
+	// ReasonHeaders indicates that some request-header name listed by
+	// the CORS-preflight request is not allowed.
+	ReasonHeaders Reason = "request headers not allowed"
+	// ReasonFetchMetadata indicates that the request's Fetch-metadata
+	// request headers are inconsistent with it being a CORS request;
+	// see the documentation of ExtraConfig.ValidateSecFetchMetadata.
+	ReasonFetchMetadata Reason = "inconsistent fetch metadata"
+)
+
+// reportPreflight reports a CORS-preflight request that icfg would have
+// rejected if report-only mode had not been active, and lets it through
+// by reflecting its origin, method, and headers.
+func (icfg *internalConfig) reportPreflight(
+	w http.ResponseWriter,
+	r *http.Request,
+	origin string,
+	originSgl []string,
+	acrmSgl []string,
+	reason Reason,
+) {
+	icfg.report(origin, reason)
+	reqHdrs, resHdrs := r.Header, w.Header()
+	resHdrs[headers.ACAO] = originSgl
+	if icfg.credentialed && icfg.pathIsCredentialed(r) {
+		resHdrs[headers.ACAC] = headers.TrueSgl
+	}
+	if acrpn, _, _ := headers.First(reqHdrs, headers.ACRPN); acrpn == headers.ValueTrue {
+		resHdrs[headers.ACAPN] = headers.TrueSgl
+	}
+	resHdrs[headers.ACAM] = acrmSgl
+	if _, acrhSgl, found := headers.First(reqHdrs, headers.ACRH); found {
+		resHdrs[headers.ACAH] = acrhSgl
+	}
 	if icfg.acma != nil {
 		resHdrs[headers.ACMA] = icfg.acma
+	} else if icfg.alwaysEmitMaxAge {
+		resHdrs[headers.ACMA] = headers.DefaultMaxAgeSgl
+	}
+	icfg.writeHeader(w, icfg.successStatus(acrmSgl[0]))
+}
+
+// lowercaseNames, if icfg calls for it, rewrites the names of
+// the CORS response headers present in resHdrs in lowercase;
+// see the documentation of ExtraConfig.LowercaseResponseHeaderNames.
+func (icfg *internalConfig) lowercaseNames(resHdrs http.Header) {
+	if !icfg.lowercaseResHdrNames {
+		return
+	}
+	for name, values := range resHdrs {
+		if strings.HasPrefix(name, headers.PrefixAccessControl) ||
+			name == headers.XDebugRejectedHeader ||
+			name == headers.XDebugForbiddenMethod ||
+			name == headers.XDebugPNA {
+			delete(resHdrs, name)
+			// Assigning to the map directly bypasses canonicalization.
+			// Because the lowercase name lacks the canonical prefix,
+			// it won't be visited again, should this loop encounter it.
+			resHdrs[util.ByteLowercase(name)] = values
+		}
+	}
+}
+
+// detachValues, if icfg calls for it, replaces the values of the headers
+// that icfg manages in resHdrs by copies,
+// so that those values share no storage with the request, with icfg,
+// or with other responses;
+// see the documentation of ExtraConfig.UseCanonicalHeaderWrites.
+func (icfg *internalConfig) detachValues(resHdrs http.Header) {
+	if !icfg.canonicalWrites {
+		return
+	}
+	for name, values := range resHdrs {
+		if strings.HasPrefix(name, headers.PrefixAccessControl) ||
+			managedResponseHeaderNames.Contains(name) {
+			resHdrs[name] = slices.Clone(values)
+		}
+	}
+}
+
+// managedResponseHeaderNames are the names of the response headers,
+// other than Access-Control-*, that middleware may set.
+var managedResponseHeaderNames = util.NewSet(
+	headers.Allow,
+	headers.Deprecation,
+	headers.Link,
+	headers.Sunset,
+	headers.Vary,
+	headers.XDebugForbiddenMethod,
+	headers.XDebugPNA,
+	headers.XDebugRejectedHeader,
+)
+
+func (icfg *internalConfig) report(origin string, reason Reason) {
+	if icfg.reportOnlyHook != nil {
+		icfg.reportOnlyHook(origin, reason)
+	}
+}
+
+// writeHeader gives the headers that icfg manages values of their own
+// if icfg calls for it, invokes the response-header hook, if any,
+// lowercases the names of CORS response headers if icfg calls for it,
+// and then writes the response's status code.
+func (icfg *internalConfig) writeHeader(w http.ResponseWriter, status int) {
+	icfg.detachValues(w.Header())
+	if icfg.resHdrHook != nil {
+		icfg.resHdrHook(w.Header())
 	}
-	w.WriteHeader(icfg.preflightStatus)
+	icfg.lowercaseNames(w.Header())
+	w.WriteHeader(status)
 }
 
 func (icfg *internalConfig) processOriginForPreflight(
 	buf http.Header,
+	r *http.Request,
 	origin string,
 	originSgl []string,
 ) bool {
+	if len(origin) > icfg.maxOriginLen { // fail fast
+		return false
+	}
 	o, ok := origins.Parse(origin)
 	if !ok {
 		return false
 	}
-	if !icfg.credentialed && icfg.allowAnyOrigin {
+	if icfg.emitsWildcardACAO() {
+		if icfg.concreteAnyACAO != nil && icfg.concreteAnyACAO(r) {
+			// See the documentation of
+			// ExtraConfig.ConcreteOriginForAnonymousAllowAll.
+			buf[headers.ACAO] = originSgl
+			return true
+		}
 		buf[headers.ACAO] = headers.WildcardSgl
 		return true
 	}
 	if !icfg.corpus.Contains(&o) {
 		return false
 	}
-	buf[headers.ACAO] = originSgl
-	if icfg.credentialed {
+	buf[headers.ACAO] = icfg.acao(origin, originSgl)
+	if icfg.credentialed && icfg.schemeIsCredentialed(o.Scheme) &&
+		icfg.pathIsCredentialed(r) {
 		// We make no attempt to infer whether the request is credentialed,
 		// simply because preflight requests don't carry credentials;
 		// see https://fetch.spec.whatwg.org/#example-xhr-credentials.
@@ -288,7 +1071,7 @@ func (icfg *internalConfig) processOriginForPreflight(
 	return true
 }
 
-func (icfg *internalConfig) processACRPN(buf, reqHdrs http.Header) bool {
+func (icfg *internalConfig) processACRPN(buf, reqHdrs http.Header, debug bool) bool {
 	// See https://wicg.github.io/private-network-access/#cors-preflight.
 	//
 	// PNA-compliant browsers send at most one ACRPN header;
@@ -302,12 +1085,34 @@ func (icfg *internalConfig) processACRPN(buf, reqHdrs http.Header) bool {
 		buf[headers.ACAPN] = headers.TrueSgl
 		return true
 	}
+	// In debug mode, we make it clear that preflight fails because of PNA
+	// rather than because of the origin or of the method.
+	if debug {
+		buf[headers.XDebugPNA] = headers.PNADisabledSgl
+	}
 	return false
 }
 
+// checkContentType invokes icfg's disallowed-content-type hook
+// if r's Content-Type header specifies a media type that is neither
+// CORS-safelisted nor among icfg's allowed content types.
+func (icfg *internalConfig) checkContentType(r *http.Request) {
+	ct, _, found := headers.First(r.Header, headers.ContentType)
+	if !found {
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err == nil && (headers.IsSafelistedContentType(mediaType) ||
+		icfg.allowedContentTypes.Contains(mediaType)) {
+		return
+	}
+	icfg.onDisallowedContentType(ct, r)
+}
+
 // Note: only for _non-preflight_ CORS requests
 func (icfg *internalConfig) handleCORSActual(
 	w http.ResponseWriter,
+	r *http.Request,
 	origin string,
 	originSgl []string,
 	isOPTIONS bool,
@@ -317,41 +1122,71 @@ func (icfg *internalConfig) handleCORSActual(
 	if icfg.privateNetworkAccessNoCors {
 		if isOPTIONS {
 			// see the implementation comment in handleCORSPreflight
-			resHdrs.Add(headers.Vary, headers.ValueVaryOptions)
+			icfg.varyOptions(resHdrs)
 		}
 		return
 	}
+	wildcard := icfg.emitsWildcardACAO()
+	allowed := !wildcard && icfg.originIsAllowed(origin)
 	switch {
 	case isOPTIONS:
 		// see the implementation comment in handleCORSPreflight
-		resHdrs.Add(headers.Vary, headers.ValueVaryOptions)
-	case !icfg.allowAnyOrigin:
+		icfg.varyOptions(resHdrs)
+	case wildcard:
+		if icfg.concreteAnyACAO != nil {
+			// See the documentation of
+			// ExtraConfig.ConcreteOriginForAnonymousAllowAll.
+			icfg.varyOrigin(resHdrs)
+		}
+	default:
+		if icfg.omitVaryDisallowed && !icfg.reportOnly && !allowed {
+			// See the documentation of ExtraConfig.OmitVaryForDisallowedOrigins.
+			break
+		}
 		// See https://fetch.spec.whatwg.org/#cors-protocol-and-http-caches.
-		resHdrs.Add(headers.Vary, headers.Origin)
+		icfg.varyOrigin(resHdrs)
+	}
+	if icfg.secFetchCheck && !fetchMetadataIsConsistent(r, origin, false) {
+		// See the documentation of ExtraConfig.ValidateSecFetchMetadata.
+		if !icfg.reportOnly {
+			return
+		}
+		icfg.report(origin, ReasonFetchMetadata)
 	}
-	if !icfg.credentialed && icfg.allowAnyOrigin {
+	if wildcard {
 		// See the last paragraph in
 		// https://fetch.spec.whatwg.org/#cors-protocol-and-http-caches.
 		// Note that we deliberately list "Origin" in the Vary header of responses
 		// to actual requests even in cases where a single origin is allowed,
 		// because doing so is simpler to implement and unlikely to be
 		// detrimental to Web caches.
-		resHdrs.Set(headers.ACAO, headers.ValueWildcard)
+		if icfg.concreteACAO(r, origin) {
+			resHdrs[headers.ACAO] = originSgl
+		} else {
+			resHdrs.Set(headers.ACAO, headers.ValueWildcard)
+		}
 		if icfg.aceh != "" {
 			// see https://github.com/whatwg/fetch/issues/1601
 			resHdrs.Set(headers.ACEH, icfg.aceh)
 		}
+		icfg.annotateDeprecation(resHdrs, origin)
+		icfg.advertisePolicy(resHdrs)
 		return
 	}
-	o, ok := origins.Parse(origin)
-	if !ok || !icfg.corpus.Contains(&o) {
-		return
+	if allowed {
+		resHdrs[headers.ACAO] = icfg.acao(origin, originSgl)
+	} else {
+		if !icfg.reportOnly {
+			return
+		}
+		icfg.report(origin, ReasonOrigin)
+		resHdrs[headers.ACAO] = originSgl
 	}
-	resHdrs[headers.ACAO] = originSgl
-	if icfg.credentialed {
-		// We make no attempt to infer whether the request is credentialed;
-		// in fact, a request’s credentials mode is not necessarily observable
-		// on the server.
+	if icfg.credentialed && icfg.mayBeCredentialed(r.Header) &&
+		icfg.originSchemeIsCredentialed(origin) && icfg.pathIsCredentialed(r) {
+		// By default, we make no attempt to infer whether the request is
+		// credentialed; in fact, a request’s credentials mode is not
+		// necessarily observable on the server.
 		// Instead, we systematically include "ACAC: true" if credentialed
 		// access is enabled and request's origin is allowed.
 		// See https://fetch.spec.whatwg.org/#example-xhr-credentials.
@@ -360,24 +1195,265 @@ func (icfg *internalConfig) handleCORSActual(
 	if icfg.aceh != "" {
 		resHdrs.Set(headers.ACEH, icfg.aceh)
 	}
+	icfg.annotateDeprecation(resHdrs, origin)
+	icfg.advertisePolicy(resHdrs)
+}
+
+// fetchMetadataIsConsistent reports whether the Fetch-metadata request
+// headers of r, if any, are consistent with r being a CORS request
+// (a CORS-preflight request if preflight is true) from origin;
+// see the documentation of ExtraConfig.ValidateSecFetchMetadata.
+func fetchMetadataIsConsistent(r *http.Request, origin string, preflight bool) bool {
+	site, _, _ := headers.First(r.Header, headers.SecFetchSite)
+	if preflight {
+		// see https://fetch.spec.whatwg.org/#cors-preflight-fetch-0 (step 1)
+		// and https://w3c.github.io/webappsec-fetch-metadata/#sec-fetch-site-header
+		if mode, _, found := headers.First(r.Header, headers.SecFetchMode); found &&
+			mode != headers.ValueModeCORS {
+			return false
+		}
+		return site != headers.ValueSameOrigin && site != headers.ValueNone
+	}
+	if site != headers.ValueSameOrigin || r.Host == "" {
+		return true
+	}
+	scheme, authority, found := strings.Cut(origin, "://")
+	if !found { // e.g. null
+		return true
+	}
+	host := r.Host
+	switch scheme {
+	case "https":
+		host = strings.TrimSuffix(host, ":443")
+	case "http":
+		host = strings.TrimSuffix(host, ":80")
+	}
+	return strings.EqualFold(authority, host)
+}
+
+// varyPreflight lists, in the Vary header of resHdrs, the header names
+// involved in CORS-preflight requests;
+// see the implementation comment in handleCORSPreflight.
+func (icfg *internalConfig) varyPreflight(resHdrs http.Header) {
+	vary, found := resHdrs[headers.Vary]
+	if !found { // fast path
+		resHdrs[headers.Vary] = icfg.varyPreflightSgl
+	} else { // slow path
+		resHdrs[headers.Vary] = append(vary, icfg.varyPreflightSgl[0])
+	}
+}
+
+// varyOptions lists, in the Vary header of resHdrs, the header names that
+// icfg's Vary strategy calls for in responses to OPTIONS requests
+// that are not CORS-preflight requests;
+// see the documentation of ExtraConfig.VaryStrategy.
+func (icfg *internalConfig) varyOptions(resHdrs http.Header) {
+	switch icfg.varyStrategy {
+	case VaryNone:
+		// deliberately omitted
+	case VaryOriginOnly:
+		resHdrs.Add(headers.Vary, icfg.varyOriginNames)
+	default:
+		resHdrs.Add(headers.Vary, icfg.varyPreflightSgl[0])
+	}
+}
+
+// varyOrigin lists, in the Vary header of resHdrs, Origin and
+// the other header names (if any) that responses to non-OPTIONS requests
+// depend on, unless icfg's Vary strategy calls for fewer names;
+// see the documentation of ExtraConfig.VaryStrategy
+// and ExtraConfig.CredentialsHeuristic.
+func (icfg *internalConfig) varyOrigin(resHdrs http.Header) {
+	switch icfg.varyStrategy {
+	case VaryNone:
+		// deliberately omitted
+	case VaryOriginOnly:
+		resHdrs.Add(headers.Vary, icfg.varyOriginNames)
+	default:
+		resHdrs.Add(headers.Vary, icfg.varyActual)
+	}
+}
+
+// isAnonymous reports whether a request whose headers are reqHdrs
+// carries no credentials (neither cookies nor an Authorization header).
+func isAnonymous(reqHdrs http.Header) bool {
+	return len(reqHdrs[headers.Cookie]) == 0 && len(reqHdrs[headers.Authz]) == 0
+}
+
+// handlePublicActual allows an anonymous actual (i.e. non-preflight)
+// CORS request from any origin, regardless of icfg's origin patterns.
+func (icfg *internalConfig) handlePublicActual(resHdrs http.Header, isOPTIONS bool) {
+	// Because the same resource may be requested with credentials,
+	// in which case the response depends on the request's origin,
+	// we list "Origin" in the Vary header.
+	if isOPTIONS {
+		// see the implementation comment in handleCORSPreflight
+		icfg.varyOptions(resHdrs)
+	} else {
+		icfg.varyOrigin(resHdrs)
+	}
+	resHdrs.Set(headers.ACAO, headers.ValueWildcard)
+	if icfg.aceh != "" {
+		resHdrs.Set(headers.ACEH, icfg.aceh)
+	}
+	icfg.advertisePolicy(resHdrs)
+}
+
+// emitsWildcardACAO reports whether icfg sets ACAO to the wildcard
+// (as opposed to the request's origin) in responses to allowed requests.
+// Only then do responses to actual requests not depend on the request's
+// origin; otherwise, those responses must list "Origin" in their Vary header.
+func (icfg *internalConfig) emitsWildcardACAO() bool {
+	// Note: allowing all origins with credentialed access enabled is
+	// currently prohibited, but the check on icfg.credentialed guards
+	// against any mode that would reflect arbitrary origins.
+	return icfg.allowAnyOrigin && !icfg.credentialed
+}
+
+// originIsAllowed reports whether origin is allowed by icfg's origin patterns
+// (regardless of whether icfg allows all origins).
+func (icfg *internalConfig) originIsAllowed(origin string) bool {
+	if len(origin) > icfg.maxOriginLen { // fail fast
+		return false
+	}
+	o, ok := origins.Parse(origin)
+	return ok && icfg.corpus.Contains(&o)
+}
+
+// concreteACAO reports whether icfg echoes origin, rather than
+// the wildcard, in the ACAO header of the response to actual request r;
+// see the documentation of ExtraConfig.ConcreteOriginForAnonymousAllowAll.
+// Precondition: icfg.emitsWildcardACAO() is true.
+func (icfg *internalConfig) concreteACAO(r *http.Request, origin string) bool {
+	if icfg.concreteAnyACAO == nil || len(origin) > icfg.maxOriginLen {
+		return false
+	}
+	if _, ok := origins.Parse(origin); !ok { // e.g. null
+		return false
+	}
+	return icfg.concreteAnyACAO(r)
+}
+
+// acao returns the value of the ACAO header for allowed origin,
+// whose single-element-slice form is originSgl.
+func (icfg *internalConfig) acao(origin string, originSgl []string) []string {
+	if icfg.transformACAO == nil {
+		return originSgl
+	}
+	transformed := icfg.transformACAO(origin)
+	if transformed == "" || transformed == origin {
+		return originSgl
+	}
+	if icfg.credentialed {
+		if _, ok := origins.Parse(transformed); !ok {
+			return originSgl
+		}
+	}
+	return []string{transformed}
+}
+
+// schemeIsCredentialed reports whether icfg grants credentialed access
+// to (allowed) origins of the specified scheme.
+func (icfg *internalConfig) schemeIsCredentialed(scheme string) bool {
+	return icfg.credentialedSchemes == nil || icfg.credentialedSchemes.Contains(scheme)
+}
+
+// originSchemeIsCredentialed reports whether icfg grants credentialed access
+// to (allowed) origin, judging by its scheme.
+func (icfg *internalConfig) originSchemeIsCredentialed(origin string) bool {
+	if icfg.credentialedSchemes == nil { // fast path
+		return true
+	}
+	scheme, _, _ := strings.Cut(origin, "://")
+	return icfg.credentialedSchemes.Contains(scheme)
+}
+
+// pathIsCredentialed reports whether icfg grants credentialed access
+// to (allowed) origins for request r, judging by
+// the credentialed-path predicate (if any).
+func (icfg *internalConfig) pathIsCredentialed(r *http.Request) bool {
+	return icfg.credPathPredicate == nil || icfg.credPathPredicate(r)
+}
+
+// annotateDeprecation adds the Deprecation and Sunset headers to resHdrs
+// if origin is deprecated.
+func (icfg *internalConfig) annotateDeprecation(resHdrs http.Header, origin string) {
+	if icfg.deprecatedOrigins == nil { // fast path
+		return
+	}
+	d, found := icfg.deprecatedOrigins[origin]
+	if !found {
+		return
+	}
+	resHdrs[headers.Deprecation] = headers.TrueSgl
+	resHdrs[headers.Sunset] = d.sunsetSgl
+}
+
+// advertisePolicy adds a Link header that references icfg's policy document
+// (if any) to resHdrs; see the documentation of ExtraConfig.PolicyLink.
+func (icfg *internalConfig) advertisePolicy(resHdrs http.Header) {
+	if icfg.policyLinkSgl == nil {
+		return
+	}
+	// The handler may add Link headers of its own; let's not overwrite them.
+	resHdrs[headers.Link] = append(resHdrs[headers.Link], icfg.policyLinkSgl...)
+}
+
+// mayBeCredentialed reports whether a request whose headers are reqHdrs
+// may be credentialed. Unless the credentials heuristic is enabled,
+// it invariably returns true.
+func (icfg *internalConfig) mayBeCredentialed(reqHdrs http.Header) bool {
+	if !icfg.credentialsHeuristic {
+		return true
+	}
+	return len(reqHdrs[headers.Cookie]) > 0 ||
+		len(reqHdrs[headers.Authz]) > 0
 }
 
 func (icfg *internalConfig) processACRM(
 	buf http.Header,
+	origin string,
 	acrm string,
 	acrmSgl []string,
+	debug bool,
 ) bool {
-	if methods.IsSafelisted(acrm, struct{}{}) {
-		// CORS-safelisted methods get a free pass; see
+	if methods.IsForbidden(acrm) {
+		// Fetch-compliant browsers never request a forbidden method;
+		// see https://fetch.spec.whatwg.org/#forbidden-method.
+		// Such a method cannot be allowed, not even by the wildcard.
+		// In debug mode, we pinpoint it in a non-standard response header,
+		// because its use hints at a non-browser client.
+		if debug {
+			buf[headers.XDebugForbiddenMethod] = acrmSgl
+		}
+		return false
+	}
+	if methods.IsSafelisted(acrm, struct{}{}) &&
+		!(icfg.headNonSafe && acrm == http.MethodHead) {
+		// CORS-safelisted methods get a free pass
+		// (unless ExtraConfig.TreatHeadAsNonSafe says otherwise); see
 		// https://fetch.spec.whatwg.org/#ref-for-cors-safelisted-method%E2%91%A2.
 		// Therefore, no need to set the ACAM header in this case.
 		return true
 	}
-	if icfg.allowAnyMethod && !icfg.credentialed {
+	if icfg.extraSafelistedMethods.Contains(acrm) {
+		// See the documentation of ExtraConfig.AdditionalSafelistedMethods.
+		return true
+	}
+	allowAnyMethod, allowedMethods := icfg.allowAnyMethod, icfg.allowedMethods
+	if ms, found := icfg.originMethods[origin]; found {
+		allowAnyMethod, allowedMethods = ms.allowAny, ms.allowed
+	}
+	if allowAnyMethod && !icfg.credentialed {
 		buf[headers.ACAM] = headers.WildcardSgl
 		return true
 	}
-	if icfg.allowAnyMethod || icfg.allowedMethods.Contains(acrm) {
+	if allowAnyMethod && icfg.credWildcardACAM != nil {
+		// See the documentation of ExtraConfig.CredentialedWildcardMethods.
+		buf[headers.ACAM] = icfg.credWildcardACAM
+		return true
+	}
+	if allowAnyMethod || allowedMethods.Contains(acrm) {
 		buf[headers.ACAM] = acrmSgl
 		return true
 	}
@@ -420,6 +1496,14 @@ func (icfg *internalConfig) processACRH(
 			// containing a maliciously long ACRH header in order to exercise
 			// this costly execution path and thereby generate undue load
 			// on the server.
+			//
+			// ExtraConfig.BoundedAuthorizationScan offers a middle ground:
+			// the scan is only performed on ACRH headers short enough.
+			if len(acrh) <= icfg.authzScanLimit &&
+				!headers.ListsAuthorization(acrhSgl) {
+				buf[headers.ACAH] = headers.WildcardSgl
+				return true
+			}
 			buf[headers.ACAH] = headers.WildcardAuthSgl
 		} else {
 			buf[headers.ACAH] = headers.WildcardSgl
@@ -460,16 +1544,38 @@ func (icfg *internalConfig) processACRH(
 		buf[headers.ACAH] = acrhSgl
 		return true
 	}
+	if icfg.lenientACRHWhitespace {
+		// See the documentation of ExtraConfig.LenientACRHTokenWhitespace.
+		if stripped, ok := headers.StripWhitespace(acrh); ok {
+			acrh, acrhSgl = stripped, []string{stripped}
+		}
+	}
 	if !debug {
 		if icfg.allowedReqHdrs.Size() == 0 {
 			return false
 		}
+		if icfg.sortACAH {
+			// See the documentation of ExtraConfig.SortAllowHeaders.
+			sorted, ok := icfg.allowedReqHdrs.Sorted(acrh)
+			if !ok {
+				return false
+			}
+			buf[headers.ACAH] = []string{sorted}
+			return true
+		}
 		if !icfg.allowedReqHdrs.Subsumes(acrh) {
 			return false
 		}
 		buf[headers.ACAH] = acrhSgl
 		return true
 	}
+	// In debug mode, we also pinpoint (in a non-standard response header)
+	// the first requested header name that isn't allowed, if any.
+	// Because we stop at the first such name, the cost of this additional
+	// computation remains bounded.
+	if name, found := icfg.allowedReqHdrs.FirstNonElem(acrh); found {
+		buf[headers.XDebugRejectedHeader] = []string{name}
+	}
 	if icfg.acah != nil {
 		buf[headers.ACAH] = icfg.acah
 		return true
@@ -477,17 +1583,69 @@ func (icfg *internalConfig) processACRH(
 	return false
 }
 
+// acrhTooLongForDebug reports whether the ACRH header in reqHdrs (if any)
+// is longer than icfg allows in debug mode.
+func (icfg *internalConfig) acrhTooLongForDebug(reqHdrs http.Header) bool {
+	acrh, _, _ := headers.First(reqHdrs, headers.ACRH)
+	return len(acrh) > icfg.maxDebugACRH
+}
+
 // SetDebug turns debug mode on (if b is true) or off (otherwise).
 // If m happens to be a passthrough middleware,
 // its debug mode is invariably off and SetDebug is a no-op.
+// SetDebug also cancels any revert scheduled by [*Middleware.SetDebugFor].
 func (m *Middleware) SetDebug(b bool) {
 	m.mu.Lock()
 	if m.icfg != nil {
-		m.icfg.debug = b
+		m.debug = b
+		m.stopDebugTimer()
 	}
 	m.mu.Unlock()
 }
 
+// SetDebugFor turns debug mode on for duration d, after which it
+// automatically turns debug mode off; SetDebugFor cancels any revert
+// scheduled by a prior call, and so does a call to [*Middleware.SetDebug].
+// SetDebugFor is a safer alternative to SetDebug for troubleshooting
+// production systems, since it removes the risk of leaving debug mode on.
+// If d is not positive, SetDebugFor simply turns debug mode off.
+// If m happens to be a passthrough middleware,
+// its debug mode is invariably off and SetDebugFor is a no-op.
+func (m *Middleware) SetDebugFor(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.icfg == nil {
+		return
+	}
+	m.stopDebugTimer()
+	if d <= 0 {
+		m.debug = false
+		return
+	}
+	m.debug = true
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		m.mu.Lock()
+		// Because m.mu was held while t was being assigned,
+		// t is guaranteed to be set by now.
+		if m.debugTimer == t { // the revert hasn't been canceled
+			m.debug = false
+			m.debugTimer = nil
+		}
+		m.mu.Unlock()
+	})
+	m.debugTimer = t
+}
+
+// stopDebugTimer cancels any scheduled revert of debug mode.
+// The caller must hold m.mu for writing.
+func (m *Middleware) stopDebugTimer() {
+	if m.debugTimer != nil {
+		m.debugTimer.Stop()
+		m.debugTimer = nil
+	}
+}
+
 // Config returns a pointer to a deep copy of m's current configuration;
 // if m is a passthrough middleware, it simply returns nil.
 // The result may differ from the [Config] with which m was created or last
@@ -506,3 +1664,425 @@ func (m *Middleware) Config() *Config {
 	m.mu.RUnlock()
 	return newConfig(icfg)
 }
+
+// ConfigHash returns a deterministic hash of m's current configuration,
+// which is useful for detecting configuration drift
+// or for deriving cache keys.
+// The hash is computed over the normalized form of that configuration
+// (as reported by [*Middleware.Config]);
+// therefore, two middleware whose configurations are equivalent
+// (e.g. that list the same origins in a different order)
+// produce the same hash, whereas two middleware that behave differently
+// produce different hashes (barring hash collisions).
+// Func-valued settings (e.g. ResponseHeaderHook) only contribute
+// to the hash through their presence or absence,
+// and debug mode has no bearing on the hash.
+// If m is a passthrough middleware, ConfigHash returns 0.
+//
+// The hash is stable across calls within a given version of this package,
+// but it may change across versions; do not persist it.
+func (m *Middleware) ConfigHash() uint64 {
+	cfg := m.Config()
+	if cfg == nil {
+		return 0
+	}
+	// The order of origin patterns is mere presentation metadata;
+	// see the documentation of ExtraConfig.PreserveOriginOrder.
+	slices.Sort(cfg.Origins)
+	env := configToEnv(cfg)
+	h := fnv.New64a()
+	for _, k := range sortedKeys(env) {
+		// The NUL byte occurs in neither keys nor values,
+		// which makes this encoding unambiguous.
+		io.WriteString(h, k)
+		h.Write([]byte{0})
+		io.WriteString(h, env[k])
+		h.Write([]byte{0})
+	}
+	// Func-valued settings, along with the settings that ConfigAsEnv omits
+	// because they're useless without some func-valued setting.
+	extras := []struct {
+		name    string
+		present bool
+	}{
+		{"ResponseHeaderHook", cfg.ResponseHeaderHook != nil},
+		{"ReportOnlyHook", cfg.ReportOnlyHook != nil},
+		{"OnSlowPreflight", cfg.OnSlowPreflight != nil},
+		{"ObserveLatency", cfg.ObserveLatency != nil},
+		{"OnDisallowedContentType", cfg.OnDisallowedContentType != nil},
+		{"OriginResolver", cfg.OriginResolver != nil},
+		{"TransformAllowedOrigin", cfg.TransformAllowedOrigin != nil},
+		{"PublicAnyOriginPredicate", cfg.PublicAnyOriginPredicate != nil},
+		{"ConcreteOriginForAnonymousAllowAll", cfg.ConcreteOriginForAnonymousAllowAll != nil},
+		{"CredentialedPathPredicate", cfg.CredentialedPathPredicate != nil},
+		{"OnUnusedExposedHeaders", cfg.OnUnusedExposedHeaders != nil},
+		{"WarnOnUnusedExposedHeaders", cfg.WarnOnUnusedExposedHeaders},
+	}
+	for _, extra := range extras {
+		if extra.present {
+			io.WriteString(h, extra.name)
+			h.Write([]byte{0})
+		}
+	}
+	return h.Sum64()
+}
+
+// AllowedMethods returns the sorted names of the methods,
+// other than the [CORS-safelisted methods], that m's current configuration
+// allows, or a single asterisk if m allows all methods.
+// If m allows no methods other than the CORS-safelisted ones
+// or happens to be a passthrough middleware, AllowedMethods returns nil.
+// If ExtraConfig.TreatHeadAsNonSafe is set, HEAD is listed in the result
+// whenever m's current configuration explicitly allows it.
+// Note that the result does not reflect the per-origin method sets
+// configured via the ExtraConfig.OriginMethods field.
+//
+// Mutating the result does not alter m's behavior.
+//
+// [CORS-safelisted methods]: https://fetch.spec.whatwg.org/#cors-safelisted-method
+func (m *Middleware) AllowedMethods() []string {
+	m.mu.RLock()
+	icfg := m.icfg
+	m.mu.RUnlock()
+	if icfg == nil {
+		return nil
+	}
+	return methodNames(icfg.allowedMethods, icfg.allowAnyMethod)
+}
+
+// OriginsInConfigOrder returns the origin patterns of m's current
+// configuration in the order in which they were specified
+// (duplicates excepted) if that configuration sets
+// ExtraConfig.PreserveOriginOrder; otherwise, or if m happens to be
+// a passthrough middleware, OriginsInConfigOrder returns nil.
+//
+// Mutating the result does not alter m's behavior.
+func (m *Middleware) OriginsInConfigOrder() []string {
+	m.mu.RLock()
+	icfg := m.icfg
+	m.mu.RUnlock()
+	if icfg == nil {
+		return nil
+	}
+	return slices.Clone(icfg.originOrder)
+}
+
+// A PNAMode describes a middleware's Private-Network Access posture;
+// see the documentation of ExtraConfig.PrivateNetworkAccess and
+// ExtraConfig.PrivateNetworkAccessInNoCORSModeOnly.
+type PNAMode uint8
+
+const (
+	// PNAOff indicates that Private-Network Access is disabled.
+	PNAOff PNAMode = iota
+	// PNAOn indicates that Private-Network Access is enabled
+	// (ExtraConfig.PrivateNetworkAccess).
+	PNAOn
+	// PNANoCORSOnly indicates that Private-Network Access is enabled
+	// in no-cors mode only
+	// (ExtraConfig.PrivateNetworkAccessInNoCORSModeOnly).
+	PNANoCORSOnly
+)
+
+// String returns the name of the PNAMode constant equal to mode
+// (e.g. "PNAOn").
+func (mode PNAMode) String() string {
+	switch mode {
+	case PNAOff:
+		return "PNAOff"
+	case PNAOn:
+		return "PNAOn"
+	case PNANoCORSOnly:
+		return "PNANoCORSOnly"
+	default:
+		return "PNAMode(" + strconv.Itoa(int(mode)) + ")"
+	}
+}
+
+// PrivateNetworkAccessMode returns m's current Private-Network Access mode.
+// If m happens to be a passthrough middleware,
+// PrivateNetworkAccessMode returns PNAOff.
+func (m *Middleware) PrivateNetworkAccessMode() PNAMode {
+	m.mu.RLock()
+	icfg := m.icfg
+	m.mu.RUnlock()
+	switch {
+	case icfg == nil:
+		return PNAOff
+	case icfg.privateNetworkAccess:
+		return PNAOn
+	case icfg.privateNetworkAccessNoCors:
+		return PNANoCORSOnly
+	default:
+		return PNAOff
+	}
+}
+
+// VaryValues returns the header names that m may list in the Vary header
+// of responses to CORS-preflight requests,
+// of responses to other OPTIONS requests, and
+// of responses to non-OPTIONS requests, respectively.
+// Caching intermediaries in front of m can rely on those results
+// for configuring their cache keys.
+// If m happens to be a passthrough middleware,
+// VaryValues returns nil, nil, nil.
+//
+// Some responses may list fewer header names than VaryValues reports;
+// for instance, a middleware configured to allow all origins
+// without credentials only lists Origin in the Vary header of responses
+// to the requests that ExtraConfig.PublicAnyOriginPredicate matches,
+// and a middleware configured with ExtraConfig.OmitVaryForDisallowedOrigins
+// lists none in responses to actual requests from disallowed origins.
+//
+// Mutating the results does not alter m's behavior.
+func (m *Middleware) VaryValues() (preflight, options, actual []string) {
+	m.mu.RLock()
+	icfg := m.icfg
+	m.mu.RUnlock()
+	if icfg == nil {
+		return nil, nil, nil
+	}
+	return icfg.varyValues()
+}
+
+// varyValues derives its results from the helpers that populate
+// the Vary header of responses, so as to stay in sync with them.
+func (icfg *internalConfig) varyValues() (preflight, options, actual []string) {
+	preflight = varyNames(icfg.varyPreflight)
+	options = varyNames(icfg.varyOptions)
+	// see handleNonCORS, handleCORSActual, and handlePublicActual
+	if icfg.publicAnyOrigin != nil || icfg.concreteAnyACAO != nil ||
+		(!icfg.privateNetworkAccessNoCors && !icfg.emitsWildcardACAO()) {
+		actual = varyNames(icfg.varyOrigin)
+	}
+	return preflight, options, actual
+}
+
+// varyNames returns the header names that vary lists in
+// the Vary header of an empty set of response headers.
+func varyNames(vary func(resHdrs http.Header)) []string {
+	resHdrs := make(http.Header, 1)
+	vary(resHdrs)
+	var names []string
+	for _, v := range resHdrs[headers.Vary] {
+		for _, name := range strings.Split(v, headers.ValueSep) {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	return names
+}
+
+// Warnings returns warnings about m's current configuration.
+// Those warnings point to settings that, though valid,
+// may not reflect your intent or may be dangerous;
+// they never prevent a middleware from being built or reconfigured.
+// If m happens to be a passthrough middleware
+// or if m's current configuration elicits no warnings,
+// Warnings returns nil.
+//
+// Mutating the result does not alter m's behavior.
+func (m *Middleware) Warnings() []error {
+	m.mu.RLock()
+	icfg := m.icfg
+	m.mu.RUnlock()
+	if icfg == nil {
+		return nil
+	}
+	return slices.Clone(icfg.warnings)
+}
+
+// WouldRegress reports which of sampleOrigins m's current configuration
+// allows but newCfg would not; in the absence of such origins,
+// WouldRegress returns nil.
+// The origins in the result appear in the order in which they appear
+// in sampleOrigins.
+// A control plane can call WouldRegress before
+// [*Middleware.Reconfigure] in order to catch policy regressions.
+// If newCfg is invalid, WouldRegress returns a nil slice and
+// the same error that [NewMiddleware] would return.
+// If m happens to be a passthrough middleware, it allows no origins;
+// therefore, WouldRegress then returns nil and the result of
+// newCfg's validation.
+//
+// WouldRegress evaluates origins as m would evaluate the value of the Origin
+// header of some request, but it disregards the other aspects of requests
+// (e.g. their method and headers) and settings that don't pertain
+// to origins (e.g. ExtraConfig.OriginResolver).
+// WouldRegress never alters m's behavior.
+func (m *Middleware) WouldRegress(newCfg Config, sampleOrigins []string) ([]string, error) {
+	m.mu.RLock()
+	icfg := m.icfg
+	m.mu.RUnlock()
+	newICfg, err := newInternalConfig(&newCfg)
+	if err != nil {
+		return nil, err
+	}
+	if icfg == nil {
+		return nil, nil
+	}
+	var regressed []string
+	for _, origin := range sampleOrigins {
+		if icfg.allowsOrigin(origin) && !newICfg.allowsOrigin(origin) {
+			regressed = append(regressed, origin)
+		}
+	}
+	return regressed, nil
+}
+
+// allowsOrigin reports whether icfg allows origin (after normalization),
+// regardless of report-only mode.
+func (icfg *internalConfig) allowsOrigin(origin string) bool {
+	if icfg.allowAnyOrigin {
+		return true
+	}
+	if len(origin) > icfg.maxOriginLen { // fail fast
+		return false
+	}
+	return icfg.originIsAllowed(icfg.normalizeOrigin(origin))
+}
+
+// A corsHeaderGuard is a [http.ResponseWriter] that, right before
+// the response headers get written,
+//   - if override is set, discards any CORS response headers
+//     that the wrapped handler may have set or added
+//     and reinstates those that the middleware set;
+//   - if reflect is set, exposes the response headers present.
+//
+// See the documentation of ExtraConfig.OverrideHandlerCORSHeaders
+// and ExtraConfig.ReflectExposedResponseHeaders.
+type corsHeaderGuard struct {
+	http.ResponseWriter
+	acao, acac, aceh []string // as set by the middleware
+	override         bool
+	reflect          bool
+	wroteHeader      bool
+}
+
+func newCORSHeaderGuard(w http.ResponseWriter, override, reflect bool) *corsHeaderGuard {
+	resHdrs := w.Header()
+	g := corsHeaderGuard{
+		ResponseWriter: w,
+		acao:           resHdrs[headers.ACAO],
+		acac:           resHdrs[headers.ACAC],
+		aceh:           resHdrs[headers.ACEH],
+		override:       override,
+		reflect:        reflect,
+	}
+	return &g
+}
+
+func (g *corsHeaderGuard) sanitize() {
+	if g.wroteHeader {
+		return
+	}
+	resHdrs := g.ResponseWriter.Header()
+	if g.override {
+		for name := range resHdrs {
+			if strings.HasPrefix(name, headers.PrefixAccessControl) {
+				delete(resHdrs, name)
+			}
+		}
+		setIfNonNil(resHdrs, headers.ACAO, g.acao)
+		setIfNonNil(resHdrs, headers.ACAC, g.acac)
+		setIfNonNil(resHdrs, headers.ACEH, g.aceh)
+	}
+	if g.reflect {
+		if aceh := headers.ExposableNames(resHdrs); aceh != "" {
+			resHdrs.Set(headers.ACEH, aceh)
+		} else {
+			resHdrs.Del(headers.ACEH)
+		}
+	}
+}
+
+func setIfNonNil(hdrs http.Header, name string, values []string) {
+	if values != nil {
+		hdrs[name] = values
+	}
+}
+
+func (g *corsHeaderGuard) WriteHeader(statusCode int) {
+	g.sanitize()
+	// Informational (1xx) responses precede the final response headers.
+	if statusCode >= http.StatusOK {
+		g.wroteHeader = true
+	}
+	g.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (g *corsHeaderGuard) Write(p []byte) (int, error) {
+	g.sanitize()
+	g.wroteHeader = true
+	return g.ResponseWriter.Write(p)
+}
+
+// Flush implements [http.Flusher].
+func (g *corsHeaderGuard) Flush() {
+	g.sanitize()
+	g.wroteHeader = true
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap enables [http.ResponseController] to access
+// the underlying [http.ResponseWriter].
+func (g *corsHeaderGuard) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// A deferredCORSWriter is a [http.ResponseWriter] that defers the setting
+// of the CORS response headers (and of the Vary header) until right before
+// the response headers get written;
+// see the documentation of ExtraConfig.ApplyAfterHandler.
+type deferredCORSWriter struct {
+	http.ResponseWriter
+	icfg      *internalConfig
+	r         *http.Request
+	cors      bool // whether r is a CORS request
+	origin    string
+	originSgl []string
+	isOPTIONS bool
+	applied   bool
+}
+
+func (dw *deferredCORSWriter) apply() {
+	if dw.applied {
+		return
+	}
+	dw.applied = true
+	icfg, w := dw.icfg, dw.ResponseWriter
+	if dw.cors {
+		icfg.handleActual(w, dw.r, dw.origin, dw.originSgl, dw.isOPTIONS)
+	} else {
+		icfg.handleNonCORS(w.Header(), dw.isOPTIONS)
+	}
+	icfg.lowercaseNames(w.Header())
+}
+
+func (dw *deferredCORSWriter) WriteHeader(statusCode int) {
+	// Informational (1xx) responses precede the final response headers.
+	if statusCode >= http.StatusOK {
+		dw.apply()
+	}
+	dw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (dw *deferredCORSWriter) Write(p []byte) (int, error) {
+	dw.apply()
+	return dw.ResponseWriter.Write(p)
+}
+
+// Flush implements [http.Flusher].
+func (dw *deferredCORSWriter) Flush() {
+	dw.apply()
+	if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap enables [http.ResponseController] to access
+// the underlying [http.ResponseWriter].
+func (dw *deferredCORSWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
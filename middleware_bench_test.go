@@ -77,6 +77,87 @@ func BenchmarkMiddleware(b *testing.B) {
 						headerACRM:   http.MethodGet,
 						headerACRH:   strings.Repeat("a,", 1024),
 					},
+				}, {
+					// exceeds the default bound (see ExtraConfig.MaxDebugACAHBytes)
+					desc:      "preflight with oversized ACRH",
+					reqMethod: http.MethodOptions,
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodGet,
+						headerACRH:   strings.Repeat("a,", 8192),
+					},
+				}, {
+					desc:      "preflight from oversized",
+					reqMethod: http.MethodOptions,
+					reqHeaders: Headers{
+						headerOrigin: oversizedOrigin,
+						headerACRM:   http.MethodGet,
+						headerACRH:   "authorization",
+					},
+				}, {
+					desc:      "actual from oversized",
+					reqMethod: http.MethodGet,
+					reqHeaders: Headers{
+						headerOrigin: oversizedOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "single origin tight max origin length",
+			newHandler: newDummyHandler(),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: requestHeadersAllowedByDefaultInRsCORS,
+				ExtraConfig: cors.ExtraConfig{
+					MaxOriginLength: len("https://example.com"),
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight from oversized",
+					reqMethod: http.MethodOptions,
+					reqHeaders: Headers{
+						headerOrigin: oversizedOrigin,
+						headerACRM:   http.MethodGet,
+						headerACRH:   "authorization",
+					},
+				}, {
+					desc:      "actual from oversized",
+					reqMethod: http.MethodGet,
+					reqHeaders: Headers{
+						headerOrigin: oversizedOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "single origin some req headers header budget",
+			newHandler: newDummyHandler(),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: requestHeadersAllowedByDefaultInRsCORS,
+				ExtraConfig: cors.ExtraConfig{
+					MaxProcessedHeaderBytes: 1024,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight from allowed",
+					reqMethod: http.MethodOptions,
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodGet,
+						headerACRH:   "authorization",
+					},
+				}, {
+					// exceeds the budget; compare with the same case
+					// in "single origin some req headers"
+					desc:      "preflight with adversarial ACRH",
+					reqMethod: http.MethodOptions,
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodGet,
+						headerACRH:   strings.Repeat("a,", 1024),
+					},
 				},
 			},
 		}, {
@@ -229,6 +310,43 @@ func BenchmarkMiddleware(b *testing.B) {
 					},
 				},
 			},
+		}, {
+			desc:       "any req headers bounded Authorization scan",
+			newHandler: newDummyHandler(),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"*", "Authorization"},
+				ExtraConfig: cors.ExtraConfig{
+					BoundedAuthorizationScan: 1024,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight",
+					reqMethod: http.MethodOptions,
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodGet,
+						headerACRH:   "authorization",
+					},
+				}, {
+					desc:      "preflight with ACRH within scan limit",
+					reqMethod: http.MethodOptions,
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodGet,
+						headerACRH:   strings.Repeat("a,", 511) + "a",
+					},
+				}, {
+					desc:      "preflight with ACRH beyond scan limit",
+					reqMethod: http.MethodOptions,
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodGet,
+						headerACRH:   strings.Repeat("a,", 1024),
+					},
+				},
+			},
 		}, {
 			desc:       "no CORS, outer Vary",
 			outerMw:    &varyMiddleware,
@@ -375,6 +493,10 @@ var severalOrigins = []string{
 
 const hostMaxLen = 253
 
+// oversizedOrigin is long (but not longer than the longest origin that
+// a middleware could possibly allow) and ends like an allowed origin.
+var oversizedOrigin = "https://" + strings.Repeat("a.", 100) + "example.com"
+
 var manyOrigins []string
 
 func init() {
@@ -30,6 +30,26 @@ func ExampleMiddleware_Wrap() {
 	log.Fatal(http.ListenAndServe(":8080", mux))
 }
 
+func ExampleMiddleware_PreflightHandler() {
+	// create CORS middleware
+	corsMw, err := cors.NewMiddleware(cors.Config{
+		Origins: []string{"https://example.com"},
+		Methods: []string{http.MethodPost},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	// Preflight requests are handled on an explicit OPTIONS route...
+	mux.Handle("OPTIONS /users", corsMw.PreflightHandler())
+	// ... whereas actual requests still go through the CORS middleware.
+	mux.Handle("GET /users", corsMw.Wrap(http.HandlerFunc(handleUsersGet)))
+	mux.Handle("POST /users", corsMw.Wrap(http.HandlerFunc(handleUsersPost)))
+
+	log.Fatal(http.ListenAndServe(":8080", mux))
+}
+
 func handleHello(w http.ResponseWriter, _ *http.Request) {
 	io.WriteString(w, "Hello, World!")
 }
@@ -1,9 +1,16 @@
 package cors_test
 
 import (
+	"errors"
+	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/jub0bs/cors"
 	"github.com/jub0bs/cors/internal/headers"
@@ -611,9 +618,10 @@ func TestMiddleware(t *testing.T) {
 					preflightPassesCORSCheck: true,
 					preflightFails:           true,
 					respHeaders: Headers{
-						headerACAO: "http://localhost:9090",
-						headerACAC: "true",
-						headerVary: varyPreflightValue,
+						headerACAO:                 "http://localhost:9090",
+						headerACAC:                 "true",
+						headerXDebugRejectedHeader: "bar",
+						headerVary:                 varyPreflightValue,
 					},
 				}, {
 					desc:      "preflight with disallowed method",
@@ -659,11 +667,31 @@ func TestMiddleware(t *testing.T) {
 					preflightPassesCORSCheck: true,
 					preflightFails:           true,
 					respHeaders: Headers{
-						headerACAO: "http://localhost:9090",
-						headerACAC: "true",
-						headerACAH: "authorization",
-						headerACMA: "30",
-						headerVary: varyPreflightValue,
+						headerACAO:                 "http://localhost:9090",
+						headerACAC:                 "true",
+						headerACAH:                 "authorization",
+						headerACMA:                 "30",
+						headerXDebugRejectedHeader: "bar",
+						headerVary:                 varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with GET and some disallowed headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+						headerACRH:   "authorization,x-bar,x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerACAO:                 "http://localhost:9090",
+						headerACAC:                 "true",
+						headerACAH:                 "authorization",
+						headerACMA:                 "30",
+						headerXDebugRejectedHeader: "x-bar",
+						headerVary:                 varyPreflightValue,
 					},
 				},
 			},
@@ -863,8 +891,9 @@ func TestMiddleware(t *testing.T) {
 					preflightPassesCORSCheck: true,
 					preflightFails:           true,
 					respHeaders: Headers{
-						headerACAO: wildcard,
-						headerVary: varyPreflightValue,
+						headerACAO:      wildcard,
+						headerXDebugPNA: "requested-but-disabled",
+						headerVary:      varyPreflightValue,
 					},
 				}, {
 					desc:      "preflight with PUT and ACRPN and headers",
@@ -879,8 +908,9 @@ func TestMiddleware(t *testing.T) {
 					preflightPassesCORSCheck: true,
 					preflightFails:           true,
 					respHeaders: Headers{
-						headerACAO: wildcard,
-						headerVary: varyPreflightValue,
+						headerACAO:      wildcard,
+						headerXDebugPNA: "requested-but-disabled",
+						headerVary:      varyPreflightValue,
 					},
 				},
 			},
@@ -1398,667 +1428,6574 @@ func TestMiddleware(t *testing.T) {
 					},
 				},
 			},
-		},
-	}
-	for _, mwtc := range cases {
-		f := func(t *testing.T) {
-			t.Parallel()
-			var (
-				mw  *cors.Middleware
-				err error
-			)
-			if mwtc.cfg == nil {
-				mw = new(cors.Middleware)
-			} else {
-				mw, err = cors.NewMiddleware(*mwtc.cfg)
-				if err != nil {
-					t.Fatalf("failure to build CORS middleware: %v", err)
-				}
-			}
-			if mwtc.debug {
-				mw.SetDebug(true)
-			}
-			for _, tc := range mwtc.cases {
-				f := func(t *testing.T) {
-					// --- arrange ---
-					innerHandler := mwtc.newHandler()
-					handler := mw.Wrap(innerHandler)
-					if outerMiddleware := mwtc.outerMw; outerMiddleware != nil {
-						handler = outerMiddleware.Wrap(handler)
-					}
-					req := newRequest(tc.reqMethod, tc.reqHeaders)
-					rec := httptest.NewRecorder()
-
-					// --- act ---
-					handler.ServeHTTP(rec, req)
-					res := rec.Result()
-
-					// --- assert ---
-					spy, ok := innerHandler.(*spyHandler)
-					if !ok {
-						t.Fatalf("handler is not a *spyHandler")
-					}
-					if tc.preflight { // preflight request
-						if spy.called.Load() {
-							t.Error("wrapped handler was called, but it should not have been")
-						}
-						assertPreflightStatus(t, spy.statusCode, res.StatusCode, &mwtc, &tc)
-						assertResponseHeaders(t, res.Header, tc.respHeaders)
-						if mwtc.outerMw != nil {
-							assertResponseHeaders(t, res.Header, mwtc.outerMw.hdrs)
-						}
-						assertNoMoreResponseHeaders(t, res.Header)
-						assertBody(t, res.Body, "")
-						return
-					} // non-preflight request
-					if !spy.called.Load() {
-						t.Error("wrapped handler wasn't called, but it should have been")
-					}
-					if res.StatusCode != spy.statusCode {
-						const tmpl = "got status code %d; want %d"
-						t.Errorf(tmpl, res.StatusCode, spy.statusCode)
-					}
-					assertResponseHeaders(t, res.Header, spy.respHeaders)
-					assertResponseHeaders(t, res.Header, tc.respHeaders)
-					if mwtc.outerMw != nil {
-						assertResponseHeaders(t, res.Header, mwtc.outerMw.hdrs)
-					}
-					assertNoMoreResponseHeaders(t, res.Header)
-					assertBody(t, res.Body, spy.body)
-				}
-				t.Run(tc.desc, f)
-			}
-		}
-		t.Run(mwtc.desc, f)
-	}
-}
-
-func TestWrappedHandlerCannotMutatePackageLevelSlices(t *testing.T) {
-	cases := []MiddlewareTestCase{
-		{
-			desc:       "anonymous",
-			newHandler: newMutatingHandler,
+		}, {
+			desc:       "request-method fallback header",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
 			cfg: &cors.Config{
-				Origins:         []string{"*"},
-				ResponseHeaders: []string{"*"},
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					RequestMethodHeaderFallback: "x-requested-method",
+				},
 			},
 			cases: []ReqTestCase{
 				{
-					desc:      "non-CORS GET",
-					reqMethod: "GET",
+					desc:      "non-CORS OPTIONS",
+					reqMethod: "OPTIONS",
+					respHeaders: Headers{
+						headerVary: varyPreflightValue + ", X-Requested-Method",
+					},
 				}, {
-					desc:      "actual GET",
-					reqMethod: "GET",
+					desc:      "preflight with PUT from allowed",
+					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
 						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue + ", X-Requested-Method",
 					},
 				}, {
-					desc:      "actual OPTIONS",
+					desc:      "OPTIONS with PUT in fallback header from allowed",
 					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
-						headerOrigin: "https://example.com",
+						headerOrigin:         "https://example.com",
+						"X-Requested-Method": "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue + ", X-Requested-Method",
+					},
+				}, {
+					desc:      "OPTIONS with DELETE in fallback header from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:         "https://example.com",
+						"X-Requested-Method": "DELETE",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue + ", X-Requested-Method",
+					},
+				}, {
+					desc:      "preflight with PUT takes precedence over fallback header",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:         "https://example.com",
+						headerACRM:           "PUT",
+						"X-Requested-Method": "DELETE",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue + ", X-Requested-Method",
+					},
+				}, {
+					desc:      "actual GET with fallback header from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin:         "https://example.com",
+						"X-Requested-Method": "PUT",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
 					},
 				},
 			},
 		}, {
-			desc:       "credentialed",
-			newHandler: newMutatingHandler,
+			desc:       "origin methods",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
 			cfg: &cors.Config{
-				Origins:         []string{"https://example.com"},
-				Credentialed:    true,
-				ResponseHeaders: []string{"X-Foo", "X-Bar"},
+				Origins: []string{
+					"https://example.com",
+					"https://*.example.com",
+				},
+				Methods: []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					OriginMethods: map[string][]string{
+						"https://app.example.com":  {http.MethodDelete},
+						"https://anon.example.com": {http.MethodGet},
+						"https://all.example.com":  {"*"},
+					},
+				},
 			},
 			cases: []ReqTestCase{
 				{
-					desc:      "actual GET",
-					reqMethod: "GET",
+					desc:      "preflight with PUT from origin subject to global methods",
+					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
 						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
 					},
 				}, {
-					desc:      "actual OPTIONS",
+					desc:      "preflight with DELETE from origin subject to global methods",
 					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
 						headerOrigin: "https://example.com",
+						headerACRM:   "DELETE",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with DELETE from origin with its own methods",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://app.example.com",
+						headerACRM:   "DELETE",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://app.example.com",
+						headerACAM: "DELETE",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT from origin with its own methods",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://app.example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT from origin with safelisted methods only",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://anon.example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with GET from origin with safelisted methods only",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://anon.example.com",
+						headerACRM:   "GET",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://anon.example.com",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PURGE from origin with all methods",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://all.example.com",
+						headerACRM:   "PURGE",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://all.example.com",
+						headerACAM: "*",
+						headerVary: varyPreflightValue,
 					},
 				},
 			},
-		},
-	}
-	checks := []struct {
-		desc string
-		old  string
-		sgl  []string
-	}{
-		{
-			desc: "headers.PreflightVarySgl[0]",
-			old:  headers.PreflightVarySgl[0],
-			sgl:  headers.PreflightVarySgl,
-		}, {
-			desc: "headers.TrueSgl[0]",
-			old:  headers.TrueSgl[0],
-			sgl:  headers.TrueSgl,
-		}, {
-			desc: "headers.OriginSgl[0]",
-			old:  headers.OriginSgl[0],
-			sgl:  headers.OriginSgl,
 		}, {
-			desc: "headers.WildcardSgl[0]",
-			old:  headers.WildcardSgl[0],
-			sgl:  headers.WildcardSgl,
-		}, {
-			desc: "headers.WildcardAuthSgl[0]",
-			old:  headers.WildcardAuthSgl[0],
-			sgl:  headers.WildcardAuthSgl,
-		},
-	}
-	for _, mwtc := range cases {
-		f := func(t *testing.T) {
-			t.Parallel()
-			var (
-				mw  *cors.Middleware
-				err error
-			)
-			if mwtc.cfg == nil {
-				mw = new(cors.Middleware)
-			} else {
-				mw, err = cors.NewMiddleware(*mwtc.cfg)
-				if err != nil {
-					t.Fatalf("failure to build CORS middleware: %v", err)
-				}
-			}
-			for _, tc := range mwtc.cases {
-				f := func(t *testing.T) {
-					// --- arrange ---
-					handler := mwtc.newHandler()
-					handler = mw.Wrap(handler)
-					req := newRequest(tc.reqMethod, tc.reqHeaders)
-					rec := httptest.NewRecorder()
-
-					// --- act ---
-					handler.ServeHTTP(rec, req)
-
-					// --- assert ---
-					for _, check := range checks {
-						want := check.old
-						got := check.sgl[0]
-						if got != want {
-							t.Errorf("%s: got %q; want %q", check.desc, got, want)
-						}
-					}
-				}
-				t.Run(tc.desc, f)
-			}
-		}
-		t.Run(mwtc.desc, f)
-	}
-}
-
-func TestReconfigure(t *testing.T) {
-	cases := []MiddlewareTestCase{
-		{
-			desc:       "passthrough",
+			desc:       "credentials heuristic",
 			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
-			cfg:        nil,
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				Methods:      []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialsHeuristic: true,
+				},
+			},
 			cases: []ReqTestCase{
 				{
-					desc:      "non-CORS GET",
-					reqMethod: "GET",
-				}, {
-					desc:      "non-CORS OPTIONS",
-					reqMethod: "OPTIONS",
-				}, {
-					desc:      "actual GET from allowed",
+					desc:      "non-CORS GET with Cookie",
 					reqMethod: "GET",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
+						"Cookie": "foo=bar",
 					},
-				}, {
-					desc:      "actual GET from disallowed",
-					reqMethod: "GET",
-					reqHeaders: Headers{
-						headerOrigin: "https://example.com",
+					respHeaders: Headers{
+						headerVary: varyCredentialsHeuristic,
 					},
 				}, {
-					desc:      "actual GET from invalid",
+					desc:      "actual GET from allowed without credentials",
 					reqMethod: "GET",
 					reqHeaders: Headers{
-						headerOrigin: "https://example.com/index.html",
+						headerOrigin: "https://example.com",
 					},
-				}, {
-					desc:      "actual OPTIONS from allowed",
-					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: varyCredentialsHeuristic,
 					},
 				}, {
-					desc:      "actual OPTIONS from disallowed",
-					reqMethod: "OPTIONS",
+					desc:      "actual GET from allowed with Cookie",
+					reqMethod: "GET",
 					reqHeaders: Headers{
 						headerOrigin: "https://example.com",
+						"Cookie":     "session=xyz",
 					},
-				}, {
-					desc:      "preflight with GET from allowed",
-					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "GET",
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerVary: varyCredentialsHeuristic,
 					},
 				}, {
-					desc:      "preflight with PURGE from allowed",
-					reqMethod: "OPTIONS",
+					desc:      "actual GET from allowed with Authorization",
+					reqMethod: "GET",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "PURGE",
+						headerOrigin:    "https://example.com",
+						"Authorization": "Basic dXNlcjpwYXNz",
 					},
-				}, {
-					desc:      "preflight with PURGE and Content-Type from allowed",
-					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "PURGE",
-						headerACRH:   "content-type",
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerVary: varyCredentialsHeuristic,
 					},
 				}, {
-					desc:      "preflight with GET from disallowed",
-					reqMethod: "OPTIONS",
+					desc:      "actual GET from disallowed with Cookie",
+					reqMethod: "GET",
 					reqHeaders: Headers{
-						headerOrigin: "https://example.com",
-						headerACRM:   "GET",
+						headerOrigin: "https://example.org",
+						"Cookie":     "session=xyz",
 					},
-				}, {
-					desc:      "preflight with GET from invalid",
-					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "https://example.com/index.html",
-						headerACRM:   "GET",
+					respHeaders: Headers{
+						headerVary: varyCredentialsHeuristic,
 					},
 				}, {
 					desc:      "preflight with PUT from allowed",
 					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "PUT",
-					},
-				}, {
-					desc:      "preflight with PUT from disallowed",
-					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
 						headerOrigin: "https://example.com",
 						headerACRM:   "PUT",
 					},
-				}, {
-					desc:      "preflight with GET and headers from allowed",
-					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "GET",
-						headerACRH:   "bar,baz,foo",
-					},
-				}, {
-					desc:      "preflight with GET and headers from disallowed",
-					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "https://example.org",
-						headerACRM:   "GET",
-						headerACRH:   "bar,baz,foo",
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
 					},
-				}, {
-					desc:      "preflight with GET and ACRPN from allowed",
+				},
+			},
+		}, {
+			desc:       "always emit max age with default max age",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					AlwaysEmitMaxAge: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with GET from allowed",
 					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRPN:  "true",
+						headerOrigin: "https://example.com",
 						headerACRM:   "GET",
 					},
-				}, {
-					desc:      "preflight with PUT and ACRPN headers from allowed",
-					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRPN:  "true",
-						headerACRM:   "PUT",
-						headerACRH:   "bar,baz,foo",
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACMA: "5",
+						headerVary: varyPreflightValue,
 					},
 				}, {
-					desc:      "preflight with GET and ACRPN from disallowed",
-					reqMethod: "OPTIONS",
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
 					reqHeaders: Headers{
 						headerOrigin: "https://example.com",
-						headerACRPN:  "true",
-						headerACRM:   "GET",
 					},
-				}, {
-					desc:      "preflight with PUT and ACRPN and headers from disallowed",
-					reqMethod: "OPTIONS",
-					reqHeaders: Headers{
-						headerOrigin: "https://example.com",
-						headerACRPN:  "true",
-						headerACRM:   "PUT",
-						headerACRH:   "bar,baz,foo",
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
 					},
 				},
 			},
 		}, {
-			desc:       "debug credentialed no req headers",
+			desc:       "always emit max age with disabled max age",
 			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
 			cfg: &cors.Config{
-				Origins:         []string{"http://localhost:9090"},
-				Credentialed:    true,
-				MaxAgeInSeconds: 30,
-				ResponseHeaders: []string{"X-Foo", "X-Bar"},
+				Origins:         []string{"https://example.com"},
+				MaxAgeInSeconds: -1,
 				ExtraConfig: cors.ExtraConfig{
-					PreflightSuccessStatus: 279,
+					AlwaysEmitMaxAge: true,
 				},
 			},
-			debug: true, // to check whether the debug mode will be retained after reconfiguration
 			cases: []ReqTestCase{
 				{
-					desc:      "preflight with GET and headers from allowed",
+					desc:      "preflight with GET from allowed",
 					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
+						headerOrigin: "https://example.com",
 						headerACRM:   "GET",
-						headerACRH:   "bar,baz,foo",
 					},
 					preflight:                true,
 					preflightPassesCORSCheck: true,
-					preflightFails:           true,
 					respHeaders: Headers{
-						headerACAO: "http://localhost:9090",
-						headerACAC: "true",
+						headerACAO: "https://example.com",
+						headerACMA: "0",
 						headerVary: varyPreflightValue,
 					},
 				}, {
-					desc:      "preflight with disallowed method",
-					reqMethod: "OPTIONS",
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "PUT",
+						headerOrigin: "https://example.com",
 					},
-					preflight:                true,
-					preflightPassesCORSCheck: true,
-					preflightFails:           true,
 					respHeaders: Headers{
-						headerACAO: "http://localhost:9090",
-						headerACAC: "true",
-						headerVary: varyPreflightValue,
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
 					},
 				},
 			},
 		}, {
-			desc:       "credentialed all req headers",
+			desc:       "always emit max age with explicit max age",
 			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
 			cfg: &cors.Config{
-				Origins:         []string{"http://localhost:9090"},
-				Credentialed:    true,
-				RequestHeaders:  []string{"*"},
+				Origins:         []string{"https://example.com"},
 				MaxAgeInSeconds: 30,
-				ResponseHeaders: []string{"X-Foo", "X-Bar"},
 				ExtraConfig: cors.ExtraConfig{
-					PreflightSuccessStatus: 279,
+					AlwaysEmitMaxAge: true,
 				},
 			},
-			debug: false, // to check whether the previous debug mode was retained after reconfiguration
 			cases: []ReqTestCase{
 				{
-					desc:      "preflight with GET and headers from allowed",
+					desc:      "preflight with GET from allowed",
 					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
+						headerOrigin: "https://example.com",
 						headerACRM:   "GET",
-						headerACRH:   "bar,baz,foo",
 					},
 					preflight:                true,
 					preflightPassesCORSCheck: true,
 					respHeaders: Headers{
-						headerACAO: "http://localhost:9090",
-						headerACAC: "true",
-						headerACAH: "bar,baz,foo",
+						headerACAO: "https://example.com",
 						headerACMA: "30",
 						headerVary: varyPreflightValue,
 					},
 				}, {
-					desc:      "preflight with PURGE and headers from allowed",
-					reqMethod: "OPTIONS",
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "PURGE",
-						headerACRH:   "bar,baz,foo,qux",
+						headerOrigin: "https://example.com",
 					},
-					preflight:                true,
-					preflightPassesCORSCheck: true,
-					preflightFails:           false, // would be true if debug were false
 					respHeaders: Headers{
-						headerACAO: "http://localhost:9090", // would be absent if debug were false
-						headerACAC: "true",                  // would be absent if debug were false
-						headerVary: varyPreflightValue,
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
 					},
 				},
 			},
 		}, {
-			desc:       "invalid config",
-			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
-			cfg:        new(cors.Config), // invalid: no origin patterns specified
-			invalid:    true,
-		}, {
-			desc:       "credentialed all req headers",
+			desc:       "response-header hook",
 			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
 			cfg: &cors.Config{
-				Origins:         []string{"http://localhost:9090"},
-				Credentialed:    true,
-				RequestHeaders:  []string{"*"},
-				MaxAgeInSeconds: 30,
-				ResponseHeaders: []string{"X-Foo", "X-Bar"},
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
 				ExtraConfig: cors.ExtraConfig{
-					PreflightSuccessStatus: 279,
+					ResponseHeaderHook: func(hdrs http.Header) {
+						if v := hdrs.Get(headerACAM); v != "" {
+							hdrs.Set(headerACAM, strings.ToLower(v))
+						}
+						hdrs.Set("X-Hooked", "true")
+					},
 				},
 			},
-			debug: false, // to check whether the previous debug mode was retained
 			cases: []ReqTestCase{
 				{
-					desc:      "preflight with GET and headers from allowed",
+					desc:      "non-CORS GET",
+					reqMethod: "GET",
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+						"X-Hooked": "true",
+					},
+				}, {
+					desc:      "preflight with PUT from allowed",
 					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "GET",
-						headerACRH:   "bar,baz,foo",
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
 					},
 					preflight:                true,
 					preflightPassesCORSCheck: true,
 					respHeaders: Headers{
-						headerACAO: "http://localhost:9090",
-						headerACAC: "true",
-						headerACAH: "bar,baz,foo",
-						headerACMA: "30",
+						headerACAO: "https://example.com",
+						headerACAM: "put",
 						headerVary: varyPreflightValue,
+						"X-Hooked": "true",
 					},
 				}, {
-					desc:      "preflight with PURGE and headers from allowed",
+					desc:      "preflight with PUT from disallowed",
 					reqMethod: "OPTIONS",
 					reqHeaders: Headers{
-						headerOrigin: "http://localhost:9090",
-						headerACRM:   "PURGE",
-						headerACRH:   "bar,baz,foo,qux",
+						headerOrigin: "https://example.org",
+						headerACRM:   "PUT",
 					},
-					preflight:                true,
-					preflightPassesCORSCheck: true,
-					preflightFails:           false, // would be true if debug were false
+					preflight:      true,
+					preflightFails: true,
 					respHeaders: Headers{
-						headerACAO: "http://localhost:9090", // would be absent if debug were false
-						headerACAC: "true",                  // would be absent if debug were false
 						headerVary: varyPreflightValue,
+						"X-Hooked": "true",
 					},
 				},
 			},
-		},
-	}
-	var mw cors.Middleware
-	for _, mwtc := range cases {
-		err := mw.Reconfigure(mwtc.cfg)
-		if err != nil && !mwtc.invalid {
-			t.Fatalf("failure to reconfigure CORS middleware: %v", err)
-		}
-		if err == nil && mwtc.invalid {
-			t.Fatal("unexpected absence of failure to reconfigure CORS middleware")
-		}
-		if mwtc.debug {
-			mw.SetDebug(true)
-		}
-		for _, tc := range mwtc.cases {
-			f := func(t *testing.T) {
-				// --- arrange ---
-				innerHandler := mwtc.newHandler()
-				handler := mw.Wrap(innerHandler)
-				if outerMiddleware := mwtc.outerMw; outerMiddleware != nil {
-					handler = outerMiddleware.Wrap(handler)
-				}
-				req := newRequest(tc.reqMethod, tc.reqHeaders)
-				rec := httptest.NewRecorder()
-
-				// --- act ---
-				handler.ServeHTTP(rec, req)
-				res := rec.Result()
-
-				// --- assert ---
-				spy, ok := innerHandler.(*spyHandler)
-				if !ok {
-					t.Fatalf("handler is not a *spyHandler")
-				}
-				if tc.preflight { // preflight request
-					if spy.called.Load() {
-						t.Error("wrapped handler was called, but it should not have been")
-					}
-					assertPreflightStatus(t, spy.statusCode, res.StatusCode, &mwtc, &tc)
-					assertResponseHeaders(t, res.Header, tc.respHeaders)
-					if mwtc.outerMw != nil {
-						assertResponseHeaders(t, res.Header, mwtc.outerMw.hdrs)
-					}
-					assertNoMoreResponseHeaders(t, res.Header)
-					assertBody(t, res.Body, "")
-					return
-				} // non-preflight request
-				if !spy.called.Load() {
-					t.Error("wrapped handler wasn't called, but it should have been")
-				}
-				if res.StatusCode != spy.statusCode {
-					const tmpl = "got status code %d; want %d"
-					t.Errorf(tmpl, res.StatusCode, spy.statusCode)
-				}
-				assertResponseHeaders(t, res.Header, spy.respHeaders)
-				assertResponseHeaders(t, res.Header, tc.respHeaders)
-				if mwtc.outerMw != nil {
-					assertResponseHeaders(t, res.Header, mwtc.outerMw.hdrs)
-				}
-				assertNoMoreResponseHeaders(t, res.Header)
-				assertBody(t, res.Body, spy.body)
-			}
-			t.Run(tc.desc, f)
-		}
-	}
-}
-
-func TestConfig(t *testing.T) {
-	cases := []struct {
-		desc string
-		cfg  *cors.Config
-		want *cors.Config
-	}{
-		{
-			desc: "passthrough",
-			cfg:  nil,
 		}, {
-			desc: "anonymous allow all",
+			desc:       "IPv4 shorthand without normalization",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
 			cfg: &cors.Config{
-				Origins:         []string{"*"},
-				Methods:         []string{"*"},
-				RequestHeaders:  []string{"authoriZation", "*"},
-				ResponseHeaders: []string{"*"},
+				Origins: []string{"http://127.0.0.1:8080"},
 			},
-			want: &cors.Config{
-				Origins:         []string{"*"},
-				Methods:         []string{"*"},
-				RequestHeaders:  []string{"*", "Authorization"},
-				ResponseHeaders: []string{"*"},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from shorthand of allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "http://127.1:8080",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with GET from shorthand of allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://127.1:8080",
+						headerACRM:   "GET",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
 			},
 		}, {
-			desc: "discrete methods discrete headers zero max age PNAnoCORS",
+			desc:       "IPv4 shorthand with normalization",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
 			cfg: &cors.Config{
-				Origins: []string{
-					"https://example.com",
-					"https://example.com",
-				},
-				RequestHeaders:  []string{"x-foO", "x-Bar", "authoRizaTion"},
-				MaxAgeInSeconds: -1,
-				ResponseHeaders: []string{"x-FOO", "X-baR", "x-foo"},
+				Origins: []string{"http://127.0.0.1:8080"},
 				ExtraConfig: cors.ExtraConfig{
-					PrivateNetworkAccessInNoCORSModeOnly: true,
+					NormalizeIPv4Shorthand: true,
 				},
 			},
-			want: &cors.Config{
-				Origins:         []string{"https://example.com"},
-				RequestHeaders:  []string{"Authorization", "X-Bar", "X-Foo"},
-				MaxAgeInSeconds: -1,
-				ResponseHeaders: []string{"X-Bar", "X-Foo"},
-				ExtraConfig: cors.ExtraConfig{
-					PrivateNetworkAccessInNoCORSModeOnly: true,
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "http://127.0.0.1:8080",
+					},
+					respHeaders: Headers{
+						headerACAO: "http://127.0.0.1:8080",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from shorthand of allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "http://127.1:8080",
+					},
+					respHeaders: Headers{
+						headerACAO: "http://127.1:8080",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from shorthand of allowed on different port",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "http://127.1:9090",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with GET from shorthand of allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://127.1:8080",
+						headerACRM:   "GET",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "http://127.1:8080",
+						headerVary: varyPreflightValue,
+					},
 				},
 			},
 		}, {
-			desc: "credentialed all req headers",
+			desc:       "empty origin treated as present",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
 			cfg: &cors.Config{
-				Origins: []string{
-					"http://example.com",
-					"https://*.example.com:8080",
-					"https://*.foo.example.com:8080",
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET with empty origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with PUT with empty origin",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "",
+						headerACRM:   "PUT",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
 				},
-				Credentialed:    true,
-				Methods:         []string{"POST", "PUT", "DELETE", "GET"},
-				RequestHeaders:  []string{"*"},
-				MaxAgeInSeconds: 30,
-				ResponseHeaders: []string{"x-FOO", "X-baR", "x-foo"},
+			},
+		}, {
+			desc:       "empty origin treated as absent",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
 				ExtraConfig: cors.ExtraConfig{
-					PreflightSuccessStatus:             279,
-					PrivateNetworkAccess:               true,
-					DangerouslyTolerateInsecureOrigins: true,
+					TreatEmptyOriginAsAbsent: true,
 				},
 			},
-			want: &cors.Config{
-				Origins: []string{
-					"http://example.com",
-					"https://*.example.com:8080",
-				},
-				Credentialed:    true,
-				Methods:         []string{"DELETE", "PUT"},
-				RequestHeaders:  []string{"*"},
-				MaxAgeInSeconds: 30,
-				ResponseHeaders: []string{"X-Bar", "X-Foo"},
-				ExtraConfig: cors.ExtraConfig{
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET with empty origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "OPTIONS with empty origin and ACRM",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "",
+						headerACRM:   "PUT",
+					},
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "report only",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				Methods:        []string{http.MethodPut},
+				RequestHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					ReportOnly:     true,
+					ReportOnlyHook: func(string, cors.Reason) {},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.org",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with PUT from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.org",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with DELETE from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "DELETE",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "DELETE",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT and disallowed headers from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+						headerACRH:   "x-bar",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerACAH: "x-bar",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PNA from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRPN:  "true",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO:  "https://example.com",
+						headerACAPN: "true",
+						headerACAM:  "GET",
+						headerVary:  varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "custom preflight failure status",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightFailureStatus: http.StatusBadRequest,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with PUT from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   "PUT",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with DELETE from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "DELETE",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "deprecated origins",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					DeprecatedOrigins: map[string]time.Time{
+						"https://partner.example.com": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from deprecated",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://partner.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO:    "https://partner.example.com",
+						headerVary:    headerOrigin,
+						"Deprecation": "true",
+						"Sunset":      "Sat, 01 Mar 2025 00:00:00 GMT",
+					},
+				}, {
+					desc:      "actual GET from non-deprecated allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://app.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://app.example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with GET from deprecated",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://partner.example.com",
+						headerACRM:   "GET",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://partner.example.com",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "subdomains only",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from apex",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from subdomain",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://foo.bar.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://foo.bar.example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from lookalike",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://notexample.com",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "apex and subdomains",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://**.example.com"},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from apex",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from subdomain",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://foo.bar.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://foo.bar.example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from lookalike",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://notexample.com",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "max origin length",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxOriginLength: len("https://foo.example.com"),
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed within max length",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://foo.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://foo.example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from allowed beyond max length",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://quux.example.com",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with GET from allowed within max length",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://foo.example.com",
+						headerACRM:   "GET",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://foo.example.com",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with GET from allowed beyond max length",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://quux.example.com",
+						headerACRM:   "GET",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "max origin length with percent-decoding",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					DecodePercentEncodedOrigin: true,
+					MaxOriginLength:            len("https://foo.example.com"),
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed percent-encoded within max length",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://%6F.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://%6F.example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from allowed percent-encoded beyond max length",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://f%6Fo.example.com",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with GET from allowed percent-encoded beyond max length",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://f%6Fo.example.com",
+						headerACRM:   "GET",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "wildcard covers authorization",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{wildcard},
+				ExtraConfig: cors.ExtraConfig{
+					WildcardCoversAuthorization: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with Authorization",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "authorization",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: wildcardAndAuth,
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with other headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "content-type,x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: wildcardAndAuth,
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "credentialed schemes",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com", "http://localhost:3000"},
+				Credentialed: true,
+				Methods:      []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedSchemes: []string{"https"},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed secure origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from allowed insecure origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:3000",
+					},
+					respHeaders: Headers{
+						headerACAO: "http://localhost:3000",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with PUT from allowed secure origin",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT from allowed insecure origin",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:3000",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "http://localhost:3000",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "websocket upgrade passthrough",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					HandleWebSocketUpgrade: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "websocket upgrade from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						"Connection": "Upgrade",
+						"Upgrade":    "websocket",
+					},
+				}, {
+					desc:      "websocket upgrade from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						"Connection": "Upgrade",
+						"Upgrade":    "websocket",
+					},
+				}, {
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "websocket upgrade without passthrough",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "websocket upgrade from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						"Connection": "Upgrade",
+						"Upgrade":    "websocket",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "credentialed wildcard methods",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com", "https://other.example.com"},
+				Credentialed: true,
+				Methods:      []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					OriginMethods: map[string][]string{
+						"https://example.com": {"*"},
+					},
+					CredentialedWildcardMethods: []string{"PUT", "DELETE", "GET", "PUT"},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with DELETE from origin allowed all methods",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "DELETE",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerACAM: "DELETE,GET,PUT",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PATCH from origin allowed all methods",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PATCH",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerACAM: "DELETE,GET,PUT",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT from other origin",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://other.example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://other.example.com",
+						headerACAC: "true",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "origin resolver",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					OriginResolver: func(r *http.Request) (string, bool) {
+						switch r.Header.Get("X-Api-Key") {
+						case "allowed":
+							return "https://example.com", true
+						case "disallowed":
+							return "https://example.org", true
+						default:
+							return "", false
+						}
+					},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET with resolvable key to allowed origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						"X-Api-Key": "allowed",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET with resolvable key to disallowed origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						"X-Api-Key": "disallowed",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET with unresolvable key",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						"X-Api-Key": "unknown",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from allowed origin with key to disallowed origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						"X-Api-Key":  "disallowed",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "decode percent-encoded origin",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com", "http://127.0.0.1"},
+				ExtraConfig: cors.ExtraConfig{
+					DecodePercentEncodedOrigin: true,
+					NormalizeIPv4Shorthand:     true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from percent-encoded allowed origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://ex%61mple.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://ex%61mple.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from percent-encoded IPv4 shorthand",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "http://%31%32%37.1",
+					},
+					respHeaders: Headers{
+						headerACAO: "http://%31%32%37.1",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from maliciously encoded origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com%2F.evil.com",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with GET from percent-encoded allowed origin",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://ex%61mple.com",
+						headerACRM:   "GET",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://ex%61mple.com",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "additional safelisted methods",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					AdditionalSafelistedMethods: []string{"QUERY"},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with QUERY from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "QUERY",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with query from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "query",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "transform allowed origin",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:      []string{"https://*.internal.example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					TransformAllowedOrigin: func(origin string) string {
+						switch origin {
+						case "https://bad.internal.example.com":
+							return "https://example.com/"
+						case "https://empty.internal.example.com":
+							return ""
+						default:
+							return strings.Replace(origin, ".internal.example.com", ".example.com", 1)
+						}
+					},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://foo.internal.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://foo.example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from allowed origin with invalid transform",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://bad.internal.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://bad.internal.example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from allowed origin with empty transform",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://empty.internal.example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://empty.internal.example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from disallowed origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://foo.example.com",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight from allowed origin",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://foo.internal.example.com",
+						headerACRM:   "GET",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://foo.example.com",
+						headerACAC: "true",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "public any-origin predicate",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				Credentialed:    true,
+				ResponseHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					PublicAnyOriginPredicate: func(r *http.Request) bool {
+						return r.Header.Get("X-Public") == "true"
+					},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET to public resource from other origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						"X-Public":   "true",
+						headerOrigin: "https://example.org",
+					},
+					respHeaders: Headers{
+						headerACAO: "*",
+						headerACEH: "x-foo",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual OPTIONS to public resource from other origin",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						"X-Public":   "true",
+						headerOrigin: "https://example.org",
+					},
+					respHeaders: Headers{
+						headerACAO: "*",
+						headerACEH: "x-foo",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "credentialed actual GET to public resource from other origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						"X-Public":   "true",
+						headerOrigin: "https://example.org",
+						"Cookie":     "id=123",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "credentialed actual GET to public resource from allowed origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						"X-Public":      "true",
+						headerOrigin:    "https://example.com",
+						"Authorization": "Bearer foo",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerACEH: "x-foo",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET to other resource from other origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET to other resource from allowed origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerACEH: "x-foo",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight to public resource from other origin",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						"X-Public":   "true",
+						headerOrigin: "https://example.org",
+						headerACRM:   "GET",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "credentialed-path predicate",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				Methods:      []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedPathPredicate: func(r *http.Request) bool {
+						return r.Header.Get("X-Session") == "true"
+					},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET to credentialed resource",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						"X-Session":  "true",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET to anonymous resource",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with PUT to credentialed resource",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+						"X-Session":  "true",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAC: "true",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PUT to anonymous resource",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "debug tight max ACAH bytes",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"Authorization"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxDebugACAHBytes: 16,
+				},
+			},
+			debug: true,
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with short ACRH",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerACAO:                 "https://example.com",
+						headerACAH:                 "authorization",
+						headerXDebugRejectedHeader: "x-foo",
+						headerVary:                 varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with oversized ACRH",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-some-very-long-header-name",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "lenient ACRH token whitespace",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo", "Authorization"},
+				ExtraConfig: cors.ExtraConfig{
+					LenientACRHTokenWhitespace: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with tab inside names",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "autho\trization,x-f\too",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: "authorization,x-foo",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with spaces around names",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "authorization, x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: "authorization,x-foo",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with tab inside disallowed name",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-b\tar",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "strict ACRH token whitespace",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo", "Authorization"},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with tab inside names",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "autho\trization,x-f\too",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "policy link",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PolicyLink: "https://example.com/.well-known/cors-policy",
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+						"Link":     `<https://example.com/.well-known/cors-policy>; rel="cors-policy"`,
+					},
+				}, {
+					desc:      "actual GET from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "non-CORS GET",
+					reqMethod: "GET",
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with GET from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   "GET",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "keep safelisted exposed headers",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				ResponseHeaders: []string{"X-Foo", "Content-Type", "Cache-Control"},
+				ExtraConfig: cors.ExtraConfig{
+					KeepSafelistedExposedHeaders: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACEH: "cache-control,content-type,x-foo",
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "debug all methods",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"http://localhost:9090"},
+				Methods: []string{"*"},
+			},
+			debug: true,
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with PUT",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090",
+						headerACAM: wildcard,
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with CONNECT",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "CONNECT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerACAO:                  "http://localhost:9090",
+						headerXDebugForbiddenMethod: "CONNECT",
+						headerVary:                  varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with lowercase trace",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "trace",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerACAO:                  "http://localhost:9090",
+						headerXDebugForbiddenMethod: "trace",
+						headerVary:                  varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "all methods",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"http://localhost:9090"},
+				Methods: []string{"*"},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with CONNECT",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "CONNECT",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with TRACE",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "TRACE",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "reflected ACAH",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo", "X-Bar", "X-Baz"},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with sorted headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-bar,x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: "x-bar,x-foo",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with unsorted headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-foo,x-bar",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "sorted ACAH",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo", "X-Bar", "X-Baz"},
+				ExtraConfig: cors.ExtraConfig{
+					SortAllowHeaders: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with sorted headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-bar,x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: "x-bar,x-foo",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with unsorted and duplicate headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-foo,x-baz,x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: "x-baz,x-foo",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with some disallowed header",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-foo,x-qux",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "bounded Authorization scan",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"*", "Authorization"},
+				ExtraConfig: cors.ExtraConfig{
+					BoundedAuthorizationScan: 32,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight without Authorization",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: wildcard,
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with Authorization",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "authorization,x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: wildcardAndAuth,
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with ACRH beyond scan limit",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-bar,x-baz,x-foo,x-qux,x-quux,x-corge",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: wildcardAndAuth,
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "origin header name",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					OriginHeaderNames: []string{"x-forwarded-origin"},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "non-CORS GET",
+					reqMethod: "GET",
+					respHeaders: Headers{
+						headerVary: "X-Forwarded-Origin",
+					},
+				}, {
+					desc:      "actual GET with forwarded origin from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin:         "https://proxy.example.org",
+						"X-Forwarded-Origin": "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: "X-Forwarded-Origin",
+					},
+				}, {
+					desc:      "actual GET with forwarded origin from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin:         "https://example.com",
+						"X-Forwarded-Origin": "https://example.org",
+					},
+					respHeaders: Headers{
+						headerVary: "X-Forwarded-Origin",
+					},
+				}, {
+					desc:      "actual GET with Origin from allowed but without forwarded origin",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerVary: "X-Forwarded-Origin",
+					},
+				}, {
+					desc:      "preflight with PUT and forwarded origin from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						"X-Forwarded-Origin": "https://example.com",
+						headerACRM:           "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: varyForwardedOriginPreflight,
+					},
+				}, {
+					desc:      "preflight with PUT and forwarded origin from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:         "https://example.com",
+						"X-Forwarded-Origin": "https://example.org",
+						headerACRM:           "PUT",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary: varyForwardedOriginPreflight,
+					},
+				},
+			},
+		}, {
+			desc:       "coalesced origin header names",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					OriginHeaderNames: []string{
+						"X-Forwarded-Origin",
+						"x-original-origin",
+						"x-forwarded-origin",
+					},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET with forwarded origin from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						"X-Forwarded-Origin": "https://example.com",
+						"X-Original-Origin":  "https://example.org",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: "X-Forwarded-Origin, X-Original-Origin",
+					},
+				}, {
+					desc:      "actual GET with forwarded origin from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						"X-Forwarded-Origin": "https://example.org",
+						"X-Original-Origin":  "https://example.com",
+					},
+					respHeaders: Headers{
+						headerVary: "X-Forwarded-Origin, X-Original-Origin",
+					},
+				}, {
+					desc:      "actual GET with original origin from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin:        "https://proxy.example.org",
+						"X-Original-Origin": "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: "X-Forwarded-Origin, X-Original-Origin",
+					},
+				}, {
+					desc:      "preflight with PUT and original origin from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:        "https://proxy.example.org",
+						"X-Original-Origin": "https://example.com",
+						headerACRM:          "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerVary: headerACRH + ", " + headerACRM + ", " + headerACRPN +
+							", X-Forwarded-Origin, X-Original-Origin",
+					},
+				},
+			},
+		}, {
+			desc:       "debug credentialed omit credentials header",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:         []string{"http://localhost:9090"},
+				Credentialed:    true,
+				RequestHeaders:  []string{"Authorization"},
+				MaxAgeInSeconds: 30,
+				ResponseHeaders: []string{"X-Foo", "X-Bar"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightSuccessStatus:     279,
+					DebugOmitCredentialsHeader: true,
+				},
+			},
+			debug: true,
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with GET and some disallowed headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+						headerACRH:   "authorization,x-bar,x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerACAO:                 "http://localhost:9090",
+						headerACAH:                 "authorization",
+						headerACMA:                 "30",
+						headerXDebugRejectedHeader: "x-bar",
+						headerVary:                 varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with disallowed method",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9091",
+						headerACRM:   "GET",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with GET and allowed headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+						headerACRH:   "authorization",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090",
+						headerACAC: "true",
+						headerACAH: "authorization",
+						headerACMA: "30",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+					},
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090",
+						headerACAC: "true",
+						headerACEH: "x-bar,x-foo",
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "legacy request headers",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{"X-Legacy", "Authorization"},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with GET and allowed and legacy headers",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "authorization,x-foo,x-legacy",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAH: "authorization,x-foo,x-legacy",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with GET and disallowed header",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+						headerACRH:   "x-bar,x-legacy",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "debug allow all suppress CORS headers on non-CORS OPTIONS",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:         []string{wildcard},
+				Methods:         []string{wildcard},
+				RequestHeaders:  []string{"Authorization", wildcard},
+				MaxAgeInSeconds: 30,
+				ResponseHeaders: []string{wildcard},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightSuccessStatus:              279,
+					SuppressCORSHeadersOnNonCORSOptions: true,
+				},
+			},
+			debug: true,
+			cases: []ReqTestCase{
+				{
+					desc:      "non-CORS GET request",
+					reqMethod: "GET",
+					respHeaders: Headers{
+						headerACAO: wildcard,
+						headerACEH: wildcard,
+					},
+				}, {
+					desc:      "non-CORS OPTIONS request",
+					reqMethod: "OPTIONS",
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "actual OPTIONS request",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+					},
+					respHeaders: Headers{
+						headerACAO: wildcard,
+						headerACEH: wildcard,
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with GET",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: wildcard,
+						headerACMA: "30",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "rejection marker header",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				Methods:        []string{http.MethodPut},
+				RequestHeaders: []string{"x-foo"},
+				ExtraConfig: cors.ExtraConfig{
+					RejectionMarkerHeader: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with PUT and x-foo from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+						headerACRH:   "x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: "PUT",
+						headerACAH: "x-foo",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   "PUT",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary:        varyPreflightValue,
+						"X-Cors-Rejected": "origin",
+					},
+				}, {
+					desc:      "preflight with disallowed method",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "DELETE",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary:        varyPreflightValue,
+						"X-Cors-Rejected": "method",
+					},
+				}, {
+					desc:      "preflight with disallowed request header",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+						headerACRH:   "x-bar",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary:        varyPreflightValue,
+						"X-Cors-Rejected": "headers",
+					},
+				}, {
+					desc:      "actual GET from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "rejection marker header with Sec-Fetch metadata validation",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ValidateSecFetchMetadata: true,
+					RejectionMarkerHeader:    true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with same-origin site",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.com",
+						headerACRM:         http.MethodGet,
+						headerSecFetchSite: "same-origin",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary:        varyPreflightValue,
+						"X-Cors-Rejected": "fetch-metadata",
+					},
+				}, {
+					desc:      "preflight with no-cors mode from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.org",
+						headerACRM:         http.MethodGet,
+						headerSecFetchSite: "cross-site",
+						headerSecFetchMode: "no-cors",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary:        varyPreflightValue,
+						"X-Cors-Rejected": "fetch-metadata",
+					},
+				}, {
+					desc:      "preflight with consistent metadata from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.org",
+						headerACRM:         http.MethodGet,
+						headerSecFetchSite: "cross-site",
+						headerSecFetchMode: "cors",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary:        varyPreflightValue,
+						"X-Cors-Rejected": "origin",
+					},
+				},
+			},
+		}, {
+			desc:       "debug rejection marker header with custom name",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				Methods:        []string{http.MethodPut},
+				RequestHeaders: []string{"x-foo"},
+				ExtraConfig: cors.ExtraConfig{
+					RejectionMarkerHeader:     true,
+					RejectionMarkerHeaderName: "x-preflight-rejected",
+				},
+			},
+			debug: true,
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   "PUT",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary:             varyPreflightValue,
+						"X-Preflight-Rejected": "origin",
+					},
+				}, {
+					desc:      "preflight with disallowed method",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "DELETE",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           false, // would be true if debug were false
+					respHeaders: Headers{
+						headerACAO:             "https://example.com",
+						headerVary:             varyPreflightValue,
+						"X-Preflight-Rejected": "method",
+					},
+				}, {
+					desc:      "preflight with disallowed request header",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+						headerACRH:   "x-bar",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           false, // would be true if debug were false
+					respHeaders: Headers{
+						headerACAO:                 "https://example.com",
+						headerACAM:                 "PUT",
+						headerACAH:                 "x-foo",
+						headerXDebugRejectedHeader: "x-bar",
+						headerVary:                 varyPreflightValue,
+						"X-Preflight-Rejected":     "headers",
+					},
+				},
+			},
+		}, {
+			desc:       "debug PNA",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"http://localhost:9090"},
+				ExtraConfig: cors.ExtraConfig{
+					PrivateNetworkAccess: true,
+				},
+			},
+			debug: true,
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with ACRPN",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRPN:  "true",
+						headerACRM:   "GET",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO:  "http://localhost:9090",
+						headerACAPN: "true",
+						headerVary:  varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with ACRPN from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRPN:  "true",
+						headerACRM:   "GET",
+					},
+					preflight: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "HEAD treated as non-safe and allowed",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodGet, http.MethodHead, http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					TreatHeadAsNonSafe: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with HEAD from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodHead,
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerACAM: http.MethodHead,
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with GET from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodGet,
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "actual HEAD from allowed",
+					reqMethod: http.MethodHead,
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "HEAD treated as non-safe but not allowed",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					TreatHeadAsNonSafe: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with HEAD from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodHead,
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with POST from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   http.MethodPost,
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "omit Vary for disallowed origins",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OmitVaryForDisallowedOrigins: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+					},
+				}, {
+					desc:      "actual GET from invalid",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "null",
+					},
+				}, {
+					desc:      "non-CORS GET",
+					reqMethod: "GET",
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   http.MethodGet,
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "omit Vary for disallowed origins in report-only mode",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OmitVaryForDisallowedOrigins: true,
+					ReportOnly:                   true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.org",
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "validate Sec-Fetch metadata",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com", "https://example.org"},
+				ExtraConfig: cors.ExtraConfig{
+					ValidateSecFetchMetadata: true,
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with consistent metadata",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.org",
+						headerACRM:         http.MethodGet,
+						headerSecFetchSite: "cross-site",
+						headerSecFetchMode: "cors",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.org",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight without metadata",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   http.MethodGet,
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.org",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with same-origin site",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.org",
+						headerACRM:         http.MethodGet,
+						headerSecFetchSite: "same-origin",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with no-cors mode",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.org",
+						headerACRM:         http.MethodGet,
+						headerSecFetchSite: "cross-site",
+						headerSecFetchMode: "no-cors",
+					},
+					preflight:      true,
+					preflightFails: true,
+					respHeaders: Headers{
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "actual GET with consistent same-origin site",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.com",
+						headerSecFetchSite: "same-origin",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET with inconsistent same-origin site",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.org",
+						headerSecFetchSite: "same-origin",
+					},
+					respHeaders: Headers{
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET with cross-site site",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin:       "https://example.org",
+						headerSecFetchSite: "cross-site",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.org",
+						headerVary: headerOrigin,
+					},
+				},
+			},
+		}, {
+			desc:       "concrete origin for anonymous allow all",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:        []string{"*"},
+				RequestHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					ConcreteOriginForAnonymousAllowAll: func(r *http.Request) bool {
+						return strings.Contains(r.UserAgent(), "ExampleBot")
+					},
+				},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "non-CORS GET",
+					reqMethod: "GET",
+					respHeaders: Headers{
+						headerACAO: wildcard,
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+					respHeaders: Headers{
+						headerACAO: wildcard,
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from bot",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						"User-Agent": "ExampleBot/1.0",
+					},
+					respHeaders: Headers{
+						headerACAO: "https://example.com",
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from null",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "null",
+					},
+					respHeaders: Headers{
+						headerACAO: wildcard,
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "actual GET from bot from null",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "null",
+						"User-Agent": "ExampleBot/1.0",
+					},
+					respHeaders: Headers{
+						headerACAO: wildcard,
+						headerVary: headerOrigin,
+					},
+				}, {
+					desc:      "preflight with GET",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   http.MethodGet,
+						headerACRH:   "x-foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: wildcard,
+						headerACAH: "x-foo",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with GET from bot",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   http.MethodGet,
+						headerACRH:   "x-foo",
+						"User-Agent": "ExampleBot/1.0",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "https://example.org",
+						headerACAH: "x-foo",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		},
+	}
+	for _, mwtc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if mwtc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*mwtc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			if mwtc.debug {
+				mw.SetDebug(true)
+			}
+			for _, tc := range mwtc.cases {
+				f := func(t *testing.T) {
+					// --- arrange ---
+					innerHandler := mwtc.newHandler()
+					handler := mw.Wrap(innerHandler)
+					if outerMiddleware := mwtc.outerMw; outerMiddleware != nil {
+						handler = outerMiddleware.Wrap(handler)
+					}
+					req := newRequest(tc.reqMethod, tc.reqHeaders)
+					rec := httptest.NewRecorder()
+
+					// --- act ---
+					handler.ServeHTTP(rec, req)
+					res := rec.Result()
+
+					// --- assert ---
+					spy, ok := innerHandler.(*spyHandler)
+					if !ok {
+						t.Fatalf("handler is not a *spyHandler")
+					}
+					if tc.preflight { // preflight request
+						if spy.called.Load() {
+							t.Error("wrapped handler was called, but it should not have been")
+						}
+						assertPreflightStatus(t, spy.statusCode, res.StatusCode, &mwtc, &tc)
+						assertResponseHeaders(t, res.Header, tc.respHeaders)
+						if mwtc.outerMw != nil {
+							assertResponseHeaders(t, res.Header, mwtc.outerMw.hdrs)
+						}
+						assertNoMoreResponseHeaders(t, res.Header)
+						assertBody(t, res.Body, "")
+						return
+					} // non-preflight request
+					if !spy.called.Load() {
+						t.Error("wrapped handler wasn't called, but it should have been")
+					}
+					if res.StatusCode != spy.statusCode {
+						const tmpl = "got status code %d; want %d"
+						t.Errorf(tmpl, res.StatusCode, spy.statusCode)
+					}
+					assertResponseHeaders(t, res.Header, spy.respHeaders)
+					assertResponseHeaders(t, res.Header, tc.respHeaders)
+					if mwtc.outerMw != nil {
+						assertResponseHeaders(t, res.Header, mwtc.outerMw.hdrs)
+					}
+					assertNoMoreResponseHeaders(t, res.Header)
+					assertBody(t, res.Body, spy.body)
+				}
+				t.Run(tc.desc, f)
+			}
+		}
+		t.Run(mwtc.desc, f)
+	}
+}
+
+func TestWrappedHandlerCannotMutatePackageLevelSlices(t *testing.T) {
+	cases := []MiddlewareTestCase{
+		{
+			desc:       "anonymous",
+			newHandler: newMutatingHandler,
+			cfg: &cors.Config{
+				Origins:         []string{"*"},
+				ResponseHeaders: []string{"*"},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "non-CORS GET",
+					reqMethod: "GET",
+				}, {
+					desc:      "actual GET",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+				}, {
+					desc:      "actual OPTIONS",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+				},
+			},
+		}, {
+			desc:       "credentialed",
+			newHandler: newMutatingHandler,
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				Credentialed:    true,
+				ResponseHeaders: []string{"X-Foo", "X-Bar"},
+			},
+			cases: []ReqTestCase{
+				{
+					desc:      "actual GET",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+				}, {
+					desc:      "actual OPTIONS",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+				},
+			},
+		},
+	}
+	checks := []struct {
+		desc string
+		old  string
+		sgl  []string
+	}{
+		{
+			desc: "headers.PreflightVarySgl[0]",
+			old:  headers.PreflightVarySgl[0],
+			sgl:  headers.PreflightVarySgl,
+		}, {
+			desc: "headers.TrueSgl[0]",
+			old:  headers.TrueSgl[0],
+			sgl:  headers.TrueSgl,
+		}, {
+			desc: "headers.OriginSgl[0]",
+			old:  headers.OriginSgl[0],
+			sgl:  headers.OriginSgl,
+		}, {
+			desc: "headers.WildcardSgl[0]",
+			old:  headers.WildcardSgl[0],
+			sgl:  headers.WildcardSgl,
+		}, {
+			desc: "headers.WildcardAuthSgl[0]",
+			old:  headers.WildcardAuthSgl[0],
+			sgl:  headers.WildcardAuthSgl,
+		}, {
+			desc: "headers.DefaultMaxAgeSgl[0]",
+			old:  headers.DefaultMaxAgeSgl[0],
+			sgl:  headers.DefaultMaxAgeSgl,
+		},
+	}
+	for _, mwtc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if mwtc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*mwtc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			for _, tc := range mwtc.cases {
+				f := func(t *testing.T) {
+					// --- arrange ---
+					handler := mwtc.newHandler()
+					handler = mw.Wrap(handler)
+					req := newRequest(tc.reqMethod, tc.reqHeaders)
+					rec := httptest.NewRecorder()
+
+					// --- act ---
+					handler.ServeHTTP(rec, req)
+
+					// --- assert ---
+					for _, check := range checks {
+						want := check.old
+						got := check.sgl[0]
+						if got != want {
+							t.Errorf("%s: got %q; want %q", check.desc, got, want)
+						}
+					}
+				}
+				t.Run(tc.desc, f)
+			}
+		}
+		t.Run(mwtc.desc, f)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	cases := []MiddlewareTestCase{
+		{
+			desc:       "passthrough",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg:        nil,
+			cases: []ReqTestCase{
+				{
+					desc:      "non-CORS GET",
+					reqMethod: "GET",
+				}, {
+					desc:      "non-CORS OPTIONS",
+					reqMethod: "OPTIONS",
+				}, {
+					desc:      "actual GET from allowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+					},
+				}, {
+					desc:      "actual GET from disallowed",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+				}, {
+					desc:      "actual GET from invalid",
+					reqMethod: "GET",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com/index.html",
+					},
+				}, {
+					desc:      "actual OPTIONS from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+					},
+				}, {
+					desc:      "actual OPTIONS from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+					},
+				}, {
+					desc:      "preflight with GET from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+					},
+				}, {
+					desc:      "preflight with PURGE from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "PURGE",
+					},
+				}, {
+					desc:      "preflight with PURGE and Content-Type from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "PURGE",
+						headerACRH:   "content-type",
+					},
+				}, {
+					desc:      "preflight with GET from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "GET",
+					},
+				}, {
+					desc:      "preflight with GET from invalid",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com/index.html",
+						headerACRM:   "GET",
+					},
+				}, {
+					desc:      "preflight with PUT from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "PUT",
+					},
+				}, {
+					desc:      "preflight with PUT from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRM:   "PUT",
+					},
+				}, {
+					desc:      "preflight with GET and headers from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+						headerACRH:   "bar,baz,foo",
+					},
+				}, {
+					desc:      "preflight with GET and headers from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.org",
+						headerACRM:   "GET",
+						headerACRH:   "bar,baz,foo",
+					},
+				}, {
+					desc:      "preflight with GET and ACRPN from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRPN:  "true",
+						headerACRM:   "GET",
+					},
+				}, {
+					desc:      "preflight with PUT and ACRPN headers from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRPN:  "true",
+						headerACRM:   "PUT",
+						headerACRH:   "bar,baz,foo",
+					},
+				}, {
+					desc:      "preflight with GET and ACRPN from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRPN:  "true",
+						headerACRM:   "GET",
+					},
+				}, {
+					desc:      "preflight with PUT and ACRPN and headers from disallowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "https://example.com",
+						headerACRPN:  "true",
+						headerACRM:   "PUT",
+						headerACRH:   "bar,baz,foo",
+					},
+				},
+			},
+		}, {
+			desc:       "debug credentialed no req headers",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:         []string{"http://localhost:9090"},
+				Credentialed:    true,
+				MaxAgeInSeconds: 30,
+				ResponseHeaders: []string{"X-Foo", "X-Bar"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightSuccessStatus: 279,
+				},
+			},
+			debug: true, // to check whether the debug mode will be retained after reconfiguration
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with GET and headers from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+						headerACRH:   "bar,baz,foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerACAO:                 "http://localhost:9090",
+						headerACAC:                 "true",
+						headerXDebugRejectedHeader: "bar",
+						headerVary:                 varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with disallowed method",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "PUT",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           true,
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090",
+						headerACAC: "true",
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "credentialed all req headers",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:         []string{"http://localhost:9090"},
+				Credentialed:    true,
+				RequestHeaders:  []string{"*"},
+				MaxAgeInSeconds: 30,
+				ResponseHeaders: []string{"X-Foo", "X-Bar"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightSuccessStatus: 279,
+				},
+			},
+			debug: false, // to check whether the previous debug mode was retained after reconfiguration
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with GET and headers from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+						headerACRH:   "bar,baz,foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090",
+						headerACAC: "true",
+						headerACAH: "bar,baz,foo",
+						headerACMA: "30",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PURGE and headers from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "PURGE",
+						headerACRH:   "bar,baz,foo,qux",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           false, // would be true if debug were false
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090", // would be absent if debug were false
+						headerACAC: "true",                  // would be absent if debug were false
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		}, {
+			desc:       "invalid config",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg:        new(cors.Config), // invalid: no origin patterns specified
+			invalid:    true,
+		}, {
+			desc:       "credentialed all req headers",
+			newHandler: newSpyHandler(200, Headers{headerVary: "foo"}, "bar"),
+			cfg: &cors.Config{
+				Origins:         []string{"http://localhost:9090"},
+				Credentialed:    true,
+				RequestHeaders:  []string{"*"},
+				MaxAgeInSeconds: 30,
+				ResponseHeaders: []string{"X-Foo", "X-Bar"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightSuccessStatus: 279,
+				},
+			},
+			debug: false, // to check whether the previous debug mode was retained
+			cases: []ReqTestCase{
+				{
+					desc:      "preflight with GET and headers from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "GET",
+						headerACRH:   "bar,baz,foo",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090",
+						headerACAC: "true",
+						headerACAH: "bar,baz,foo",
+						headerACMA: "30",
+						headerVary: varyPreflightValue,
+					},
+				}, {
+					desc:      "preflight with PURGE and headers from allowed",
+					reqMethod: "OPTIONS",
+					reqHeaders: Headers{
+						headerOrigin: "http://localhost:9090",
+						headerACRM:   "PURGE",
+						headerACRH:   "bar,baz,foo,qux",
+					},
+					preflight:                true,
+					preflightPassesCORSCheck: true,
+					preflightFails:           false, // would be true if debug were false
+					respHeaders: Headers{
+						headerACAO: "http://localhost:9090", // would be absent if debug were false
+						headerACAC: "true",                  // would be absent if debug were false
+						headerVary: varyPreflightValue,
+					},
+				},
+			},
+		},
+	}
+	var mw cors.Middleware
+	for _, mwtc := range cases {
+		err := mw.Reconfigure(mwtc.cfg)
+		if err != nil && !mwtc.invalid {
+			t.Fatalf("failure to reconfigure CORS middleware: %v", err)
+		}
+		if err == nil && mwtc.invalid {
+			t.Fatal("unexpected absence of failure to reconfigure CORS middleware")
+		}
+		if mwtc.debug {
+			mw.SetDebug(true)
+		}
+		for _, tc := range mwtc.cases {
+			f := func(t *testing.T) {
+				// --- arrange ---
+				innerHandler := mwtc.newHandler()
+				handler := mw.Wrap(innerHandler)
+				if outerMiddleware := mwtc.outerMw; outerMiddleware != nil {
+					handler = outerMiddleware.Wrap(handler)
+				}
+				req := newRequest(tc.reqMethod, tc.reqHeaders)
+				rec := httptest.NewRecorder()
+
+				// --- act ---
+				handler.ServeHTTP(rec, req)
+				res := rec.Result()
+
+				// --- assert ---
+				spy, ok := innerHandler.(*spyHandler)
+				if !ok {
+					t.Fatalf("handler is not a *spyHandler")
+				}
+				if tc.preflight { // preflight request
+					if spy.called.Load() {
+						t.Error("wrapped handler was called, but it should not have been")
+					}
+					assertPreflightStatus(t, spy.statusCode, res.StatusCode, &mwtc, &tc)
+					assertResponseHeaders(t, res.Header, tc.respHeaders)
+					if mwtc.outerMw != nil {
+						assertResponseHeaders(t, res.Header, mwtc.outerMw.hdrs)
+					}
+					assertNoMoreResponseHeaders(t, res.Header)
+					assertBody(t, res.Body, "")
+					return
+				} // non-preflight request
+				if !spy.called.Load() {
+					t.Error("wrapped handler wasn't called, but it should have been")
+				}
+				if res.StatusCode != spy.statusCode {
+					const tmpl = "got status code %d; want %d"
+					t.Errorf(tmpl, res.StatusCode, spy.statusCode)
+				}
+				assertResponseHeaders(t, res.Header, spy.respHeaders)
+				assertResponseHeaders(t, res.Header, tc.respHeaders)
+				if mwtc.outerMw != nil {
+					assertResponseHeaders(t, res.Header, mwtc.outerMw.hdrs)
+				}
+				assertNoMoreResponseHeaders(t, res.Header)
+				assertBody(t, res.Body, spy.body)
+			}
+			t.Run(tc.desc, f)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	cases := []struct {
+		desc string
+		cfg  *cors.Config
+		want *cors.Config
+	}{
+		{
+			desc: "passthrough",
+			cfg:  nil,
+		}, {
+			desc: "anonymous allow all",
+			cfg: &cors.Config{
+				Origins:         []string{"*"},
+				Methods:         []string{"*"},
+				RequestHeaders:  []string{"authoriZation", "*"},
+				ResponseHeaders: []string{"*"},
+			},
+			want: &cors.Config{
+				Origins:         []string{"*"},
+				Methods:         []string{"*"},
+				RequestHeaders:  []string{"*", "Authorization"},
+				ResponseHeaders: []string{"*"},
+			},
+		}, {
+			desc: "discrete methods discrete headers zero max age PNAnoCORS",
+			cfg: &cors.Config{
+				Origins: []string{
+					"https://example.com",
+					"https://example.com",
+				},
+				RequestHeaders:  []string{"x-foO", "x-Bar", "authoRizaTion"},
+				MaxAgeInSeconds: -1,
+				ResponseHeaders: []string{"x-FOO", "X-baR", "x-foo"},
+				ExtraConfig: cors.ExtraConfig{
+					PrivateNetworkAccessInNoCORSModeOnly: true,
+				},
+			},
+			want: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				RequestHeaders:  []string{"Authorization", "X-Bar", "X-Foo"},
+				MaxAgeInSeconds: -1,
+				ResponseHeaders: []string{"X-Bar", "X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					PrivateNetworkAccessInNoCORSModeOnly: true,
+				},
+			},
+		}, {
+			desc: "credentialed all req headers",
+			cfg: &cors.Config{
+				Origins: []string{
+					"http://example.com",
+					"https://*.example.com:8080",
+					"https://*.foo.example.com:8080",
+				},
+				Credentialed:    true,
+				Methods:         []string{"POST", "PUT", "DELETE", "GET"},
+				RequestHeaders:  []string{"*"},
+				MaxAgeInSeconds: 30,
+				ResponseHeaders: []string{"x-FOO", "X-baR", "x-foo"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightSuccessStatus:             279,
+					PrivateNetworkAccess:               true,
+					DangerouslyTolerateInsecureOrigins: true,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{
+					"http://example.com",
+					"https://*.example.com:8080",
+				},
+				Credentialed:    true,
+				Methods:         []string{"DELETE", "PUT"},
+				RequestHeaders:  []string{"*"},
+				MaxAgeInSeconds: 30,
+				ResponseHeaders: []string{"X-Bar", "X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
 					PreflightSuccessStatus:             279,
 					PrivateNetworkAccess:               true,
 					DangerouslyTolerateInsecureOrigins: true,
 				},
 			},
+		}, {
+			desc: "request-method fallback header",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RequestMethodHeaderFallback: "x-requested-method",
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RequestMethodHeaderFallback: "X-Requested-Method",
+				},
+			},
+		}, {
+			desc: "origin methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginMethods: map[string][]string{
+						"https://app.example.com":  {http.MethodPut, http.MethodDelete, http.MethodGet},
+						"https://anon.example.com": {http.MethodGet},
+						"https://all.example.com":  {"*"},
+					},
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginMethods: map[string][]string{
+						"https://app.example.com":  {http.MethodDelete, http.MethodPut},
+						"https://anon.example.com": nil,
+						"https://all.example.com":  {"*"},
+					},
+				},
+			},
+		}, {
+			desc: "credentials heuristic",
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					CredentialsHeuristic: true,
+				},
+			},
+			want: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					CredentialsHeuristic: true,
+				},
+			},
+		}, {
+			desc: "always emit max age",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					AlwaysEmitMaxAge: true,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					AlwaysEmitMaxAge: true,
+				},
+			},
+		}, {
+			desc: "response-header hook",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ResponseHeaderHook: func(http.Header) {},
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ResponseHeaderHook: func(http.Header) {},
+				},
+			},
+		}, {
+			desc: "IPv4 shorthand normalization",
+			cfg: &cors.Config{
+				Origins: []string{"http://127.0.0.1:8080"},
+				ExtraConfig: cors.ExtraConfig{
+					NormalizeIPv4Shorthand: true,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"http://127.0.0.1:8080"},
+				ExtraConfig: cors.ExtraConfig{
+					NormalizeIPv4Shorthand: true,
+				},
+			},
+		}, {
+			desc: "empty origin treated as absent",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					TreatEmptyOriginAsAbsent: true,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					TreatEmptyOriginAsAbsent: true,
+				},
+			},
+		}, {
+			desc: "report only",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ReportOnly:     true,
+					ReportOnlyHook: func(string, cors.Reason) {},
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ReportOnly:     true,
+					ReportOnlyHook: func(string, cors.Reason) {},
+				},
+			},
+		}, {
+			desc: "custom preflight failure status",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightFailureStatus: http.StatusBadRequest,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightFailureStatus: http.StatusBadRequest,
+				},
+			},
+		}, {
+			desc: "default preflight failure status",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PreflightFailureStatus: http.StatusForbidden,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+		}, {
+			desc: "deprecated origins",
+			cfg: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					DeprecatedOrigins: map[string]time.Time{
+						"https://partner.example.com": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					DeprecatedOrigins: map[string]time.Time{
+						"https://partner.example.com": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		}, {
+			desc: "apex and subdomains",
+			cfg: &cors.Config{
+				Origins: []string{"https://**.example.com"},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://*.example.com", "https://example.com"},
+			},
+		}, {
+			desc: "max origin length",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxOriginLength: 64,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxOriginLength: 64,
+				},
+			},
+		}, {
+			desc: "default max origin length",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					MaxOriginLength: 267,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+		}, {
+			desc: "wildcard covers authorization",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"*", "Authorization"},
+				ExtraConfig: cors.ExtraConfig{
+					WildcardCoversAuthorization: true,
+				},
+			},
+			want: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					WildcardCoversAuthorization: true,
+				},
+			},
+		}, {
+			desc: "credentialed wildcard methods",
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				Methods:      []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedWildcardMethods: []string{"PUT", "DELETE", "PUT"},
+				},
+			},
+			want: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				Methods:      []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedWildcardMethods: []string{"DELETE", "PUT"},
+				},
+			},
+		}, {
+			desc: "origin resolver",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginResolver: func(*http.Request) (string, bool) { return "", false },
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginResolver: func(*http.Request) (string, bool) { return "", false },
+				},
+			},
+		}, {
+			desc: "additional safelisted methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					AdditionalSafelistedMethods: []string{"QUERY", "GET", "PROPFIND", "QUERY"},
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					AdditionalSafelistedMethods: []string{"PROPFIND", "QUERY"},
+				},
+			},
+		}, {
+			desc: "legacy request headers",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"x-foo"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{"x-legacy", "X-Foo", "authorization", "X-LEGACY"},
+				},
+			},
+			want: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{"Authorization", "X-Legacy"},
+				},
+			},
+		}, {
+			desc: "legacy request headers only",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{"x-legacy"},
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{"X-Legacy"},
+				},
+			},
+		}, {
+			desc: "HEAD treated as non-safe",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodGet, http.MethodHead, http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					TreatHeadAsNonSafe: true,
+				},
+			},
+			want: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodHead, http.MethodPut},
+				ExtraConfig: cors.ExtraConfig{
+					TreatHeadAsNonSafe: true,
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			got := mw.Config()
+			assertConfigEqual(t, got, tc.want)
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	cases := []struct {
+		desc string
+		cfg  *cors.Config
+		want []string
+	}{
+		{
+			desc: "passthrough",
+			cfg:  nil,
+		}, {
+			desc: "safelisted methods only",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodGet, http.MethodPost},
+			},
+		}, {
+			desc: "discrete methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodPut, http.MethodGet, http.MethodDelete, http.MethodPut},
+			},
+			want: []string{http.MethodDelete, http.MethodPut},
+		}, {
+			desc: "all methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{"*"},
+			},
+			want: []string{"*"},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			got := mw.AllowedMethods()
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestOriginsInConfigOrder(t *testing.T) {
+	origins := []string{
+		"https://b.example.com",
+		"https://*.example.org",
+		"https://a.example.com",
+		"https://b.example.com", // duplicate
+	}
+	want := []string{
+		"https://b.example.com",
+		"https://*.example.org",
+		"https://a.example.com",
+	}
+	cfg := cors.Config{
+		Origins: origins,
+		ExtraConfig: cors.ExtraConfig{
+			PreserveOriginOrder: true,
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	if got := mw.OriginsInConfigOrder(); !slices.Equal(got, want) {
+		t.Errorf("OriginsInConfigOrder: got %q; want %q", got, want)
+	}
+	if got := mw.Config().Origins; !slices.Equal(got, want) {
+		t.Errorf("Config().Origins: got %q; want %q", got, want)
+	}
+	hash := mw.ConfigHash()
+	if err := mw.Reconfigure(mw.Config()); err != nil {
+		t.Fatalf("failure to reconfigure CORS middleware: %v", err)
+	}
+	if got := mw.OriginsInConfigOrder(); !slices.Equal(got, want) {
+		t.Errorf("after round trip: got %q; want %q", got, want)
+	}
+	if got := mw.ConfigHash(); got != hash {
+		t.Errorf("after round trip: got hash %x; want %x", got, hash)
+	}
+	// mutating the result must not alter the middleware
+	mw.OriginsInConfigOrder()[0] = "https://example.net"
+	if got := mw.OriginsInConfigOrder(); !slices.Equal(got, want) {
+		t.Errorf("after mutation of result: got %q; want %q", got, want)
+	}
+
+	cfg.PreserveOriginOrder = false
+	if err := mw.Reconfigure(&cfg); err != nil {
+		t.Fatalf("failure to reconfigure CORS middleware: %v", err)
+	}
+	if got := mw.OriginsInConfigOrder(); got != nil {
+		t.Errorf("without PreserveOriginOrder: got %q; want nil", got)
+	}
+	if got := new(cors.Middleware).OriginsInConfigOrder(); got != nil {
+		t.Errorf("passthrough: got %q; want nil", got)
+	}
+}
+
+func TestWouldRegress(t *testing.T) {
+	sample := []string{
+		"https://example.com",
+		"https://foo.example.com",
+		"https://bar.example.com",
+		"http://example.com",
+		"null",
+	}
+	cases := []struct {
+		desc   string
+		cfg    *cors.Config
+		newCfg cors.Config
+		want   []string
+		errMsg string
+	}{
+		{
+			desc:   "passthrough",
+			cfg:    nil,
+			newCfg: cors.Config{Origins: []string{"https://example.com"}},
+		}, {
+			desc:   "passthrough with invalid new config",
+			cfg:    nil,
+			newCfg: cors.Config{},
+			errMsg: "cors: at least one origin pattern must be specified",
+		}, {
+			desc: "invalid new config",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+			newCfg: cors.Config{Origins: []string{"https://example.com/"}},
+			errMsg: `cors: invalid origin pattern "https://example.com/"`,
+		}, {
+			desc: "identical",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com", "https://*.example.com"},
+			},
+			newCfg: cors.Config{
+				Origins: []string{"https://*.example.com", "https://example.com"},
+			},
+		}, {
+			desc: "loosened",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+			newCfg: cors.Config{Origins: []string{"*"}},
+		}, {
+			desc: "tightened",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com", "https://*.example.com"},
+			},
+			newCfg: cors.Config{
+				Origins: []string{"https://example.com", "https://foo.example.com"},
+			},
+			want: []string{"https://bar.example.com"},
+		}, {
+			desc: "tightened from all origins",
+			cfg: &cors.Config{
+				Origins: []string{"*"},
+			},
+			newCfg: cors.Config{Origins: []string{"https://example.com"}},
+			want: []string{
+				"https://foo.example.com",
+				"https://bar.example.com",
+				"http://example.com",
+				"null",
+			},
+		}, {
+			desc: "report only",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+			newCfg: cors.Config{
+				Origins: []string{"https://foo.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ReportOnly: true,
+				},
+			},
+			want: []string{"https://example.com"},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			before := mw.Config()
+			got, err := mw.WouldRegress(tc.newCfg, sample)
+			if tc.errMsg != "" {
+				if err == nil || err.Error() != tc.errMsg {
+					t.Fatalf("got error %v; want %q", err, tc.errMsg)
+				}
+			} else if err != nil {
+				t.Fatalf("got error %v; want nil error", err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+			assertConfigEqual(t, mw.Config(), before)
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestDumpPreflightResponse(t *testing.T) {
+	cfg := cors.Config{
+		Origins:         []string{"https://example.com"},
+		Credentialed:    true,
+		Methods:         []string{http.MethodPut},
+		RequestHeaders:  []string{"X-Foo"},
+		MaxAgeInSeconds: 30,
+		ExtraConfig: cors.ExtraConfig{
+			PreflightSuccessStatus: 279,
+		},
+	}
+	cases := []struct {
+		desc       string
+		cfg        *cors.Config
+		debug      bool
+		origin     string
+		method     string
+		acrh       []string
+		wantStatus int
+		want       Headers
+	}{
+		{
+			desc:       "passthrough",
+			origin:     "https://example.com",
+			method:     http.MethodPut,
+			wantStatus: http.StatusMethodNotAllowed,
+			want: Headers{
+				"Allow":    http.MethodOptions,
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:       "allowed",
+			cfg:        &cfg,
+			origin:     "https://example.com",
+			method:     http.MethodPut,
+			acrh:       []string{"x-foo"},
+			wantStatus: 279,
+			want: Headers{
+				headerACAO: "https://example.com",
+				headerACAC: "true",
+				headerACAM: http.MethodPut,
+				headerACAH: "x-foo",
+				headerACMA: "30",
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:       "disallowed header",
+			cfg:        &cfg,
+			origin:     "https://example.com",
+			method:     http.MethodPut,
+			acrh:       []string{"x-bar", "x-foo"},
+			wantStatus: http.StatusForbidden,
+			want: Headers{
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:       "disallowed header in debug mode",
+			cfg:        &cfg,
+			debug:      true,
+			origin:     "https://example.com",
+			method:     http.MethodPut,
+			acrh:       []string{"x-bar", "x-foo"},
+			wantStatus: 279,
+			want: Headers{
+				headerACAO:                 "https://example.com",
+				headerACAC:                 "true",
+				headerACAM:                 http.MethodPut,
+				headerACAH:                 "x-foo",
+				headerACMA:                 "30",
+				headerXDebugRejectedHeader: "x-bar",
+				headerVary:                 varyPreflightValue,
+			},
+		}, {
+			desc:       "disallowed origin",
+			cfg:        &cfg,
+			origin:     "https://example.org",
+			method:     http.MethodPut,
+			wantStatus: http.StatusForbidden,
+			want: Headers{
+				headerVary: varyPreflightValue,
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			mw.SetDebug(tc.debug)
+			status, hdrs := mw.DumpPreflightResponse(tc.origin, tc.method, tc.acrh)
+			if status != tc.wantStatus {
+				t.Errorf("got status %d; want %d", status, tc.wantStatus)
+			}
+			assertResponseHeaders(t, hdrs, tc.want)
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestVaryOriginMatrix(t *testing.T) {
+	cfgs := []struct {
+		desc string
+		cfg  cors.Config
+		// whether responses to allowed actual requests reflect the origin
+		reflected bool
+	}{
+		{
+			desc: "wildcard",
+			cfg:  cors.Config{Origins: []string{"*"}},
+		}, {
+			desc:      "reflected",
+			cfg:       cors.Config{Origins: []string{"https://example.com"}},
+			reflected: true,
+		},
+	}
+	reqs := []struct {
+		desc   string
+		method string
+		hdrs   Headers
+		// whether the response lists Origin in its Vary header
+		// regardless of whether the origin is reflected
+		alwaysVary bool
+	}{
+		{
+			desc:   "actual GET",
+			method: http.MethodGet,
+			hdrs:   Headers{headerOrigin: "https://example.com"},
+		}, {
+			desc:       "actual OPTIONS",
+			method:     http.MethodOptions,
+			hdrs:       Headers{headerOrigin: "https://example.com"},
+			alwaysVary: true,
+		}, {
+			desc:   "non-CORS GET",
+			method: http.MethodGet,
+		}, {
+			desc:       "non-CORS OPTIONS",
+			method:     http.MethodOptions,
+			alwaysVary: true,
+		},
+	}
+	for _, c := range cfgs {
+		mw, err := cors.NewMiddleware(c.cfg)
+		if err != nil {
+			t.Fatalf("failure to build CORS middleware: %v", err)
+		}
+		handler := mw.Wrap(newSpyHandler(200, nil, "")())
+		for _, req := range reqs {
+			f := func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, newRequest(req.method, req.hdrs))
+				res := rec.Result()
+				var varyOrigin bool
+				for _, v := range res.Header.Values(headerVary) {
+					for _, elem := range strings.Split(v, ",") {
+						varyOrigin = varyOrigin || strings.TrimSpace(elem) == headerOrigin
+					}
+				}
+				if want := c.reflected || req.alwaysVary; varyOrigin != want {
+					t.Errorf("got Vary: Origin %t; want %t", varyOrigin, want)
+				}
+				acao := res.Header.Get(headerACAO)
+				switch {
+				case req.hdrs == nil && c.reflected:
+					if acao != "" {
+						t.Errorf("got ACAO %q; want none", acao)
+					}
+				case c.reflected:
+					if want := req.hdrs[headerOrigin]; acao != want {
+						t.Errorf("got ACAO %q; want %q", acao, want)
+					}
+				default:
+					if acao != wildcard {
+						t.Errorf("got ACAO %q; want %q", acao, wildcard)
+					}
+				}
+			}
+			t.Run(c.desc+" "+req.desc, f)
+		}
+	}
+}
+
+func TestUseCanonicalHeaderWrites(t *testing.T) {
+	// The hook tampers with the values of all response headers in place.
+	tamper := func(resHdrs http.Header) {
+		for _, values := range resHdrs {
+			for i := range values {
+				values[i] = "tampered"
+			}
+		}
+	}
+	cfg := cors.Config{
+		Origins:         []string{"https://example.com"},
+		Methods:         []string{http.MethodPut},
+		MaxAgeInSeconds: 30,
+		ExtraConfig: cors.ExtraConfig{
+			ResponseHeaderHook:       tamper,
+			UseCanonicalHeaderWrites: true,
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	handler := mw.Wrap(newSpyHandler(200, nil, "")())
+	for range 2 {
+		req := newRequest(http.MethodOptions, Headers{
+			headerOrigin: "https://example.com",
+			headerACRM:   http.MethodPut,
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got := req.Header.Get(headerOrigin); got != "https://example.com" {
+			t.Errorf("preflight: got request Origin %q; want %q", got, "https://example.com")
+		}
+		req = newRequest(http.MethodGet, Headers{headerOrigin: "https://example.com"})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got := req.Header.Get(headerOrigin); got != "https://example.com" {
+			t.Errorf("actual: got request Origin %q; want %q", got, "https://example.com")
+		}
+	}
+
+	// The values that the middleware emits must have survived the tampering.
+	cfg.ResponseHeaderHook = nil
+	if err := mw.Reconfigure(&cfg); err != nil {
+		t.Fatalf("failure to reconfigure CORS middleware: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	req := newRequest(http.MethodOptions, Headers{
+		headerOrigin: "https://example.com",
+		headerACRM:   http.MethodPut,
+	})
+	handler.ServeHTTP(rec, req)
+	want := Headers{
+		headerACAO: "https://example.com",
+		headerACAM: http.MethodPut,
+		headerACMA: "30",
+		headerVary: varyPreflightValue,
+	}
+	assertResponseHeaders(t, rec.Result().Header, want)
+}
+
+func TestPreflightStatusByMethod(t *testing.T) {
+	cfg := cors.Config{
+		Origins: []string{"https://example.com"},
+		Methods: []string{http.MethodPut, http.MethodDelete},
+		ExtraConfig: cors.ExtraConfig{
+			PreflightSuccessStatus: 299,
+			PreflightStatusByMethod: map[string]int{
+				http.MethodGet: http.StatusNoContent,
+				http.MethodPut: http.StatusOK,
+			},
+		},
+	}
+	cases := []struct {
+		desc       string
+		reportOnly bool
+		acrm       string
+		want       int
+	}{
+		{desc: "safelisted method in map", acrm: http.MethodGet, want: http.StatusNoContent},
+		{desc: "safelisted method not in map", acrm: http.MethodPost, want: 299},
+		{desc: "allowed method in map", acrm: http.MethodPut, want: http.StatusOK},
+		{desc: "allowed method not in map", acrm: http.MethodDelete, want: 299},
+		{desc: "disallowed method", acrm: http.MethodPatch, want: http.StatusForbidden},
+		{
+			desc:       "disallowed method in report-only mode",
+			reportOnly: true,
+			acrm:       http.MethodPatch,
+			want:       299,
+		}, {
+			desc:       "disallowed method in map in report-only mode",
+			reportOnly: true,
+			acrm:       "put", // method names are case-sensitive
+			want:       299,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			cfg := cfg
+			cfg.ReportOnly = tc.reportOnly
+			mw, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			req := newRequest(http.MethodOptions, Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   tc.acrm,
+			})
+			rec := httptest.NewRecorder()
+			mw.Wrap(newSpyHandler(200, nil, "")()).ServeHTTP(rec, req)
+			if got := rec.Result().StatusCode; got != tc.want {
+				t.Errorf("got status %d; want %d", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestMaxProcessedHeaderBytes(t *testing.T) {
+	cfg := cors.Config{
+		Origins:        []string{"https://example.com"},
+		Methods:        []string{http.MethodPut},
+		RequestHeaders: []string{"*"},
+		ExtraConfig: cors.ExtraConfig{
+			MaxProcessedHeaderBytes: 64,
+		},
+	}
+	cases := []struct {
+		desc       string
+		reportOnly bool
+		acrh       string
+		want       int
+	}{
+		{desc: "within budget", acrh: "x-foo", want: http.StatusNoContent},
+		{
+			desc: "over budget",
+			acrh: strings.Repeat("a,", 32),
+			want: http.StatusRequestHeaderFieldsTooLarge,
+		}, {
+			desc:       "over budget in report-only mode",
+			reportOnly: true,
+			acrh:       strings.Repeat("a,", 32),
+			want:       http.StatusRequestHeaderFieldsTooLarge,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			cfg := cfg
+			cfg.ReportOnly = tc.reportOnly
+			mw, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			req := newRequest(http.MethodOptions, Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodPut,
+				headerACRH:   tc.acrh,
+			})
+			rec := httptest.NewRecorder()
+			mw.Wrap(newSpyHandler(200, nil, "")()).ServeHTTP(rec, req)
+			res := rec.Result()
+			if got := res.StatusCode; got != tc.want {
+				t.Errorf("got status %d; want %d", got, tc.want)
+			}
+			acao := res.Header.Get(headerACAO)
+			if wantACAO := tc.want == http.StatusNoContent; (acao != "") != wantACAO {
+				t.Errorf("got ACAO %q; want ACAO %t", acao, wantACAO)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestVaryStrategy(t *testing.T) {
+	reqs := []struct {
+		desc   string
+		method string
+		hdrs   Headers
+	}{
+		{
+			desc:   "actual GET",
+			method: http.MethodGet,
+			hdrs:   Headers{headerOrigin: "https://example.com"},
+		}, {
+			desc:   "actual OPTIONS",
+			method: http.MethodOptions,
+			hdrs:   Headers{headerOrigin: "https://example.com"},
+		}, {
+			desc:   "non-CORS GET",
+			method: http.MethodGet,
+		}, {
+			desc:   "non-CORS OPTIONS",
+			method: http.MethodOptions,
+		}, {
+			desc:   "preflight",
+			method: http.MethodOptions,
+			hdrs: Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodPut,
+			},
+		},
+	}
+	cases := []struct {
+		vs   cors.VaryStrategy
+		want []string // Vary values, in the same order as reqs
+	}{
+		{
+			vs: cors.VaryDefault,
+			want: []string{
+				headerOrigin,
+				varyPreflightValue,
+				headerOrigin,
+				varyPreflightValue,
+				varyPreflightValue,
+			},
+		}, {
+			vs: cors.VaryFull,
+			want: []string{
+				headerOrigin,
+				varyPreflightValue,
+				headerOrigin,
+				varyPreflightValue,
+				varyPreflightValue,
+			},
+		}, {
+			vs: cors.VaryOriginOnly,
+			want: []string{
+				headerOrigin,
+				headerOrigin,
+				headerOrigin,
+				headerOrigin,
+				varyPreflightValue,
+			},
+		}, {
+			vs:   cors.VaryNone,
+			want: []string{"", "", "", "", varyPreflightValue},
+		},
+	}
+	for _, tc := range cases {
+		cfg := cors.Config{
+			Origins: []string{"https://example.com"},
+			Methods: []string{http.MethodPut},
+			ExtraConfig: cors.ExtraConfig{
+				VaryStrategy: tc.vs,
+			},
+		}
+		mw, err := cors.NewMiddleware(cfg)
+		if err != nil {
+			t.Fatalf("failure to build CORS middleware: %v", err)
+		}
+		handler := mw.Wrap(newSpyHandler(200, nil, "")())
+		for i, req := range reqs {
+			f := func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, newRequest(req.method, req.hdrs))
+				got := strings.Join(rec.Result().Header.Values(headerVary), ", ")
+				if want := tc.want[i]; got != want {
+					t.Errorf("got Vary %q; want %q", got, want)
+				}
+			}
+			t.Run(tc.vs.String()+" "+req.desc, f)
+		}
+	}
+}
+
+func TestVaryStrategyString(t *testing.T) {
+	cases := []struct {
+		vs   cors.VaryStrategy
+		want string
+	}{
+		{cors.VaryDefault, "VaryDefault"},
+		{cors.VaryOriginOnly, "VaryOriginOnly"},
+		{cors.VaryNone, "VaryNone"},
+		{cors.VaryFull, "VaryFull"},
+		{cors.VaryStrategy(42), "VaryStrategy(42)"},
+	}
+	for _, tc := range cases {
+		if got := tc.vs.String(); got != tc.want {
+			t.Errorf("%d: got %q; want %q", tc.vs, got, tc.want)
+		}
+	}
+}
+
+func TestPrivateNetworkAccessMode(t *testing.T) {
+	cases := []struct {
+		desc string
+		cfg  *cors.Config
+		want cors.PNAMode
+	}{
+		{
+			desc: "passthrough",
+			cfg:  nil,
+			want: cors.PNAOff,
+		}, {
+			desc: "no PNA",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+			want: cors.PNAOff,
+		}, {
+			desc: "PNA",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PrivateNetworkAccess: true,
+				},
+			},
+			want: cors.PNAOn,
+		}, {
+			desc: "PNA in no-cors mode only",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PrivateNetworkAccessInNoCORSModeOnly: true,
+				},
+			},
+			want: cors.PNANoCORSOnly,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			if got := mw.PrivateNetworkAccessMode(); got != tc.want {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestVaryValues(t *testing.T) {
+	preflightVary := []string{headerACRH, headerACRM, headerACRPN, headerOrigin}
+	cases := []struct {
+		desc      string
+		cfg       *cors.Config
+		preflight []string
+		options   []string
+		actual    []string
+	}{
+		{
+			desc: "passthrough",
+			cfg:  nil,
+		}, {
+			desc: "anonymous allow all",
+			cfg: &cors.Config{
+				Origins: []string{"*"},
+			},
+			preflight: preflightVary,
+			options:   preflightVary,
+		}, {
+			desc: "discrete origins",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+			preflight: preflightVary,
+			options:   preflightVary,
+			actual:    []string{headerOrigin},
+		}, {
+			desc: "PNAnoCORS",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					PrivateNetworkAccessInNoCORSModeOnly: true,
+				},
+			},
+			preflight: preflightVary,
+			options:   preflightVary,
+		}, {
+			desc: "VaryOriginOnly",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					VaryStrategy: cors.VaryOriginOnly,
+				},
+			},
+			preflight: preflightVary,
+			options:   []string{headerOrigin},
+			actual:    []string{headerOrigin},
+		}, {
+			desc: "VaryNone",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					VaryStrategy: cors.VaryNone,
+				},
+			},
+			preflight: preflightVary,
+		}, {
+			desc: "OmitVaryForDisallowedOrigins",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OmitVaryForDisallowedOrigins: true,
+				},
+			},
+			preflight: preflightVary,
+			options:   preflightVary,
+			actual:    []string{headerOrigin},
+		}, {
+			desc: "request-method fallback header",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					RequestMethodHeaderFallback: "x-requested-method",
+				},
+			},
+			preflight: []string{headerACRH, headerACRM, headerACRPN, headerOrigin, "X-Requested-Method"},
+			options:   []string{headerACRH, headerACRM, headerACRPN, headerOrigin, "X-Requested-Method"},
+			actual:    []string{headerOrigin},
+		}, {
+			desc: "credentials heuristic",
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					CredentialsHeuristic: true,
+				},
+			},
+			preflight: preflightVary,
+			options:   preflightVary,
+			actual:    []string{headerOrigin, "Cookie", "Authorization"},
+		}, {
+			desc: "credentials heuristic with VaryOriginOnly",
+			cfg: &cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					CredentialsHeuristic: true,
+					VaryStrategy:         cors.VaryOriginOnly,
+				},
+			},
+			preflight: preflightVary,
+			options:   []string{headerOrigin},
+			actual:    []string{headerOrigin},
+		}, {
+			desc: "coalesced origin header names",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginHeaderNames: []string{"x-forwarded-origin", "Origin"},
+				},
+			},
+			preflight: []string{headerACRH, headerACRM, headerACRPN, "X-Forwarded-Origin", headerOrigin},
+			options:   []string{headerACRH, headerACRM, headerACRPN, "X-Forwarded-Origin", headerOrigin},
+			actual:    []string{"X-Forwarded-Origin", headerOrigin},
+		}, {
+			desc: "coalesced origin header names with VaryOriginOnly",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginHeaderNames: []string{"x-forwarded-origin", "Origin"},
+					VaryStrategy:      cors.VaryOriginOnly,
+				},
+			},
+			preflight: []string{headerACRH, headerACRM, headerACRPN, "X-Forwarded-Origin", headerOrigin},
+			options:   []string{"X-Forwarded-Origin", headerOrigin},
+			actual:    []string{"X-Forwarded-Origin", headerOrigin},
+		}, {
+			desc: "anonymous allow all with concrete-origin predicate",
+			cfg: &cors.Config{
+				Origins: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					ConcreteOriginForAnonymousAllowAll: func(*http.Request) bool { return true },
+				},
+			},
+			preflight: preflightVary,
+			options:   preflightVary,
+			actual:    []string{headerOrigin},
+		}, {
+			desc: "anonymous allow all with public predicate",
+			cfg: &cors.Config{
+				Origins: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					PublicAnyOriginPredicate: func(*http.Request) bool { return true },
+				},
+			},
+			preflight: preflightVary,
+			options:   preflightVary,
+			actual:    []string{headerOrigin},
+		},
+	}
+	// The responses to the following requests must, collectively,
+	// list in their Vary header all the names that VaryValues reports.
+	preflightReqs := []Headers{
+		{headerOrigin: "https://example.com", headerACRM: http.MethodGet},
+		{headerOrigin: "https://example.org", headerACRM: http.MethodGet},
+	}
+	otherReqs := []Headers{
+		nil,
+		{headerOrigin: "https://example.com"},
+		{headerOrigin: "https://example.org"},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			preflight, options, actual := mw.VaryValues()
+			if !slices.Equal(preflight, tc.preflight) {
+				t.Errorf("preflight: got %q; want %q", preflight, tc.preflight)
+			}
+			if !slices.Equal(options, tc.options) {
+				t.Errorf("options: got %q; want %q", options, tc.options)
+			}
+			if !slices.Equal(actual, tc.actual) {
+				t.Errorf("actual: got %q; want %q", actual, tc.actual)
+			}
+			sorted := func(names []string) []string {
+				names = slices.Clone(names)
+				slices.Sort(names)
+				return slices.Compact(names)
+			}
+			h := mw.Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			varied := func(method string, reqs []Headers) []string {
+				var names []string
+				for _, reqHdrs := range reqs {
+					rec := httptest.NewRecorder()
+					h.ServeHTTP(rec, newRequest(method, reqHdrs))
+					for _, v := range rec.Result().Header.Values(headerVary) {
+						for _, name := range strings.Split(v, ",") {
+							names = append(names, strings.TrimSpace(name))
+						}
+					}
+				}
+				return sorted(names)
+			}
+			if got := varied(http.MethodOptions, preflightReqs); !slices.Equal(got, sorted(preflight)) {
+				t.Errorf("preflight responses list %q in Vary; VaryValues reports %q", got, preflight)
+			}
+			if got := varied(http.MethodOptions, otherReqs); !slices.Equal(got, sorted(options)) {
+				t.Errorf("OPTIONS responses list %q in Vary; VaryValues reports %q", got, options)
+			}
+			if got := varied(http.MethodGet, otherReqs); !slices.Equal(got, sorted(actual)) {
+				t.Errorf("GET responses list %q in Vary; VaryValues reports %q", got, actual)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	cases := []struct {
+		desc string
+		cfg  *cors.Config
+		msgs []string
+	}{
+		{
+			desc: "passthrough",
+			cfg:  nil,
+		}, {
+			desc: "no warnings",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+		}, {
+			desc: "anonymous insecure origin alongside its secure counterpart",
+			cfg: &cors.Config{
+				Origins: []string{
+					"http://example.com",
+					"https://example.com",
+				},
+			},
+		}, {
+			desc: "credentialed insecure origin alongside its secure counterpart",
+			cfg: &cors.Config{
+				Origins: []string{
+					"http://example.com",
+					"https://example.com",
+					"http://*.example.com:8080",
+					"https://*.example.com:8080",
+					"http://example.org",
+				},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					DangerouslyTolerateInsecureOrigins: true,
+				},
+			},
+			msgs: []string{
+				`cors: insecure origin pattern "http://example.com" is allowed ` +
+					`alongside its secure counterpart "https://example.com"`,
+				`cors: insecure origin pattern "http://*.example.com:8080" is allowed ` +
+					`alongside its secure counterpart "https://*.example.com:8080"`,
+			},
+		}, {
+			desc: "report only",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ReportOnly: true,
+				},
+			},
+			msgs: []string{
+				`cors: report-only mode is active: the CORS policy is not enforced`,
+			},
+		}, {
+			desc: "origin patterns that look like network addresses",
+			cfg: &cors.Config{
+				Origins: []string{
+					"http://10.0.0.0",
+					"http://10.0.0.1",
+					"http://[2001:db8::]:8080",
+				},
+			},
+			msgs: []string{
+				`cors: origin pattern "http://10.0.0.0" looks like a network address, ` +
+					`but it encompasses only the origin whose host is that very IP address`,
+				`cors: origin pattern "http://[2001:db8::]:8080" looks like a network address, ` +
+					`but it encompasses only the origin whose host is that very IP address`,
+			},
+		}, {
+			desc: "no Vary header with a single discrete origin",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					VaryStrategy: cors.VaryNone,
+				},
+			},
+		}, {
+			desc: "no Vary header with multiple origins",
+			cfg: &cors.Config{
+				Origins: []string{"https://*.example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					VaryStrategy: cors.VaryNone,
+				},
+			},
+			msgs: []string{
+				`cors: Vary strategy VaryNone is unsafe for Web caches ` +
+					`because more than one origin may be allowed`,
+			},
+		}, {
+			desc: "legacy request headers",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{"x-legacy-b", "X-Foo", "X-Legacy-A"},
+				},
+			},
+			msgs: []string{
+				`cors: legacy request-header name "X-Legacy-A" is allowed; consider removing it`,
+				`cors: legacy request-header name "X-Legacy-B" is allowed; consider removing it`,
+			},
+		}, {
+			desc: "only safelisted methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+			},
+			msgs: []string{
+				`cors: Methods lists only CORS-safelisted methods ` +
+					`(GET, HEAD, and POST), which are allowed anyway; ` +
+					`no other method is allowed`,
+			},
+		}, {
+			desc: "only safelisted methods with origin methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodGet},
+				ExtraConfig: cors.ExtraConfig{
+					OriginMethods: map[string][]string{
+						"https://example.com": {http.MethodPut},
+					},
+				},
+			},
+		}, {
+			desc: "some non-safelisted methods",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				Methods: []string{http.MethodGet, http.MethodPut},
+			},
+		}, {
+			desc: "all origins and some exposed headers",
+			cfg: &cors.Config{
+				Origins:         []string{"*"},
+				ResponseHeaders: []string{"X-Foo"},
+			},
+			msgs: []string{
+				`cors: exposing response headers other than the CORS-safelisted ` +
+					`ones to all origins may have caching implications, because ` +
+					`responses to actual requests then don't list Origin in their Vary header`,
+			},
+		}, {
+			desc: "all origins and all exposed headers",
+			cfg: &cors.Config{
+				Origins:         []string{"*"},
+				ResponseHeaders: []string{"*"},
+			},
+			msgs: []string{
+				`cors: exposing response headers other than the CORS-safelisted ` +
+					`ones to all origins may have caching implications, because ` +
+					`responses to actual requests then don't list Origin in their Vary header`,
+			},
+		}, {
+			desc: "all origins and only safelisted exposed headers",
+			cfg: &cors.Config{
+				Origins:         []string{"*"},
+				ResponseHeaders: []string{"Content-Type", "Cache-Control"},
+				ExtraConfig: cors.ExtraConfig{
+					KeepSafelistedExposedHeaders: true,
+				},
+			},
+		}, {
+			desc: "discrete origin and some exposed headers",
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				ResponseHeaders: []string{"X-Foo"},
+			},
+		}, {
+			desc: "safelisted request headers",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"range", "X-Foo", "Content-Type"},
+			},
+			msgs: []string{
+				`cors: request-header name "Content-Type" is CORS-safelisted for some values: ` +
+					`browsers only list it in Access-Control-Request-Headers ` +
+					`when its value is not safelisted`,
+				`cors: request-header name "Range" is CORS-safelisted for some values: ` +
+					`browsers only list it in Access-Control-Request-Headers ` +
+					`when its value is not safelisted`,
+			},
+		}, {
+			desc: "safelisted legacy request header",
+			cfg: &cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					LegacyAllowedRequestHeaders: []string{"Accept"},
+				},
+			},
+			msgs: []string{
+				`cors: request-header name "Accept" is CORS-safelisted for some values: ` +
+					`browsers only list it in Access-Control-Request-Headers ` +
+					`when its value is not safelisted`,
+				`cors: legacy request-header name "Accept" is allowed; consider removing it`,
+			},
+		}, {
+			desc: "allowed content types without Content-Type request header",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					AllowedContentTypesOnPreflight: []string{"application/json"},
+				},
+			},
+			msgs: []string{
+				`cors: allowed content types are specified, ` +
+					`but request-header name Content-Type is not allowed`,
+			},
+		}, {
+			desc: "allowed content types with all request headers",
+			cfg: &cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					AllowedContentTypesOnPreflight: []string{"application/json"},
+				},
+			},
+		}, {
+			desc: "max age above Chromium's cap",
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				MaxAgeInSeconds: 7201,
+			},
+			msgs: []string{
+				`cors: max-age value 7201 exceeds the cap of some browsers, ` +
+					`which silently clamp it (known caps: Firefox 86400, Chromium 7200, Safari 600)`,
+			},
+		}, {
+			desc: "max age at Safari's cap",
+			cfg: &cors.Config{
+				Origins:         []string{"https://example.com"},
+				MaxAgeInSeconds: 600,
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			var msgs []string
+			for _, w := range mw.Warnings() {
+				msgs = append(msgs, w.Error())
+			}
+			slices.Sort(msgs) // the order doesn't matter
+			want := slices.Clone(tc.msgs)
+			slices.Sort(want)
+			res, same := diff(msgs, want)
+			if !same {
+				t.Error("unexpected warning(s):")
+				for _, s := range res {
+					t.Logf("\t%s", s)
+				}
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestPreflightBypass(t *testing.T) {
+	mw, err := cors.NewMiddleware(cors.Config{
+		Origins:        []string{"https://example.com"},
+		Methods:        []string{http.MethodPut},
+		RequestHeaders: []string{"Authorization"},
+	})
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	var authCalls int
+	auth := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authCalls++
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+	api := newSpyHandler(200, nil, "")()
+	// misordered chain: authentication takes place ahead of CORS
+	handler := cors.PreflightBypass(auth(mw.Wrap(api)), mw)
+	cases := []struct {
+		desc      string
+		reqMethod string
+		hdrs      Headers
+		status    int
+		acao      string
+		authCalls int
+	}{
+		{
+			desc:      "preflight",
+			reqMethod: http.MethodOptions,
+			hdrs: Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodPut,
+				headerACRH:   "authorization",
+			},
+			status: http.StatusNoContent,
+			acao:   "https://example.com",
+		}, {
+			desc:      "preflight from disallowed",
+			reqMethod: http.MethodOptions,
+			hdrs: Headers{
+				headerOrigin: "https://example.org",
+				headerACRM:   http.MethodPut,
+			},
+			status: http.StatusForbidden,
+		}, {
+			desc:      "actual OPTIONS",
+			reqMethod: http.MethodOptions,
+			hdrs: Headers{
+				headerOrigin: "https://example.com",
+			},
+			status:    http.StatusUnauthorized,
+			authCalls: 1,
+		}, {
+			desc:      "unauthenticated actual PUT",
+			reqMethod: http.MethodPut,
+			hdrs: Headers{
+				headerOrigin: "https://example.com",
+			},
+			status:    http.StatusUnauthorized,
+			authCalls: 1,
+		}, {
+			desc:      "authenticated actual PUT",
+			reqMethod: http.MethodPut,
+			hdrs: Headers{
+				headerOrigin:    "https://example.com",
+				"Authorization": "Bearer xyz",
+			},
+			status:    http.StatusOK,
+			acao:      "https://example.com",
+			authCalls: 1,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			authCalls = 0
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, newRequest(tc.reqMethod, tc.hdrs))
+			res := rec.Result()
+			if res.StatusCode != tc.status {
+				t.Errorf("got status code %d; want %d", res.StatusCode, tc.status)
+			}
+			if got := res.Header.Get(headerACAO); got != tc.acao {
+				t.Errorf("got ACAO %q; want %q", got, tc.acao)
+			}
+			if authCalls != tc.authCalls {
+				t.Errorf("got %d call(s) to auth; want %d", authCalls, tc.authCalls)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+
+	// A passthrough middleware never bypasses next.
+	handler = cors.PreflightBypass(auth(api), new(cors.Middleware))
+	req := newRequest(http.MethodOptions, Headers{
+		headerOrigin: "https://example.com",
+		headerACRM:   http.MethodPut,
+	})
+	authCalls = 0
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if authCalls != 1 {
+		t.Errorf("passthrough: got %d call(s) to auth; want 1", authCalls)
+	}
+}
+
+func TestPreflightHandler(t *testing.T) {
+	cfg := &cors.Config{
+		Origins: []string{"https://example.com"},
+		Methods: []string{http.MethodPut},
+	}
+	cases := []struct {
+		desc        string
+		cfg         *cors.Config
+		reqMethod   string
+		reqHeaders  Headers
+		status      int
+		respHeaders Headers
+	}{
+		{
+			desc:      "passthrough",
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodPut,
+			},
+			status: http.StatusMethodNotAllowed,
+			respHeaders: Headers{
+				"Allow":    http.MethodOptions,
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "non-CORS GET",
+			cfg:       cfg,
+			reqMethod: http.MethodGet,
+			status:    http.StatusMethodNotAllowed,
+			respHeaders: Headers{
+				"Allow": http.MethodOptions,
+			},
+		}, {
+			desc:      "actual GET",
+			cfg:       cfg,
+			reqMethod: http.MethodGet,
+			reqHeaders: Headers{
+				headerOrigin: "https://example.com",
+			},
+			status: http.StatusMethodNotAllowed,
+			respHeaders: Headers{
+				"Allow": http.MethodOptions,
+			},
+		}, {
+			desc:      "non-CORS OPTIONS",
+			cfg:       cfg,
+			reqMethod: http.MethodOptions,
+			status:    http.StatusMethodNotAllowed,
+			respHeaders: Headers{
+				"Allow":    http.MethodOptions,
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "actual OPTIONS",
+			cfg:       cfg,
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://example.com",
+			},
+			status: http.StatusMethodNotAllowed,
+			respHeaders: Headers{
+				"Allow":    http.MethodOptions,
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "preflight with PUT from allowed",
+			cfg:       cfg,
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodPut,
+			},
+			status: http.StatusNoContent,
+			respHeaders: Headers{
+				headerACAO: "https://example.com",
+				headerACAM: http.MethodPut,
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "preflight with DELETE from allowed",
+			cfg:       cfg,
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodDelete,
+			},
+			status: http.StatusForbidden,
+			respHeaders: Headers{
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "preflight with PUT from disallowed",
+			cfg:       cfg,
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://example.org",
+				headerACRM:   http.MethodPut,
+			},
+			status: http.StatusForbidden,
+			respHeaders: Headers{
+				headerVary: varyPreflightValue,
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var (
+				mw  *cors.Middleware
+				err error
+			)
+			if tc.cfg == nil {
+				mw = new(cors.Middleware)
+			} else {
+				mw, err = cors.NewMiddleware(*tc.cfg)
+				if err != nil {
+					t.Fatalf("failure to build CORS middleware: %v", err)
+				}
+			}
+			req := newRequest(tc.reqMethod, tc.reqHeaders)
+			rec := httptest.NewRecorder()
+			mw.PreflightHandler().ServeHTTP(rec, req)
+			res := rec.Result()
+			if res.StatusCode != tc.status {
+				t.Errorf("got status code %d; want %d", res.StatusCode, tc.status)
+			}
+			assertResponseHeaders(t, res.Header, tc.respHeaders)
+			assertNoMoreResponseHeaders(t, res.Header)
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestReportOnlyHook(t *testing.T) {
+	type report struct {
+		origin string
+		reason cors.Reason
+	}
+	var reports []report
+	cfg := cors.Config{
+		Origins:        []string{"https://example.com"},
+		Methods:        []string{http.MethodPut},
+		RequestHeaders: []string{"X-Foo"},
+		ExtraConfig: cors.ExtraConfig{
+			ReportOnly: true,
+			ReportOnlyHook: func(origin string, reason cors.Reason) {
+				reports = append(reports, report{origin, reason})
+			},
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	handler := mw.Wrap(newSpyHandler(200, nil, "")())
+	reqs := []struct {
+		method string
+		hdrs   Headers
+	}{
+		{http.MethodGet, Headers{headerOrigin: "https://example.com"}},
+		{http.MethodGet, Headers{headerOrigin: "https://example.org"}},
+		{http.MethodOptions, Headers{headerOrigin: "https://example.com", headerACRM: "PUT"}},
+		{http.MethodOptions, Headers{headerOrigin: "https://example.org", headerACRM: "PUT"}},
+		{http.MethodOptions, Headers{headerOrigin: "https://example.com", headerACRM: "DELETE"}},
+		{http.MethodOptions, Headers{headerOrigin: "https://example.com", headerACRM: "PUT", headerACRH: "x-bar"}},
+		{http.MethodOptions, Headers{headerOrigin: "https://example.com", headerACRM: "GET", headerACRPN: "true"}},
+	}
+	for _, req := range reqs {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest(req.method, req.hdrs))
+	}
+	want := []report{
+		{"https://example.org", "origin not allowed"},
+		{"https://example.org", "origin not allowed"},
+		{"https://example.com", "method not allowed"},
+		{"https://example.com", "request headers not allowed"},
+		{"https://example.com", "Private-Network Access not allowed"},
+	}
+	if !slices.Equal(reports, want) {
+		t.Errorf("got %q; want %q", reports, want)
+	}
+}
+
+func TestReportOnlyHookWithSecFetchMetadataValidation(t *testing.T) {
+	type report struct {
+		origin string
+		reason cors.Reason
+	}
+	var reports []report
+	cfg := cors.Config{
+		Origins: []string{"https://example.com"},
+		Methods: []string{http.MethodPut},
+		ExtraConfig: cors.ExtraConfig{
+			ValidateSecFetchMetadata: true,
+			ReportOnly:               true,
+			ReportOnlyHook: func(origin string, reason cors.Reason) {
+				reports = append(reports, report{origin, reason})
+			},
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	handler := mw.Wrap(newSpyHandler(200, nil, "")())
+	reqs := []struct {
+		method string
+		hdrs   Headers
+	}{
+		{http.MethodOptions, Headers{headerOrigin: "https://example.com", headerACRM: "PUT", headerSecFetchSite: "cross-site", headerSecFetchMode: "cors"}},
+		{http.MethodOptions, Headers{headerOrigin: "https://example.com", headerACRM: "PUT", headerSecFetchSite: "same-origin"}},
+		{http.MethodOptions, Headers{headerOrigin: "https://example.com", headerACRM: "PUT", headerSecFetchMode: "no-cors"}},
+		{http.MethodGet, Headers{headerOrigin: "https://example.com", headerSecFetchSite: "same-origin"}},
+		{http.MethodGet, Headers{headerOrigin: "https://sub.example.com", headerSecFetchSite: "same-origin"}},
+	}
+	for _, req := range reqs {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest(req.method, req.hdrs))
+	}
+	want := []report{
+		{"https://example.com", cors.ReasonFetchMetadata},
+		{"https://example.com", cors.ReasonFetchMetadata},
+		{"https://sub.example.com", cors.ReasonFetchMetadata},
+		{"https://sub.example.com", cors.ReasonOrigin},
+	}
+	if !slices.Equal(reports, want) {
+		t.Errorf("got %q; want %q", reports, want)
+	}
+}
+
+func TestReconfigureWithVersion(t *testing.T) {
+	cfg := cors.Config{Origins: []string{"https://example.com"}}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	if got := mw.Version(); got != 0 {
+		t.Fatalf("initial version: got %d; want 0", got)
+	}
+	other := cors.Config{Origins: []string{"https://example.org"}}
+	got, err := mw.ReconfigureWithVersion(&other, 0)
+	if err != nil || got != 1 {
+		t.Fatalf("got %d, %v; want 1, nil error", got, err)
+	}
+	got, err = mw.ReconfigureWithVersion(&cfg, 0) // stale version
+	if !errors.Is(err, cors.ErrVersionConflict) || got != 1 {
+		t.Errorf("got %d, %v; want 1, %v", got, err, cors.ErrVersionConflict)
+	}
+	if origins := mw.Config().Origins; !slices.Equal(origins, other.Origins) {
+		t.Errorf("after conflict: got origins %q; want %q", origins, other.Origins)
+	}
+	invalid := cors.Config{Origins: []string{"https://example.com/"}}
+	got, err = mw.ReconfigureWithVersion(&invalid, 1)
+	if err == nil || errors.Is(err, cors.ErrVersionConflict) || got != 1 {
+		t.Errorf("got %d, %v; want 1, some config error", got, err)
+	}
+	if err := mw.Reconfigure(nil); err != nil {
+		t.Fatalf("got error %v; want nil error", err)
+	}
+	got, err = mw.ReconfigureWithVersion(&cfg, 2)
+	if err != nil || got != 3 {
+		t.Errorf("got %d, %v; want 3, nil error", got, err)
+	}
+}
+
+func TestReconfigureIf(t *testing.T) {
+	const monitoring = "https://monitoring.example.com"
+	allowsMonitoring := func(cfg *cors.Config) bool {
+		return cfg != nil && slices.Contains(cfg.Origins, monitoring)
+	}
+	cfg := cors.Config{Origins: []string{monitoring}}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	other := cors.Config{Origins: []string{"https://example.org"}}
+	applied, err := mw.ReconfigureIf(&other, allowsMonitoring)
+	if applied || err != nil {
+		t.Errorf("got %t, %v; want false, nil error", applied, err)
+	}
+	if got := mw.Version(); got != 0 {
+		t.Errorf("after rejection: got version %d; want 0", got)
+	}
+	applied, err = mw.ReconfigureIf(nil, allowsMonitoring)
+	if applied || err != nil {
+		t.Errorf("got %t, %v; want false, nil error", applied, err)
+	}
+	invalid := cors.Config{Origins: []string{monitoring + "/"}}
+	applied, err = mw.ReconfigureIf(&invalid, func(*cors.Config) bool {
+		t.Error("predicate unexpectedly called on invalid config")
+		return true
+	})
+	if applied || err == nil {
+		t.Errorf("got %t, %v; want false, some config error", applied, err)
+	}
+	both := cors.Config{Origins: []string{"https://example.org", monitoring, monitoring}}
+	var seen []string
+	applied, err = mw.ReconfigureIf(&both, func(cfg *cors.Config) bool {
+		seen = cfg.Origins
+		return allowsMonitoring(cfg)
+	})
+	if !applied || err != nil {
+		t.Fatalf("got %t, %v; want true, nil error", applied, err)
+	}
+	want := []string{"https://example.org", monitoring}
+	if !slices.Equal(seen, want) {
+		t.Errorf("predicate got origins %q; want normalized %q", seen, want)
+	}
+	if origins := mw.Config().Origins; !slices.Equal(origins, want) {
+		t.Errorf("after reconfiguration: got origins %q; want %q", origins, want)
+	}
+	if got := mw.Version(); got != 1 {
+		t.Errorf("after reconfiguration: got version %d; want 1", got)
+	}
+}
+
+func TestSetDebugFor(t *testing.T) {
+	cfg := cors.Config{
+		Origins: []string{"https://example.com"},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	handler := mw.Wrap(newSpyHandler(200, nil, "")())
+	debugIsOn := func() bool {
+		req := newRequest(http.MethodOptions, Headers{
+			headerOrigin: "https://example.com",
+			headerACRM:   http.MethodGet,
+			headerACRH:   "x-foo",
+		})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Header().Get(headerXDebugRejectedHeader) != ""
+	}
+	waitForDebugOff := func(t *testing.T) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for debugIsOn() {
+			if time.Now().After(deadline) {
+				t.Fatal("debug mode unexpectedly still on")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	mw.SetDebugFor(10 * time.Millisecond)
+	if !debugIsOn() {
+		t.Fatal("debug mode unexpectedly off")
+	}
+	waitForDebugOff(t)
+
+	// A later call cancels the revert scheduled by a prior call.
+	mw.SetDebugFor(10 * time.Millisecond)
+	mw.SetDebugFor(time.Hour)
+	time.Sleep(30 * time.Millisecond)
+	if !debugIsOn() {
+		t.Fatal("debug mode unexpectedly off")
+	}
+
+	// So does a call to SetDebug.
+	mw.SetDebugFor(10 * time.Millisecond)
+	mw.SetDebug(true)
+	time.Sleep(30 * time.Millisecond)
+	if !debugIsOn() {
+		t.Fatal("debug mode unexpectedly off")
+	}
+
+	mw.SetDebugFor(0)
+	if debugIsOn() {
+		t.Fatal("debug mode unexpectedly on")
+	}
+
+	// SetDebugFor is a no-op for passthrough middleware.
+	var passthrough cors.Middleware
+	passthrough.SetDebugFor(time.Hour)
+	if err := passthrough.Reconfigure(&cfg); err != nil {
+		t.Fatalf("failure to reconfigure CORS middleware: %v", err)
+	}
+	handler = passthrough.Wrap(newSpyHandler(200, nil, "")())
+	if debugIsOn() {
+		t.Fatal("debug mode unexpectedly on")
+	}
+}
+
+func TestAnswerPlainOptions(t *testing.T) {
+	cfg := cors.Config{
+		Origins: []string{"https://example.com"},
+		Methods: []string{http.MethodPut, http.MethodDelete, http.MethodGet},
+		ExtraConfig: cors.ExtraConfig{
+			OriginMethods: map[string][]string{
+				"https://example.com": {http.MethodPatch},
+			},
+			AnswerPlainOptions: true,
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	cases := []struct {
+		desc       string
+		reqMethod  string
+		reqHeaders Headers
+		answered   bool
+	}{
+		{
+			desc:      "plain OPTIONS",
+			reqMethod: http.MethodOptions,
+			answered:  true,
+		}, {
+			desc:      "plain GET",
+			reqMethod: http.MethodGet,
+		}, {
+			desc:       "non-preflight OPTIONS from allowed origin",
+			reqMethod:  http.MethodOptions,
+			reqHeaders: Headers{headerOrigin: "https://example.com"},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			spy := newSpyHandler(200, nil, "")().(*spyHandler)
+			rec := httptest.NewRecorder()
+			mw.Wrap(spy).ServeHTTP(rec, newRequest(tc.reqMethod, tc.reqHeaders))
+			if !tc.answered {
+				if !spy.called.Load() {
+					t.Error("wrapped handler wasn't called, but should have been")
+				}
+				return
+			}
+			if spy.called.Load() {
+				t.Error("wrapped handler was called, but should not have been")
+			}
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("got status %d; want %d", rec.Code, http.StatusNoContent)
+			}
+			const want = "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT"
+			if got := rec.Header().Get("Allow"); got != want {
+				t.Errorf("got Allow %q; want %q", got, want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	newMiddleware := func(t *testing.T, cfg *cors.Config) *cors.Middleware {
+		t.Helper()
+		var mw cors.Middleware
+		if err := mw.Reconfigure(cfg); err != nil {
+			t.Fatalf("failure to reconfigure CORS middleware: %v", err)
+		}
+		return &mw
+	}
+	base := &cors.Config{
+		Origins:        []string{"https://example.com", "https://*.example.org"},
+		Methods:        []string{http.MethodPut, http.MethodDelete},
+		RequestHeaders: []string{"X-Foo"},
+	}
+	equivalent := &cors.Config{
+		Origins: []string{
+			"https://*.example.org",
+			"https://example.com",
+			"https://example.com",
+		},
+		Methods:        []string{http.MethodDelete, http.MethodPut, http.MethodGet},
+		RequestHeaders: []string{"x-foo"},
+	}
+	different := []*cors.Config{
+		{
+			Origins:        []string{"https://example.com"},
+			Methods:        []string{http.MethodPut, http.MethodDelete},
+			RequestHeaders: []string{"X-Foo"},
+		}, {
+			Origins:        []string{"https://example.com", "https://*.example.org"},
+			Credentialed:   true,
+			Methods:        []string{http.MethodPut, http.MethodDelete},
+			RequestHeaders: []string{"X-Foo"},
+		}, {
+			Origins:        []string{"https://example.com", "https://*.example.org"},
+			Methods:        []string{http.MethodPut, http.MethodDelete},
+			RequestHeaders: []string{"X-Foo"},
+			ExtraConfig: cors.ExtraConfig{
+				ResponseHeaderHook: func(http.Header) {},
+			},
+		},
+	}
+	mw := newMiddleware(t, base)
+	want := mw.ConfigHash()
+	if want == 0 {
+		t.Fatal("got 0 hash for non-passthrough middleware")
+	}
+	if got := newMiddleware(t, equivalent).ConfigHash(); got != want {
+		t.Errorf("got hash %x for equivalent config; want %x", got, want)
+	}
+	mw.SetDebug(true)
+	if err := mw.Reconfigure(mw.Config()); err != nil {
+		t.Fatalf("failure to reconfigure CORS middleware: %v", err)
+	}
+	if got := mw.ConfigHash(); got != want {
+		t.Errorf("got hash %x after no-op reconfiguration; want %x", got, want)
+	}
+	for i, cfg := range different {
+		if got := newMiddleware(t, cfg).ConfigHash(); got == want {
+			t.Errorf("config #%d: got same hash as for different config", i)
+		}
+	}
+	if got := new(cors.Middleware).ConfigHash(); got != 0 {
+		t.Errorf("got hash %x for passthrough middleware; want 0", got)
+	}
+}
+
+func TestSlowPreflightHook(t *testing.T) {
+	cases := []struct {
+		desc      string
+		threshold time.Duration
+		wantCalls int
+	}{
+		{
+			desc:      "threshold exceeded",
+			threshold: time.Nanosecond,
+			wantCalls: 1,
+		}, {
+			desc:      "threshold not exceeded",
+			threshold: time.Hour,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			var calls int
+			req := newRequest(http.MethodOptions, Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodGet,
+				headerACRH:   "x-foo",
+			})
+			cfg := cors.Config{
+				Origins:        []string{"https://example.com"},
+				RequestHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					SlowPreflightThreshold: tc.threshold,
+					OnSlowPreflight: func(d time.Duration, r *http.Request) {
+						calls++
+						if d <= tc.threshold {
+							t.Errorf("got duration %v; want more than %v", d, tc.threshold)
+						}
+						if r != req {
+							t.Error("hook invoked with an unexpected request")
+						}
+					},
+				},
+			}
+			mw, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			mw.Wrap(newSpyHandler(200, nil, "")()).ServeHTTP(httptest.NewRecorder(), req)
+			if calls != tc.wantCalls {
+				t.Errorf("got %d call(s) to the hook; want %d", calls, tc.wantCalls)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestOnDisallowedContentType(t *testing.T) {
+	cases := []struct {
+		desc        string
+		reqMethod   string
+		contentType string // empty if the request has no Content-Type header
+		wantCalls   int
+	}{
+		{
+			desc:      "no content type",
+			reqMethod: http.MethodPut,
+		}, {
+			desc:        "allowed content type",
+			reqMethod:   http.MethodPut,
+			contentType: "application/json",
+		}, {
+			desc:        "allowed content type with parameters in other case",
+			reqMethod:   http.MethodPut,
+			contentType: "Application/JSON; charset=utf-8",
+		}, {
+			desc:        "safelisted content type",
+			reqMethod:   http.MethodPost,
+			contentType: "text/plain",
+		}, {
+			desc:        "disallowed content type",
+			reqMethod:   http.MethodPut,
+			contentType: "application/xml",
+			wantCalls:   1,
+		}, {
+			desc:        "invalid content type",
+			reqMethod:   http.MethodPut,
+			contentType: "application/",
+			wantCalls:   1,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			var calls int
+			hdrs := Headers{headerOrigin: "https://example.com"}
+			if tc.contentType != "" {
+				hdrs["Content-Type"] = tc.contentType
+			}
+			req := newRequest(tc.reqMethod, hdrs)
+			cfg := cors.Config{
+				Origins:        []string{"https://example.com"},
+				Methods:        []string{http.MethodPut},
+				RequestHeaders: []string{"Content-Type"},
+				ExtraConfig: cors.ExtraConfig{
+					AllowedContentTypesOnPreflight: []string{"application/json"},
+					OnDisallowedContentType: func(contentType string, r *http.Request) {
+						calls++
+						if contentType != tc.contentType {
+							t.Errorf("got content type %q; want %q", contentType, tc.contentType)
+						}
+						if r != req {
+							t.Error("hook invoked with an unexpected request")
+						}
+					},
+				},
+			}
+			mw, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			mw.Wrap(newSpyHandler(200, nil, "")()).ServeHTTP(rec, req)
+			if calls != tc.wantCalls {
+				t.Errorf("got %d call(s) to the hook; want %d", calls, tc.wantCalls)
+			}
+			// The check is post-hoc: it doesn't prevent the request
+			// from reaching the wrapped handler.
+			if got := rec.Result().StatusCode; got != 200 {
+				t.Errorf("got status %d; want 200", got)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestObserveLatency(t *testing.T) {
+	type observation struct {
+		kind string
+		d    time.Duration
+	}
+	var observations []observation
+	cfg := cors.Config{
+		Origins: []string{"https://example.com"},
+		ExtraConfig: cors.ExtraConfig{
+			ObserveLatency: func(kind string, d time.Duration) {
+				observations = append(observations, observation{kind, d})
+			},
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	const handlerDelay = 50 * time.Millisecond
+	handler := mw.Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		time.Sleep(handlerDelay)
+	}))
+	preflight := func() *http.Request {
+		return newRequest(http.MethodOptions, Headers{
+			headerOrigin: "https://example.com",
+			headerACRM:   http.MethodGet,
+		})
+	}
+	reqs := []struct {
+		handler http.Handler
+		req     *http.Request
+	}{
+		{handler, newRequest(http.MethodGet, nil)}, // not timed
+		{handler, newRequest(http.MethodGet, Headers{headerOrigin: "https://example.com"})},
+		{handler, newRequest(http.MethodGet, Headers{headerOrigin: "https://example.org"})},
+		{handler, preflight()},
+		{mw.PreflightHandler(), preflight()},
+	}
+	for _, r := range reqs {
+		r.handler.ServeHTTP(httptest.NewRecorder(), r.req)
+	}
+	wantKinds := []string{"actual", "actual", "preflight", "preflight"}
+	if len(observations) != len(wantKinds) {
+		t.Fatalf("got %d observations; want %d", len(observations), len(wantKinds))
+	}
+	for i, o := range observations {
+		if o.kind != wantKinds[i] {
+			t.Errorf("observation %d: got kind %q; want %q", i, o.kind, wantKinds[i])
+		}
+		if o.d < 0 || o.d >= handlerDelay {
+			const tmpl = "observation %d: got duration %v; want in [0, %v)"
+			t.Errorf(tmpl, i, o.d, handlerDelay)
+		}
+	}
+}
+
+func TestApplyAfterHandler(t *testing.T) {
+	// handler that clobbers the Vary header, as some inner layers do
+	clobbering := func(write bool) http.Handler {
+		f := func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set(headerVary, "Accept-Encoding")
+			if write {
+				io.WriteString(w, "foo")
+			}
+		}
+		return http.HandlerFunc(f)
+	}
+	cases := []struct {
+		desc   string
+		apply  bool
+		origin string
+		write  bool
+		want   map[string][]string
+	}{
+		{
+			desc:   "disabled",
+			origin: "https://example.com",
+			write:  true,
+			want: map[string][]string{
+				headerACAO: {"https://example.com"},
+				headerVary: {"Accept-Encoding"},
+			},
+		}, {
+			desc:   "allowed origin",
+			apply:  true,
+			origin: "https://example.com",
+			write:  true,
+			want: map[string][]string{
+				headerACAO: {"https://example.com"},
+				headerVary: {"Accept-Encoding", headerOrigin},
+			},
+		}, {
+			desc:   "allowed origin without writes",
+			apply:  true,
+			origin: "https://example.com",
+			want: map[string][]string{
+				headerACAO: {"https://example.com"},
+				headerVary: {"Accept-Encoding", headerOrigin},
+			},
+		}, {
+			desc:   "disallowed origin",
+			apply:  true,
+			origin: "https://example.org",
+			write:  true,
+			want: map[string][]string{
+				headerVary: {"Accept-Encoding", headerOrigin},
+			},
+		}, {
+			desc:  "non-CORS",
+			apply: true,
+			write: true,
+			want: map[string][]string{
+				headerVary: {"Accept-Encoding", headerOrigin},
+			},
 		},
 	}
 	for _, tc := range cases {
 		f := func(t *testing.T) {
-			t.Parallel()
-			var (
-				mw  *cors.Middleware
-				err error
-			)
-			if tc.cfg == nil {
-				mw = new(cors.Middleware)
-			} else {
-				mw, err = cors.NewMiddleware(*tc.cfg)
-				if err != nil {
-					t.Fatalf("failure to build CORS middleware: %v", err)
+			cfg := cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					ApplyAfterHandler: tc.apply,
+				},
+			}
+			mw, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			hdrs := make(Headers)
+			if tc.origin != "" {
+				hdrs[headerOrigin] = tc.origin
+			}
+			rec := httptest.NewRecorder()
+			req := newRequest(http.MethodGet, hdrs)
+			mw.Wrap(clobbering(tc.write)).ServeHTTP(rec, req)
+			for name, want := range tc.want {
+				if got := rec.Result().Header[name]; !slices.Equal(got, want) {
+					t.Errorf("%s: got %q; want %q", name, got, want)
 				}
 			}
-			got := mw.Config()
-			assertConfigEqual(t, got, tc.want)
 		}
 		t.Run(tc.desc, f)
 	}
 }
+
+func TestOverrideHandlerCORSHeaders(t *testing.T) {
+	rogue := func(write bool) http.Handler {
+		f := func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Add(headerACAO, "https://attacker.example")
+			w.Header().Set(headerACAC, "true")
+			w.Header().Set(headerACAM, "DELETE")
+			w.Header().Set("X-Foo", "foo")
+			if write {
+				io.WriteString(w, "bar")
+			}
+		}
+		return http.HandlerFunc(f)
+	}
+	cases := []struct {
+		desc     string
+		override bool
+		origin   string
+		write    bool
+		want     map[string][]string
+	}{
+		{
+			desc:   "disabled",
+			origin: "https://example.com",
+			write:  true,
+			want: map[string][]string{
+				headerACAO: {"https://example.com", "https://attacker.example"},
+				headerACAC: {"true"},
+				headerACAM: {"DELETE"},
+				"X-Foo":    {"foo"},
+			},
+		}, {
+			desc:     "allowed origin",
+			override: true,
+			origin:   "https://example.com",
+			write:    true,
+			want: map[string][]string{
+				headerACAO: {"https://example.com"},
+				"X-Foo":    {"foo"},
+			},
+		}, {
+			desc:     "allowed origin without writes",
+			override: true,
+			origin:   "https://example.com",
+			want: map[string][]string{
+				headerACAO: {"https://example.com"},
+				"X-Foo":    {"foo"},
+			},
+		}, {
+			desc:     "disallowed origin",
+			override: true,
+			origin:   "https://example.org",
+			write:    true,
+			want: map[string][]string{
+				"X-Foo": {"foo"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			cfg := cors.Config{
+				Origins: []string{"https://example.com"},
+				ExtraConfig: cors.ExtraConfig{
+					OverrideHandlerCORSHeaders: tc.override,
+				},
+			}
+			mw, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			req := newRequest(http.MethodGet, Headers{headerOrigin: tc.origin})
+			mw.Wrap(rogue(tc.write)).ServeHTTP(rec, req)
+			for name, want := range tc.want {
+				if got := rec.Result().Header[name]; !slices.Equal(got, want) {
+					t.Errorf("%s: got %q; want %q", name, got, want)
+				}
+			}
+			for name := range rec.Result().Header {
+				if _, found := tc.want[name]; !found && name != headerVary && name != "Content-Type" {
+					t.Errorf("unexpected header %q", name)
+				}
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestSanitizeInboundCORSHeaders(t *testing.T) {
+	const fallback = "X-Requested-Method"
+	cfg := cors.Config{
+		Origins: []string{"https://example.com"},
+		ExtraConfig: cors.ExtraConfig{
+			RequestMethodHeaderFallback: fallback,
+			SanitizeInboundCORSHeaders:  true,
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	legitimate := Headers{
+		headerACRM:  http.MethodGet,
+		headerACRH:  "x-foo",
+		headerACRPN: "true",
+		fallback:    http.MethodPut,
+		"X-Foo":     "foo",
+	}
+	rogue := Headers{
+		headerACAO:                     "https://attacker.example",
+		headerACAC:                     "true",
+		headerACEH:                     "X-Secret",
+		"Access-Control-Request-Other": "foo",
+	}
+	cases := []struct {
+		desc   string
+		method string
+		origin string
+	}{
+		{
+			desc:   "actual GET",
+			method: http.MethodGet,
+			origin: "https://example.com",
+		}, {
+			desc:   "actual GET from disallowed",
+			method: http.MethodGet,
+			origin: "https://example.org",
+		}, {
+			desc:   "non-CORS GET",
+			method: http.MethodGet,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			hdrs := make(Headers)
+			maps.Copy(hdrs, legitimate)
+			maps.Copy(hdrs, rogue)
+			if tc.origin != "" {
+				hdrs[headerOrigin] = tc.origin
+			}
+			var got http.Header
+			handler := func(_ http.ResponseWriter, r *http.Request) {
+				got = r.Header
+			}
+			req := newRequest(tc.method, hdrs)
+			mw.Wrap(http.HandlerFunc(handler)).ServeHTTP(httptest.NewRecorder(), req)
+			for name, value := range legitimate {
+				if v := got.Get(name); v != value {
+					t.Errorf("%s: got %q; want %q", name, v, value)
+				}
+			}
+			if got.Get(headerOrigin) != tc.origin {
+				t.Errorf("%s: got %q; want %q", headerOrigin, got.Get(headerOrigin), tc.origin)
+			}
+			for name := range rogue {
+				if v, found := got[name]; found {
+					t.Errorf("%s: got %q; want none", name, v)
+				}
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestReflectExposedResponseHeaders(t *testing.T) {
+	handler := func(write bool) http.Handler {
+		f := func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("X-Foo", "foo")
+			w.Header().Set("X-Bar", "bar")
+			w.Header().Set("Set-Cookie", "id=123")
+			w.Header().Set("Content-Type", "text/plain")
+			if write {
+				io.WriteString(w, "baz")
+			}
+			w.Header().Set("X-Qux", "qux") // too late if write is set
+		}
+		return http.HandlerFunc(f)
+	}
+	cases := []struct {
+		desc   string
+		origin string
+		write  bool
+		want   []string
+	}{
+		{
+			desc:   "allowed origin",
+			origin: "https://example.com",
+			write:  true,
+			want:   []string{"x-bar,x-foo"},
+		}, {
+			desc:   "allowed origin without writes",
+			origin: "https://example.com",
+			want:   []string{"x-bar,x-foo,x-qux"},
+		}, {
+			desc:   "disallowed origin",
+			origin: "https://example.org",
+			write:  true,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			cfg := cors.Config{
+				Origins:      []string{"https://example.com"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					ReflectExposedResponseHeaders: true,
+				},
+			}
+			mw, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			req := newRequest(http.MethodGet, Headers{headerOrigin: tc.origin})
+			mw.Wrap(handler(tc.write)).ServeHTTP(rec, req)
+			if got := rec.Result().Header[headerACEH]; !slices.Equal(got, tc.want) {
+				t.Errorf("got ACEH %q; want %q", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestLowercaseResponseHeaderNames(t *testing.T) {
+	cfg := cors.Config{
+		Origins:         []string{"https://example.com"},
+		Credentialed:    true,
+		Methods:         []string{http.MethodPut},
+		RequestHeaders:  []string{"X-Foo"},
+		MaxAgeInSeconds: 30,
+		ResponseHeaders: []string{"X-Bar"},
+		ExtraConfig: cors.ExtraConfig{
+			LowercaseResponseHeaderNames: true,
+		},
+	}
+	cases := []struct {
+		desc  string
+		debug bool
+		req   *http.Request
+		want  []string // keys of the response-header map
+	}{
+		{
+			desc: "preflight",
+			req: newRequest(http.MethodOptions, Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodPut,
+				headerACRH:   "x-foo",
+			}),
+			want: []string{
+				"Vary",
+				"access-control-allow-credentials",
+				"access-control-allow-headers",
+				"access-control-allow-methods",
+				"access-control-allow-origin",
+				"access-control-max-age",
+			},
+		}, {
+			desc:  "preflight with disallowed header in debug mode",
+			debug: true,
+			req: newRequest(http.MethodOptions, Headers{
+				headerOrigin: "https://example.com",
+				headerACRM:   http.MethodPut,
+				headerACRH:   "x-baz",
+			}),
+			want: []string{
+				"Vary",
+				"access-control-allow-credentials",
+				"access-control-allow-headers",
+				"access-control-allow-methods",
+				"access-control-allow-origin",
+				"access-control-max-age",
+				"x-debug-rejected-header",
+			},
+		}, {
+			desc: "actual",
+			req:  newRequest(http.MethodGet, Headers{headerOrigin: "https://example.com"}),
+			want: []string{
+				"Vary",
+				"X-Handler", // set by the handler, hence left untouched
+				"access-control-allow-credentials",
+				"access-control-allow-origin",
+				"access-control-expose-headers",
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			mw, err := cors.NewMiddleware(cfg)
+			if err != nil {
+				t.Fatalf("failure to build CORS middleware: %v", err)
+			}
+			mw.SetDebug(tc.debug)
+			handler := func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("X-Handler", "foo")
+			}
+			rec := httptest.NewRecorder()
+			mw.Wrap(http.HandlerFunc(handler)).ServeHTTP(rec, tc.req)
+			var got []string
+			for k := range rec.Header() {
+				got = append(got, k)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got keys %q; want %q", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestWarnOnUnusedExposedHeaders(t *testing.T) {
+	type report struct {
+		names []string
+		path  string
+	}
+	var reports []report
+	cfg := cors.Config{
+		Origins:         []string{"https://example.com"},
+		ResponseHeaders: []string{"X-Trace-Id", "X-Foo", "X-Bar"},
+		ExtraConfig: cors.ExtraConfig{
+			WarnOnUnusedExposedHeaders: true,
+			OnUnusedExposedHeaders: func(names []string, r *http.Request) {
+				reports = append(reports, report{names, r.URL.Path})
+			},
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Foo", "foo")
+		if r.Header.Get("X-All") == "true" {
+			w.Header().Set("X-Bar", "bar")
+			w.Header().Set("X-Trace-Id", "42")
+		}
+		io.WriteString(w, "baz")
+	}
+	reqs := []Headers{
+		{headerOrigin: "https://example.com"},
+		{headerOrigin: "https://example.com", "X-All": "true"},
+		// no report expected for disallowed origins or non-CORS requests
+		{headerOrigin: "https://example.org"},
+		{},
+	}
+	for _, hdrs := range reqs {
+		req := newRequest(http.MethodGet, hdrs)
+		mw.Wrap(http.HandlerFunc(handler)).ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports; want 1", len(reports))
+	}
+	want := []string{"x-bar", "x-trace-id"}
+	if got := reports[0].names; !slices.Equal(got, want) {
+		t.Errorf("got unused names %q; want %q", got, want)
+	}
+	if got, want := reports[0].path, "/whatever"; got != want {
+		t.Errorf("got path %q; want %q", got, want)
+	}
+}
+
+func TestOverrideHandlerCORSHeadersWithResponseController(t *testing.T) {
+	cfg := cors.Config{
+		Origins: []string{"https://example.com"},
+		ExtraConfig: cors.ExtraConfig{
+			OverrideHandlerCORSHeaders: true,
+		},
+	}
+	mw, err := cors.NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	h := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(headerACAO, "*")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("failure to flush: %v", err)
+		}
+	}
+	rec := httptest.NewRecorder()
+	req := newRequest(http.MethodGet, Headers{headerOrigin: "https://example.com"})
+	mw.Wrap(http.HandlerFunc(h)).ServeHTTP(rec, req)
+	if !rec.Flushed {
+		t.Error("response was not flushed")
+	}
+	if got, want := rec.Result().Header.Get(headerACAO), "https://example.com"; got != want {
+		t.Errorf("%s: got %q; want %q", headerACAO, got, want)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	cfgs := []*cors.Config{
+		{
+			Origins:        []string{"https://example.com"},
+			Credentialed:   true,
+			Methods:        []string{http.MethodPut},
+			RequestHeaders: []string{"X-Foo"},
+		}, {
+			Origins: []string{"*"},
+			Methods: []string{"*"},
+		},
+		nil, // passthrough
+	}
+	mw, err := cors.NewMiddleware(*cfgs[0])
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	handler := mw.Wrap(newSpyHandler(200, nil, "")())
+	preflightHandler := mw.PreflightHandler()
+	serve := func(h http.Handler, method string, hdrs Headers) func() {
+		return func() {
+			h.ServeHTTP(httptest.NewRecorder(), newRequest(method, hdrs))
+		}
+	}
+	preflightHdrs := Headers{
+		headerOrigin: "https://example.com",
+		headerACRM:   http.MethodPut,
+		headerACRH:   "x-bar",
+	}
+	var i atomic.Uint32
+	const n = 200
+	stress(t, n,
+		serve(handler, http.MethodGet, Headers{headerOrigin: "https://example.com"}),
+		serve(handler, http.MethodOptions, preflightHdrs),
+		serve(preflightHandler, http.MethodOptions, preflightHdrs),
+		serve(mw.DiagnosticHandler(), http.MethodGet, nil),
+		func() { mw.SetDebug(i.Add(1)%2 == 0) },
+		func() { mw.SetDebugFor(time.Duration(i.Add(1)%3) * time.Millisecond) },
+		func() { mw.Reconfigure(cfgs[int(i.Add(1))%len(cfgs)]) },
+		func() { mw.Reconfigure(mw.Config()) },
+		func() { mw.AllowedMethods() },
+		func() { mw.OriginsInConfigOrder() },
+		func() { mw.VaryValues() },
+		func() { mw.PrivateNetworkAccessMode() },
+		func() { mw.Warnings() },
+		func() { mw.ConfigAsEnv() },
+		func() { mw.ConfigHash() },
+		func() { mw.ReconfigureWithVersion(cfgs[int(i.Add(1))%len(cfgs)], mw.Version()) },
+		func() {
+			mw.ReconfigureIf(cfgs[int(i.Add(1))%len(cfgs)], func(*cors.Config) bool { return i.Add(1)%2 == 0 })
+		},
+	)
+}
@@ -0,0 +1,100 @@
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A PathMux applies different CORS middleware to requests depending on
+// their URL path; it is useful when different parts of a server
+// (e.g. different versions of an API) call for different CORS policies.
+// For instance:
+//
+//	pm := cors.NewPathMux()
+//	pm.Handle("/api/v1/", corsMwV1)
+//	pm.Handle("/api/v2/", corsMwV2)
+//	http.ListenAndServe(":8080", pm.Wrap(mux))
+//
+// Each request (including CORS-preflight requests) is processed by
+// the middleware registered with the longest path prefix that
+// the request's URL path starts with, exactly as if that middleware
+// (via its [*Middleware.Wrap] method) were the only one applied to
+// the wrapped handler; in particular, that middleware responds to
+// CORS-preflight requests without invoking the wrapped handler.
+// Requests whose URL path matches none of the registered prefixes
+// are passed on to the wrapped handler as is.
+//
+// A PathMux is safe for concurrent use by multiple goroutines.
+// The zero value is an empty PathMux.
+type PathMux struct {
+	mu      sync.RWMutex
+	entries []pathMuxEntry // sorted by decreasing prefix length
+}
+
+type pathMuxEntry struct {
+	prefix string
+	m      *Middleware
+}
+
+// NewPathMux returns a new, empty PathMux.
+func NewPathMux() *PathMux {
+	return new(PathMux)
+}
+
+// Handle registers m for the requests whose URL path starts with prefix.
+// Prefix matching is byte-wise: "/api/v1" matches "/api/v10" as well as
+// "/api/v1/users"; end prefix with a slash if you don't want that.
+// Handle panics if prefix doesn't start with a slash,
+// if m is nil, or if some middleware is already registered for prefix.
+func (pm *PathMux) Handle(prefix string, m *Middleware) {
+	if !strings.HasPrefix(prefix, "/") {
+		panic("cors: invalid path prefix " + strconv.Quote(prefix))
+	}
+	if m == nil {
+		panic("cors: nil middleware")
+	}
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	order := func(e pathMuxEntry, prefix string) int {
+		// decreasing length first, then increasing lexicographical order
+		if d := len(prefix) - len(e.prefix); d != 0 {
+			return d
+		}
+		return strings.Compare(e.prefix, prefix)
+	}
+	i, found := slices.BinarySearchFunc(pm.entries, prefix, order)
+	if found {
+		panic("cors: multiple registrations for path prefix " + strconv.Quote(prefix))
+	}
+	pm.entries = slices.Insert(pm.entries, i, pathMuxEntry{prefix: prefix, m: m})
+}
+
+// Wrap applies pm to the specified handler.
+// Middleware registered via [*PathMux.Handle] after the call to Wrap
+// also apply to the resulting handler.
+func (pm *PathMux) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := pm.match(r.URL.Path)
+		if m == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		m.serve(w, r, h)
+	})
+}
+
+// match returns the middleware registered with the longest prefix of path,
+// or nil if there is none.
+func (pm *PathMux) match(path string) *Middleware {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	for _, e := range pm.entries {
+		if strings.HasPrefix(path, e.prefix) {
+			return e.m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestPathMux(t *testing.T) {
+	mw1, err := cors.NewMiddleware(cors.Config{
+		Origins: []string{"https://v1.example.com"},
+		Methods: []string{http.MethodPut},
+	})
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	mw2, err := cors.NewMiddleware(cors.Config{
+		Origins: []string{"https://v2.example.com"},
+		Methods: []string{http.MethodDelete},
+	})
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	mw2Admin, err := cors.NewMiddleware(cors.Config{
+		Origins: []string{"https://admin.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	pm := cors.NewPathMux()
+	pm.Handle("/api/v2/", mw2)
+	pm.Handle("/api/v1/", mw1)
+	pm.Handle("/api/v2/admin/", mw2Admin)
+
+	cases := []struct {
+		desc          string
+		path          string
+		reqMethod     string
+		reqHeaders    Headers
+		handlerCalled bool
+		wantStatus    int
+		respHeaders   Headers
+	}{
+		{
+			desc:      "preflight v1 from allowed",
+			path:      "/api/v1/users",
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://v1.example.com",
+				headerACRM:   http.MethodPut,
+			},
+			wantStatus: http.StatusNoContent,
+			respHeaders: Headers{
+				headerACAO: "https://v1.example.com",
+				headerACAM: http.MethodPut,
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "preflight v1 from v2 origin",
+			path:      "/api/v1/users",
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://v2.example.com",
+				headerACRM:   http.MethodPut,
+			},
+			wantStatus: http.StatusForbidden,
+			respHeaders: Headers{
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "preflight v2 from allowed",
+			path:      "/api/v2/users",
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://v2.example.com",
+				headerACRM:   http.MethodDelete,
+			},
+			wantStatus: http.StatusNoContent,
+			respHeaders: Headers{
+				headerACAO: "https://v2.example.com",
+				headerACAM: http.MethodDelete,
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "preflight v2 with method allowed by v1 only",
+			path:      "/api/v2/users",
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://v2.example.com",
+				headerACRM:   http.MethodPut,
+			},
+			wantStatus: http.StatusForbidden,
+			respHeaders: Headers{
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "preflight longest prefix",
+			path:      "/api/v2/admin/users",
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://v2.example.com",
+				headerACRM:   http.MethodDelete,
+			},
+			wantStatus: http.StatusForbidden,
+			respHeaders: Headers{
+				headerVary: varyPreflightValue,
+			},
+		}, {
+			desc:      "actual GET v1 from allowed",
+			path:      "/api/v1/users",
+			reqMethod: http.MethodGet,
+			reqHeaders: Headers{
+				headerOrigin: "https://v1.example.com",
+			},
+			handlerCalled: true,
+			wantStatus:    http.StatusOK,
+			respHeaders: Headers{
+				headerACAO: "https://v1.example.com",
+				headerVary: headerOrigin,
+			},
+		}, {
+			desc:      "actual GET v2 from v1 origin",
+			path:      "/api/v2/users",
+			reqMethod: http.MethodGet,
+			reqHeaders: Headers{
+				headerOrigin: "https://v1.example.com",
+			},
+			handlerCalled: true,
+			wantStatus:    http.StatusOK,
+			respHeaders: Headers{
+				headerVary: headerOrigin,
+			},
+		}, {
+			desc:      "actual GET longest prefix from allowed",
+			path:      "/api/v2/admin/users",
+			reqMethod: http.MethodGet,
+			reqHeaders: Headers{
+				headerOrigin: "https://admin.example.com",
+			},
+			handlerCalled: true,
+			wantStatus:    http.StatusOK,
+			respHeaders: Headers{
+				headerACAO: "https://admin.example.com",
+				headerVary: headerOrigin,
+			},
+		}, {
+			desc:      "no matching prefix",
+			path:      "/api/v3/users",
+			reqMethod: http.MethodOptions,
+			reqHeaders: Headers{
+				headerOrigin: "https://v1.example.com",
+				headerACRM:   http.MethodPut,
+			},
+			handlerCalled: true,
+			wantStatus:    http.StatusOK,
+		}, {
+			desc:      "prefix without trailing slash",
+			path:      "/api/v1",
+			reqMethod: http.MethodGet,
+			reqHeaders: Headers{
+				headerOrigin: "https://v1.example.com",
+			},
+			handlerCalled: true,
+			wantStatus:    http.StatusOK,
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			t.Parallel()
+			var called bool
+			h := func(w http.ResponseWriter, _ *http.Request) {
+				called = true
+			}
+			req := httptest.NewRequest(tc.reqMethod, "https://example.com"+tc.path, nil)
+			for name, value := range tc.reqHeaders {
+				req.Header.Add(name, value)
+			}
+			rec := httptest.NewRecorder()
+			pm.Wrap(http.HandlerFunc(h)).ServeHTTP(rec, req)
+			if called != tc.handlerCalled {
+				t.Errorf("handler called: got %t; want %t", called, tc.handlerCalled)
+			}
+			res := rec.Result()
+			if res.StatusCode != tc.wantStatus {
+				t.Errorf("got status %d; want %d", res.StatusCode, tc.wantStatus)
+			}
+			assertResponseHeaders(t, res.Header, tc.respHeaders)
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestPathMuxHandlePanics(t *testing.T) {
+	mw, err := cors.NewMiddleware(cors.Config{
+		Origins: []string{"https://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	cases := []struct {
+		desc   string
+		prefix string
+		mw     *cors.Middleware
+	}{
+		{
+			desc:   "empty prefix",
+			prefix: "",
+			mw:     mw,
+		}, {
+			desc:   "relative prefix",
+			prefix: "api/",
+			mw:     mw,
+		}, {
+			desc:   "nil middleware",
+			prefix: "/api/",
+		}, {
+			desc:   "duplicate prefix",
+			prefix: "/dup/",
+			mw:     mw,
+		},
+	}
+	pm := cors.NewPathMux()
+	pm.Handle("/dup/", mw)
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("got no panic; want panic")
+				}
+			}()
+			pm.Handle(tc.prefix, tc.mw)
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestPathMuxDoesNotAllocate(t *testing.T) {
+	mw, err := cors.NewMiddleware(cors.Config{
+		Origins: []string{"https://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("failure to build CORS middleware: %v", err)
+	}
+	pm := cors.NewPathMux()
+	pm.Handle("/api/", mw)
+	h := newDummyHandler()()
+	req := newRequest(http.MethodGet, Headers{headerOrigin: "https://example.com"})
+	req.URL.Path = "/api/users"
+	allocs := func(h http.Handler) float64 {
+		rec := httptest.NewRecorder()
+		return testing.AllocsPerRun(100, func() {
+			clear(rec.Header())
+			h.ServeHTTP(rec, req)
+		})
+	}
+	direct := allocs(mw.Wrap(h))
+	if got := allocs(pm.Wrap(h)); got > direct {
+		t.Errorf("got %v allocs per request; want at most %v", got, direct)
+	}
+}
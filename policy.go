@@ -0,0 +1,202 @@
+package cors
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jub0bs/cors/internal/origins"
+	"github.com/jub0bs/cors/internal/util"
+)
+
+// A ConfigPolicy specifies constructs that configurations must not use,
+// beyond those that this package prohibits anyway;
+// see [SetConfigPolicy].
+// The zero value forbids nothing.
+type ConfigPolicy struct {
+	// ForbidAllOrigins forbids the single-asterisk pattern in Config.Origins.
+	ForbidAllOrigins bool
+	// ForbidWildcardMethods forbids the wildcard in Config.Methods.
+	ForbidWildcardMethods bool
+	// ForbidWildcardRequestHeaders forbids the wildcard
+	// in Config.RequestHeaders.
+	ForbidWildcardRequestHeaders bool
+	// ForbidWildcardResponseHeaders forbids the wildcard
+	// in Config.ResponseHeaders.
+	ForbidWildcardResponseHeaders bool
+	// ForbidCredentialedInsecureOrigins forbids insecure origin patterns
+	// (see [RequireSecureOrigins]) alongside credentialed access,
+	// even if ExtraConfig.DangerouslyTolerateInsecureOrigins is set.
+	ForbidCredentialedInsecureOrigins bool
+}
+
+// configPolicy is the policy in effect (nil if none).
+var configPolicy atomic.Pointer[ConfigPolicy]
+
+// SetConfigPolicy sets the policy that [NewMiddleware],
+// [NewMiddlewareFromMatcher], [*Middleware.Reconfigure],
+// and the other functions that validate configurations
+// enforce on top of this package's built-in rules.
+// Policy violations are reported as [*PolicyViolationError] values,
+// which you can identify with [errors.As].
+// SetConfigPolicy enables platform operators to centrally tighten
+// what configurations (e.g. those supplied by tenants) are acceptable.
+// The zero ConfigPolicy removes any policy previously set.
+//
+// The policy is global to the process.
+// SetConfigPolicy is safe for concurrent use by multiple goroutines,
+// but it only affects subsequent validations of configurations;
+// existing middleware are unaffected.
+// Therefore, you should call SetConfigPolicy once,
+// at program initialization, before building any middleware.
+func SetConfigPolicy(p ConfigPolicy) {
+	if p == (ConfigPolicy{}) {
+		configPolicy.Store(nil)
+		return
+	}
+	configPolicy.Store(&p)
+}
+
+// A PolicyViolationError indicates that a configuration violates
+// the ConfigPolicy in effect; see [SetConfigPolicy].
+type PolicyViolationError struct {
+	// Rule is the name of the ConfigPolicy field that the configuration
+	// violates (e.g. ForbidWildcardMethods).
+	Rule string
+	msg  string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return "cors: policy " + e.Rule + " forbids " + e.msg
+}
+
+// dangerousFlagsForbidden indicates whether ForbidDangerousFlags was called.
+var dangerousFlagsForbidden atomic.Bool
+
+// ForbidDangerousFlags makes [NewMiddleware], [NewMiddlewareFromMatcher],
+// [*Middleware.Reconfigure], and the other functions that validate
+// configurations reject any configuration that sets some dangerous flag,
+// i.e. an ExtraConfig field whose name starts with "Dangerously"
+// (e.g. ExtraConfig.DangerouslyTolerateInsecureOrigins).
+// Such rejections are reported as [*DangerousFlagError] values,
+// which you can identify with [errors.As].
+// ForbidDangerousFlags enables you to build binaries (e.g. production ones)
+// that cannot enable insecure settings, regardless of their configuration.
+//
+// The effect of ForbidDangerousFlags is global to the process and
+// cannot be undone.
+// ForbidDangerousFlags is safe for concurrent use by multiple goroutines,
+// but it only affects subsequent validations of configurations;
+// existing middleware are unaffected.
+// Therefore, you should call ForbidDangerousFlags once,
+// at program initialization (e.g. in an init function
+// of a file guarded by some build constraint),
+// before building any middleware.
+func ForbidDangerousFlags() {
+	dangerousFlagsForbidden.Store(true)
+}
+
+// A DangerousFlagError indicates that a configuration sets
+// some dangerous flag even though [ForbidDangerousFlags] was called.
+type DangerousFlagError struct {
+	// Flag is the name of the ExtraConfig field that the configuration sets
+	// (e.g. DangerouslyTolerateInsecureOrigins).
+	Flag string
+}
+
+func (e *DangerousFlagError) Error() string {
+	return "cors: dangerous flag " + e.Flag + " is forbidden"
+}
+
+// RequireOrigins checks that cfg allows all the required origins
+// (e.g. that of some mandated monitoring service) and, if not,
+// returns some non-nil error that lists the missing ones.
+// If cfg is invalid, RequireOrigins returns the same error that
+// [NewMiddleware] would return.
+// RequireOrigins enables you to enforce, in continuous integration,
+// that mandated origins remain allowed after any change to cfg.
+//
+// RequireOrigins evaluates the required origins as a middleware built from
+// cfg would evaluate the value of the Origin header of some request;
+// for instance, origin pattern https://*.example.com covers
+// required origin https://foo.example.com.
+// However, it disregards report-only mode and settings that don't pertain
+// to origins (e.g. ExtraConfig.OriginResolver).
+func RequireOrigins(cfg Config, required ...string) error {
+	icfg, err := newInternalConfig(&cfg)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, origin := range required {
+		if !icfg.allowsOrigin(origin) {
+			missing = append(missing, strconv.Quote(origin))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	const tmpl = "required origins not allowed: %s"
+	return util.Errorf(tmpl, strings.Join(missing, ", "))
+}
+
+// checkPolicy returns the violations of the policy in effect, if any,
+// by the configuration from which icfg was populated,
+// along with its uses of dangerous flags if those are forbidden.
+// Precondition: icfg.tmp is non-nil.
+func (icfg *internalConfig) checkPolicy() []error {
+	var errs []error
+	if dangerousFlagsForbidden.Load() {
+		if icfg.insecureOrigins {
+			const flag = "DangerouslyTolerateInsecureOrigins"
+			errs = append(errs, &DangerousFlagError{Flag: flag})
+		}
+		if icfg.subsOfPublicSuffixes {
+			const flag = "DangerouslyTolerateSubdomainsOfPublicSuffixes"
+			errs = append(errs, &DangerousFlagError{Flag: flag})
+		}
+	}
+	p := configPolicy.Load()
+	if p == nil {
+		return errs
+	}
+	violate := func(rule, msg string) {
+		errs = append(errs, &PolicyViolationError{Rule: rule, msg: msg})
+	}
+	if p.ForbidAllOrigins && icfg.allowAnyOrigin {
+		violate("ForbidAllOrigins", "allowing all origins")
+	}
+	if p.ForbidWildcardMethods && icfg.allowAnyMethod {
+		violate("ForbidWildcardMethods", "allowing all methods")
+	}
+	if p.ForbidWildcardRequestHeaders && icfg.asteriskReqHdrs {
+		violate("ForbidWildcardRequestHeaders", "allowing all request headers")
+	}
+	if p.ForbidWildcardResponseHeaders && icfg.exposeAllResHdrs {
+		violate("ForbidWildcardResponseHeaders", "exposing all response headers")
+	}
+	if p.ForbidCredentialedInsecureOrigins && icfg.credentialed &&
+		icfg.allowsInsecureOrigins() {
+		const msg = "allowing insecure origins with credentialed access"
+		violate("ForbidCredentialedInsecureOrigins", msg)
+	}
+	return errs
+}
+
+// allowsInsecureOrigins reports whether icfg allows some insecure origins
+// (see [RequireSecureOrigins]).
+// Precondition: icfg.tmp is non-nil.
+func (icfg *internalConfig) allowsInsecureOrigins() bool {
+	if len(icfg.tmp.originPatterns) != 0 { // see validateOrigins
+		return len(icfg.tmp.insecureOriginPatterns) != 0
+	}
+	// The origins of an OriginMatcher don't go through validateOrigins;
+	// see NewMiddlewareFromMatcher.
+	for _, raw := range icfg.corpus.Elems() {
+		pattern, err := origins.ParsePattern(raw)
+		if err == nil && pattern.IsDeemedInsecure() {
+			return true
+		}
+	}
+	return false
+}
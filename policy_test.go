@@ -0,0 +1,224 @@
+package cors_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+// Note: because it mutates global state, this test must not run in parallel
+// with other tests.
+func TestSetConfigPolicy(t *testing.T) {
+	cfg := cors.Config{
+		Origins:         []string{"http://example.com"},
+		Credentialed:    true,
+		Methods:         []string{"*"},
+		RequestHeaders:  []string{"*"},
+		ResponseHeaders: []string{"X-Foo"},
+		ExtraConfig: cors.ExtraConfig{
+			DangerouslyTolerateInsecureOrigins: true,
+		},
+	}
+	if _, err := cors.NewMiddleware(cfg); err != nil {
+		t.Fatalf("no policy: got error %v; want nil error", err)
+	}
+
+	policy := cors.ConfigPolicy{
+		ForbidAllOrigins:                  true,
+		ForbidWildcardMethods:             true,
+		ForbidWildcardRequestHeaders:      true,
+		ForbidWildcardResponseHeaders:     true,
+		ForbidCredentialedInsecureOrigins: true,
+	}
+	cors.SetConfigPolicy(policy)
+	t.Cleanup(func() { cors.SetConfigPolicy(cors.ConfigPolicy{}) })
+	_, err := cors.NewMiddleware(cfg)
+	if err == nil {
+		t.Fatal("policy: got nil error; want non-nil error")
+	}
+	var perr *cors.PolicyViolationError
+	if !errors.As(err, &perr) {
+		t.Errorf("policy: got error %v; want some *cors.PolicyViolationError", err)
+	}
+	msgs := flatten(err)
+	sort.Strings(msgs)
+	want := []string{
+		"cors: policy ForbidCredentialedInsecureOrigins forbids allowing insecure origins with credentialed access",
+		"cors: policy ForbidWildcardMethods forbids allowing all methods",
+		"cors: policy ForbidWildcardRequestHeaders forbids allowing all request headers",
+	}
+	if !slices.Equal(msgs, want) {
+		t.Errorf("policy: got\n%q\nwant\n%q", msgs, want)
+	}
+	anon := cors.Config{
+		Origins:         []string{"*"},
+		ResponseHeaders: []string{"*"},
+	}
+	_, err = cors.NewMiddleware(anon)
+	msgs = flatten(err)
+	sort.Strings(msgs)
+	want = []string{
+		"cors: policy ForbidAllOrigins forbids allowing all origins",
+		"cors: policy ForbidWildcardResponseHeaders forbids exposing all response headers",
+	}
+	if !slices.Equal(msgs, want) {
+		t.Errorf("policy: got\n%q\nwant\n%q", msgs, want)
+	}
+
+	// The origins of a matcher must not escape the policy.
+	matcher, err := cors.NewOriginMatcher("https://example.com", "http://example.org")
+	if err != nil {
+		t.Fatalf("failure to build origin matcher: %v", err)
+	}
+	credentialed := cors.Config{Credentialed: true}
+	_, err = cors.NewMiddlewareFromMatcher(matcher, credentialed)
+	if !errors.As(err, &perr) {
+		t.Errorf("policy with matcher: got error %v; want some *cors.PolicyViolationError", err)
+	}
+	msgs = flatten(err)
+	want = []string{
+		"cors: policy ForbidCredentialedInsecureOrigins forbids allowing insecure origins with credentialed access",
+	}
+	if !slices.Equal(msgs, want) {
+		t.Errorf("policy with matcher: got\n%q\nwant\n%q", msgs, want)
+	}
+	secure, err := cors.NewOriginMatcher("https://example.com", "http://localhost:8080")
+	if err != nil {
+		t.Fatalf("failure to build origin matcher: %v", err)
+	}
+	if _, err := cors.NewMiddlewareFromMatcher(secure, credentialed); err != nil {
+		t.Errorf("policy with secure matcher: got error %v; want nil error", err)
+	}
+
+	cors.SetConfigPolicy(cors.ConfigPolicy{})
+	if _, err := cors.NewMiddleware(cfg); err != nil {
+		t.Errorf("removed policy: got error %v; want nil error", err)
+	}
+	if _, err := cors.NewMiddlewareFromMatcher(matcher, credentialed); err != nil {
+		t.Errorf("removed policy with matcher: got error %v; want nil error", err)
+	}
+}
+
+// Because ForbidDangerousFlags cannot be undone, this test calls it
+// in a subprocess, lest it affect other tests.
+func TestForbidDangerousFlags(t *testing.T) {
+	const envKey = "CORS_TEST_FORBID_DANGEROUS_FLAGS"
+	if os.Getenv(envKey) != "1" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestForbidDangerousFlags$")
+		cmd.Env = append(os.Environ(), envKey+"=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("subprocess failed: %v\n%s", err, out)
+		}
+		return
+	}
+	cfg := cors.Config{
+		Origins:      []string{"http://example.com", "https://*.com"},
+		Credentialed: true,
+		ExtraConfig: cors.ExtraConfig{
+			DangerouslyTolerateInsecureOrigins:            true,
+			DangerouslyTolerateSubdomainsOfPublicSuffixes: true,
+		},
+	}
+	if _, err := cors.NewMiddleware(cfg); err != nil {
+		t.Fatalf("no gate: got error %v; want nil error", err)
+	}
+	safe := cors.Config{
+		Origins: []string{"https://example.com"},
+	}
+	mw, err := cors.NewMiddleware(safe)
+	if err != nil {
+		t.Fatalf("no gate: got error %v; want nil error", err)
+	}
+
+	cors.ForbidDangerousFlags()
+	_, err = cors.NewMiddleware(cfg)
+	if err == nil {
+		t.Fatal("gate: got nil error; want non-nil error")
+	}
+	var derr *cors.DangerousFlagError
+	if !errors.As(err, &derr) {
+		t.Errorf("gate: got error %v; want some *cors.DangerousFlagError", err)
+	}
+	msgs := flatten(err)
+	sort.Strings(msgs)
+	want := []string{
+		"cors: dangerous flag DangerouslyTolerateInsecureOrigins is forbidden",
+		"cors: dangerous flag DangerouslyTolerateSubdomainsOfPublicSuffixes is forbidden",
+	}
+	if !slices.Equal(msgs, want) {
+		t.Errorf("gate: got\n%q\nwant\n%q", msgs, want)
+	}
+	if err := mw.Reconfigure(&cfg); err == nil {
+		t.Error("gate: Reconfigure: got nil error; want non-nil error")
+	}
+	if _, err := cors.NewMiddleware(safe); err != nil {
+		t.Errorf("gate: got error %v; want nil error", err)
+	}
+}
+
+func TestRequireOrigins(t *testing.T) {
+	cases := []struct {
+		desc     string
+		cfg      cors.Config
+		required []string
+		want     string // empty if no error is expected
+	}{
+		{
+			desc: "all required origins allowed",
+			cfg: cors.Config{
+				Origins: []string{"https://example.com", "https://*.example.com"},
+			},
+			required: []string{"https://monitor.example.com", "https://example.com"},
+		}, {
+			desc: "no required origins",
+			cfg: cors.Config{
+				Origins: []string{"https://example.com"},
+			},
+		}, {
+			desc: "all origins allowed",
+			cfg: cors.Config{
+				Origins: []string{"*"},
+			},
+			required: []string{"https://monitor.example.com"},
+		}, {
+			desc: "some required origins not allowed",
+			cfg: cors.Config{
+				Origins: []string{"https://*.example.com"},
+			},
+			required: []string{
+				"https://example.com",
+				"https://monitor.example.com",
+				"http://monitor.example.com",
+			},
+			want: `cors: required origins not allowed: "https://example.com", "http://monitor.example.com"`,
+		}, {
+			desc:     "invalid config",
+			cfg:      cors.Config{},
+			required: []string{"https://monitor.example.com"},
+			want:     "cors: at least one origin pattern must be specified",
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			err := cors.RequireOrigins(tc.cfg, tc.required...)
+			if tc.want == "" {
+				if err != nil {
+					t.Errorf("got error %v; want nil error", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got nil error; want %q", tc.want)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Errorf("got error %q; want %q", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
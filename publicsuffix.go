@@ -0,0 +1,45 @@
+package cors
+
+import "github.com/jub0bs/cors/internal/origins"
+
+// A PublicSuffixList provides the public suffix of domains;
+// see [SetPublicSuffixList].
+type PublicSuffixList interface {
+	// PublicSuffix returns the public suffix of host (e.g. "co.uk" for
+	// "example.co.uk") and reports whether that suffix is managed by ICANN,
+	// in the manner of [golang.org/x/net/publicsuffix.PublicSuffix].
+	// This package ignores the boolean result.
+	//
+	// [golang.org/x/net/publicsuffix.PublicSuffix]: https://pkg.go.dev/golang.org/x/net/publicsuffix#PublicSuffix
+	PublicSuffix(host string) (string, bool)
+}
+
+// SetPublicSuffixList overrides the [public suffix list] that this package
+// relies on for identifying origin patterns that encompass arbitrary
+// subdomains of a public suffix (e.g. https://*.github.io);
+// see the documentation of the Config.Origins field and of
+// ExtraConfig.DangerouslyTolerateSubdomainsOfPublicSuffixes.
+// By default, this package relies on the list embedded in
+// golang.org/x/net/publicsuffix, which changes with updates of
+// that dependency; SetPublicSuffixList enables you to pin a known snapshot
+// of the list and thereby decouple the outcome of configuration validation
+// from dependency updates.
+// If list is nil, SetPublicSuffixList restores the default list.
+//
+// SetPublicSuffixList is safe for concurrent use by multiple goroutines,
+// but it only affects subsequent validations of origin patterns
+// (e.g. by [NewMiddleware], [*Middleware.Reconfigure], or
+// [InspectOriginPattern]); existing middleware are unaffected.
+// Therefore, you should call SetPublicSuffixList once,
+// at program initialization, before building any middleware.
+// The methods of list must be safe for concurrent use
+// by multiple goroutines.
+//
+// [public suffix list]: https://publicsuffix.org/
+func SetPublicSuffixList(list PublicSuffixList) {
+	if list == nil {
+		origins.SetPublicSuffixFunc(nil)
+		return
+	}
+	origins.SetPublicSuffixFunc(list.PublicSuffix)
+}
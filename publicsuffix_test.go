@@ -0,0 +1,55 @@
+package cors_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+// pinnedList is a minimal public suffix list that only contains "example"
+// and "corp.example".
+type pinnedList struct{}
+
+func (pinnedList) PublicSuffix(host string) (string, bool) {
+	if host == "corp.example" || strings.HasSuffix(host, ".corp.example") {
+		return "corp.example", false
+	}
+	if i := strings.LastIndexByte(host, '.'); i >= 0 {
+		return host[i+1:], false
+	}
+	return host, false
+}
+
+// Note: because it mutates global state, this test must not run in parallel
+// with other tests.
+func TestSetPublicSuffixList(t *testing.T) {
+	const pattern = "https://*.corp.example"
+	cfg := cors.Config{Origins: []string{pattern}}
+	if _, err := cors.NewMiddleware(cfg); err != nil {
+		t.Fatalf("default list: got error %v; want nil error", err)
+	}
+
+	cors.SetPublicSuffixList(pinnedList{})
+	t.Cleanup(func() { cors.SetPublicSuffixList(nil) })
+	if _, err := cors.NewMiddleware(cfg); err == nil {
+		t.Error("pinned list: got nil error; want some non-nil error")
+	}
+	info, err := cors.InspectOriginPattern(pattern)
+	if err != nil {
+		t.Fatalf("pinned list: got error %v; want nil error", err)
+	}
+	if got, want := info.PublicSuffix, "corp.example"; got != want {
+		t.Errorf("pinned list: got public suffix %q; want %q", got, want)
+	}
+	// github.io is absent from the pinned list
+	cfg = cors.Config{Origins: []string{"https://*.github.io"}}
+	if _, err := cors.NewMiddleware(cfg); err != nil {
+		t.Errorf("pinned list: got error %v; want nil error", err)
+	}
+
+	cors.SetPublicSuffixList(nil)
+	if _, err := cors.NewMiddleware(cfg); err == nil {
+		t.Error("restored list: got nil error; want some non-nil error")
+	}
+}
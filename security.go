@@ -0,0 +1,191 @@
+package cors
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jub0bs/cors/internal/headers"
+	"github.com/jub0bs/cors/internal/origins"
+)
+
+// A Severity indicates how far a [Finding] strays from
+// the safest CORS posture.
+type Severity uint8
+
+const (
+	// SeverityLow marks deviations that are common and usually benign
+	// (e.g. enabling credentialed access).
+	SeverityLow Severity = iota
+	// SeverityMedium marks deviations that widen the attack surface
+	// and deserve scrutiny (e.g. allowing all methods).
+	SeverityMedium
+	// SeverityHigh marks deviations that disable some of the protections
+	// that this package enforces by default
+	// (e.g. tolerating insecure origins alongside credentialed access).
+	SeverityHigh
+)
+
+// String returns the lowercase name of s (e.g. "high").
+func (s Severity) String() string {
+	switch s {
+	case SeverityLow:
+		return "low"
+	case SeverityMedium:
+		return "medium"
+	case SeverityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("Severity(%d)", s)
+	}
+}
+
+// A Finding describes a way in which a [Config] deviates from
+// the safest CORS posture.
+// See [SecurityFindings].
+type Finding struct {
+	// Severity is the finding's severity.
+	Severity Severity
+	// Setting is the name of the Config field from which the finding stems
+	// (e.g. Origins, or ExtraConfig.DangerouslyTolerateInsecureOrigins).
+	Setting string
+	// Message is a human-readable description of the finding.
+	Message string
+}
+
+// longMaxAge is the max-age value (in seconds) above which
+// SecurityFindings reports a finding.
+const longMaxAge = 600
+
+// SecurityFindings returns the ways in which cfg deviates from
+// the safest CORS posture, i.e. one in which credentialed access is disabled,
+// no wildcards are used, all allowed origins are secure
+// (see [RequireSecureOrigins]), and preflight responses are cached
+// for no longer than ten minutes, and in which none of the settings
+// that relax the protections that this package enforces by default
+// (e.g. ExtraConfig.DangerouslyTolerateInsecureOrigins) are active.
+// Hooks (e.g. ExtraConfig.PublicAnyOriginPredicate) elicit a finding
+// whenever they're non-nil, since SecurityFindings cannot tell which
+// requests they select.
+// The findings are listed in the order in which the corresponding fields
+// appear in Config.
+//
+// SecurityFindings does not validate cfg; invalid origin patterns are
+// ignored, since [NewMiddleware] reports them. Moreover, a lack of findings
+// doesn't mean that cfg is appropriate: only you can determine which
+// origins deserve access to your resources.
+// SecurityFindings is meant to feed reports (e.g. a security scorecard)
+// rather than to serve as a gate; for the latter,
+// see [RequireSecureOrigins].
+func SecurityFindings(cfg Config) []Finding {
+	var findings []Finding
+	add := func(sev Severity, setting, format string, a ...any) {
+		f := Finding{
+			Severity: sev,
+			Setting:  setting,
+			Message:  fmt.Sprintf(format, a...),
+		}
+		findings = append(findings, f)
+	}
+	pna := cfg.PrivateNetworkAccess || cfg.PrivateNetworkAccessInNoCORSModeOnly
+	for _, raw := range expandOriginPatterns(cfg.Origins) {
+		if raw == headers.ValueWildcard {
+			add(SeverityMedium, "Origins", "all origins are allowed")
+			continue
+		}
+		pattern, err := origins.ParsePattern(raw)
+		if err != nil {
+			continue
+		}
+		if pattern.IsDeemedInsecure() {
+			sev := SeverityMedium
+			if cfg.Credentialed || pna {
+				sev = SeverityHigh
+			}
+			add(sev, "Origins", "origin pattern %q is insecure", raw)
+		}
+		if pattern.Kind == origins.PatternKindSubdomains {
+			if etld, ok := pattern.HostIsEffectiveTLD(); ok {
+				const tmpl = "origin pattern %q encompasses arbitrary subdomains " +
+					"of public suffix %q"
+				add(SeverityHigh, "Origins", tmpl, raw, etld)
+			} else {
+				const tmpl = "origin pattern %q encompasses arbitrary subdomains"
+				add(SeverityLow, "Origins", tmpl, raw)
+			}
+		}
+		if pattern.Port < 0 { // sentinel value for arbitrary ports
+			add(SeverityLow, "Origins", "origin pattern %q encompasses arbitrary ports", raw)
+		}
+	}
+	if cfg.Credentialed {
+		add(SeverityLow, "Credentialed", "credentialed access is enabled")
+	}
+	if slices.Contains(cfg.Methods, headers.ValueWildcard) {
+		add(SeverityMedium, "Methods", "all methods are allowed")
+	}
+	if slices.Contains(cfg.RequestHeaders, headers.ValueWildcard) {
+		add(SeverityMedium, "RequestHeaders", "all request headers are allowed")
+	}
+	if cfg.MaxAgeInSeconds > longMaxAge {
+		const tmpl = "preflight responses may be cached for %d seconds"
+		add(SeverityLow, "MaxAgeInSeconds", tmpl, cfg.MaxAgeInSeconds)
+	}
+	if slices.Contains(cfg.ResponseHeaders, headers.ValueWildcard) {
+		add(SeverityLow, "ResponseHeaders", "all response headers are exposed")
+	}
+	if cfg.PrivateNetworkAccess {
+		const msg = "Private-Network Access is enabled"
+		add(SeverityMedium, "ExtraConfig.PrivateNetworkAccess", msg)
+	}
+	if cfg.PrivateNetworkAccessInNoCORSModeOnly {
+		const msg = "Private-Network Access in no-cors mode is enabled"
+		add(SeverityMedium, "ExtraConfig.PrivateNetworkAccessInNoCORSModeOnly", msg)
+	}
+	if len(cfg.CredentialedWildcardMethods) > 0 {
+		const tmpl = "credentialed preflight responses list methods %q " +
+			"regardless of the requested method"
+		add(SeverityLow, "ExtraConfig.CredentialedWildcardMethods", tmpl, cfg.CredentialedWildcardMethods)
+	}
+	if len(cfg.OriginHeaderNames) > 0 {
+		const tmpl = "the request's origin is read from headers %q"
+		add(SeverityMedium, "ExtraConfig.OriginHeaderNames", tmpl, cfg.OriginHeaderNames)
+	}
+	if cfg.ReportOnly {
+		const msg = "report-only mode is active: the CORS policy is not enforced"
+		add(SeverityHigh, "ExtraConfig.ReportOnly", msg)
+	}
+	if cfg.WildcardCoversAuthorization {
+		const msg = "the wildcard in RequestHeaders covers Authorization"
+		add(SeverityMedium, "ExtraConfig.WildcardCoversAuthorization", msg)
+	}
+	if cfg.BoundedAuthorizationScan > 0 {
+		const tmpl = "Access-Control-Request-Headers headers of up to %d bytes " +
+			"are scanned for Authorization"
+		add(SeverityLow, "ExtraConfig.BoundedAuthorizationScan", tmpl, cfg.BoundedAuthorizationScan)
+	}
+	if cfg.ReflectExposedResponseHeaders {
+		const msg = "all response headers present are exposed"
+		add(SeverityMedium, "ExtraConfig.ReflectExposedResponseHeaders", msg)
+	}
+	if cfg.OriginResolver != nil {
+		const msg = "the origin of requests that lack one is resolved by a hook"
+		add(SeverityLow, "ExtraConfig.OriginResolver", msg)
+	}
+	if cfg.PublicAnyOriginPredicate != nil {
+		const msg = "some requests are allowed from all origins"
+		add(SeverityMedium, "ExtraConfig.PublicAnyOriginPredicate", msg)
+	}
+	if cfg.ConcreteOriginForAnonymousAllowAll != nil {
+		const msg = "some requests from any origin get their origin echoed"
+		add(SeverityLow, "ExtraConfig.ConcreteOriginForAnonymousAllowAll", msg)
+	}
+	if cfg.DangerouslyTolerateInsecureOrigins {
+		const msg = "insecure origins are tolerated"
+		add(SeverityHigh, "ExtraConfig.DangerouslyTolerateInsecureOrigins", msg)
+	}
+	if cfg.DangerouslyTolerateSubdomainsOfPublicSuffixes {
+		const msg = "arbitrary subdomains of public suffixes are tolerated"
+		add(SeverityHigh, "ExtraConfig.DangerouslyTolerateSubdomainsOfPublicSuffixes", msg)
+	}
+	return findings
+}
@@ -0,0 +1,147 @@
+package cors_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jub0bs/cors"
+)
+
+func TestSecurityFindings(t *testing.T) {
+	cases := []struct {
+		desc string
+		cfg  cors.Config
+		want []cors.Finding
+	}{
+		{
+			desc: "safest posture",
+			cfg: cors.Config{
+				Origins:         []string{"https://example.com", "http://localhost:8080"},
+				Methods:         []string{"PUT"},
+				MaxAgeInSeconds: 30,
+			},
+		}, {
+			desc: "anonymous wildcards",
+			cfg: cors.Config{
+				Origins:         []string{"*"},
+				Methods:         []string{"*"},
+				RequestHeaders:  []string{"*"},
+				ResponseHeaders: []string{"*"},
+				ExtraConfig: cors.ExtraConfig{
+					WildcardCoversAuthorization: true,
+				},
+			},
+			want: []cors.Finding{
+				{cors.SeverityMedium, "Origins", "all origins are allowed"},
+				{cors.SeverityMedium, "Methods", "all methods are allowed"},
+				{cors.SeverityMedium, "RequestHeaders", "all request headers are allowed"},
+				{cors.SeverityLow, "ResponseHeaders", "all response headers are exposed"},
+				{cors.SeverityMedium, "ExtraConfig.WildcardCoversAuthorization", "the wildcard in RequestHeaders covers Authorization"},
+			},
+		}, {
+			desc: "credentialed with dangerous flags",
+			cfg: cors.Config{
+				Origins: []string{
+					"http://example.com",
+					"https://**.example.com",
+					"https://*.github.io",
+					"http://localhost:*",
+					"https://example.com/", // invalid, hence ignored
+				},
+				Credentialed:    true,
+				MaxAgeInSeconds: 3600,
+				ExtraConfig: cors.ExtraConfig{
+					PrivateNetworkAccess:                          true,
+					ReportOnly:                                    true,
+					DangerouslyTolerateInsecureOrigins:            true,
+					DangerouslyTolerateSubdomainsOfPublicSuffixes: true,
+				},
+			},
+			want: []cors.Finding{
+				{cors.SeverityHigh, "Origins", `origin pattern "http://example.com" is insecure`},
+				{cors.SeverityLow, "Origins", `origin pattern "https://*.example.com" encompasses arbitrary subdomains`},
+				{cors.SeverityHigh, "Origins", `origin pattern "https://*.github.io" encompasses arbitrary subdomains of public suffix "github.io"`},
+				{cors.SeverityLow, "Origins", `origin pattern "http://localhost:*" encompasses arbitrary ports`},
+				{cors.SeverityLow, "Credentialed", "credentialed access is enabled"},
+				{cors.SeverityLow, "MaxAgeInSeconds", "preflight responses may be cached for 3600 seconds"},
+				{cors.SeverityMedium, "ExtraConfig.PrivateNetworkAccess", "Private-Network Access is enabled"},
+				{cors.SeverityHigh, "ExtraConfig.ReportOnly", "report-only mode is active: the CORS policy is not enforced"},
+				{cors.SeverityHigh, "ExtraConfig.DangerouslyTolerateInsecureOrigins", "insecure origins are tolerated"},
+				{cors.SeverityHigh, "ExtraConfig.DangerouslyTolerateSubdomainsOfPublicSuffixes", "arbitrary subdomains of public suffixes are tolerated"},
+			},
+		}, {
+			desc: "settings that relax headers and origins",
+			cfg: cors.Config{
+				Origins:         []string{"*"},
+				Methods:         []string{"*"},
+				RequestHeaders:  []string{"*"},
+				ResponseHeaders: []string{"X-Foo"},
+				ExtraConfig: cors.ExtraConfig{
+					OriginHeaderNames:                  []string{"X-Forwarded-Origin"},
+					WildcardCoversAuthorization:        true,
+					BoundedAuthorizationScan:           256,
+					ConcreteOriginForAnonymousAllowAll: func(*http.Request) bool { return true },
+				},
+			},
+			want: []cors.Finding{
+				{cors.SeverityMedium, "Origins", "all origins are allowed"},
+				{cors.SeverityMedium, "Methods", "all methods are allowed"},
+				{cors.SeverityMedium, "RequestHeaders", "all request headers are allowed"},
+				{cors.SeverityMedium, "ExtraConfig.OriginHeaderNames", `the request's origin is read from headers ["X-Forwarded-Origin"]`},
+				{cors.SeverityMedium, "ExtraConfig.WildcardCoversAuthorization", "the wildcard in RequestHeaders covers Authorization"},
+				{cors.SeverityLow, "ExtraConfig.BoundedAuthorizationScan", "Access-Control-Request-Headers headers of up to 256 bytes are scanned for Authorization"},
+				{cors.SeverityLow, "ExtraConfig.ConcreteOriginForAnonymousAllowAll", "some requests from any origin get their origin echoed"},
+			},
+		}, {
+			desc: "credentialed with hooks",
+			cfg: cors.Config{
+				Origins:      []string{"https://example.com"},
+				Methods:      []string{"*"},
+				Credentialed: true,
+				ExtraConfig: cors.ExtraConfig{
+					CredentialedWildcardMethods:   []string{"PUT", "DELETE"},
+					ReflectExposedResponseHeaders: true,
+					OriginResolver: func(*http.Request) (string, bool) {
+						return "https://example.com", true
+					},
+					PublicAnyOriginPredicate: func(*http.Request) bool { return false },
+				},
+			},
+			want: []cors.Finding{
+				{cors.SeverityLow, "Credentialed", "credentialed access is enabled"},
+				{cors.SeverityMedium, "Methods", "all methods are allowed"},
+				{cors.SeverityLow, "ExtraConfig.CredentialedWildcardMethods", `credentialed preflight responses list methods ["PUT" "DELETE"] regardless of the requested method`},
+				{cors.SeverityMedium, "ExtraConfig.ReflectExposedResponseHeaders", "all response headers present are exposed"},
+				{cors.SeverityLow, "ExtraConfig.OriginResolver", "the origin of requests that lack one is resolved by a hook"},
+				{cors.SeverityMedium, "ExtraConfig.PublicAnyOriginPredicate", "some requests are allowed from all origins"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		f := func(t *testing.T) {
+			got := cors.SecurityFindings(tc.cfg)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got\n%v\nwant\n%v", got, tc.want)
+			}
+		}
+		t.Run(tc.desc, f)
+	}
+}
+
+func TestSeverityString(t *testing.T) {
+	cases := []struct {
+		sev  cors.Severity
+		want string
+	}{
+		{cors.SeverityLow, "low"},
+		{cors.SeverityMedium, "medium"},
+		{cors.SeverityHigh, "high"},
+		{cors.Severity(42), "Severity(42)"},
+	}
+	for _, tc := range cases {
+		if got := tc.sev.String(); got != tc.want {
+			t.Errorf("%d: got %q; want %q", tc.sev, got, tc.want)
+		}
+	}
+}
@@ -3,11 +3,14 @@ package cors_test
 import (
 	"bytes"
 	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"slices"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/jub0bs/cors"
 )
@@ -21,6 +24,10 @@ const (
 	headerACRM  = "Access-Control-Request-Method"
 	headerACRH  = "Access-Control-Request-Headers"
 
+	// Fetch-metadata request headers
+	headerSecFetchSite = "Sec-Fetch-Site"
+	headerSecFetchMode = "Sec-Fetch-Mode"
+
 	// common response headers
 	headerACAO = "Access-Control-Allow-Origin"
 	headerACAC = "Access-Control-Allow-Credentials"
@@ -35,11 +42,21 @@ const (
 	headerACEH = "Access-Control-Expose-Headers"
 
 	headerVary = "Vary"
+
+	// debug-only response headers
+	headerXDebugRejectedHeader  = "X-Debug-Rejected-Header"
+	headerXDebugForbiddenMethod = "X-Debug-Forbidden-Method"
+	headerXDebugPNA             = "X-Debug-Pna"
 )
 
 const (
 	varyPreflightValue = headerACRH + ", " + headerACRM + ", " +
 		headerACRPN + ", " + headerOrigin
+	// see the documentation of ExtraConfig.OriginHeaderNames
+	varyForwardedOriginPreflight = headerACRH + ", " + headerACRM + ", " +
+		headerACRPN + ", X-Forwarded-Origin"
+	// see the documentation of ExtraConfig.CredentialsHeuristic
+	varyCredentialsHeuristic = headerOrigin + ", Cookie, Authorization"
 
 	wildcard        = "*"
 	wildcardAndAuth = "*,authorization"
@@ -138,8 +155,11 @@ func assertPreflightStatus(t *testing.T, spyStatus, gotStatus int, mwtc *Middlew
 	switch {
 	case mwtc.cfg == nil:
 		wantStatusCode = spyStatus
-	case !tc.preflightPassesCORSCheck || !mwtc.debug && tc.preflightFails:
+	case (!tc.preflightPassesCORSCheck || !mwtc.debug && tc.preflightFails) &&
+		mwtc.cfg.PreflightFailureStatus == 0:
 		wantStatusCode = http.StatusForbidden
+	case !tc.preflightPassesCORSCheck || !mwtc.debug && tc.preflightFails:
+		wantStatusCode = mwtc.cfg.PreflightFailureStatus
 	case mwtc.cfg.PreflightSuccessStatus == 0:
 		wantStatusCode = http.StatusNoContent
 	default:
@@ -275,4 +295,252 @@ func assertConfigEqual(t *testing.T, got, want *cors.Config) {
 		const tmpl = "DangerouslyTolerateSubdomainsOfPublicSuffixes: got %t; want %t"
 		t.Errorf(tmpl, got.DangerouslyTolerateSubdomainsOfPublicSuffixes, want.DangerouslyTolerateSubdomainsOfPublicSuffixes)
 	}
+	if got.RequestMethodHeaderFallback != want.RequestMethodHeaderFallback {
+		const tmpl = "RequestMethodHeaderFallback: got %q; want %q"
+		t.Errorf(tmpl, got.RequestMethodHeaderFallback, want.RequestMethodHeaderFallback)
+	}
+	if !maps.EqualFunc(got.OriginMethods, want.OriginMethods, slices.Equal) {
+		const tmpl = "OriginMethods: got %q; want %q"
+		t.Errorf(tmpl, got.OriginMethods, want.OriginMethods)
+	}
+	if got.CredentialsHeuristic != want.CredentialsHeuristic {
+		const tmpl = "CredentialsHeuristic: got %t; want %t"
+		t.Errorf(tmpl, got.CredentialsHeuristic, want.CredentialsHeuristic)
+	}
+	if got.TreatHeadAsNonSafe != want.TreatHeadAsNonSafe {
+		const tmpl = "TreatHeadAsNonSafe: got %t; want %t"
+		t.Errorf(tmpl, got.TreatHeadAsNonSafe, want.TreatHeadAsNonSafe)
+	}
+	if got.AlwaysEmitMaxAge != want.AlwaysEmitMaxAge {
+		const tmpl = "AlwaysEmitMaxAge: got %t; want %t"
+		t.Errorf(tmpl, got.AlwaysEmitMaxAge, want.AlwaysEmitMaxAge)
+	}
+	if (got.ResponseHeaderHook == nil) != (want.ResponseHeaderHook == nil) {
+		const tmpl = "ResponseHeaderHook: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.ResponseHeaderHook != nil, want.ResponseHeaderHook != nil)
+	}
+	if got.LowercaseResponseHeaderNames != want.LowercaseResponseHeaderNames {
+		const tmpl = "LowercaseResponseHeaderNames: got %t; want %t"
+		t.Errorf(tmpl, got.LowercaseResponseHeaderNames, want.LowercaseResponseHeaderNames)
+	}
+	if got.UseCanonicalHeaderWrites != want.UseCanonicalHeaderWrites {
+		const tmpl = "UseCanonicalHeaderWrites: got %t; want %t"
+		t.Errorf(tmpl, got.UseCanonicalHeaderWrites, want.UseCanonicalHeaderWrites)
+	}
+	if (got.ConcreteOriginForAnonymousAllowAll == nil) != (want.ConcreteOriginForAnonymousAllowAll == nil) {
+		const tmpl = "ConcreteOriginForAnonymousAllowAll: got nil: %t; want nil: %t"
+		t.Errorf(tmpl, got.ConcreteOriginForAnonymousAllowAll == nil, want.ConcreteOriginForAnonymousAllowAll == nil)
+	}
+	if got.ValidateSecFetchMetadata != want.ValidateSecFetchMetadata {
+		const tmpl = "ValidateSecFetchMetadata: got %t; want %t"
+		t.Errorf(tmpl, got.ValidateSecFetchMetadata, want.ValidateSecFetchMetadata)
+	}
+	if got.OmitVaryForDisallowedOrigins != want.OmitVaryForDisallowedOrigins {
+		const tmpl = "OmitVaryForDisallowedOrigins: got %t; want %t"
+		t.Errorf(tmpl, got.OmitVaryForDisallowedOrigins, want.OmitVaryForDisallowedOrigins)
+	}
+	if got.VaryStrategy != want.VaryStrategy {
+		const tmpl = "VaryStrategy: got %v; want %v"
+		t.Errorf(tmpl, got.VaryStrategy, want.VaryStrategy)
+	}
+	if got.NormalizeIPv4Shorthand != want.NormalizeIPv4Shorthand {
+		const tmpl = "NormalizeIPv4Shorthand: got %t; want %t"
+		t.Errorf(tmpl, got.NormalizeIPv4Shorthand, want.NormalizeIPv4Shorthand)
+	}
+	if got.TreatEmptyOriginAsAbsent != want.TreatEmptyOriginAsAbsent {
+		const tmpl = "TreatEmptyOriginAsAbsent: got %t; want %t"
+		t.Errorf(tmpl, got.TreatEmptyOriginAsAbsent, want.TreatEmptyOriginAsAbsent)
+	}
+	if !slices.Equal(got.OriginHeaderNames, want.OriginHeaderNames) {
+		const tmpl = "OriginHeaderNames: got %q; want %q"
+		t.Errorf(tmpl, got.OriginHeaderNames, want.OriginHeaderNames)
+	}
+	if got.PreserveOriginOrder != want.PreserveOriginOrder {
+		const tmpl = "PreserveOriginOrder: got %t; want %t"
+		t.Errorf(tmpl, got.PreserveOriginOrder, want.PreserveOriginOrder)
+	}
+	if got.ReportOnly != want.ReportOnly {
+		const tmpl = "ReportOnly: got %t; want %t"
+		t.Errorf(tmpl, got.ReportOnly, want.ReportOnly)
+	}
+	if (got.ReportOnlyHook == nil) != (want.ReportOnlyHook == nil) {
+		const tmpl = "ReportOnlyHook: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.ReportOnlyHook != nil, want.ReportOnlyHook != nil)
+	}
+	if got.PreflightFailureStatus != want.PreflightFailureStatus {
+		const tmpl = "PreflightFailureStatus: got %d; want %d"
+		t.Errorf(tmpl, got.PreflightFailureStatus, want.PreflightFailureStatus)
+	}
+	if !maps.Equal(got.PreflightStatusByMethod, want.PreflightStatusByMethod) {
+		const tmpl = "PreflightStatusByMethod: got %v; want %v"
+		t.Errorf(tmpl, got.PreflightStatusByMethod, want.PreflightStatusByMethod)
+	}
+	if got.RejectionMarkerHeader != want.RejectionMarkerHeader {
+		const tmpl = "RejectionMarkerHeader: got %t; want %t"
+		t.Errorf(tmpl, got.RejectionMarkerHeader, want.RejectionMarkerHeader)
+	}
+	if got.RejectionMarkerHeaderName != want.RejectionMarkerHeaderName {
+		const tmpl = "RejectionMarkerHeaderName: got %q; want %q"
+		t.Errorf(tmpl, got.RejectionMarkerHeaderName, want.RejectionMarkerHeaderName)
+	}
+	if !maps.EqualFunc(got.DeprecatedOrigins, want.DeprecatedOrigins, time.Time.Equal) {
+		const tmpl = "DeprecatedOrigins: got %v; want %v"
+		t.Errorf(tmpl, got.DeprecatedOrigins, want.DeprecatedOrigins)
+	}
+	if got.MaxOriginLength != want.MaxOriginLength {
+		const tmpl = "MaxOriginLength: got %d; want %d"
+		t.Errorf(tmpl, got.MaxOriginLength, want.MaxOriginLength)
+	}
+	if got.InjectDecision != want.InjectDecision {
+		const tmpl = "InjectDecision: got %t; want %t"
+		t.Errorf(tmpl, got.InjectDecision, want.InjectDecision)
+	}
+	if got.WildcardCoversAuthorization != want.WildcardCoversAuthorization {
+		const tmpl = "WildcardCoversAuthorization: got %t; want %t"
+		t.Errorf(tmpl, got.WildcardCoversAuthorization, want.WildcardCoversAuthorization)
+	}
+	if got.BoundedAuthorizationScan != want.BoundedAuthorizationScan {
+		const tmpl = "BoundedAuthorizationScan: got %d; want %d"
+		t.Errorf(tmpl, got.BoundedAuthorizationScan, want.BoundedAuthorizationScan)
+	}
+	if !slices.Equal(got.CredentialedSchemes, want.CredentialedSchemes) {
+		const tmpl = "CredentialedSchemes: got %q; want %q"
+		t.Errorf(tmpl, got.CredentialedSchemes, want.CredentialedSchemes)
+	}
+	if got.SlowPreflightThreshold != want.SlowPreflightThreshold {
+		const tmpl = "SlowPreflightThreshold: got %v; want %v"
+		t.Errorf(tmpl, got.SlowPreflightThreshold, want.SlowPreflightThreshold)
+	}
+	if (got.OnSlowPreflight == nil) != (want.OnSlowPreflight == nil) {
+		const tmpl = "OnSlowPreflight: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.OnSlowPreflight != nil, want.OnSlowPreflight != nil)
+	}
+	if (got.ObserveLatency == nil) != (want.ObserveLatency == nil) {
+		const tmpl = "ObserveLatency: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.ObserveLatency != nil, want.ObserveLatency != nil)
+	}
+	if !slices.Equal(got.AllowedContentTypesOnPreflight, want.AllowedContentTypesOnPreflight) {
+		const tmpl = "AllowedContentTypesOnPreflight: got %q; want %q"
+		t.Errorf(tmpl, got.AllowedContentTypesOnPreflight, want.AllowedContentTypesOnPreflight)
+	}
+	if (got.OnDisallowedContentType == nil) != (want.OnDisallowedContentType == nil) {
+		const tmpl = "OnDisallowedContentType: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.OnDisallowedContentType != nil, want.OnDisallowedContentType != nil)
+	}
+	if got.OverrideHandlerCORSHeaders != want.OverrideHandlerCORSHeaders {
+		const tmpl = "OverrideHandlerCORSHeaders: got %t; want %t"
+		t.Errorf(tmpl, got.OverrideHandlerCORSHeaders, want.OverrideHandlerCORSHeaders)
+	}
+	if got.ApplyAfterHandler != want.ApplyAfterHandler {
+		const tmpl = "ApplyAfterHandler: got %t; want %t"
+		t.Errorf(tmpl, got.ApplyAfterHandler, want.ApplyAfterHandler)
+	}
+	if got.SanitizeInboundCORSHeaders != want.SanitizeInboundCORSHeaders {
+		const tmpl = "SanitizeInboundCORSHeaders: got %t; want %t"
+		t.Errorf(tmpl, got.SanitizeInboundCORSHeaders, want.SanitizeInboundCORSHeaders)
+	}
+	if got.HandleWebSocketUpgrade != want.HandleWebSocketUpgrade {
+		const tmpl = "HandleWebSocketUpgrade: got %t; want %t"
+		t.Errorf(tmpl, got.HandleWebSocketUpgrade, want.HandleWebSocketUpgrade)
+	}
+	if !slices.Equal(got.CredentialedWildcardMethods, want.CredentialedWildcardMethods) {
+		const tmpl = "CredentialedWildcardMethods: got %q; want %q"
+		t.Errorf(tmpl, got.CredentialedWildcardMethods, want.CredentialedWildcardMethods)
+	}
+	if (got.OriginResolver == nil) != (want.OriginResolver == nil) {
+		const tmpl = "OriginResolver: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.OriginResolver != nil, want.OriginResolver != nil)
+	}
+	if got.AnswerPlainOptions != want.AnswerPlainOptions {
+		const tmpl = "AnswerPlainOptions: got %t; want %t"
+		t.Errorf(tmpl, got.AnswerPlainOptions, want.AnswerPlainOptions)
+	}
+	if got.SuppressCORSHeadersOnNonCORSOptions != want.SuppressCORSHeadersOnNonCORSOptions {
+		const tmpl = "SuppressCORSHeadersOnNonCORSOptions: got %t; want %t"
+		t.Errorf(tmpl, got.SuppressCORSHeadersOnNonCORSOptions,
+			want.SuppressCORSHeadersOnNonCORSOptions)
+	}
+	if got.DecodePercentEncodedOrigin != want.DecodePercentEncodedOrigin {
+		const tmpl = "DecodePercentEncodedOrigin: got %t; want %t"
+		t.Errorf(tmpl, got.DecodePercentEncodedOrigin, want.DecodePercentEncodedOrigin)
+	}
+	if !slices.Equal(got.AdditionalSafelistedMethods, want.AdditionalSafelistedMethods) {
+		const tmpl = "AdditionalSafelistedMethods: got %q; want %q"
+		t.Errorf(tmpl, got.AdditionalSafelistedMethods, want.AdditionalSafelistedMethods)
+	}
+	if got.ReflectExposedResponseHeaders != want.ReflectExposedResponseHeaders {
+		const tmpl = "ReflectExposedResponseHeaders: got %t; want %t"
+		t.Errorf(tmpl, got.ReflectExposedResponseHeaders, want.ReflectExposedResponseHeaders)
+	}
+	if got.KeepSafelistedExposedHeaders != want.KeepSafelistedExposedHeaders {
+		const tmpl = "KeepSafelistedExposedHeaders: got %t; want %t"
+		t.Errorf(tmpl, got.KeepSafelistedExposedHeaders, want.KeepSafelistedExposedHeaders)
+	}
+	if (got.TransformAllowedOrigin == nil) != (want.TransformAllowedOrigin == nil) {
+		const tmpl = "TransformAllowedOrigin: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.TransformAllowedOrigin != nil, want.TransformAllowedOrigin != nil)
+	}
+	if (got.PublicAnyOriginPredicate == nil) != (want.PublicAnyOriginPredicate == nil) {
+		const tmpl = "PublicAnyOriginPredicate: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.PublicAnyOriginPredicate != nil, want.PublicAnyOriginPredicate != nil)
+	}
+	if (got.CredentialedPathPredicate == nil) != (want.CredentialedPathPredicate == nil) {
+		const tmpl = "CredentialedPathPredicate: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.CredentialedPathPredicate != nil, want.CredentialedPathPredicate != nil)
+	}
+	if got.WarnOnUnusedExposedHeaders != want.WarnOnUnusedExposedHeaders {
+		const tmpl = "WarnOnUnusedExposedHeaders: got %t; want %t"
+		t.Errorf(tmpl, got.WarnOnUnusedExposedHeaders, want.WarnOnUnusedExposedHeaders)
+	}
+	if (got.OnUnusedExposedHeaders == nil) != (want.OnUnusedExposedHeaders == nil) {
+		const tmpl = "OnUnusedExposedHeaders: got non-nil %t; want non-nil %t"
+		t.Errorf(tmpl, got.OnUnusedExposedHeaders != nil, want.OnUnusedExposedHeaders != nil)
+	}
+	if got.MaxDebugACAHBytes != want.MaxDebugACAHBytes {
+		const tmpl = "MaxDebugACAHBytes: got %d; want %d"
+		t.Errorf(tmpl, got.MaxDebugACAHBytes, want.MaxDebugACAHBytes)
+	}
+	if got.DebugOmitCredentialsHeader != want.DebugOmitCredentialsHeader {
+		const tmpl = "DebugOmitCredentialsHeader: got %t; want %t"
+		t.Errorf(tmpl, got.DebugOmitCredentialsHeader, want.DebugOmitCredentialsHeader)
+	}
+	if got.MaxProcessedHeaderBytes != want.MaxProcessedHeaderBytes {
+		const tmpl = "MaxProcessedHeaderBytes: got %d; want %d"
+		t.Errorf(tmpl, got.MaxProcessedHeaderBytes, want.MaxProcessedHeaderBytes)
+	}
+	if got.LenientACRHTokenWhitespace != want.LenientACRHTokenWhitespace {
+		const tmpl = "LenientACRHTokenWhitespace: got %t; want %t"
+		t.Errorf(tmpl, got.LenientACRHTokenWhitespace, want.LenientACRHTokenWhitespace)
+	}
+	if got.SortAllowHeaders != want.SortAllowHeaders {
+		const tmpl = "SortAllowHeaders: got %t; want %t"
+		t.Errorf(tmpl, got.SortAllowHeaders, want.SortAllowHeaders)
+	}
+	if !slices.Equal(got.LegacyAllowedRequestHeaders, want.LegacyAllowedRequestHeaders) {
+		const tmpl = "LegacyAllowedRequestHeaders: got %q; want %q"
+		t.Errorf(tmpl, got.LegacyAllowedRequestHeaders, want.LegacyAllowedRequestHeaders)
+	}
+	if got.PolicyLink != want.PolicyLink {
+		const tmpl = "PolicyLink: got %q; want %q"
+		t.Errorf(tmpl, got.PolicyLink, want.PolicyLink)
+	}
+}
+
+// stress runs each of fs in its own goroutine, n times in a tight loop,
+// and waits for all of them to complete.
+// Run tests that use it with the race detector enabled (go test -race)
+// in order to detect unsynchronized accesses to shared state.
+func stress(t *testing.T, n int, fs ...func()) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for _, f := range fs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range n {
+				f()
+			}
+		}()
+	}
+	wg.Wait()
 }